@@ -10,13 +10,16 @@ import (
 	"io"
 	"os"
 	"regexp"
+	"teamide/pkg/alert"
 )
 
 type ServerConfig struct {
-	Server          *server `json:"server,omitempty" yaml:"server,omitempty"`
-	Mysql           *mysql  `json:"mysql,omitempty" yaml:"mysql,omitempty"`
-	Log             *log    `json:"log,omitempty" yaml:"log,omitempty"`
-	LogDataSaveDays int     `json:"logDataSaveDays,omitempty" yaml:"logDataSaveDays,omitempty"`
+	Server          *server   `json:"server,omitempty" yaml:"server,omitempty"`
+	Mysql           *mysql    `json:"mysql,omitempty" yaml:"mysql,omitempty"`
+	Log             *log      `json:"log,omitempty" yaml:"log,omitempty"`
+	LogDataSaveDays int       `json:"logDataSaveDays,omitempty" yaml:"logDataSaveDays,omitempty"`
+	Node            *node     `json:"node,omitempty" yaml:"node,omitempty"`
+	Terminal        *terminal `json:"terminal,omitempty" yaml:"terminal,omitempty"`
 }
 
 type server struct {
@@ -35,6 +38,20 @@ type ServerTLS struct {
 	Cert string `json:"cert,omitempty" yaml:"cert,omitempty"`
 	Key  string `json:"key,omitempty" yaml:"key,omitempty"`
 }
+type node struct {
+	Metrics             bool          `json:"metrics,omitempty" yaml:"metrics,omitempty"`                         // 是否开启 Prometheus 指标
+	MetricsToken        string        `json:"metricsToken,omitempty" yaml:"metricsToken,omitempty"`               // 指标访问 Token，开启指标时必须配置
+	MonitorDataSaveDays int           `json:"monitorDataSaveDays,omitempty" yaml:"monitorDataSaveDays,omitempty"` // 节点监控数据持久化保留天数，0 不持久化
+	Alert               *alert.Config `json:"alert,omitempty" yaml:"alert,omitempty"`                             // 告警规则、通知渠道
+}
+
+type terminal struct {
+	SSHMonitorDataSaveDays int      `json:"sshMonitorDataSaveDays,omitempty" yaml:"sshMonitorDataSaveDays,omitempty"` // SSH 服务器监控数据持久化保留天数，0 不持久化
+	ForwardBindHostList    []string `json:"forwardBindHostList,omitempty" yaml:"forwardBindHostList,omitempty"`       // SSH 本地和动态转发允许监听的非回环地址，为空只允许监听回环地址
+	ForwardTargetHostList  []string `json:"forwardTargetHostList,omitempty" yaml:"forwardTargetHostList,omitempty"`   // SSH 远程转发允许连接的目标地址，为主机名、IP 或 CIDR，为空不允许远程转发
+	SSHAgentSocket         string   `json:"sshAgentSocket,omitempty" yaml:"sshAgentSocket,omitempty"`                 // SSH 工具可以使用的 SSH Agent 地址，SSH_AUTH_SOCK 使用环境变量，为空不允许使用
+}
+
 type mysql struct {
 	Host     string `json:"host,omitempty" yaml:"host,omitempty"`
 	Port     int    `json:"port,omitempty" yaml:"port,omitempty"`
@@ -104,6 +121,16 @@ func CreateServerConfig(configPath string) (config *ServerConfig, err error) {
 	if config.Server == nil {
 		config.Server = &server{}
 	}
+	if config.Node == nil {
+		config.Node = &node{}
+	}
+	if config.Terminal == nil {
+		config.Terminal = &terminal{}
+	}
+	if config.Node.Metrics && config.Node.MetricsToken == "" {
+		err = errors.New("开启节点指标 node.metrics 时需要配置 node.metricsToken")
+		return
+	}
 	if config.Log == nil {
 		config.Log = &log{
 			MaxSize:    100,
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"teamide/internal/config"
 	"teamide/pkg/node"
+	"teamide/pkg/ssh"
 )
 
 type ServerConf struct {
@@ -201,6 +202,11 @@ func (this_ *ServerContext) Init(serverConfig *config.ServerConfig) (err error)
 	util.Logger = this_.Logger
 	util.SetTempDir(serverConfig.Server.TempDir)
 	node.Logger = this_.Logger
+	if serverConfig.Terminal != nil {
+		ssh.ForwardBindHostList = serverConfig.Terminal.ForwardBindHostList
+		ssh.ForwardTargetHostList = serverConfig.Terminal.ForwardTargetHostList
+		ssh.AgentSocket = serverConfig.Terminal.SSHAgentSocket
+	}
 	db.FileUploadDir = this_.GetFilesDir()
 
 	this_.ServerContext = serverConfig.Server.Context
@@ -157,7 +157,7 @@ func (this_ *Api) GetApis() (apis []*base.ApiWorker, err error) {
 	apis = append(apis, &base.ApiWorker{Power: listenPower, Do: this_.listen, NotRecodeLog: true})
 
 	apis = append(apis, module_toolbox.NewToolboxApi(this_.toolboxService).GetApis()...)
-	apis = append(apis, module_node.NewNodeApi(this_.nodeService).GetApis()...)
+	apis = append(apis, module_node.NewNodeApi(this_.nodeService, this_.toolboxService).GetApis()...)
 	apis = append(apis, module_file_manager.NewApi(this_.toolboxService, this_.nodeService).GetApis()...)
 	apis = append(apis, module_terminal.NewApi(this_.toolboxService, this_.nodeService).GetApis()...)
 	apis = append(apis, module_user.NewApi(this_.userService).GetApis()...)
@@ -166,28 +166,38 @@ func getService(config *db.Config, sshConfig *ssh.Config) (res db.IService, err
 	if sshConfig != nil {
 		key += "-ssh-" + sshConfig.Address
 		key += "-ssh-" + sshConfig.Username
+		if len(sshConfig.JumpHosts) > 0 {
+			key += "-jump-" + sshConfig.GetJumpAddress()
+		}
 	}
 
 	var serviceInfo *base.ServiceInfo
 	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
 		var s db.IService
+		var releaseSSH = func() {}
 		if sshConfig != nil {
-			config.SSHClient, err = ssh.NewClient(*sshConfig)
+			config.SSHClient, releaseSSH, err = ssh.GetPoolClient(*sshConfig)
 			if err != nil {
-				util.Logger.Error("getDatabaseService ssh NewClient error", zap.Any("key", key), zap.Error(err))
+				util.Logger.Error("getDatabaseService ssh GetPoolClient error", zap.Any("key", key), zap.Error(err))
 				return
 			}
 		}
 		s, err = db.New(config)
 		if err != nil {
 			util.Logger.Error("getDatabaseService error", zap.Any("key", key), zap.Error(err))
+			releaseSSH()
 			return
 		}
 		res = &base.ServiceInfo{
 			WaitTime:    10 * 60 * 1000,
 			LastUseTime: util.GetNowMilli(),
 			Service:     s,
-			Stop:        s.Close,
+			Stop: func() {
+				// 数据库服务关闭时会关闭 SSHClient，连接池中的连接由连接池关闭
+				config.SSHClient = nil
+				s.Close()
+				releaseSSH()
+			},
 		}
 		return
 	})
@@ -53,7 +53,7 @@ func (this_ *worker) GetService(fileWorkerKey string, param *BaseParam) (service
 		}
 
 		var config *ssh.Config
-		config, err = this_.toolboxService.GetSSHConfig(tD.Option)
+		config, err = this_.toolboxService.GetSSHConfig(tD.Option, tD.UserId)
 
 		service = ssh.CreateOrGetClient(fileWorkerKey, config)
 	case "node":
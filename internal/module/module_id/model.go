@@ -50,6 +50,8 @@ const (
 	IDTypeToolboxQuickCommand = 5005
 	// IDTypeToolboxExtend 工具箱扩展ID类型
 	IDTypeToolboxExtend = 5006
+	// IDTypeToolboxKnownHost 工具箱SSH已信任主机公钥ID类型
+	IDTypeToolboxKnownHost = 5007
 
 	// IDTypeNode 节点
 	IDTypeNode = 6001
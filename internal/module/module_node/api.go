@@ -1,22 +1,28 @@
 package module_node
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/team-ide/go-tool/util"
 	"teamide/internal/context"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/alert"
 	"teamide/pkg/base"
+	"teamide/pkg/node"
 	"teamide/pkg/system"
 )
 
 type NodeApi struct {
 	*context.ServerContext
-	NodeService *NodeService
+	NodeService    *NodeService
+	toolboxService *module_toolbox.ToolboxService
 }
 
-func NewNodeApi(NodeService *NodeService) *NodeApi {
+func NewNodeApi(NodeService *NodeService, toolboxService *module_toolbox.ToolboxService) *NodeApi {
 	return &NodeApi{
-		ServerContext: NodeService.ServerContext,
-		NodeService:   NodeService,
+		ServerContext:  NodeService.ServerContext,
+		NodeService:    NodeService,
+		toolboxService: toolboxService,
 	}
 }
 
@@ -35,11 +41,22 @@ var (
 	enablePower       = base.AppendPower(&base.PowerAction{Action: "enable", Text: "节点启用", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
 	disablePower      = base.AppendPower(&base.PowerAction{Action: "disable", Text: "节点停用", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
 	deletePower       = base.AppendPower(&base.PowerAction{Action: "delete", Text: "节点删除", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
+	deployPower       = base.AppendPower(&base.PowerAction{Action: "deploy", Text: "节点部署", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
+	alertListPower    = base.AppendPower(&base.PowerAction{Action: "alertList", Text: "节点告警列表", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
+	metricsPower      = base.AppendPower(&base.PowerAction{Action: "metrics", Text: "节点 Prometheus 指标", Parent: PowerNode, ShouldLogin: false, StandAlone: true})
 
 	systemPower                 = base.AppendPower(&base.PowerAction{Action: "system", Text: "节点服务器信息", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
 	systemInfoPower             = base.AppendPower(&base.PowerAction{Action: "info", Text: "节点服务器信息", Parent: systemPower, ShouldLogin: true, StandAlone: true})
 	systemMonitorDataPower      = base.AppendPower(&base.PowerAction{Action: "monitorData", Text: "节点服务器监控数据", Parent: systemPower, ShouldLogin: false, StandAlone: true})
 	systemCleanMonitorDataPower = base.AppendPower(&base.PowerAction{Action: "cleanMonitorData", Text: "节点服务器清理监控数据", Parent: systemPower, ShouldLogin: true, StandAlone: true})
+	systemProcessListPower      = base.AppendPower(&base.PowerAction{Action: "processList", Text: "节点服务器进程列表", Parent: systemPower, ShouldLogin: true, StandAlone: true})
+	systemProcessSignalPower    = base.AppendPower(&base.PowerAction{Action: "processSignal", Text: "节点服务器进程发送信号", Parent: systemPower, ShouldLogin: true, StandAlone: true})
+
+	execPower     = base.AppendPower(&base.PowerAction{Action: "exec", Text: "节点执行命令", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
+	execKillPower = base.AppendPower(&base.PowerAction{Action: "execKill", Text: "节点终止命令", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
+
+	upgradePower       = base.AppendPower(&base.PowerAction{Action: "upgrade", Text: "节点升级", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
+	upgradeStatusPower = base.AppendPower(&base.PowerAction{Action: "upgradeStatus", Text: "节点升级状态", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
 
 	PowerNetProxy             = base.AppendPower(&base.PowerAction{Action: "netProxy", Text: "节点代理", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
 	netProxyListPower         = base.AppendPower(&base.PowerAction{Action: "list", Text: "节点代理列表", Parent: PowerNetProxy, ShouldLogin: true, StandAlone: true})
@@ -63,10 +80,21 @@ func (this_ *NodeApi) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: enablePower, Do: this_.enable})
 	apis = append(apis, &base.ApiWorker{Power: disablePower, Do: this_.disable})
 	apis = append(apis, &base.ApiWorker{Power: deletePower, Do: this_.delete})
+	apis = append(apis, &base.ApiWorker{Power: deployPower, Do: this_.deploy})
+	apis = append(apis, &base.ApiWorker{Power: alertListPower, Do: this_.alertList, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: metricsPower, Do: this_.metrics, IsGet: true, NotRecodeLog: true})
 
 	apis = append(apis, &base.ApiWorker{Power: systemInfoPower, Do: this_.nodeSystemInfo})
 	apis = append(apis, &base.ApiWorker{Power: systemMonitorDataPower, Do: this_.nodeSystemQueryMonitorData, NotRecodeLog: true})
 	apis = append(apis, &base.ApiWorker{Power: systemCleanMonitorDataPower, Do: this_.nodeSystemCleanMonitorData})
+	apis = append(apis, &base.ApiWorker{Power: systemProcessListPower, Do: this_.nodeSystemProcessList, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: systemProcessSignalPower, Do: this_.nodeSystemProcessSignal})
+
+	apis = append(apis, &base.ApiWorker{Power: execPower, Do: this_.nodeExec})
+	apis = append(apis, &base.ApiWorker{Power: execKillPower, Do: this_.nodeExecKill})
+
+	apis = append(apis, &base.ApiWorker{Power: upgradePower, Do: this_.nodeUpgrade})
+	apis = append(apis, &base.ApiWorker{Power: upgradeStatusPower, Do: this_.nodeUpgradeStatus, NotRecodeLog: true})
 
 	apis = append(apis, &base.ApiWorker{Power: netProxyListPower, Do: this_.netProxyList})
 	apis = append(apis, &base.ApiWorker{Power: netProxyInsertPower, Do: this_.netProxyInsert})
@@ -164,7 +192,7 @@ func (this_ *NodeApi) nodeSystemInfo(_ *base.RequestBean, c *gin.Context) (res i
 		return
 	}
 
-	res = this_.NodeService.nodeContext.SystemGetInfo(request.NodeId)
+	res = this_.NodeService.nodeContext.SystemGetInfo(c.Request.Context(), request.NodeId)
 	return
 }
 
@@ -179,7 +207,7 @@ func (this_ *NodeApi) nodeSystemQueryMonitorData(_ *base.RequestBean, c *gin.Con
 		return
 	}
 
-	res = this_.NodeService.nodeContext.SystemQueryMonitorData(request.NodeId, request.QueryRequest)
+	res = this_.NodeService.nodeContext.SystemQueryMonitorData(c.Request.Context(), request.NodeId, request.QueryRequest)
 	return
 }
 
@@ -194,6 +222,141 @@ func (this_ *NodeApi) nodeSystemCleanMonitorData(_ *base.RequestBean, c *gin.Con
 		return
 	}
 
-	this_.NodeService.nodeContext.SystemCleanMonitorData(request.NodeId)
+	this_.NodeService.nodeContext.SystemCleanMonitorData(c.Request.Context(), request.NodeId)
+	return
+}
+
+type NodeProcessRequest struct {
+	NodeId string `json:"nodeId,omitempty"`
+	*system.ProcessQueryRequest
+}
+
+func (this_ *NodeApi) nodeSystemProcessList(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &NodeProcessRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = this_.NodeService.nodeContext.SystemProcessList(c.Request.Context(), request.NodeId, request.ProcessQueryRequest)
+	return
+}
+
+type NodeProcessSignalRequest struct {
+	NodeId string `json:"nodeId,omitempty"`
+	*system.ProcessSignalRequest
+}
+
+func (this_ *NodeApi) nodeSystemProcessSignal(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &NodeProcessSignalRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.ProcessSignalRequest == nil || request.Pid <= 0 {
+		err = errors.New("进程ID不能为空")
+		return
+	}
+
+	err = this_.NodeService.nodeContext.SystemProcessSignal(c.Request.Context(), request.NodeId, request.ProcessSignalRequest)
+	return
+}
+
+type NodeAlertListRequest struct {
+	Status string `json:"status,omitempty"` // pending、firing、resolved，为空时所有
+}
+
+type NodeAlertListResponse struct {
+	AlertList []*alert.Alert `json:"alertList,omitempty"`
+}
+
+func (this_ *NodeApi) alertList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &NodeAlertListRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res = &NodeAlertListResponse{
+		AlertList: this_.NodeService.nodeContext.GetUserAlertList(requestBean.JWT.UserId, request.Status),
+	}
+	return
+}
+
+type NodeExecRequest struct {
+	NodeId string `json:"nodeId,omitempty"`
+	*node.ExecRequest
+}
+
+type NodeExecResponse struct {
+	Key string `json:"key,omitempty"`
+}
+
+// nodeExec 执行命令，返回命令标识，输出以及结果通过 node-data-change 事件发送
+func (this_ *NodeApi) nodeExec(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &NodeExecRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.ExecRequest == nil || request.Command == "" {
+		err = errors.New("命令不能为空")
+		return
+	}
+
+	key, err := this_.NodeService.nodeContext.ExecStart(requestBean.JWT.UserId, request.NodeId, request.ExecRequest)
+	if err != nil {
+		return
+	}
+	res = &NodeExecResponse{
+		Key: key,
+	}
+	return
+}
+
+// nodeExecKill 终止命令，命令标识为 nodeExec 返回的标识
+func (this_ *NodeApi) nodeExecKill(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &NodeExecRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.ExecRequest == nil || request.Key == "" {
+		err = errors.New("命令标识不能为空")
+		return
+	}
+
+	err = this_.NodeService.nodeContext.ExecKill(requestBean.JWT.UserId, request.Key)
+	return
+}
+
+func (this_ *NodeApi) nodeUpgrade(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &NodeUpgradeRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = this_.NodeService.nodeContext.Upgrade(requestBean.JWT.UserId, request)
+	return
+}
+
+type NodeUpgradeStatusRequest struct {
+	TaskId string `json:"taskId,omitempty"`
+}
+
+func (this_ *NodeApi) nodeUpgradeStatus(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &NodeUpgradeStatusRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	task := this_.NodeService.nodeContext.GetUpgradeTask(requestBean.JWT.UserId, request.TaskId)
+	if task == nil {
+		err = errors.New("升级任务[" + request.TaskId + "]不存在")
+		return
+	}
+
+	res = task
 	return
 }
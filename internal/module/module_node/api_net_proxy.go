@@ -41,10 +41,10 @@ func (this_ *NodeApi) netProxyMonitorData(_ *base.RequestBean, c *gin.Context) (
 			Id: id,
 		}
 		if len(innerLineNodeIdList) > 0 {
-			one.InnerMonitorData = ToMonitorDataFormat(this_.NodeService.nodeContext.GetServer().GetNetProxyInnerMonitorData(innerLineNodeIdList, id))
+			one.InnerMonitorData = ToMonitorDataFormat(this_.NodeService.nodeContext.GetServer().GetNetProxyInnerMonitorDataContext(c.Request.Context(), innerLineNodeIdList, id))
 		}
 		if len(outerLineNodeIdList) > 0 {
-			one.OuterMonitorData = ToMonitorDataFormat(this_.NodeService.nodeContext.GetServer().GetNetProxyOuterMonitorData(outerLineNodeIdList, id))
+			one.OuterMonitorData = ToMonitorDataFormat(this_.NodeService.nodeContext.GetServer().GetNetProxyOuterMonitorDataContext(c.Request.Context(), outerLineNodeIdList, id))
 		}
 		response.NetProxyMonitorDataList = append(response.NetProxyMonitorDataList, one)
 	}
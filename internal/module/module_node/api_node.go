@@ -51,8 +51,19 @@ func (this_ *NodeApi) insert(requestBean *base.RequestBean, c *gin.Context) (res
 	}
 	response := &InsertResponse{}
 
-	node := request.NodeModel
-	node.UserId = requestBean.JWT.UserId
+	_, err = this_.insertNode(requestBean.JWT.UserId, request)
+	if err != nil {
+		return
+	}
+
+	res = response
+	return
+}
+
+// insertNode 新增节点，非本地节点需要关联父节点或连接节点
+func (this_ *NodeApi) insertNode(userId int64, request *InsertRequest) (node *NodeModel, err error) {
+	node = request.NodeModel
+	node.UserId = userId
 
 	var parentNodeModel *NodeModel
 	var toNodeModel *NodeModel
@@ -114,8 +125,6 @@ func (this_ *NodeApi) insert(requestBean *base.RequestBean, c *gin.Context) (res
 			}
 		}
 	}
-
-	res = response
 	return
 }
 
@@ -250,3 +259,31 @@ func (this_ *NodeApi) delete(requestBean *base.RequestBean, c *gin.Context) (res
 	res = response
 	return
 }
+
+func (this_ *NodeApi) deploy(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &NodeDeployRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	toolboxModel, err := this_.toolboxService.Get(request.ToolboxId)
+	if err != nil {
+		return
+	}
+	if toolboxModel == nil || toolboxModel.ToolboxType != "ssh" || toolboxModel.Option == "" {
+		err = errors.New("SSH配置不存在")
+		return
+	}
+	err = this_.toolboxService.CheckToolboxPower(requestBean, toolboxModel)
+	if err != nil {
+		return
+	}
+	sshConfig, err := this_.toolboxService.GetSSHConfig(toolboxModel.Option, toolboxModel.UserId)
+	if err != nil {
+		return
+	}
+
+	res, err = this_.Deploy(requestBean.JWT.UserId, sshConfig, request)
+	return
+}
@@ -3,9 +3,12 @@ package module_node
 import (
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
+	"path/filepath"
 	"strconv"
 	"sync"
+	"teamide/pkg/alert"
 	"teamide/pkg/node"
+	"teamide/pkg/system"
 )
 
 func (this_ *NodeService) InitContext() {
@@ -20,6 +23,10 @@ func (this_ *NodeService) InitContext() {
 			codeModelCache:       make(map[string]*NetProxyModel),
 
 			lineNodeIdListCache: make(map[string][]string),
+
+			upgradeTaskCache: make(map[string]*NodeUpgradeTask),
+
+			execCache: make(map[string]*nodeExec),
 		}
 	}
 	err := this_.nodeContext.initContext()
@@ -59,6 +66,14 @@ type NodeContext struct {
 
 	doAliveIng  bool
 	doAliveLock sync.Mutex
+
+	upgradeTaskCache     map[string]*NodeUpgradeTask
+	upgradeTaskCacheLock sync.Mutex
+
+	execCache     map[string]*nodeExec
+	execCacheLock sync.Mutex
+
+	alertManager *alert.Manager
 }
 
 func (this_ *NodeContext) GetServer() *node.Server {
@@ -87,6 +102,10 @@ func (this_ *NodeContext) GetNodeLineTo(nodeId string) (lineIdList []string) {
 			break
 		}
 	}
+	if len(lineIdList) == 0 && this_.server != nil {
+		// 未配置连接关系的节点，使用节点间交换的路由
+		lineIdList = this_.server.GetRoute(nodeId)
+	}
 	return
 }
 
@@ -121,6 +140,12 @@ func (this_ *NodeContext) GetNodeLineByFromTo(fromNodeId, toNodeId string) (line
 
 func (this_ *NodeContext) initContext() (err error) {
 
+	if this_.ServerConfig.Node != nil && this_.ServerConfig.Node.MonitorDataSaveDays > 0 {
+		dir := filepath.Join(this_.ServerConfig.Server.Data, "node", "monitor")
+		if e := system.SetHistory(dir, this_.ServerConfig.Node.MonitorDataSaveDays); e != nil {
+			this_.Logger.Error("节点监控数据持久化目录创建异常", zap.Any("dir", dir), zap.Error(e))
+		}
+	}
 	if this_.server == nil {
 		this_.server = &node.Server{}
 		this_.server.Start()
@@ -155,6 +180,10 @@ func (this_ *NodeContext) initContext() (err error) {
 	}
 
 	go this_.doAlive()
+
+	if this_.alertManager == nil {
+		this_.initAlert()
+	}
 	return
 }
 
@@ -172,6 +201,7 @@ func (this_ *NodeContext) appendLocalNode(localNode *NodeModel) (err error) {
 		Id:          localNode.ServerId,
 		BindToken:   localNode.BindToken,
 		BindAddress: localNode.BindAddress,
+		BindTLS:     this_.getLocalTLSConfig(localNode.GetOption().BindTLS),
 	}
 	this_.GetServer().AddLocalNode(serverLocalNode)
 
@@ -260,6 +290,9 @@ type MonitorDataFormat struct {
 	ReadTimeUnit string `json:"readTimeUnit,omitempty"`
 	ReadSleep    string `json:"readSleep,omitempty"`
 
+	ReadRawSize     string `json:"readRawSize,omitempty"`
+	ReadRawSizeUnit string `json:"readRawSizeUnit,omitempty"`
+
 	ReadLastSize      string `json:"readLastSize,omitempty"`
 	ReadLastSizeUnit  string `json:"readLastSizeUnit,omitempty"`
 	ReadLastTime      string `json:"readLastTime,omitempty"`
@@ -274,6 +307,9 @@ type MonitorDataFormat struct {
 	WriteTimeUnit string `json:"writeTimeUnit,omitempty"`
 	WriteSleep    string `json:"writeSleep,omitempty"`
 
+	WriteRawSize     string `json:"writeRawSize,omitempty"`
+	WriteRawSizeUnit string `json:"writeRawSizeUnit,omitempty"`
+
 	WriteLastSize      string `json:"writeLastSize,omitempty"`
 	WriteLastSizeUnit  string `json:"writeLastSizeUnit,omitempty"`
 	WriteLastTime      string `json:"writeLastTime,omitempty"`
@@ -318,6 +354,8 @@ func ToMonitorDataFormat(monitorData *node.MonitorData) *MonitorDataFormat {
 		ReadSleep = ReadSize / ReadTime
 	}
 
+	ReadRawSize, ReadRawSizeUnit := GetSizeAndUnit(float64(monitorData.ReadRawSize))
+
 	ReadLastSize, ReadLastSizeUnit := GetSizeAndUnit(float64(monitorData.ReadLastSize))
 	ReadLastTime := float64(monitorData.ReadLastTime) / 1000000000
 	ReadLastTimeUnit := "秒"
@@ -336,6 +374,8 @@ func ToMonitorDataFormat(monitorData *node.MonitorData) *MonitorDataFormat {
 		WriteSleep = WriteSize / WriteTime
 	}
 
+	WriteRawSize, WriteRawSizeUnit := GetSizeAndUnit(float64(monitorData.WriteRawSize))
+
 	WriteLastSize, WriteLastSizeUnit := GetSizeAndUnit(float64(monitorData.WriteLastSize))
 	WriteLastTime := float64(monitorData.WriteLastTime) / 1000000000
 	WriteLastTimeUnit := "秒"
@@ -353,6 +393,9 @@ func ToMonitorDataFormat(monitorData *node.MonitorData) *MonitorDataFormat {
 		ReadTimeUnit: ReadTimeUnit,
 		ReadSleep:    strconv.FormatFloat(ReadSleep, 'f', 2, 64),
 
+		ReadRawSize:     strconv.FormatFloat(ReadRawSize, 'f', 2, 64),
+		ReadRawSizeUnit: ReadRawSizeUnit,
+
 		ReadLastSize:      strconv.FormatFloat(ReadLastSize, 'f', 2, 64),
 		ReadLastSizeUnit:  ReadLastSizeUnit,
 		ReadLastTime:      strconv.FormatFloat(ReadLastTime, 'f', 2, 64),
@@ -367,6 +410,9 @@ func ToMonitorDataFormat(monitorData *node.MonitorData) *MonitorDataFormat {
 		WriteTimeUnit: WriteTimeUnit,
 		WriteSleep:    strconv.FormatFloat(WriteSleep, 'f', 2, 64),
 
+		WriteRawSize:     strconv.FormatFloat(WriteRawSize, 'f', 2, 64),
+		WriteRawSizeUnit: WriteRawSizeUnit,
+
 		WriteLastSize:      strconv.FormatFloat(WriteLastSize, 'f', 2, 64),
 		WriteLastSizeUnit:  WriteLastSizeUnit,
 		WriteLastTime:      strconv.FormatFloat(WriteLastTime, 'f', 2, 64),
@@ -0,0 +1,141 @@
+package module_node
+
+import (
+	"go.uber.org/zap"
+	"teamide/pkg/alert"
+	"teamide/pkg/node"
+	"teamide/pkg/system"
+	"time"
+)
+
+// initAlert 按配置创建告警管理并开始定时检查，未配置规则时不检查
+func (this_ *NodeContext) initAlert() {
+	config := this_.ServerConfig.Node
+	if config == nil || config.Alert == nil || len(config.Alert.RuleList) == 0 {
+		return
+	}
+	manager, err := alert.NewManager(config.Alert)
+	if err != nil {
+		this_.Logger.Error("节点告警配置异常", zap.Error(err))
+		return
+	}
+	manager.OnAlertChange = this_.callNodeAlertChange
+	this_.alertManager = manager
+
+	go func() {
+		for {
+			time.Sleep(manager.GetInterval())
+			this_.doAlert()
+		}
+	}()
+}
+
+func (this_ *NodeContext) doAlert() {
+	defer func() {
+		if e := recover(); e != nil {
+			this_.Logger.Error("doAlert error", zap.Any("error", e))
+		}
+	}()
+	this_.alertManager.Evaluate(this_.getAlertSampleList(), time.Now())
+}
+
+// getAlertSampleList 采集告警样本，只采集有规则的指标，节点状态使用保活更新的状态
+func (this_ *NodeContext) getAlertSampleList() (sampleList []*alert.Sample) {
+	manager := this_.alertManager
+	needSystem := manager.HasMetric(alert.MetricCpuPercent) || manager.HasMetric(alert.MetricMemoryPercent) ||
+		manager.HasMetric(alert.MetricNetRecvSpeed) || manager.HasMetric(alert.MetricNetSentSpeed)
+	needDisk := manager.HasMetric(alert.MetricDiskPercent)
+
+	for _, nodeModel := range this_.getNodeModelList() {
+		if nodeModel.Enabled == 2 {
+			continue
+		}
+		nodeId := nodeModel.ServerId
+		isUp := nodeModel.Status == node.StatusStarted
+		sampleList = append(sampleList, &alert.Sample{Metric: alert.MetricNodeOffline, Target: nodeId, Value: boolMetric(!isUp)})
+		if !isUp {
+			continue
+		}
+		lineNodeIdList := this_.GetNodeLineTo(nodeId)
+		if len(lineNodeIdList) == 0 {
+			continue
+		}
+		if needSystem {
+			sampleList = append(sampleList, getSystemAlertSampleList(nodeId, this_.GetServer().SystemMonitorData(lineNodeIdList))...)
+		}
+		if needDisk {
+			if info := this_.GetServer().SystemGetInfo(lineNodeIdList); info != nil && len(info.Disks) > 0 {
+				var maxPercent float64
+				for _, disk := range info.Disks {
+					if disk.Total > 0 && disk.UsedPercent > maxPercent {
+						maxPercent = disk.UsedPercent
+					}
+				}
+				sampleList = append(sampleList, &alert.Sample{Metric: alert.MetricDiskPercent, Target: nodeId, Value: maxPercent})
+			}
+		}
+	}
+
+	for _, netProxyModel := range this_.getNetProxyModelList() {
+		if netProxyModel.Enabled == 2 {
+			continue
+		}
+		isDown := netProxyModel.InnerStatus != node.StatusStarted || netProxyModel.OuterStatus != node.StatusStarted
+		sampleList = append(sampleList, &alert.Sample{Metric: alert.MetricNetProxyDown, Target: netProxyModel.Code, Value: boolMetric(isDown)})
+	}
+	return
+}
+
+func getSystemAlertSampleList(nodeId string, monitorData *system.MonitorData) (sampleList []*alert.Sample) {
+	if monitorData == nil {
+		return
+	}
+	if len(monitorData.CpuPercents) > 0 {
+		var total float64
+		for _, one := range monitorData.CpuPercents {
+			total += one
+		}
+		sampleList = append(sampleList, &alert.Sample{Metric: alert.MetricCpuPercent, Target: nodeId, Value: total / float64(len(monitorData.CpuPercents))})
+	}
+	if monitorData.VirtualMemoryStat != nil {
+		sampleList = append(sampleList, &alert.Sample{Metric: alert.MetricMemoryPercent, Target: nodeId, Value: monitorData.VirtualMemoryStat.UsedPercent})
+	}
+	var recvSpeed, sentSpeed uint64
+	for _, one := range monitorData.NetIOCountersStats {
+		recvSpeed += one.SpeedRecv
+		sentSpeed += one.SpeedSent
+	}
+	sampleList = append(sampleList, &alert.Sample{Metric: alert.MetricNetRecvSpeed, Target: nodeId, Value: float64(recvSpeed)})
+	sampleList = append(sampleList, &alert.Sample{Metric: alert.MetricNetSentSpeed, Target: nodeId, Value: float64(sentSpeed)})
+	return
+}
+
+// GetAlertList 告警列表，未开启告警时为空
+func (this_ *NodeContext) GetAlertList(status string) []*alert.Alert {
+	if this_.alertManager == nil {
+		return nil
+	}
+	return this_.alertManager.GetAlertList(status)
+}
+
+// GetUserAlertList 用户节点、网络代理的告警
+func (this_ *NodeContext) GetUserAlertList(userId int64, status string) (alertList []*alert.Alert) {
+	for _, one := range this_.GetAlertList(status) {
+		if this_.getAlertUserId(one) == userId {
+			alertList = append(alertList, one)
+		}
+	}
+	return
+}
+
+// getAlertUserId 告警目标（节点或网络代理）所属用户
+func (this_ *NodeContext) getAlertUserId(one *alert.Alert) (userId int64) {
+	if one.Metric == alert.MetricNetProxyDown {
+		if netProxyModel := this_.getNetProxyModelByCode(one.Target); netProxyModel != nil {
+			userId = netProxyModel.UserId
+		}
+	} else if nodeModel := this_.getNodeModelByServerId(one.Target); nodeModel != nil {
+		userId = nodeModel.UserId
+	}
+	return
+}
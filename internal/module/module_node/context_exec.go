@@ -0,0 +1,90 @@
+package module_node
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"teamide/pkg/node"
+)
+
+// DefaultExecTimeout 执行命令默认超时时间（毫秒）
+var DefaultExecTimeout int64 = 10 * 60 * 1000
+
+// nodeExec 执行中的命令，只有发起的用户可以终止
+type nodeExec struct {
+	key    string
+	nodeId string
+	userId int64
+}
+
+func (this_ *NodeContext) addExec(one *nodeExec) {
+	this_.execCacheLock.Lock()
+	defer this_.execCacheLock.Unlock()
+
+	this_.execCache[one.key] = one
+}
+
+func (this_ *NodeContext) getExec(key string) (one *nodeExec) {
+	this_.execCacheLock.Lock()
+	defer this_.execCacheLock.Unlock()
+
+	one = this_.execCache[key]
+	return
+}
+
+func (this_ *NodeContext) removeExec(key string) {
+	this_.execCacheLock.Lock()
+	defer this_.execCacheLock.Unlock()
+
+	delete(this_.execCache, key)
+}
+
+// ExecStart 在节点上执行命令，返回命令标识，输出以及结束结果通过 node-data-change 事件按流发送给发起的用户
+func (this_ *NodeContext) ExecStart(userId int64, nodeId string, request *node.ExecRequest) (key string, err error) {
+	lineNodeIdList := this_.GetNodeLineTo(nodeId)
+	if len(lineNodeIdList) == 0 {
+		err = errors.New("无法连接到节点[" + nodeId + "]")
+		return
+	}
+	if request.Timeout <= 0 {
+		request.Timeout = DefaultExecTimeout
+	}
+	// 命令标识由服务端生成，不使用客户端传入的标识
+	key = util.GetUUID()
+	request.Key = key
+	this_.addExec(&nodeExec{
+		key:    key,
+		nodeId: nodeId,
+		userId: userId,
+	})
+
+	_, err = this_.GetServer().ExecStart(lineNodeIdList, request, func(buf []byte) (err error) {
+		this_.callNodeExecChange(userId, &NodeExecChange{Key: key, NodeId: nodeId, Stdout: string(buf)})
+		return
+	}, func(buf []byte) (err error) {
+		this_.callNodeExecChange(userId, &NodeExecChange{Key: key, NodeId: nodeId, Stderr: string(buf)})
+		return
+	}, func(result *node.ExecResult) {
+		this_.removeExec(key)
+		this_.callNodeExecChange(userId, &NodeExecChange{Key: key, NodeId: nodeId, Result: result})
+	})
+	if err != nil {
+		this_.removeExec(key)
+		return
+	}
+	return
+}
+
+// ExecKill 终止用户执行中的命令
+func (this_ *NodeContext) ExecKill(userId int64, key string) (err error) {
+	one := this_.getExec(key)
+	if one == nil || one.userId != userId {
+		err = node.ExecNotFoundError
+		return
+	}
+	lineNodeIdList := this_.GetNodeLineTo(one.nodeId)
+	if len(lineNodeIdList) == 0 {
+		err = errors.New("无法连接到节点[" + one.nodeId + "]")
+		return
+	}
+	return this_.GetServer().ExecKill(lineNodeIdList, key)
+}
@@ -69,7 +69,10 @@ func (this_ *NodeContext) formatNetProxy(netProxyModel *NetProxyModel) (err erro
 		err = errors.New("网络代理输入地址不能为空")
 		return
 	}
-	if netProxyModel.OuterAddress == "" {
+	if netProxyModel.IsDynamic() {
+		// 动态代理 输出端连接客户端请求的地址
+		netProxyModel.OuterType = node.NetProxyTypeDynamic
+	} else if netProxyModel.OuterAddress == "" {
 		err = errors.New("网络代理输出地址不能为空")
 		return
 	}
@@ -153,6 +156,7 @@ func (this_ *NodeContext) toAddNetProxyModel(netProxyModel *NetProxyModel) {
 				Address:               netProxyModel.OuterAddress,
 				Enabled:               netProxyModel.Enabled,
 				ReverseLineNodeIdList: netProxyModel.ReverseLineNodeIdList,
+				AllowList:             netProxyModel.GetOption().AllowList,
 			},
 		})
 		if err != nil {
@@ -1,8 +1,11 @@
 package module_node
 
 import (
+	"context"
+	"errors"
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
+	"os"
 	"teamide/pkg/node"
 	"teamide/pkg/system"
 )
@@ -140,6 +143,7 @@ func (this_ *NodeContext) onUpdateNodeConnServerIds(nodeId int64, connServerIds
 			Id:          toNodeModel.ServerId,
 			ConnAddress: toNodeModel.ConnAddress,
 			ConnToken:   toNodeModel.ConnToken,
+			ConnTLS:     this_.getRemoteTLSConfig(toNodeModel.GetOption().ConnTLS),
 			Enabled:     toNodeModel.Enabled,
 		})
 	}
@@ -238,25 +242,83 @@ func (this_ *NodeContext) toAddNodeModel(nodeModel *NodeModel) {
 				Id:          nodeModel.ServerId,
 				ConnAddress: nodeModel.ConnAddress,
 				ConnToken:   nodeModel.ConnToken,
+				ConnTLS:     this_.getRemoteTLSConfig(nodeModel.GetOption().ConnTLS),
 				Enabled:     nodeModel.Enabled,
 			},
 		})
 	}
 }
 
-func (this_ *NodeContext) SystemGetInfo(nodeId string) (info *system.Info) {
+func (this_ *NodeContext) SystemGetInfo(ctx context.Context, nodeId string) (info *system.Info) {
 	lineNodeIdList := this_.GetNodeLineTo(nodeId)
 
-	return this_.GetServer().SystemGetInfo(lineNodeIdList)
+	return this_.GetServer().SystemGetInfoContext(ctx, lineNodeIdList)
 
 }
 
-func (this_ *NodeContext) SystemQueryMonitorData(nodeId string, request *system.QueryRequest) (info *system.QueryResponse) {
+func (this_ *NodeContext) SystemQueryMonitorData(ctx context.Context, nodeId string, request *system.QueryRequest) (info *system.QueryResponse) {
 	lineNodeIdList := this_.GetNodeLineTo(nodeId)
-	return this_.GetServer().SystemQueryMonitorData(lineNodeIdList, request)
+	return this_.GetServer().SystemQueryMonitorDataContext(ctx, lineNodeIdList, request)
 }
 
-func (this_ *NodeContext) SystemCleanMonitorData(nodeId string) {
+func (this_ *NodeContext) SystemCleanMonitorData(ctx context.Context, nodeId string) {
 	lineNodeIdList := this_.GetNodeLineTo(nodeId)
-	this_.GetServer().SystemCleanMonitorData(lineNodeIdList)
+	this_.GetServer().SystemCleanMonitorDataContext(ctx, lineNodeIdList)
+}
+
+func (this_ *NodeContext) SystemProcessList(ctx context.Context, nodeId string, request *system.ProcessQueryRequest) (response *system.ProcessQueryResponse, err error) {
+	lineNodeIdList := this_.GetNodeLineTo(nodeId)
+	if len(lineNodeIdList) == 0 {
+		err = errors.New("无法连接到节点[" + nodeId + "]")
+		return
+	}
+	return this_.GetServer().SystemProcessListContext(ctx, lineNodeIdList, request)
+}
+
+func (this_ *NodeContext) SystemProcessSignal(ctx context.Context, nodeId string, request *system.ProcessSignalRequest) (err error) {
+	lineNodeIdList := this_.GetNodeLineTo(nodeId)
+	if len(lineNodeIdList) == 0 {
+		err = errors.New("无法连接到节点[" + nodeId + "]")
+		return
+	}
+	return this_.GetServer().SystemProcessSignalContext(ctx, lineNodeIdList, request)
+}
+
+// getLocalTLSConfig 本地节点使用的 TLS 配置，证书为上传至文件目录的文件
+func (this_ *NodeContext) getLocalTLSConfig(tlsConfig *node.TLSConfig) (res *node.TLSConfig) {
+	if !tlsConfig.IsOpen() {
+		return
+	}
+	res = &node.TLSConfig{}
+	*res = *tlsConfig
+	if res.Cert != "" {
+		res.Cert = this_.GetFilesFile(res.Cert)
+	}
+	if res.Key != "" {
+		res.Key = this_.GetFilesFile(res.Key)
+	}
+	if res.CA != "" {
+		res.CA = this_.GetFilesFile(res.CA)
+	}
+	return
+}
+
+// getRemoteTLSConfig 下发至节点的 TLS 配置，节点可能在其它服务器，所以读取证书内容下发
+func (this_ *NodeContext) getRemoteTLSConfig(tlsConfig *node.TLSConfig) (res *node.TLSConfig) {
+	res = this_.getLocalTLSConfig(tlsConfig)
+	if res == nil {
+		return
+	}
+	for _, value := range []*string{&res.Cert, &res.Key, &res.CA} {
+		if *value == "" {
+			continue
+		}
+		bs, err := os.ReadFile(*value)
+		if err != nil {
+			this_.Logger.Error("read node tls file error", zap.Any("path", *value), zap.Error(err))
+			continue
+		}
+		*value = string(bs)
+	}
+	return
 }
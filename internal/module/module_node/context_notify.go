@@ -1,6 +1,10 @@
 package module_node
 
-import "teamide/internal/context"
+import (
+	"teamide/internal/context"
+	"teamide/pkg/alert"
+	"teamide/pkg/node"
+)
 
 type NodeListChange struct {
 	Type     string       `json:"type,omitempty"`
@@ -25,3 +29,46 @@ func (this_ *NodeContext) callNetProxyListChange(userId int64, netProxyList []*N
 		NetProxyList: netProxyList,
 	}))
 }
+
+type NodeUpgradeChange struct {
+	Type        string           `json:"type,omitempty"`
+	UpgradeTask *NodeUpgradeTask `json:"upgradeTask,omitempty"`
+}
+
+func (this_ *NodeContext) callNodeUpgradeChange(userId int64, upgradeTask *NodeUpgradeTask) {
+	context.CallUserEvent(userId, context.NewListenEvent("node-data-change", &NodeUpgradeChange{
+		Type:        "node-upgrade",
+		UpgradeTask: upgradeTask,
+	}))
+}
+
+type NodeExecChange struct {
+	Type   string           `json:"type,omitempty"`
+	Key    string           `json:"key,omitempty"`
+	NodeId string           `json:"nodeId,omitempty"`
+	Stdout string           `json:"stdout,omitempty"`
+	Stderr string           `json:"stderr,omitempty"`
+	Result *node.ExecResult `json:"result,omitempty"` // 命令结束时的结果
+}
+
+func (this_ *NodeContext) callNodeExecChange(userId int64, change *NodeExecChange) {
+	change.Type = "node-exec"
+	context.CallUserEvent(userId, context.NewListenEvent("node-data-change", change))
+}
+
+type NodeAlertChange struct {
+	Type  string       `json:"type,omitempty"`
+	Alert *alert.Alert `json:"alert,omitempty"`
+}
+
+// callNodeAlertChange 通知告警目标所属用户
+func (this_ *NodeContext) callNodeAlertChange(one *alert.Alert) {
+	userId := this_.getAlertUserId(one)
+	if userId == 0 {
+		return
+	}
+	context.CallUserEvent(userId, context.NewListenEvent("node-data-change", &NodeAlertChange{
+		Type:  "node-alert",
+		Alert: one,
+	}))
+}
@@ -0,0 +1,305 @@
+package module_node
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"sync"
+	"teamide/pkg/node"
+	"time"
+)
+
+var (
+	// DefaultUpgradeReconnectTimeout 节点升级后等待重新连接的默认时间（毫秒），需要小于节点的 UpgradeConfirmTimeout
+	DefaultUpgradeReconnectTimeout int64 = 2 * 60 * 1000
+
+	UpgradeStatusWaiting    = "waiting"
+	UpgradeStatusUploading  = "uploading"
+	UpgradeStatusRestarting = "restarting"
+	UpgradeStatusSuccess    = "success"
+	UpgradeStatusFailed     = "failed"
+	UpgradeStatusSkipped    = "skipped"
+)
+
+// NodeUpgradeFile 节点程序，按节点的系统、架构选择
+type NodeUpgradeFile struct {
+	Os        string `json:"os,omitempty"`
+	Arch      string `json:"arch,omitempty"`
+	Path      string `json:"path,omitempty"`      // 上传至文件目录的节点程序
+	Signature string `json:"signature,omitempty"` // 程序 SHA256 摘要的 ed25519 签名（base64）
+}
+
+// NodeUpgradeRequest 节点升级请求
+type NodeUpgradeRequest struct {
+	NodeIdList       []string           `json:"nodeIdList,omitempty"`
+	Version          string             `json:"version,omitempty"`
+	FileList         []*NodeUpgradeFile `json:"fileList,omitempty"`
+	StageSize        int                `json:"stageSize,omitempty"`        // 每批升级的节点数，默认 1
+	ReconnectTimeout int64              `json:"reconnectTimeout,omitempty"` // 升级后等待重新连接的时间（毫秒）
+}
+
+// NodeUpgradeStatus 单个节点的升级状态
+type NodeUpgradeStatus struct {
+	NodeId      string `json:"nodeId,omitempty"`
+	Stage       int    `json:"stage"`
+	Status      string `json:"status,omitempty"`
+	FromVersion string `json:"fromVersion,omitempty"`
+	ToVersion   string `json:"toVersion,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	UploadSize  int64  `json:"uploadSize,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// NodeUpgradeTask 节点升级任务
+type NodeUpgradeTask struct {
+	TaskId    string               `json:"taskId,omitempty"`
+	Version   string               `json:"version,omitempty"`
+	IsEnd     bool                 `json:"isEnd"`
+	StartTime time.Time            `json:"startTime,omitempty"`
+	EndTime   time.Time            `json:"endTime,omitempty"`
+	NodeList  []*NodeUpgradeStatus `json:"nodeList,omitempty"`
+	userId    int64
+	lock      sync.Mutex
+}
+
+func (this_ *NodeContext) addUpgradeTask(task *NodeUpgradeTask) {
+	this_.upgradeTaskCacheLock.Lock()
+	defer this_.upgradeTaskCacheLock.Unlock()
+
+	this_.upgradeTaskCache[task.TaskId] = task
+}
+
+func (this_ *NodeContext) getUpgradeTask(taskId string) (task *NodeUpgradeTask) {
+	this_.upgradeTaskCacheLock.Lock()
+	defer this_.upgradeTaskCacheLock.Unlock()
+
+	task = this_.upgradeTaskCache[taskId]
+	return
+}
+
+// GetUpgradeTask 用户升级任务状态的副本，任务不属于该用户时返回 nil
+func (this_ *NodeContext) GetUpgradeTask(userId int64, taskId string) (res *NodeUpgradeTask) {
+	task := this_.getUpgradeTask(taskId)
+	if task == nil || task.userId != userId {
+		return
+	}
+	task.lock.Lock()
+	defer task.lock.Unlock()
+
+	res = &NodeUpgradeTask{
+		TaskId:    task.TaskId,
+		Version:   task.Version,
+		IsEnd:     task.IsEnd,
+		StartTime: task.StartTime,
+		EndTime:   task.EndTime,
+	}
+	for _, one := range task.NodeList {
+		status := *one
+		res.NodeList = append(res.NodeList, &status)
+	}
+	return
+}
+
+func (this_ *NodeContext) setUpgradeStatus(task *NodeUpgradeTask, set func()) {
+	task.lock.Lock()
+	set()
+	task.lock.Unlock()
+	this_.callNodeUpgradeChange(task.userId, this_.GetUpgradeTask(task.userId, task.TaskId))
+}
+
+// Upgrade 按批次升级节点，每批节点升级并重新连接后再升级下一批，有节点失败时停止后续批次
+func (this_ *NodeContext) Upgrade(userId int64, request *NodeUpgradeRequest) (task *NodeUpgradeTask, err error) {
+	if len(request.NodeIdList) == 0 {
+		err = errors.New("升级节点不能为空")
+		return
+	}
+	if len(request.FileList) == 0 {
+		err = errors.New("节点程序不能为空")
+		return
+	}
+	if request.StageSize <= 0 {
+		request.StageSize = 1
+	}
+	if request.ReconnectTimeout <= 0 {
+		request.ReconnectTimeout = DefaultUpgradeReconnectTimeout
+	}
+
+	task = &NodeUpgradeTask{
+		TaskId:    util.GetUUID(),
+		Version:   request.Version,
+		StartTime: time.Now(),
+		userId:    userId,
+	}
+	for i, nodeId := range request.NodeIdList {
+		task.NodeList = append(task.NodeList, &NodeUpgradeStatus{
+			NodeId: nodeId,
+			Stage:  i / request.StageSize,
+			Status: UpgradeStatusWaiting,
+		})
+	}
+	this_.addUpgradeTask(task)
+
+	go this_.doUpgradeTask(task, request)
+
+	task = this_.GetUpgradeTask(task.userId, task.TaskId)
+	return
+}
+
+func (this_ *NodeContext) doUpgradeTask(task *NodeUpgradeTask, request *NodeUpgradeRequest) {
+	defer func() {
+		this_.setUpgradeStatus(task, func() {
+			task.IsEnd = true
+			task.EndTime = time.Now()
+		})
+	}()
+
+	var failed bool
+	for start := 0; start < len(task.NodeList); start += request.StageSize {
+		end := start + request.StageSize
+		if end > len(task.NodeList) {
+			end = len(task.NodeList)
+		}
+		stage := task.NodeList[start:end]
+		if failed {
+			for _, status := range stage {
+				this_.setUpgradeStatus(task, func() {
+					status.Status = UpgradeStatusSkipped
+				})
+			}
+			continue
+		}
+
+		var waitGroup sync.WaitGroup
+		for _, status := range stage {
+			waitGroup.Add(1)
+			go func(status *NodeUpgradeStatus) {
+				defer waitGroup.Done()
+				e := this_.upgradeNode(task, status, request)
+				if e != nil {
+					this_.Logger.Error("node upgrade error", zap.Any("nodeId", status.NodeId), zap.Error(e))
+				}
+				this_.setUpgradeStatus(task, func() {
+					if e != nil {
+						status.Status = UpgradeStatusFailed
+						status.Error = e.Error()
+					} else {
+						status.Status = UpgradeStatusSuccess
+					}
+				})
+			}(status)
+		}
+		waitGroup.Wait()
+
+		for _, status := range stage {
+			if status.Status == UpgradeStatusFailed {
+				failed = true
+			}
+		}
+	}
+}
+
+func getUpgradeSha256(path string) (sha256Str string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, f); err != nil {
+		return
+	}
+	sha256Str = hex.EncodeToString(hash.Sum(nil))
+	return
+}
+
+func (this_ *NodeContext) upgradeNode(task *NodeUpgradeTask, status *NodeUpgradeStatus, request *NodeUpgradeRequest) (err error) {
+	if nodeModel := this_.getNodeModelByServerId(status.NodeId); nodeModel != nil && nodeModel.IsLocalNode() {
+		err = errors.New("本地节点[" + status.NodeId + "]随服务升级，不能单独升级")
+		return
+	}
+	server := this_.GetServer()
+	lineNodeIdList := this_.GetNodeLineTo(status.NodeId)
+	if len(lineNodeIdList) == 0 {
+		err = errors.New("无法连接到节点[" + status.NodeId + "]")
+		return
+	}
+	info, err := server.GetUpgradeInfo(lineNodeIdList)
+	if err != nil {
+		return
+	}
+
+	file := getNodeFile(request.FileList, info.Os, info.Arch)
+	if file == nil {
+		err = fmt.Errorf("没有[%s/%s]的节点程序", info.Os, info.Arch)
+		return
+	}
+	path := this_.GetFilesFile(file.Path)
+	stat, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	sha256Str, err := getUpgradeSha256(path)
+	if err != nil {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	this_.setUpgradeStatus(task, func() {
+		status.Status = UpgradeStatusUploading
+		status.FromVersion = info.Version
+		status.ToVersion = request.Version
+		status.Size = stat.Size()
+	})
+	var callStop bool
+	var lastUploadTime time.Time
+	upgradeRequest := &node.UpgradeRequest{
+		Id:        util.GetUUID(),
+		Version:   request.Version,
+		Sha256:    sha256Str,
+		Signature: file.Signature,
+	}
+	err = server.Upgrade(lineNodeIdList, upgradeRequest, f, func(readSize int64, writeSize int64) {
+		// 控制通知频率
+		if time.Since(lastUploadTime) < time.Second && writeSize < stat.Size() {
+			return
+		}
+		lastUploadTime = time.Now()
+		this_.setUpgradeStatus(task, func() {
+			status.UploadSize = writeSize
+		})
+	}, &callStop)
+	if err != nil {
+		return
+	}
+
+	this_.setUpgradeStatus(task, func() {
+		status.Status = UpgradeStatusRestarting
+	})
+	// 等待节点重启后重新连接，新进程返回本次升级标识后确认升级
+	timeout := time.Now().Add(time.Duration(request.ReconnectTimeout) * time.Millisecond)
+	for time.Now().Before(timeout) {
+		time.Sleep(time.Second)
+		lineNodeIdList = this_.GetNodeLineTo(status.NodeId)
+		if len(lineNodeIdList) == 0 {
+			continue
+		}
+		info, err = server.GetUpgradeInfo(lineNodeIdList)
+		if err != nil || info.UpgradeId != upgradeRequest.Id {
+			continue
+		}
+		err = server.UpgradeConfirm(lineNodeIdList)
+		return
+	}
+	err = fmt.Errorf("节点升级后 %d 秒内未重新连接，节点将自动回滚", request.ReconnectTimeout/1000)
+	return
+}
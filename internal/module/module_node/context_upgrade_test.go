@@ -0,0 +1,25 @@
+package module_node
+
+import "testing"
+
+func TestGetUpgradeTask(t *testing.T) {
+	nodeContext := &NodeContext{
+		upgradeTaskCache: make(map[string]*NodeUpgradeTask),
+	}
+	nodeContext.addUpgradeTask(&NodeUpgradeTask{
+		TaskId:   "task-1",
+		NodeList: []*NodeUpgradeStatus{{}},
+		userId:   1,
+	})
+
+	task := nodeContext.GetUpgradeTask(1, "task-1")
+	if task == nil || task.TaskId != "task-1" || len(task.NodeList) != 1 {
+		t.Fatal("user upgrade task error:", task)
+	}
+	if nodeContext.GetUpgradeTask(2, "task-1") != nil {
+		t.Fatal("other user should not get upgrade task")
+	}
+	if nodeContext.GetUpgradeTask(1, "task-2") != nil {
+		t.Fatal("not exist upgrade task should be nil")
+	}
+}
@@ -0,0 +1,387 @@
+package module_node
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/pkg/sftp"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"teamide/pkg/ssh"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+var (
+	// DefaultDeployDir 默认部署目录，相对 SSH 用户目录
+	DefaultDeployDir = "teamide-node"
+	// DefaultDeployPort 默认节点监听端口
+	DefaultDeployPort = 21091
+	// DeployConnectTimeout 部署后等待节点连接的时间
+	DeployConnectTimeout = 30 * time.Second
+
+	// deployServerIdRegexp 节点ID 用于 systemd 服务名和远程命令，只允许字母、数字、下划线、点和短横线
+	deployServerIdRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+	DeployServiceSystemd = "systemd"
+	DeployServiceNohup   = "nohup"
+
+	deployExecutable = "teamide-node"
+	// deployEnvFile 节点 Token 等敏感配置写入该文件（0600），不出现在命令行以及服务文件中
+	deployEnvFile = "node.env"
+)
+
+// NodeDeployRequest 通过 SSH 部署节点
+type NodeDeployRequest struct {
+	ToolboxId      int64              `json:"toolboxId,omitempty"` // SSH 工具
+	ServerId       string             `json:"serverId,omitempty"`  // 节点ID，为空时自动生成
+	Name           string             `json:"name,omitempty"`
+	Comment        string             `json:"comment,omitempty"`
+	Port           int                `json:"port,omitempty"`           // 节点监听端口
+	ConnHost       string             `json:"connHost,omitempty"`       // 其它节点连接该节点的主机，默认为 SSH 地址的主机
+	Dir            string             `json:"dir,omitempty"`            // 部署目录
+	ServiceType    string             `json:"serviceType,omitempty"`    // systemd、nohup，为空时 root 用户且有 systemctl 时使用 systemd
+	ParentServerId string             `json:"parentServerId,omitempty"` // 由父节点连接该节点
+	ToServerId     string             `json:"toServerId,omitempty"`     // 该节点连接的节点，用于节点无法被连接的网络
+	FileList       []*NodeUpgradeFile `json:"fileList,omitempty"`       // 节点程序，按系统、架构选择
+}
+
+// NodeDeployResponse 部署结果
+type NodeDeployResponse struct {
+	Node        *NodeModel `json:"node,omitempty"`
+	Os          string     `json:"os,omitempty"`
+	Arch        string     `json:"arch,omitempty"`
+	Dir         string     `json:"dir,omitempty"`
+	ServiceType string     `json:"serviceType,omitempty"`
+	IsConnected bool       `json:"isConnected"`
+}
+
+// getUnameOsArch uname -sm 输出转换为 GOOS、GOARCH
+func getUnameOsArch(uname string) (goos string, goarch string) {
+	fields := strings.Fields(uname)
+	if len(fields) < 2 {
+		return
+	}
+	goos = strings.ToLower(fields[0])
+	switch fields[1] {
+	case "x86_64", "amd64":
+		goarch = "amd64"
+	case "aarch64", "arm64":
+		goarch = "arm64"
+	case "i386", "i686":
+		goarch = "386"
+	case "armv7l", "armv6l":
+		goarch = "arm"
+	default:
+		goarch = fields[1]
+	}
+	return
+}
+
+func getNodeFile(fileList []*NodeUpgradeFile, goos string, goarch string) (file *NodeUpgradeFile) {
+	for _, one := range fileList {
+		if one.Os == goos && one.Arch == goarch {
+			file = one
+			return
+		}
+	}
+	return
+}
+
+func shellQuote(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", `'\''`) + "'"
+}
+
+func runSSHCommand(client *gossh.Client, command string) (out string, err error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return
+	}
+	defer func() { _ = session.Close() }()
+
+	bs, err := session.CombinedOutput(command)
+	out = strings.TrimSpace(string(bs))
+	if err != nil {
+		err = fmt.Errorf("执行[%s]失败:%s %s", command, err.Error(), out)
+		return
+	}
+	return
+}
+
+func writeSFTPFile(sftpClient *sftp.Client, path string, reader io.Reader, mode os.FileMode) (err error) {
+	// 先写入临时文件再重命名，节点程序运行中时不能直接覆盖
+	tmpPath := path + ".tmp"
+	f, err := sftpClient.Create(tmpPath)
+	if err != nil {
+		return
+	}
+	// 写入内容前设置权限，敏感文件不会有可被其它用户读取的时刻
+	if err = f.Chmod(mode); err != nil {
+		_ = f.Close()
+		return
+	}
+	_, err = io.Copy(f, reader)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return
+	}
+	if err = sftpClient.PosixRename(tmpPath, path); err != nil {
+		_ = sftpClient.Remove(path)
+		err = sftpClient.Rename(tmpPath, path)
+	}
+	return
+}
+
+// getDeployEnv 节点 Token 通过环境变量传入节点程序，可以被 systemd EnvironmentFile 以及 sh 读取
+func getDeployEnv(token string, connToken string) (env string, err error) {
+	for _, value := range []string{token, connToken} {
+		if strings.ContainsAny(value, "'\r\n") {
+			err = errors.New("Token 不能包含单引号以及换行")
+			return
+		}
+	}
+	env = "TEAMIDE_NODE_TOKEN='" + token + "'\n"
+	if connToken != "" {
+		env += "TEAMIDE_NODE_CONN_TOKEN='" + connToken + "'\n"
+	}
+	return
+}
+
+func getDeploySystemdUnit(serverId string, dir string, args string) string {
+	return `[Unit]
+Description=Team IDE Node ` + serverId + `
+After=network.target
+
+[Service]
+Type=simple
+WorkingDirectory=` + dir + `
+EnvironmentFile=` + dir + `/` + deployEnvFile + `
+ExecStart=` + dir + `/` + deployExecutable + ` ` + args + `
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`
+}
+
+func getDeployNohupScript(args string) string {
+	return `#!/bin/sh
+cd "$(dirname "$0")"
+
+start() {
+    mkdir -p log
+    set -a
+    . ./` + deployEnvFile + `
+    set +a
+    nohup ./` + deployExecutable + ` ` + args + ` > log/start.log 2>&1 < /dev/null &
+    echo $! > node.pid
+}
+
+stop() {
+    if [ -f node.pid ]; then
+        kill "$(cat node.pid)" 2>/dev/null
+        rm -f node.pid
+    fi
+}
+
+case $1 in
+"start")
+    start
+    ;;
+"stop")
+    stop
+    ;;
+"restart")
+    stop && sleep 1 && start
+    ;;
+"status")
+    [ -f node.pid ] && kill -0 "$(cat node.pid)"
+    ;;
+*)
+    echo "请输入: start, stop, restart, status"
+    ;;
+esac
+`
+}
+
+// Deploy 通过 SSH 上传节点程序并启动，然后注册节点
+func (this_ *NodeApi) Deploy(userId int64, sshConfig *ssh.Config, request *NodeDeployRequest) (response *NodeDeployResponse, err error) {
+	if len(request.FileList) == 0 {
+		err = errors.New("节点程序不能为空")
+		return
+	}
+	if request.ParentServerId == "" && request.ToServerId == "" {
+		err = errors.New("需要关联节点")
+		return
+	}
+	var toNodeModel *NodeModel
+	if request.ToServerId != "" {
+		toNodeModel = this_.NodeService.nodeContext.getNodeModelByServerId(request.ToServerId)
+		if toNodeModel == nil {
+			err = errors.New("连接节点[" + request.ToServerId + "]不存在")
+			return
+		}
+	}
+	if request.ServerId == "" {
+		request.ServerId = "node-" + util.GetUUID()[0:8]
+	}
+	if !deployServerIdRegexp.MatchString(request.ServerId) {
+		err = errors.New("节点ID[" + request.ServerId + "]只能包含字母、数字、下划线、点和短横线")
+		return
+	}
+	if request.Name == "" {
+		request.Name = request.ServerId
+	}
+	if request.Port <= 0 {
+		request.Port = DefaultDeployPort
+	}
+	if request.Dir == "" {
+		request.Dir = DefaultDeployDir
+	}
+	if request.ConnHost == "" {
+		request.ConnHost, _, err = net.SplitHostPort(sshConfig.Address)
+		if err != nil {
+			return
+		}
+	}
+	response = &NodeDeployResponse{}
+
+	client, err := ssh.NewClient(*sshConfig)
+	if err != nil {
+		return
+	}
+	defer func() { _ = client.Close() }()
+
+	out, err := runSSHCommand(client, "uname -sm")
+	if err != nil {
+		return
+	}
+	response.Os, response.Arch = getUnameOsArch(out)
+	file := getNodeFile(request.FileList, response.Os, response.Arch)
+	if file == nil {
+		err = fmt.Errorf("没有[%s/%s]的节点程序", response.Os, response.Arch)
+		return
+	}
+	response.Dir, err = runSSHCommand(client, "mkdir -p "+shellQuote(request.Dir)+" && cd "+shellQuote(request.Dir)+" && pwd")
+	if err != nil {
+		return
+	}
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return
+	}
+	defer func() { _ = sftpClient.Close() }()
+
+	localFile, err := os.Open(this_.GetFilesFile(file.Path))
+	if err != nil {
+		return
+	}
+	defer func() { _ = localFile.Close() }()
+	err = writeSFTPFile(sftpClient, response.Dir+"/"+deployExecutable, localFile, 0755)
+	if err != nil {
+		return
+	}
+	this_.Logger.Info("node deploy upload success", zap.Any("serverId", request.ServerId), zap.Any("dir", response.Dir))
+
+	token := util.GetUUID()
+	args := "-id " + shellQuote(request.ServerId) + " -address :" + strconv.Itoa(request.Port)
+	var connToken string
+	if toNodeModel != nil {
+		args += " -connAddress " + shellQuote(toNodeModel.ConnAddress)
+		connToken = toNodeModel.ConnToken
+	}
+	env, err := getDeployEnv(token, connToken)
+	if err != nil {
+		return
+	}
+	err = writeSFTPFile(sftpClient, response.Dir+"/"+deployEnvFile, strings.NewReader(env), 0600)
+	if err != nil {
+		return
+	}
+
+	response.ServiceType = request.ServiceType
+	if response.ServiceType == "" {
+		response.ServiceType = DeployServiceNohup
+		if uid, e := runSSHCommand(client, "id -u"); e == nil && uid == "0" {
+			if _, e = runSSHCommand(client, "command -v systemctl"); e == nil {
+				response.ServiceType = DeployServiceSystemd
+			}
+		}
+	}
+	switch response.ServiceType {
+	case DeployServiceSystemd:
+		serviceName := "teamide-node-" + request.ServerId
+		unit := getDeploySystemdUnit(request.ServerId, response.Dir, args)
+		err = writeSFTPFile(sftpClient, "/etc/systemd/system/"+serviceName+".service", strings.NewReader(unit), 0644)
+		if err != nil {
+			return
+		}
+		_, err = runSSHCommand(client, "systemctl daemon-reload && systemctl enable "+shellQuote(serviceName)+" && systemctl restart "+shellQuote(serviceName))
+		if err != nil {
+			return
+		}
+		time.Sleep(time.Second)
+		_, err = runSSHCommand(client, "systemctl is-active "+shellQuote(serviceName))
+	case DeployServiceNohup:
+		script := response.Dir + "/node_server.sh"
+		err = writeSFTPFile(sftpClient, script, bytes.NewReader([]byte(getDeployNohupScript(args))), 0755)
+		if err != nil {
+			return
+		}
+		_, err = runSSHCommand(client, "sh "+shellQuote(script)+" restart")
+		if err != nil {
+			return
+		}
+		time.Sleep(time.Second)
+		_, err = runSSHCommand(client, "sh "+shellQuote(script)+" status")
+	default:
+		err = errors.New("不支持的启动方式[" + response.ServiceType + "]")
+		return
+	}
+	if err != nil {
+		err = errors.New("节点启动失败，请查看部署目录下的日志:" + err.Error())
+		return
+	}
+	this_.Logger.Info("node deploy start success", zap.Any("serverId", request.ServerId), zap.Any("serviceType", response.ServiceType))
+
+	response.Node, err = this_.insertNode(userId, &InsertRequest{
+		NodeModel: &NodeModel{
+			ServerId:    request.ServerId,
+			Name:        request.Name,
+			Comment:     request.Comment,
+			BindAddress: ":" + strconv.Itoa(request.Port),
+			BindToken:   token,
+			ConnAddress: net.JoinHostPort(request.ConnHost, strconv.Itoa(request.Port)),
+			ConnToken:   token,
+		},
+		ParentServerId: request.ParentServerId,
+		ToServerId:     request.ToServerId,
+	})
+	if err != nil {
+		return
+	}
+
+	// 等待节点连接，连接失败时节点已注册，可修改连接地址后重试
+	timeout := time.Now().Add(DeployConnectTimeout)
+	for time.Now().Before(timeout) && !response.IsConnected {
+		time.Sleep(time.Second)
+		lineNodeIdList := this_.NodeService.nodeContext.GetNodeLineTo(request.ServerId)
+		if len(lineNodeIdList) == 0 {
+			continue
+		}
+		_, e := this_.NodeService.nodeContext.GetServer().GetUpgradeInfo(lineNodeIdList)
+		response.IsConnected = e == nil
+	}
+	return
+}
@@ -0,0 +1,135 @@
+package module_node
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"teamide/pkg/ssh"
+	"testing"
+)
+
+func TestGetUnameOsArch(t *testing.T) {
+	for uname, want := range map[string][2]string{
+		"Linux x86_64":   {"linux", "amd64"},
+		"Linux aarch64":  {"linux", "arm64"},
+		"Darwin arm64":   {"darwin", "arm64"},
+		"Linux i686":     {"linux", "386"},
+		"Linux armv7l":   {"linux", "arm"},
+		"FreeBSD amd64":  {"freebsd", "amd64"},
+		"Linux mips64\n": {"linux", "mips64"},
+		"Linux":          {"", ""},
+		"":               {"", ""},
+	} {
+		goos, goarch := getUnameOsArch(uname)
+		if goos != want[0] || goarch != want[1] {
+			t.Errorf("uname [%s] error: %s/%s", uname, goos, goarch)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell quote test uses sh")
+	}
+	for _, str := range []string{"", "a b", "it's", `"$(id)"; rm -rf /`, "a\nb", `\'`} {
+		out, err := exec.Command("sh", "-c", "printf %s "+shellQuote(str)).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != str {
+			t.Errorf("quote [%s] error: [%s]", str, out)
+		}
+	}
+}
+
+func TestGetDeployEnv(t *testing.T) {
+	if _, err := getDeployEnv("a'b", ""); err == nil {
+		t.Fatal("token with quote should fail")
+	}
+	if _, err := getDeployEnv("a", "b\nc"); err == nil {
+		t.Fatal("conn token with newline should fail")
+	}
+
+	env, err := getDeployEnv("token1", "")
+	if err != nil || env != "TEAMIDE_NODE_TOKEN='token1'\n" {
+		t.Fatal("env error:", env, err)
+	}
+	env, err = getDeployEnv("token1", "conn token$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+	// 启动脚本通过 sh 读取
+	dir := t.TempDir()
+	if err = os.WriteFile(filepath.Join(dir, deployEnvFile), []byte(env), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("sh", "-c", `set -a; . ./`+deployEnvFile+`; set +a; sh -c 'printf "%s|%s" "$TEAMIDE_NODE_TOKEN" "$TEAMIDE_NODE_CONN_TOKEN"'`)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "token1|conn token$" {
+		t.Fatal("env read error:", string(out))
+	}
+}
+
+func TestGetDeploySystemdUnit(t *testing.T) {
+	unit := getDeploySystemdUnit("node1", "/opt/teamide-node", "-id 'node1' -address :21091")
+	for _, line := range []string{
+		"Description=Team IDE Node node1",
+		"WorkingDirectory=/opt/teamide-node",
+		"EnvironmentFile=/opt/teamide-node/" + deployEnvFile,
+		"ExecStart=/opt/teamide-node/" + deployExecutable + " -id 'node1' -address :21091",
+		"Restart=on-failure",
+	} {
+		if !strings.Contains(unit, line+"\n") {
+			t.Errorf("unit should contain [%s]:\n%s", line, unit)
+		}
+	}
+	if strings.Contains(unit, "token") {
+		t.Fatal("unit should not contain token:\n" + unit)
+	}
+}
+
+func TestGetDeployNohupScript(t *testing.T) {
+	script := getDeployNohupScript("-id 'node1' -address :21091")
+	if !strings.Contains(script, ". ./"+deployEnvFile+"\n") {
+		t.Fatal("script should read env file:\n" + script)
+	}
+	if !strings.Contains(script, "nohup ./"+deployExecutable+" -id 'node1' -address :21091 > log/start.log") {
+		t.Fatal("script command error:\n" + script)
+	}
+	if strings.Contains(script, "token") {
+		t.Fatal("script should not contain token:\n" + script)
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if err := exec.Command("sh", "-n", "-c", script).Run(); err != nil {
+		t.Fatal("script syntax error:", err)
+	}
+}
+
+func TestDeployServerId(t *testing.T) {
+	for _, serverId := range []string{"a/b", "../etc", "a b", "a;reboot", "$(id)", "a\nb", "节点"} {
+		_, err := (&NodeApi{}).Deploy(1, &ssh.Config{}, &NodeDeployRequest{
+			ServerId:       serverId,
+			ParentServerId: "parent",
+			FileList:       []*NodeUpgradeFile{{}},
+		})
+		if err == nil || !strings.HasPrefix(err.Error(), "节点ID") {
+			t.Errorf("server id [%s] should be checked: %v", serverId, err)
+		}
+	}
+	for _, serverId := range []string{"node-1a2b3c4d", "web_01.prod"} {
+		if !deployServerIdRegexp.MatchString(serverId) {
+			t.Errorf("server id [%s] should be allowed", serverId)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package module_node
 import (
 	"errors"
 	"io"
+	"os"
+	"strconv"
 	"teamide/pkg/filework"
 	"teamide/pkg/node"
 )
@@ -50,12 +52,16 @@ func (this_ *fileService) Exist(path string) (exist bool, err error) {
 }
 
 func (this_ *fileService) ExistAndMd5(path string) (exist bool, md5 string, err error) {
-	exist, err = this_.Exist(path)
+	var server *node.Server
+	server, err = this_.getServer()
 	if err != nil {
 		return
 	}
-	if !exist {
-		return
+
+	exist, md5, err = server.FileWorkMd5(this_.nodeLine, path)
+	if errors.Is(err, node.FileResumeNotSupportError) {
+		// 旧版本节点不支持计算 MD5
+		exist, err = server.FileWorkExist(this_.nodeLine, path)
 	}
 	return
 }
@@ -78,7 +84,22 @@ func (this_ *fileService) Write(path string, reader io.Reader, onDo func(readSiz
 		return
 	}
 
-	err = server.FileWorkWrite(this_.nodeLine, path, reader, onDo, callStop)
+	err = server.FileWorkWriteResume(this_.nodeLine, path, getFileUploadKey(reader), reader, onDo, callStop)
+	return
+}
+
+// getFileUploadKey 断点续传的 key 为文件大小，重新上传同一文件时继续上传，继续前节点按已上传部分的 MD5 校验内容是否一致；
+// 无法获取大小时为空
+func getFileUploadKey(reader io.Reader) (key string) {
+	switch one := reader.(type) {
+	case *os.File:
+		stat, err := one.Stat()
+		if err == nil {
+			key = strconv.FormatInt(stat.Size(), 10)
+		}
+	case interface{ Size() int64 }:
+		key = strconv.FormatInt(one.Size(), 10)
+	}
 	return
 }
 
@@ -89,7 +110,7 @@ func (this_ *fileService) Read(path string, writer io.Writer, onDo func(readSize
 		return
 	}
 
-	err = server.FileWorkRead(this_.nodeLine, path, writer, onDo, callStop)
+	err = server.FileWorkReadResume(this_.nodeLine, path, writer, onDo, callStop)
 	return
 }
 
@@ -0,0 +1,36 @@
+package module_node
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetFileUploadKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("12345"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	for _, one := range []struct {
+		reader io.Reader
+		key    string
+	}{
+		{f, "5"},
+		{strings.NewReader("123"), "3"},
+		{io.NewSectionReader(f, 0, 4), "4"},
+		{bytes.NewBufferString("12"), ""},
+	} {
+		if key := getFileUploadKey(one.reader); key != one.key {
+			t.Errorf("upload key error: %s, want %s", key, one.key)
+		}
+	}
+}
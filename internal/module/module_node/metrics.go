@@ -0,0 +1,206 @@
+package module_node
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"teamide/pkg/node"
+	"teamide/pkg/system"
+)
+
+// Prometheus 指标，按节点ID输出节点连接、网络代理、节点服务器监控数据
+
+type metricFamily struct {
+	name       string
+	help       string
+	metricType string
+	sampleList []string
+}
+
+type metricsWriter struct {
+	familyList  []*metricFamily
+	familyCache map[string]*metricFamily
+	lock        sync.Mutex
+}
+
+func newMetricsWriter() *metricsWriter {
+	return &metricsWriter{
+		familyCache: make(map[string]*metricFamily),
+	}
+}
+
+var metricLabelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var metricHelpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// add 添加样本，labels 为名称、值交替的列表
+func (this_ *metricsWriter) add(name string, metricType string, help string, value float64, labels ...string) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	family := this_.familyCache[name]
+	if family == nil {
+		family = &metricFamily{name: name, help: help, metricType: metricType}
+		this_.familyCache[name] = family
+		this_.familyList = append(this_.familyList, family)
+	}
+	sample := name
+	if len(labels) > 0 {
+		var labelList []string
+		for i := 0; i+1 < len(labels); i += 2 {
+			labelList = append(labelList, labels[i]+`="`+metricLabelValueReplacer.Replace(labels[i+1])+`"`)
+		}
+		sample += "{" + strings.Join(labelList, ",") + "}"
+	}
+	sample += " " + strconv.FormatFloat(value, 'g', -1, 64)
+	family.sampleList = append(family.sampleList, sample)
+}
+
+func (this_ *metricsWriter) gauge(name string, help string, value float64, labels ...string) {
+	this_.add(name, "gauge", help, value, labels...)
+}
+
+func (this_ *metricsWriter) counter(name string, help string, value float64, labels ...string) {
+	this_.add(name, "counter", help, value, labels...)
+}
+
+// Bytes Prometheus 文本格式，同一指标的样本连续输出
+func (this_ *metricsWriter) Bytes() []byte {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	buf := &bytes.Buffer{}
+	for _, family := range this_.familyList {
+		buf.WriteString("# HELP " + family.name + " " + metricHelpReplacer.Replace(family.help) + "\n")
+		buf.WriteString("# TYPE " + family.name + " " + family.metricType + "\n")
+		for _, sample := range family.sampleList {
+			buf.WriteString(sample + "\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+func boolMetric(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func writeTransferMetrics(writer *metricsWriter, prefix string, what string, monitorData *node.MonitorData, labels ...string) {
+	if monitorData == nil {
+		return
+	}
+	writer.counter(prefix+"_read_bytes_total", what+"读取字节数", float64(monitorData.ReadSize), labels...)
+	writer.counter(prefix+"_write_bytes_total", what+"写入字节数", float64(monitorData.WriteSize), labels...)
+	writer.counter(prefix+"_read_raw_bytes_total", what+"读取解压后字节数", float64(monitorData.ReadRawSize), labels...)
+	writer.counter(prefix+"_write_raw_bytes_total", what+"写入压缩前字节数", float64(monitorData.WriteRawSize), labels...)
+}
+
+func writeSystemMetrics(writer *metricsWriter, monitorData *system.MonitorData, nodeId string) {
+	if monitorData == nil {
+		return
+	}
+	for i, percent := range monitorData.CpuPercents {
+		writer.gauge("teamide_node_cpu_percent", "节点服务器 CPU 使用率", percent, "node_id", nodeId, "cpu", strconv.Itoa(i))
+	}
+	if memory := monitorData.VirtualMemoryStat; memory != nil {
+		writer.gauge("teamide_node_memory_total_bytes", "节点服务器内存总量", float64(memory.Total), "node_id", nodeId)
+		writer.gauge("teamide_node_memory_used_bytes", "节点服务器内存使用量", float64(memory.Used), "node_id", nodeId)
+		writer.gauge("teamide_node_memory_used_percent", "节点服务器内存使用率", memory.UsedPercent, "node_id", nodeId)
+	}
+	for _, one := range monitorData.NetIOCountersStats {
+		writer.counter("teamide_node_network_receive_bytes_total", "节点服务器网卡接收字节数", float64(one.BytesRecv), "node_id", nodeId, "device", one.Name)
+		writer.counter("teamide_node_network_transmit_bytes_total", "节点服务器网卡发送字节数", float64(one.BytesSent), "node_id", nodeId, "device", one.Name)
+	}
+	for _, one := range monitorData.DiskIOCountersStats {
+		writer.counter("teamide_node_disk_read_bytes_total", "节点服务器磁盘读取字节数", float64(one.ReadBytes), "node_id", nodeId, "device", one.Name)
+		writer.counter("teamide_node_disk_write_bytes_total", "节点服务器磁盘写入字节数", float64(one.WriteBytes), "node_id", nodeId, "device", one.Name)
+	}
+}
+
+// GetMetrics 所有节点、网络代理的指标，只查询已连接的节点，节点之间并发查询
+func (this_ *NodeContext) GetMetrics(ctx context.Context) []byte {
+	writer := newMetricsWriter()
+	server := this_.GetServer()
+	if server == nil {
+		return writer.Bytes()
+	}
+
+	var waitGroup sync.WaitGroup
+	for _, nodeModel := range this_.getNodeModelList() {
+		nodeId := nodeModel.ServerId
+		isUp := nodeModel.Status == node.StatusStarted
+		writer.gauge("teamide_node_up", "节点是否已连接", boolMetric(isUp), "node_id", nodeId, "name", nodeModel.Name)
+		if !isUp {
+			continue
+		}
+		lineNodeIdList := this_.GetNodeLineTo(nodeId)
+		if len(lineNodeIdList) == 0 {
+			continue
+		}
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			writeTransferMetrics(writer, "teamide_node_link", "节点连接", server.GetNodeMonitorDataContext(ctx, lineNodeIdList), "node_id", nodeId)
+			writeSystemMetrics(writer, server.SystemMonitorDataContext(ctx, lineNodeIdList), nodeId)
+		}()
+	}
+
+	for _, netProxyModel := range this_.getNetProxyModelList() {
+		code := netProxyModel.Code
+		innerNodeId := netProxyModel.InnerServerId
+		outerNodeId := netProxyModel.OuterServerId
+		labels := []string{"net_proxy_id", code, "name", netProxyModel.Name, "inner_node_id", innerNodeId, "outer_node_id", outerNodeId}
+		writer.gauge("teamide_net_proxy_inner_up", "网络代理输入端是否已启动", boolMetric(netProxyModel.InnerStatus == node.StatusStarted), labels...)
+		writer.gauge("teamide_net_proxy_outer_up", "网络代理输出端是否已启动", boolMetric(netProxyModel.OuterStatus == node.StatusStarted), labels...)
+		if netProxyModel.InnerStatus == node.StatusStarted {
+			if lineNodeIdList := this_.GetNodeLineTo(innerNodeId); len(lineNodeIdList) > 0 {
+				waitGroup.Add(1)
+				go func() {
+					defer waitGroup.Done()
+					writeTransferMetrics(writer, "teamide_net_proxy_inner", "网络代理输入端", server.GetNetProxyInnerMonitorDataContext(ctx, lineNodeIdList, code), "net_proxy_id", code, "node_id", innerNodeId)
+				}()
+			}
+		}
+		if netProxyModel.OuterStatus == node.StatusStarted {
+			if lineNodeIdList := this_.GetNodeLineTo(outerNodeId); len(lineNodeIdList) > 0 {
+				waitGroup.Add(1)
+				go func() {
+					defer waitGroup.Done()
+					writeTransferMetrics(writer, "teamide_net_proxy_outer", "网络代理输出端", server.GetNetProxyOuterMonitorDataContext(ctx, lineNodeIdList, code), "net_proxy_id", code, "node_id", outerNodeId)
+				}()
+			}
+		}
+	}
+	waitGroup.Wait()
+	return writer.Bytes()
+}
+
+// checkMetricsToken 校验请求头 Authorization: Bearer <Token>，未配置 Token 时拒绝访问
+func checkMetricsToken(metricsToken string, authorization string) bool {
+	if metricsToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(authorization), []byte("Bearer "+metricsToken)) == 1
+}
+
+func (this_ *NodeApi) metrics(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	res = base.HttpNotResponse
+	config := this_.ServerConfig.Node
+	if config == nil || !config.Metrics {
+		c.String(http.StatusNotFound, "节点指标未开启")
+		return
+	}
+	if !checkMetricsToken(config.MetricsToken, c.GetHeader("Authorization")) {
+		c.String(http.StatusUnauthorized, "节点指标 Token 错误")
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", this_.NodeService.nodeContext.GetMetrics(c.Request.Context()))
+	return
+}
@@ -0,0 +1,69 @@
+package module_node
+
+import (
+	"strings"
+	"teamide/pkg/node"
+	"testing"
+)
+
+func TestMetricsWriter(t *testing.T) {
+	writer := newMetricsWriter()
+	writer.gauge("teamide_node_up", "节点是否已连接", 1, "node_id", "node1", "name", `a"b\c`+"\nd")
+	writer.counter("teamide_node_link_read_bytes_total", "读取\\字节数\n", 10)
+	// 同一指标的样本连续输出，HELP、TYPE 只输出一次
+	writer.gauge("teamide_node_up", "节点是否已连接", 0, "node_id", "node2", "name", "n2")
+
+	want := `# HELP teamide_node_up 节点是否已连接
+# TYPE teamide_node_up gauge
+teamide_node_up{node_id="node1",name="a\"b\\c\nd"} 1
+teamide_node_up{node_id="node2",name="n2"} 0
+# HELP teamide_node_link_read_bytes_total 读取\\字节数\n
+# TYPE teamide_node_link_read_bytes_total counter
+teamide_node_link_read_bytes_total 10
+`
+	if got := string(writer.Bytes()); got != want {
+		t.Fatalf("metrics error:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTransferMetrics(t *testing.T) {
+	writer := newMetricsWriter()
+	writeTransferMetrics(writer, "teamide_node_link", "节点连接", nil, "node_id", "node1")
+	if len(writer.Bytes()) != 0 {
+		t.Fatal("nil monitor data should not output")
+	}
+
+	writeTransferMetrics(writer, "teamide_node_link", "节点连接", &node.MonitorData{ReadSize: 1, WriteSize: 2, ReadRawSize: 3, WriteRawSize: 1.5e10}, "node_id", "node1")
+	got := string(writer.Bytes())
+	for _, line := range []string{
+		"# TYPE teamide_node_link_read_bytes_total counter\n",
+		`teamide_node_link_read_bytes_total{node_id="node1"} 1` + "\n",
+		`teamide_node_link_write_bytes_total{node_id="node1"} 2` + "\n",
+		`teamide_node_link_read_raw_bytes_total{node_id="node1"} 3` + "\n",
+		`teamide_node_link_write_raw_bytes_total{node_id="node1"} 1.5e+10` + "\n",
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("metrics should contain [%s]:\n%s", line, got)
+		}
+	}
+}
+
+func TestCheckMetricsToken(t *testing.T) {
+	for _, one := range []struct {
+		token         string
+		authorization string
+		ok            bool
+	}{
+		{"", "", false},
+		{"", "Bearer ", false},
+		{"abc", "", false},
+		{"abc", "Bearer ab", false},
+		{"abc", "Bearer abcd", false},
+		{"abc", "abc", false},
+		{"abc", "Bearer abc", true},
+	} {
+		if checkMetricsToken(one.token, one.authorization) != one.ok {
+			t.Errorf("check token [%s] [%s] should be %v", one.token, one.authorization, one.ok)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package module_node
 
 import (
 	"encoding/json"
+	"teamide/pkg/node"
 	"time"
 )
 
@@ -64,6 +65,20 @@ func (entity *NodeModel) IsLocalNode() bool {
 	return entity.IsLocal == 1
 }
 
+// NodeOption 节点配置，存储在节点 option 中
+type NodeOption struct {
+	BindTLS *node.TLSConfig `json:"bindTLS,omitempty"` // 本地节点服务 TLS 配置
+	ConnTLS *node.TLSConfig `json:"connTLS,omitempty"` // 连接该节点使用的 TLS 配置
+}
+
+func (entity *NodeModel) GetOption() (option *NodeOption) {
+	option = &NodeOption{}
+	if entity.Option != "" {
+		_ = json.Unmarshal([]byte(entity.Option), option)
+	}
+	return
+}
+
 // NetProxyModel 节点网络代理
 type NetProxyModel struct {
 	NetProxyId    int64     `json:"netProxyId,omitempty"`
@@ -93,3 +108,21 @@ type NetProxyModel struct {
 	LineNodeIdList        []string `json:"lineNodeIdList,omitempty"`
 	ReverseLineNodeIdList []string `json:"reverseLineNodeIdList,omitempty"`
 }
+
+// NetProxyOption 网络代理配置，存储在网络代理 option 中
+type NetProxyOption struct {
+	AllowList []*node.NetProxyAllow `json:"allowList,omitempty"` // 动态代理 允许访问的目标地址
+}
+
+func (entity *NetProxyModel) GetOption() (option *NetProxyOption) {
+	option = &NetProxyOption{}
+	if entity.Option != "" {
+		_ = json.Unmarshal([]byte(entity.Option), option)
+	}
+	return
+}
+
+// IsDynamic 是否为动态代理
+func (entity *NetProxyModel) IsDynamic() bool {
+	return entity.InnerType == node.NetProxyTypeDynamic
+}
@@ -1,11 +1,14 @@
 package module_node
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"go.uber.org/zap"
+	"reflect"
 	"strings"
 	"teamide/internal/module/module_id"
+	"teamide/pkg/node"
 	"time"
 )
 
@@ -203,6 +206,18 @@ func (this_ *NodeService) UpdateNetProxy(netProxy *NetProxyModel) (rowsAffected
 
 // UpdateNetProxyOption 更新
 func (this_ *NodeService) UpdateNetProxyOption(netProxy *NetProxyModel) (rowsAffected int64, err error) {
+	if netProxy.Option != "" {
+		var option = &NetProxyOption{}
+		err = json.Unmarshal([]byte(netProxy.Option), option)
+		if err != nil {
+			err = errors.New("网络代理配置格式错误，" + err.Error())
+			return
+		}
+		err = node.CheckNetProxyAllowList(option.AllowList)
+		if err != nil {
+			return
+		}
+	}
 
 	var values []interface{}
 
@@ -227,7 +242,11 @@ func (this_ *NodeService) UpdateNetProxyOption(netProxy *NetProxyModel) (rowsAff
 
 	var find = this_.nodeContext.getNetProxyModel(netProxy.NetProxyId)
 	if find != nil {
+		oldOption := find.GetOption()
 		find.Option = netProxy.Option
+		if !reflect.DeepEqual(oldOption.AllowList, find.GetOption().AllowList) {
+			this_.nodeContext.onUpdateNetProxyModel(find)
+		}
 	}
 	//this_.nodeContext.onUpdateNetProxyModel(node)
 	return
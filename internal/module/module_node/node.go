@@ -297,7 +297,12 @@ func (this_ *NodeService) UpdateOption(node *NodeModel) (rowsAffected int64, err
 
 	var find = this_.nodeContext.getNodeModel(node.NodeId)
 	if find != nil {
+		oldOption := find.GetOption()
 		find.Option = node.Option
+		newOption := find.GetOption()
+		if !oldOption.BindTLS.Equal(newOption.BindTLS) || !oldOption.ConnTLS.Equal(newOption.ConnTLS) {
+			this_.nodeContext.onUpdateNodeModel(find)
+		}
 	}
 	//this_.nodeContext.onUpdateNodeModel(node)
 	return
@@ -158,3 +158,23 @@ func (this_ *terminalService) SystemMonitorData() (res *system.MonitorData, err
 	res = server.SystemMonitorData(this_.nodeLine)
 	return
 }
+
+func (this_ *terminalService) SystemProcessList(request *system.ProcessQueryRequest) (res *system.ProcessQueryResponse, err error) {
+	var server *node.Server
+	server, err = this_.getServer()
+	if err != nil {
+		return
+	}
+	res, err = server.SystemProcessList(this_.nodeLine, request)
+	return
+}
+
+func (this_ *terminalService) SystemProcessSignal(request *system.ProcessSignalRequest) (err error) {
+	var server *node.Server
+	server, err = this_.getServer()
+	if err != nil {
+		return
+	}
+	err = server.SystemProcessSignal(this_.nodeLine, request)
+	return
+}
@@ -91,6 +91,9 @@ func getServiceKey(redisConfig *redis.Config, sshConfig *ssh.Config) (key string
 	if sshConfig != nil {
 		key += "-ssh-" + sshConfig.Address
 		key += "-ssh-" + sshConfig.Username
+		if len(sshConfig.JumpHosts) > 0 {
+			key += "-jump-" + sshConfig.GetJumpAddress()
+		}
 	}
 	return
 }
@@ -99,11 +102,12 @@ func getService(redisConfig *redis.Config, sshConfig *ssh.Config) (res redis.ISe
 	var serviceInfo *base.ServiceInfo
 	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
 		var s redis.IService
+		var releaseSSH = func() {}
 		if sshConfig != nil {
 			var sshClient *goSSH.Client
-			sshClient, err = ssh.NewClient(*sshConfig)
+			sshClient, releaseSSH, err = ssh.GetPoolClient(*sshConfig)
 			if err != nil {
-				util.Logger.Error("getZKService ssh NewClient error", zap.Any("key", key), zap.Error(err))
+				util.Logger.Error("getZKService ssh GetPoolClient error", zap.Any("key", key), zap.Error(err))
 				return
 			}
 			redisConfig.SSHClient = sshClient
@@ -114,6 +118,7 @@ func getService(redisConfig *redis.Config, sshConfig *ssh.Config) (res redis.ISe
 			if s != nil {
 				s.Close()
 			}
+			releaseSSH()
 			return
 		}
 
@@ -123,13 +128,17 @@ func getService(redisConfig *redis.Config, sshConfig *ssh.Config) (res redis.ISe
 			if s != nil {
 				s.Close()
 			}
+			releaseSSH()
 			return
 		}
 		res = &base.ServiceInfo{
 			WaitTime:    10 * 60 * 1000,
 			LastUseTime: util.GetNowMilli(),
 			Service:     s,
-			Stop:        s.Close,
+			Stop: func() {
+				s.Close()
+				releaseSSH()
+			},
 		}
 		return
 	})
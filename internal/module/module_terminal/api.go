@@ -16,18 +16,27 @@ import (
 	"teamide/internal/module/module_toolbox"
 	"teamide/pkg/base"
 	"teamide/pkg/ssh"
+	"teamide/pkg/system"
 	"teamide/pkg/terminal"
 )
 
 type api struct {
 	*WorkerFactory
 	terminalCommandService *TerminalCommandService
+	sshMonitorService      *SSHMonitorService
+	sshForwardService      *SSHForwardService
 }
 
 func NewApi(toolboxService_ *module_toolbox.ToolboxService, nodeService_ *module_node.NodeService) *api {
+	sshMonitorService := NewSSHMonitorService(toolboxService_)
+	sshMonitorService.Start()
+	sshForwardService := NewSSHForwardService(toolboxService_)
+	sshForwardService.Start()
 	return &api{
 		WorkerFactory:          NewWorkerFactory(toolboxService_, nodeService_),
 		terminalCommandService: NewTerminalCommandService(toolboxService_.ServerContext),
+		sshMonitorService:      sshMonitorService,
+		sshForwardService:      sshForwardService,
 	}
 }
 
@@ -46,8 +55,18 @@ var (
 	deleteLog            = base.AppendPower(&base.PowerAction{Action: "deleteLog", Text: "deleteLog", ShouldLogin: true, StandAlone: true, Parent: Power})
 	cleanLog             = base.AppendPower(&base.PowerAction{Action: "cleanLog", Text: "cleanLog", ShouldLogin: true, StandAlone: true, Parent: Power})
 	downloadLog          = base.AppendPower(&base.PowerAction{Action: "downloadLog", Text: "downloadLog", ShouldLogin: true, StandAlone: true, Parent: Power})
+	getRecords           = base.AppendPower(&base.PowerAction{Action: "getRecords", Text: "终端录像查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	playRecord           = base.AppendPower(&base.PowerAction{Action: "playRecord", Text: "终端录像播放", ShouldLogin: true, StandAlone: true, Parent: Power})
+	downloadRecord       = base.AppendPower(&base.PowerAction{Action: "downloadRecord", Text: "终端录像下载", ShouldLogin: true, StandAlone: true, Parent: Power})
+	deleteRecord         = base.AppendPower(&base.PowerAction{Action: "deleteRecord", Text: "终端录像删除", ShouldLogin: true, StandAlone: true, Parent: Power})
 	systemInfo           = base.AppendPower(&base.PowerAction{Action: "system/info", Text: "system", ShouldLogin: true, StandAlone: true, Parent: Power})
 	systemMonitor        = base.AppendPower(&base.PowerAction{Action: "system/monitor", Text: "system", ShouldLogin: true, StandAlone: true, Parent: Power})
+	systemProcess        = base.AppendPower(&base.PowerAction{Action: "system/process", Text: "system", ShouldLogin: true, StandAlone: true, Parent: Power})
+	systemProcessSignal  = base.AppendPower(&base.PowerAction{Action: "system/process/signal", Text: "system", ShouldLogin: true, StandAlone: true, Parent: Power})
+	sshMonitorData       = base.AppendPower(&base.PowerAction{Action: "ssh/monitorData", Text: "SSH服务器监控数据", ShouldLogin: true, StandAlone: true, Parent: Power})
+	sshCleanMonitorData  = base.AppendPower(&base.PowerAction{Action: "ssh/cleanMonitorData", Text: "SSH服务器清理监控数据", ShouldLogin: true, StandAlone: true, Parent: Power})
+	sshForwardStatus     = base.AppendPower(&base.PowerAction{Action: "ssh/forwardStatus", Text: "SSH端口转发状态", ShouldLogin: true, StandAlone: true, Parent: Power})
+	sshForwardReload     = base.AppendPower(&base.PowerAction{Action: "ssh/forwardReload", Text: "SSH端口转发重新加载", ShouldLogin: true, StandAlone: true, Parent: Power})
 
 	command       = base.AppendPower(&base.PowerAction{Action: "command", Text: "命令行", ShouldLogin: true, StandAlone: true, Parent: Power})
 	commandSave   = base.AppendPower(&base.PowerAction{Action: "save", Text: "插入", ShouldLogin: true, StandAlone: true, Parent: command})
@@ -68,8 +87,18 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: deleteLog, Do: this_.deleteLog})
 	apis = append(apis, &base.ApiWorker{Power: cleanLog, Do: this_.cleanLog})
 	apis = append(apis, &base.ApiWorker{Power: downloadLog, Do: this_.downloadLog})
+	apis = append(apis, &base.ApiWorker{Power: getRecords, Do: this_.getRecords})
+	apis = append(apis, &base.ApiWorker{Power: playRecord, Do: this_.playRecord, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: downloadRecord, Do: this_.downloadRecord})
+	apis = append(apis, &base.ApiWorker{Power: deleteRecord, Do: this_.deleteRecord})
 	apis = append(apis, &base.ApiWorker{Power: systemInfo, Do: this_.systemInfo})
 	apis = append(apis, &base.ApiWorker{Power: systemMonitor, Do: this_.systemMonitor, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: systemProcess, Do: this_.systemProcess, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: systemProcessSignal, Do: this_.systemProcessSignal})
+	apis = append(apis, &base.ApiWorker{Power: sshMonitorData, Do: this_.sshMonitorData, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: sshCleanMonitorData, Do: this_.sshCleanMonitorData})
+	apis = append(apis, &base.ApiWorker{Power: sshForwardStatus, Do: this_.sshForwardStatus, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: sshForwardReload, Do: this_.sshForwardReload})
 	apis = append(apis, &base.ApiWorker{Power: commandSave, Do: this_.commandSave, NotRecodeLog: true})
 	apis = append(apis, &base.ApiWorker{Power: commandQuery, Do: this_.commandQuery, NotRecodeLog: true})
 	apis = append(apis, &base.ApiWorker{Power: commandCount, Do: this_.commandCount, NotRecodeLog: true})
@@ -163,6 +192,9 @@ func (this_ *api) websocket(request *base.RequestBean, c *gin.Context) (res inte
 			workerId: workerId,
 			lastUser: c.Query("lastUser"),
 			lastDir:  c.Query("lastDir"),
+
+			record:      c.Query("record") == "true",
+			recordInput: c.Query("recordInput") == "true",
 		},
 		&terminal.Size{
 			Cols: cols,
@@ -210,6 +242,150 @@ func (this_ *api) systemMonitor(_ *base.RequestBean, c *gin.Context) (res interf
 	return
 }
 
+type ProcessRequest struct {
+	Key string `json:"key,omitempty"`
+	*system.ProcessQueryRequest
+}
+
+func (this_ *api) systemProcess(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &ProcessRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	service := this_.GetService(request.Key)
+	if service == nil || service.service == nil {
+		return
+	}
+
+	res, err = service.service.SystemProcessList(request.ProcessQueryRequest)
+	return
+}
+
+type ProcessSignalRequest struct {
+	Key string `json:"key,omitempty"`
+	*system.ProcessSignalRequest
+}
+
+func (this_ *api) systemProcessSignal(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &ProcessSignalRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	service := this_.GetService(request.Key)
+	if service == nil || service.service == nil {
+		return
+	}
+
+	err = service.service.SystemProcessSignal(request.ProcessSignalRequest)
+	return
+}
+
+type SSHMonitorRequest struct {
+	ToolboxId int64 `json:"toolboxId,omitempty"`
+	*system.QueryRequest
+}
+
+// checkSSHToolbox 校验 SSH 工具是否存在以及当前用户是否有权限
+func (this_ *api) checkSSHToolbox(requestBean *base.RequestBean, toolboxId int64) (err error) {
+	toolboxModel, err := this_.toolboxService.Get(toolboxId)
+	if err != nil {
+		return
+	}
+	if toolboxModel == nil || toolboxModel.ToolboxType != "ssh" {
+		err = errors.New("SSH[" + strconv.FormatInt(toolboxId, 10) + "]配置不存在")
+		return
+	}
+	err = this_.toolboxService.CheckToolboxPower(requestBean, toolboxModel)
+	return
+}
+
+// checkRecordPlace 校验录像所属的终端，SSH 终端校验当前用户是否有 SSH 工具的权限，本地和节点终端不区分用户，与打开终端的权限一致；
+// place、placeId 拼接路径前由 getRecordDir 校验
+func (this_ *api) checkRecordPlace(requestBean *base.RequestBean, place string, placeId string) (err error) {
+	switch place {
+	case "ssh":
+		var toolboxId int64
+		toolboxId, err = strconv.ParseInt(placeId, 10, 64)
+		if err != nil {
+			err = errors.New("SSH[" + placeId + "]配置不存在")
+			return
+		}
+		err = this_.checkSSHToolbox(requestBean, toolboxId)
+	case "local", "node":
+	default:
+		err = errors.New("[" + place + "]终端不存在")
+	}
+	return
+}
+
+func (this_ *api) sshMonitorData(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &SSHMonitorRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	err = this_.checkSSHToolbox(requestBean, request.ToolboxId)
+	if err != nil {
+		return
+	}
+
+	res = this_.sshMonitorService.QueryMonitorData(request.ToolboxId, request.QueryRequest)
+	return
+}
+
+func (this_ *api) sshCleanMonitorData(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &SSHMonitorRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	err = this_.checkSSHToolbox(requestBean, request.ToolboxId)
+	if err != nil {
+		return
+	}
+
+	this_.sshMonitorService.CleanMonitorData(request.ToolboxId)
+	return
+}
+
+type SSHForwardRequest struct {
+	ToolboxId int64 `json:"toolboxId,omitempty"`
+}
+
+func (this_ *api) sshForwardStatus(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &SSHForwardRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	err = this_.checkSSHToolbox(requestBean, request.ToolboxId)
+	if err != nil {
+		return
+	}
+
+	res = this_.sshForwardService.QueryForwardStatus(request.ToolboxId)
+	return
+}
+
+// sshForwardReload 修改端口转发配置后立即生效，无需等待后台检查
+func (this_ *api) sshForwardReload(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &SSHForwardRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	err = this_.checkSSHToolbox(requestBean, request.ToolboxId)
+	if err != nil {
+		return
+	}
+
+	this_.sshForwardService.Check()
+	res = this_.sshForwardService.QueryForwardStatus(request.ToolboxId)
+	return
+}
+
 func (this_ *api) commandSave(r *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 	request := &TerminalCommandModel{}
 	if !base.RequestJSON(request, c) {
@@ -357,12 +533,13 @@ type Request struct {
 	PlaceId  string `json:"placeId,omitempty"`
 	Key      string `json:"key,omitempty"`
 	WorkerId string `json:"workerId"`
+	RecordId string `json:"recordId,omitempty"`
 	LastUser string `json:"lastUser,omitempty"`
 	LastDir  string `json:"lastDir,omitempty"`
 	*terminal.Size
 }
 
-func (this_ *api) check(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+func (this_ *api) check(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 	request := &module_toolbox.ToolboxModel{}
 	if !base.RequestJSON(request, c) {
 		return
@@ -373,8 +550,12 @@ func (this_ *api) check(_ *base.RequestBean, c *gin.Context) (res interface{}, e
 		return
 	}
 
+	var userId int64
+	if requestBean.JWT != nil {
+		userId = requestBean.JWT.UserId
+	}
 	var config *ssh.Config
-	config, err = this_.toolboxService.GetSSHConfig(request.Option)
+	config, err = this_.toolboxService.GetSSHConfig(request.Option, userId)
 	if err != nil {
 		return
 	}
@@ -401,7 +582,7 @@ func (this_ *api) changeSize(_ *base.RequestBean, c *gin.Context) (res interface
 	}
 	service := this_.GetService(request.Key)
 	if service != nil {
-		err = service.service.ChangeSize(request.Size)
+		err = service.changeSize(request.Size)
 	}
 	return
 }
@@ -487,3 +668,116 @@ func (this_ *api) downloadLog(_ *base.RequestBean, c *gin.Context) (res interfac
 	c.Status(http.StatusOK)
 	return
 }
+
+func (this_ *api) getRecords(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &Request{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	err = this_.checkRecordPlace(requestBean, request.Place, request.PlaceId)
+	if err != nil {
+		return
+	}
+
+	res, err = this_.WorkerFactory.getRecords(request.Place, request.PlaceId)
+	return
+}
+
+// playRecord 输出 asciicast 录像用于播放，参数在 URL 中，follow=true 时正在录制的录像持续输出直到录制结束
+func (this_ *api) playRecord(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	err = this_.checkRecordPlace(requestBean, c.Query("place"), c.Query("placeId"))
+	if err != nil {
+		return
+	}
+	path, err := this_.WorkerFactory.getRecordPath(c.Query("place"), c.Query("placeId"), c.Query("workerId"), c.Query("recordId"))
+	if err != nil {
+		return
+	}
+	if ex, _ := util.PathExists(path); !ex {
+		err = errors.New("录像不存在")
+		return
+	}
+	res = base.HttpNotResponse
+
+	c.Header("Content-Type", "application/x-asciicast")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+
+	ctx := c.Request.Context()
+	err = this_.WorkerFactory.copyRecord(path, c.Writer, c.Query("follow") == "true", c.Writer.Flush, func() bool {
+		return ctx.Err() != nil
+	})
+	if err != nil {
+		this_.Logger.Error("play record error", zap.Error(err))
+		err = nil
+	}
+	return
+}
+
+func (this_ *api) downloadRecord(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Transfer-Encoding", "binary")
+
+	res = base.HttpNotResponse
+	defer func() {
+		if err != nil {
+			_, _ = c.Writer.WriteString(err.Error())
+		}
+	}()
+
+	request := map[string]string{}
+
+	err = c.Bind(&request)
+	if err != nil {
+		return
+	}
+
+	err = this_.checkRecordPlace(requestBean, request["place"], request["placeId"])
+	if err != nil {
+		return
+	}
+	path, err := this_.WorkerFactory.getRecordPath(request["place"], request["placeId"], request["workerId"], request["recordId"])
+	if err != nil {
+		return
+	}
+	if ex, _ := util.PathExists(path); !ex {
+		err = errors.New("录像不存在")
+		return
+	}
+
+	fileName := request["fileName"]
+	if fileName == "" {
+		fileName = recordFilePrefix + request["recordId"]
+	}
+	fileName += recordFileSuffix
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=utf-8''%s", url.QueryEscape(fileName)))
+	c.Header("download-file-name", fileName)
+
+	err = this_.WorkerFactory.copyRecord(path, c.Writer, false, nil, nil)
+	c.Status(http.StatusOK)
+	return
+}
+
+func (this_ *api) deleteRecord(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &Request{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	err = this_.checkRecordPlace(requestBean, request.Place, request.PlaceId)
+	if err != nil {
+		return
+	}
+
+	path, err := this_.WorkerFactory.getRecordPath(request.Place, request.PlaceId, request.WorkerId, request.RecordId)
+	if err != nil {
+		return
+	}
+	if this_.WorkerFactory.isRecording(path) {
+		err = errors.New("录像正在录制，不能删除")
+		return
+	}
+	if ex, _ := util.PathExists(path); ex {
+		err = os.Remove(path)
+	}
+	return
+}
@@ -0,0 +1,373 @@
+package module_terminal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"teamide/pkg/terminal"
+	"time"
+	"unicode/utf8"
+)
+
+// 终端录像
+//
+// 按 asciicast v2 格式录制终端会话：第一行为头信息，之后每行一个事件 [时间(秒), 类型, 数据]，
+// 类型 o 为输出，i 为输入，r 为窗口大小变更（数据为 列x行），可以使用 asciinema 播放。
+// 录像文件保存在会话日志目录下，文件名为 record-开始时间毫秒.cast。
+
+const (
+	recordFilePrefix = "record-"
+	recordFileSuffix = ".cast"
+)
+
+// castHeader asciicast v2 头信息
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+type castRecorder struct {
+	path        string
+	file        *os.File
+	startTime   time.Time
+	recordInput bool
+	// 输出按 UTF-8 拆分时，不完整的字符留到下一次输出
+	outputRest []byte
+	inputRest  []byte
+	isClosed   bool
+	lock       sync.Mutex
+}
+
+func newCastRecorder(path string, title string, size *terminal.Size, recordInput bool) (recorder *castRecorder, err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	recorder = &castRecorder{
+		path:        path,
+		file:        file,
+		startTime:   time.Now(),
+		recordInput: recordInput,
+	}
+	header := &castHeader{
+		Version:   2,
+		Width:     80,
+		Height:    24,
+		Timestamp: recorder.startTime.Unix(),
+		Title:     title,
+		Env:       map[string]string{"TERM": "xterm"},
+	}
+	if size != nil && size.Cols > 0 && size.Rows > 0 {
+		header.Width = size.Cols
+		header.Height = size.Rows
+	}
+	bs, err := json.Marshal(header)
+	if err != nil {
+		_ = file.Close()
+		return
+	}
+	_, err = file.Write(append(bs, '\n'))
+	if err != nil {
+		_ = file.Close()
+		return
+	}
+	return
+}
+
+func (this_ *castRecorder) Output(bs []byte) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.outputRest = this_.writeData("o", this_.outputRest, bs)
+}
+
+func (this_ *castRecorder) Input(bs []byte) {
+	if !this_.recordInput {
+		return
+	}
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.inputRest = this_.writeData("i", this_.inputRest, bs)
+}
+
+func (this_ *castRecorder) Resize(size *terminal.Size) {
+	if size == nil || size.Cols <= 0 || size.Rows <= 0 {
+		return
+	}
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.writeEvent("r", util.GetStringValue(size.Cols)+"x"+util.GetStringValue(size.Rows))
+}
+
+// writeData 写入完整的 UTF-8 字符，返回末尾不完整的字节
+func (this_ *castRecorder) writeData(code string, rest []byte, bs []byte) []byte {
+	data := append(rest, bs...)
+	end := len(data)
+	// 末尾最多 3 个字节可能是不完整的字符
+	for i := 1; i <= 3 && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				end = len(data) - i
+			}
+			break
+		}
+	}
+	if end > 0 {
+		this_.writeEvent(code, string(data[:end]))
+	}
+	return append([]byte{}, data[end:]...)
+}
+
+func (this_ *castRecorder) writeEvent(code string, data string) {
+	if this_.isClosed {
+		return
+	}
+	elapsed := math.Round(time.Since(this_.startTime).Seconds()*1e6) / 1e6
+	bs, err := json.Marshal([]interface{}{elapsed, code, data})
+	if err != nil {
+		return
+	}
+	_, err = this_.file.Write(append(bs, '\n'))
+	if err != nil {
+		util.Logger.Error("terminal record write error", zap.Error(err))
+		this_.isClosed = true
+		_ = this_.file.Close()
+	}
+}
+
+func (this_ *castRecorder) Close() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.isClosed {
+		return
+	}
+	if len(this_.outputRest) > 0 {
+		this_.writeEvent("o", string(this_.outputRest))
+	}
+	if len(this_.inputRest) > 0 {
+		this_.writeEvent("i", string(this_.inputRest))
+	}
+	this_.isClosed = true
+	_ = this_.file.Close()
+}
+
+// RecordInfo 录像信息
+type RecordInfo struct {
+	PlaceId   string `json:"placeId"`
+	WorkerId  string `json:"workerId"`
+	RecordId  string `json:"recordId"`
+	Size      int64  `json:"size"`
+	StartTime int64  `json:"startTime,omitempty"`
+	ModTime   int64  `json:"modTime,omitempty"`
+	Recording bool   `json:"recording"` // 正在录制
+}
+
+// checkRecordName 会话 ID 和录像 ID 用于拼接路径，不能包含路径分隔符
+func checkRecordName(name string) (err error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		err = errors.New("名称[" + name + "]不合法")
+	}
+	return
+}
+
+// getRecordDir 终端的会话日志目录，本地终端的 placeId 可以为空
+func (this_ *WorkerFactory) getRecordDir(place string, placeId string) (dir string, err error) {
+	if err = checkRecordName(place); err != nil {
+		return
+	}
+	if placeId != "" || place != "local" {
+		if err = checkRecordName(placeId); err != nil {
+			return
+		}
+	}
+	dir = this_.getParentDir(place, placeId)
+	return
+}
+
+func (this_ *WorkerFactory) getRecordPath(place string, placeId string, workerId string, recordId string) (path string, err error) {
+	if err = checkRecordName(workerId); err != nil {
+		return
+	}
+	if err = checkRecordName(recordId); err != nil {
+		return
+	}
+	dir, err := this_.getRecordDir(place, placeId)
+	if err != nil {
+		return
+	}
+	path = dir + workerId + "/" + recordFilePrefix + recordId + recordFileSuffix
+	return
+}
+
+// startRecord 开始录制，录像 ID 为开始时间毫秒
+func (this_ *Worker) startRecord(size *terminal.Size, recordInput bool) (err error) {
+	if this_.dir == "" {
+		err = errors.New("会话目录创建失败")
+		return
+	}
+	recordId := util.GetStringValue(util.GetNowMilli())
+	path, err := this_.getRecordPath(this_.place, this_.placeId, this_.workerId, recordId)
+	if err != nil {
+		return
+	}
+	recorder, err := newCastRecorder(path, this_.place+"-"+this_.placeId, size, recordInput)
+	if err != nil {
+		return
+	}
+	this_.recorder = recorder
+
+	this_.recordingCacheLock.Lock()
+	this_.recordingCache[path] = recorder
+	this_.recordingCacheLock.Unlock()
+	return
+}
+
+func (this_ *Worker) stopRecord() {
+	if this_.recorder == nil {
+		return
+	}
+	this_.recorder.Close()
+
+	this_.recordingCacheLock.Lock()
+	delete(this_.recordingCache, this_.recorder.path)
+	this_.recordingCacheLock.Unlock()
+}
+
+// recordOutput 录制输出，rz、sz 传输的数据不录制
+func (this_ *Worker) recordOutput(bs []byte) {
+	if this_.recorder == nil || this_.isRz || this_.isSz || bytes.Contains(bs, sshRZCtrlStart) {
+		return
+	}
+	this_.recorder.Output(bs)
+}
+
+func (this_ *Worker) recordInput(bs []byte) {
+	if this_.recorder == nil || bytes.Contains(bs, sshRZCtrlStart) {
+		return
+	}
+	this_.recorder.Input(bs)
+}
+
+// changeSize 终端窗口大小变更，录制时记录
+func (this_ *Worker) changeSize(size *terminal.Size) (err error) {
+	err = this_.service.ChangeSize(size)
+	if err != nil {
+		return
+	}
+	if this_.recorder != nil {
+		this_.recorder.Resize(size)
+	}
+	return
+}
+
+func (this_ *WorkerFactory) isRecording(path string) bool {
+	this_.recordingCacheLock.Lock()
+	defer this_.recordingCacheLock.Unlock()
+
+	return this_.recordingCache[path] != nil
+}
+
+// getRecords 查询录像，按开始时间倒序
+func (this_ *WorkerFactory) getRecords(place string, placeId string) (records []*RecordInfo, err error) {
+	parentDir, err := this_.getRecordDir(place, placeId)
+	if err != nil {
+		return
+	}
+
+	ex, _ := util.PathExists(parentDir)
+	if !ex {
+		return
+	}
+
+	dirList, err := os.ReadDir(parentDir)
+	if err != nil {
+		return
+	}
+	for _, dir := range dirList {
+		if !dir.IsDir() {
+			continue
+		}
+		fileList, _ := os.ReadDir(parentDir + dir.Name())
+		for _, f := range fileList {
+			name := f.Name()
+			if f.IsDir() || !strings.HasPrefix(name, recordFilePrefix) || !strings.HasSuffix(name, recordFileSuffix) {
+				continue
+			}
+			stat, e := f.Info()
+			if e != nil {
+				continue
+			}
+			recordId := strings.TrimSuffix(strings.TrimPrefix(name, recordFilePrefix), recordFileSuffix)
+			record := &RecordInfo{
+				PlaceId:   placeId,
+				WorkerId:  dir.Name(),
+				RecordId:  recordId,
+				Size:      stat.Size(),
+				StartTime: util.StringToInt64(recordId),
+				ModTime:   util.GetMilliByTime(stat.ModTime()),
+				Recording: this_.isRecording(parentDir + dir.Name() + "/" + name),
+			}
+			records = append(records, record)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].StartTime > records[j].StartTime
+	})
+	return
+}
+
+// copyRecord 输出录像，follow 为 true 并且正在录制时，持续输出新的事件直到录制结束或 stop 返回 true
+func (this_ *WorkerFactory) copyRecord(path string, writer io.Writer, follow bool, flush func(), stop func() bool) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	var buf = make([]byte, 32*1024)
+	for {
+		var n int
+		n, err = f.Read(buf)
+		if n > 0 {
+			if _, err = writer.Write(buf[:n]); err != nil {
+				return
+			}
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return
+		}
+		err = nil
+		if !follow {
+			return
+		}
+		// 录制结束后再读取一次，输出结束前写入的事件
+		if !this_.isRecording(path) {
+			follow = false
+			continue
+		}
+		if flush != nil {
+			flush()
+		}
+		if stop != nil && stop() {
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
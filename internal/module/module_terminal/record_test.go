@@ -0,0 +1,151 @@
+package module_terminal
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"teamide/pkg/terminal"
+	"testing"
+	"time"
+)
+
+// readCastFile 读取录像的头信息和事件
+func readCastFile(t *testing.T, path string) (header *castHeader, events [][]interface{}) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(bs), "\n"), "\n")
+	header = &castHeader{}
+	if err = json.Unmarshal([]byte(lines[0]), header); err != nil {
+		t.Fatal("header error:", err)
+	}
+	for _, line := range lines[1:] {
+		var event []interface{}
+		if err = json.Unmarshal([]byte(line), &event); err != nil || len(event) != 3 {
+			t.Fatal("event error:", line, err)
+		}
+		events = append(events, event)
+	}
+	return
+}
+
+func TestCastRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.cast")
+	recorder, err := newCastRecorder(path, "ssh-1", &terminal.Size{Cols: 100, Rows: 30}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zh := []byte("中")
+	// 不完整的字符留到下一次输出
+	recorder.Output(zh[:2])
+	recorder.Output(append(zh[2:], 'a'))
+	// 未开启时不记录输入
+	recorder.Input([]byte("ls\r"))
+	recorder.Resize(&terminal.Size{Cols: 120, Rows: 40})
+	recorder.Resize(&terminal.Size{})
+	recorder.Output([]byte("b"))
+	recorder.Output(zh[:1])
+	// 关闭时输出剩余的字节
+	recorder.Close()
+	recorder.Output([]byte("closed"))
+
+	header, events := readCastFile(t, path)
+	if header.Version != 2 || header.Width != 100 || header.Height != 30 || header.Title != "ssh-1" || header.Timestamp == 0 {
+		t.Fatalf("header error: %+v", header)
+	}
+	want := [][2]string{{"o", "中a"}, {"r", "120x40"}, {"o", "b"}, {"o", "�"}}
+	if len(events) != len(want) {
+		t.Fatal("events error:", events)
+	}
+	for i, one := range want {
+		if events[i][1] != one[0] || events[i][2] != one[1] {
+			t.Errorf("event %d error: %v", i, events[i])
+		}
+	}
+
+	// 未指定窗口大小时使用默认大小，开启时记录输入
+	path = filepath.Join(t.TempDir(), "record.cast")
+	recorder, err = newCastRecorder(path, "local-", nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.Input([]byte("ls\r"))
+	recorder.Close()
+	header, events = readCastFile(t, path)
+	if header.Width != 80 || header.Height != 24 {
+		t.Fatalf("default size error: %+v", header)
+	}
+	if len(events) != 1 || events[0][1] != "i" || events[0][2] != "ls\r" {
+		t.Fatal("input event error:", events)
+	}
+}
+
+func TestCopyRecord(t *testing.T) {
+	factory := &WorkerFactory{
+		recordingCache: make(map[string]*castRecorder),
+	}
+	path := filepath.Join(t.TempDir(), "record.cast")
+	if err := os.WriteFile(path, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := factory.copyRecord(path, &out, true, nil, nil); err != nil || out.String() != "abc" {
+		t.Fatal("not recording copy error:", out.String(), err)
+	}
+
+	factory.recordingCache[path] = &castRecorder{path: path}
+
+	// 正在录制时 stop 返回 true 后结束
+	out.Reset()
+	if err := factory.copyRecord(path, &out, true, nil, func() bool { return true }); err != nil || out.String() != "abc" {
+		t.Fatal("stop copy error:", out.String(), err)
+	}
+
+	// 正在录制时持续输出，录制结束后输出剩余的内容
+	out.Reset()
+	var flushCount int
+	done := make(chan error, 1)
+	go func() {
+		done <- factory.copyRecord(path, &out, true, func() { flushCount++ }, func() bool { return false })
+	}()
+	time.Sleep(300 * time.Millisecond)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = file.WriteString("def")
+	_ = file.Close()
+
+	factory.recordingCacheLock.Lock()
+	delete(factory.recordingCache, path)
+	factory.recordingCacheLock.Unlock()
+
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("follow copy should end after record stopped")
+	}
+	if err != nil || out.String() != "abcdef" || flushCount == 0 {
+		t.Fatal("follow copy error:", out.String(), flushCount, err)
+	}
+}
+
+func TestGetRecordPath(t *testing.T) {
+	factory := &WorkerFactory{}
+	for _, one := range [][4]string{
+		{"..", "1", "w", "1"},
+		{"ssh", "", "w", "1"},
+		{"ssh", "..", "w", "1"},
+		{"node", "a/b", "w", "1"},
+		{"ssh", "1", "../w", "1"},
+		{"ssh", "1", "w", `..\1`},
+	} {
+		if _, err := factory.getRecordPath(one[0], one[1], one[2], one[3]); err == nil {
+			t.Errorf("record path %v should be checked", one)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package module_terminal
+
+import (
+	"go.uber.org/zap"
+	"sync"
+	"teamide/internal/context"
+	"teamide/internal/module/module_node"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+// SSHForwardCheckInterval 检查 SSH 工具端口转发配置变更的间隔
+var SSHForwardCheckInterval = time.Minute
+
+// NewSSHForwardService 配置了端口转发的 SSH 工具，服务启动后在后台保持转发
+func NewSSHForwardService(toolboxService_ *module_toolbox.ToolboxService) *SSHForwardService {
+	return &SSHForwardService{
+		ServerContext:  toolboxService_.ServerContext,
+		toolboxService: toolboxService_,
+		forwarderCache: make(map[int64]*sshForwarder),
+	}
+}
+
+type SSHForwardService struct {
+	*context.ServerContext
+	toolboxService     *module_toolbox.ToolboxService
+	forwarderCache     map[int64]*sshForwarder
+	forwarderCacheLock sync.Mutex
+	startOnce          sync.Once
+}
+
+type sshForwarder struct {
+	option    string
+	forwarder *ssh.PortForwarder
+}
+
+// Start 启动后台检查，按 SSH 工具配置启动或停止转发
+func (this_ *SSHForwardService) Start() {
+	this_.startOnce.Do(func() {
+		go func() {
+			for {
+				this_.Check()
+				time.Sleep(SSHForwardCheckInterval)
+			}
+		}()
+	})
+}
+
+// Check 检查 SSH 工具配置，配置变更的重新启动，修改配置后调用可以立即生效
+func (this_ *SSHForwardService) Check() {
+	defer func() {
+		if e := recover(); e != nil {
+			this_.Logger.Error("ssh forward check error", zap.Any("error", e))
+		}
+	}()
+
+	list, err := this_.toolboxService.QueryByType("ssh")
+	if err != nil {
+		return
+	}
+	var optionCache = make(map[int64]string)
+	for _, one := range list {
+		if one.Option == "" {
+			continue
+		}
+		config, e := this_.toolboxService.GetSSHConfig(one.Option, one.UserId)
+		if e != nil || config == nil || !hasOpenForward(config) {
+			continue
+		}
+		// 后台转发不推送确认，主机公钥需要先在终端连接时确认信任
+		config.HostKeyStore = this_.toolboxService.NewKnownHostStore(one.UserId, false)
+		optionCache[one.ToolboxId] = one.Option
+		this_.startForwarder(one.ToolboxId, one.Option, config)
+	}
+
+	this_.forwarderCacheLock.Lock()
+	defer this_.forwarderCacheLock.Unlock()
+
+	for toolboxId, one := range this_.forwarderCache {
+		if _, find := optionCache[toolboxId]; !find {
+			one.forwarder.Stop()
+			delete(this_.forwarderCache, toolboxId)
+		}
+	}
+}
+
+func hasOpenForward(config *ssh.Config) bool {
+	for _, one := range config.Forwards {
+		if one != nil && one.Open {
+			return true
+		}
+	}
+	return false
+}
+
+// startForwarder 启动转发，配置变更时停止后重新启动
+func (this_ *SSHForwardService) startForwarder(toolboxId int64, option string, config *ssh.Config) {
+	this_.forwarderCacheLock.Lock()
+	defer this_.forwarderCacheLock.Unlock()
+
+	one := this_.forwarderCache[toolboxId]
+	if one != nil {
+		if one.option == option {
+			return
+		}
+		one.forwarder.Stop()
+	}
+	one = &sshForwarder{
+		option:    option,
+		forwarder: ssh.NewPortForwarder(config),
+	}
+	this_.forwarderCache[toolboxId] = one
+	one.forwarder.Start()
+}
+
+func (this_ *SSHForwardService) getForwarder(toolboxId int64) (forwarder *ssh.PortForwarder) {
+	this_.forwarderCacheLock.Lock()
+	defer this_.forwarderCacheLock.Unlock()
+
+	if one := this_.forwarderCache[toolboxId]; one != nil {
+		forwarder = one.forwarder
+	}
+	return
+}
+
+type SSHForwardStatusResponse struct {
+	Open        bool                `json:"open"`
+	LastError   string              `json:"lastError,omitempty"`
+	ForwardList []*SSHForwardStatus `json:"forwardList,omitempty"`
+}
+
+// SSHForwardStatus 端口转发状态，统计数据格式与节点代理一致
+type SSHForwardStatus struct {
+	*ssh.ForwardConfig
+	Status      int8                           `json:"status"`
+	StatusError string                         `json:"statusError,omitempty"`
+	ConnCount   int64                          `json:"connCount"`
+	MonitorData *module_node.MonitorDataFormat `json:"monitorData,omitempty"`
+}
+
+// QueryForwardStatus 查询端口转发状态，没有开启的转发时 Open 为 false
+func (this_ *SSHForwardService) QueryForwardStatus(toolboxId int64) (res *SSHForwardStatusResponse) {
+	res = &SSHForwardStatusResponse{}
+	forwarder := this_.getForwarder(toolboxId)
+	if forwarder == nil {
+		return
+	}
+	res.Open = true
+	res.LastError = forwarder.GetLastError()
+	for _, one := range forwarder.GetStatusList() {
+		res.ForwardList = append(res.ForwardList, &SSHForwardStatus{
+			ForwardConfig: one.ForwardConfig,
+			Status:        one.Status,
+			StatusError:   one.StatusError,
+			ConnCount:     one.ConnCount,
+			MonitorData:   module_node.ToMonitorDataFormat(one.MonitorData),
+		})
+	}
+	return
+}
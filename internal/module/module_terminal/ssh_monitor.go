@@ -0,0 +1,156 @@
+package module_terminal
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"path/filepath"
+	"sync"
+	"teamide/internal/context"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/ssh"
+	"teamide/pkg/system"
+	"time"
+)
+
+// SSHMonitorCheckInterval 检查 SSH 工具配置变更的间隔
+var SSHMonitorCheckInterval = time.Minute
+
+// NewSSHMonitorService 开启了后台采集的 SSH 工具，无需安装节点即可采集服务器监控数据
+func NewSSHMonitorService(toolboxService_ *module_toolbox.ToolboxService) *SSHMonitorService {
+	return &SSHMonitorService{
+		ServerContext:  toolboxService_.ServerContext,
+		toolboxService: toolboxService_,
+		monitorCache:   make(map[int64]*sshMonitor),
+	}
+}
+
+type SSHMonitorService struct {
+	*context.ServerContext
+	toolboxService   *module_toolbox.ToolboxService
+	monitorCache     map[int64]*sshMonitor
+	monitorCacheLock sync.Mutex
+	startOnce        sync.Once
+}
+
+type sshMonitor struct {
+	option    string
+	collector *ssh.MonitorCollector
+	store     *system.MonitorStore
+}
+
+// Start 启动后台检查，按 SSH 工具配置启动或停止采集
+func (this_ *SSHMonitorService) Start() {
+	this_.startOnce.Do(func() {
+		go func() {
+			for {
+				this_.check()
+				time.Sleep(SSHMonitorCheckInterval)
+			}
+		}()
+	})
+}
+
+func (this_ *SSHMonitorService) check() {
+	defer func() {
+		if e := recover(); e != nil {
+			this_.Logger.Error("ssh monitor check error", zap.Any("error", e))
+		}
+	}()
+
+	list, err := this_.toolboxService.QueryByType("ssh")
+	if err != nil {
+		return
+	}
+	var optionCache = make(map[int64]string)
+	for _, one := range list {
+		if one.Option == "" {
+			continue
+		}
+		config, e := this_.toolboxService.GetSSHConfig(one.Option, one.UserId)
+		if e != nil || config == nil || !config.MonitorOpen {
+			continue
+		}
+		// 后台采集不推送确认，主机公钥需要先在终端连接时确认信任
+		config.HostKeyStore = this_.toolboxService.NewKnownHostStore(one.UserId, false)
+		optionCache[one.ToolboxId] = one.Option
+		this_.startMonitor(one.ToolboxId, one.Option, config)
+	}
+
+	this_.monitorCacheLock.Lock()
+	defer this_.monitorCacheLock.Unlock()
+
+	for toolboxId, monitor := range this_.monitorCache {
+		if _, find := optionCache[toolboxId]; !find {
+			monitor.collector.Stop()
+			delete(this_.monitorCache, toolboxId)
+		}
+	}
+}
+
+// startMonitor 启动采集，配置变更时重新连接，已采集的数据保留
+func (this_ *SSHMonitorService) startMonitor(toolboxId int64, option string, config *ssh.Config) {
+	this_.monitorCacheLock.Lock()
+	defer this_.monitorCacheLock.Unlock()
+
+	monitor := this_.monitorCache[toolboxId]
+	if monitor != nil {
+		if monitor.option == option {
+			return
+		}
+		monitor.collector.Stop()
+	} else {
+		monitor = &sshMonitor{
+			store: system.NewMonitorStore(),
+		}
+		if this_.ServerConfig.Terminal != nil && this_.ServerConfig.Terminal.SSHMonitorDataSaveDays > 0 {
+			dir := filepath.Join(this_.ServerConfig.Server.Data, "ssh", "monitor", fmt.Sprint(toolboxId))
+			if e := monitor.store.SetHistory(dir, this_.ServerConfig.Terminal.SSHMonitorDataSaveDays); e != nil {
+				this_.Logger.Error("SSH监控数据持久化目录创建异常", zap.Any("dir", dir), zap.Error(e))
+			}
+		}
+		this_.monitorCache[toolboxId] = monitor
+	}
+	monitor.option = option
+	monitor.collector = ssh.NewMonitorCollector(config, monitor.store)
+	monitor.collector.Start()
+}
+
+func (this_ *SSHMonitorService) getMonitor(toolboxId int64) (store *system.MonitorStore, collector *ssh.MonitorCollector) {
+	this_.monitorCacheLock.Lock()
+	defer this_.monitorCacheLock.Unlock()
+
+	if monitor := this_.monitorCache[toolboxId]; monitor != nil {
+		store = monitor.store
+		collector = monitor.collector
+	}
+	return
+}
+
+type SSHMonitorDataResponse struct {
+	*system.QueryResponse
+	Open      bool   `json:"open"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// QueryMonitorData 查询监控数据，格式与节点监控数据一致，未开启采集时 Open 为 false
+func (this_ *SSHMonitorService) QueryMonitorData(toolboxId int64, request *system.QueryRequest) (res *SSHMonitorDataResponse) {
+	res = &SSHMonitorDataResponse{}
+	store, collector := this_.getMonitor(toolboxId)
+	if store == nil {
+		res.QueryResponse = &system.QueryResponse{}
+		return
+	}
+	res.Open = true
+	res.QueryResponse = store.Query(request)
+	res.LastError = collector.GetLastError()
+	return
+}
+
+// CleanMonitorData 清理监控数据
+func (this_ *SSHMonitorService) CleanMonitorData(toolboxId int64) {
+	store, _ := this_.getMonitor(toolboxId)
+	if store == nil {
+		return
+	}
+	store.Clean()
+}
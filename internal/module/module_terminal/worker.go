@@ -30,6 +30,8 @@ func NewWorkerFactory(toolboxService_ *module_toolbox.ToolboxService, nodeServic
 		toolboxService: toolboxService_,
 		nodeService:    nodeService_,
 		workerCache:    make(map[string]*Worker),
+		workerStarting: make(map[string]bool),
+		recordingCache: make(map[string]*castRecorder),
 	}
 }
 
@@ -38,7 +40,11 @@ type WorkerFactory struct {
 	toolboxService  *module_toolbox.ToolboxService
 	nodeService     *module_node.NodeService
 	workerCache     map[string]*Worker
+	workerStarting  map[string]bool // 正在连接的会话
 	workerCacheLock sync.Mutex
+	// recordingCache 正在录制的录像，key 为录像文件路径
+	recordingCache     map[string]*castRecorder
+	recordingCacheLock sync.Mutex
 }
 
 func (this_ *WorkerFactory) GetService(key string) (res *Worker) {
@@ -55,6 +61,10 @@ type CreateParam struct {
 	workerId string
 	lastUser string
 	lastDir  string
+	ws       *websocket.Conn // 终端的 WebSocket，连接时在终端中完成键盘交互认证
+
+	record      bool // 录制终端会话
+	recordInput bool // 录像中记录输入
 }
 
 func (this_ *WorkerFactory) createService(param *CreateParam) (worker *Worker, command string, err error) {
@@ -89,12 +99,15 @@ func (this_ *WorkerFactory) createService(param *CreateParam) (worker *Worker, c
 		}
 
 		var config *ssh.Config
-		config, err = this_.toolboxService.GetSSHConfig(tD.Option)
+		config, err = this_.toolboxService.GetSSHConfig(tD.Option, tD.UserId)
 		if err != nil {
 			return
 		}
 		if config != nil {
 			command = config.Command
+			if param.ws != nil {
+				config.KeyboardInteractive = ssh.NewTerminalKeyboardInteractive(&wsTerminal{ws: param.ws})
+			}
 		}
 
 		service = ssh.NewTerminalService(config, param.lastUser, param.lastDir)
@@ -129,16 +142,24 @@ func (this_ *WorkerFactory) Start(key string, param *CreateParam, size *terminal
 		}
 	}()
 
+	// 连接时可能等待用户在终端中完成键盘交互认证或确认主机公钥，在锁外连接，只占用 key
 	this_.workerCacheLock.Lock()
-	defer this_.workerCacheLock.Unlock()
-
-	worker := this_.workerCache[key]
-	if worker != nil {
+	if this_.workerCache[key] != nil || this_.workerStarting[key] {
+		this_.workerCacheLock.Unlock()
 		err = errors.New("会话服务[" + key + "]已存在")
 		return
 	}
+	this_.workerStarting[key] = true
+	this_.workerCacheLock.Unlock()
+	defer func() {
+		this_.workerCacheLock.Lock()
+		delete(this_.workerStarting, key)
+		this_.workerCacheLock.Unlock()
+	}()
+
 	var command string
-	worker, command, err = this_.createService(param)
+	param.ws = ws
+	worker, command, err := this_.createService(param)
 	if err != nil {
 		return
 	}
@@ -152,6 +173,16 @@ func (this_ *WorkerFactory) Start(key string, param *CreateParam, size *terminal
 	if err != nil {
 		return
 	}
+
+	this_.workerCacheLock.Lock()
+	this_.workerCache[key] = worker
+	this_.workerCacheLock.Unlock()
+
+	if param.record {
+		if e := worker.startRecord(size, param.recordInput); e != nil {
+			this_.Logger.Error("terminal start record error", zap.Error(e))
+		}
+	}
 	if command != "" {
 		go func() {
 			command = strings.ReplaceAll(command, "\n\r", "\n")
@@ -183,8 +214,38 @@ func (this_ *WorkerFactory) Start(key string, param *CreateParam, size *terminal
 
 	go worker.startReadWS(isWindow)
 	go worker.startReadService(isWindow)
+	return
+}
 
-	this_.workerCache[key] = worker
+// TerminalPromptTimeout 连接时等待用户在终端中输入的超时时间，超时后关闭连接
+var TerminalPromptTimeout = 60 * time.Second
+
+// wsTerminal 终端开始读取 WebSocket 之前，在终端中输出和读取用户的输入
+type wsTerminal struct {
+	ws  *websocket.Conn
+	buf []byte
+}
+
+func (this_ *wsTerminal) Read(p []byte) (n int, err error) {
+	for len(this_.buf) == 0 {
+		_ = this_.ws.SetReadDeadline(time.Now().Add(TerminalPromptTimeout))
+		_, this_.buf, err = this_.ws.ReadMessage()
+		_ = this_.ws.SetReadDeadline(time.Time{})
+		if err != nil {
+			return
+		}
+	}
+	n = copy(p, this_.buf)
+	this_.buf = this_.buf[n:]
+	return
+}
+
+func (this_ *wsTerminal) Write(p []byte) (n int, err error) {
+	err = this_.ws.WriteMessage(websocket.BinaryMessage, p)
+	if err != nil {
+		return
+	}
+	n = len(p)
 	return
 }
 
@@ -274,6 +335,7 @@ type Worker struct {
 	service        terminal.Service
 	ws             *websocket.Conn
 	commandLogFile *os.File
+	recorder       *castRecorder
 	isRz           bool
 	isSz           bool
 	isLastSzEnd    bool
@@ -449,6 +511,7 @@ func (this_ *Worker) startReadWS(isWindow bool) {
 		if writeErr != nil {
 			break
 		}
+		this_.recordInput(buf)
 		if readErr == io.EOF {
 			readErr = nil
 			break
@@ -505,6 +568,7 @@ func (this_ *Worker) startReadService(isWindow bool) {
 
 		if n > 0 {
 			this_.onServiceRead(buf[:n])
+			this_.recordOutput(buf[:n])
 			writeErr = this_.ws.WriteMessage(websocket.BinaryMessage, buf[:n])
 			if writeErr != nil {
 				break
@@ -572,4 +636,5 @@ func (this_ *Worker) stopAll() {
 	if this_.commandLogFile != nil {
 		_ = this_.commandLogFile.Close()
 	}
+	this_.stopRecord()
 }
@@ -61,6 +61,19 @@ var (
 	extendDelete   = base.AppendPower(&base.PowerAction{Action: "delete", Text: "删除", Parent: extend, ShouldLogin: true, StandAlone: true})
 	extendLoadFile = base.AppendPower(&base.PowerAction{Action: "loadFile", Text: "加载文件", Parent: extend, ShouldLogin: true, StandAlone: true})
 	extendSaveFile = base.AppendPower(&base.PowerAction{Action: "saveFile", Text: "保存文件", Parent: extend, ShouldLogin: true, StandAlone: true})
+
+	knownHost             = base.AppendPower(&base.PowerAction{Action: "knownHost", Text: "SSH已信任主机公钥", Parent: Power, ShouldLogin: true, StandAlone: true})
+	knownHostList         = base.AppendPower(&base.PowerAction{Action: "list", Text: "查询", Parent: knownHost, ShouldLogin: true, StandAlone: true})
+	knownHostImport       = base.AppendPower(&base.PowerAction{Action: "import", Text: "导入", Parent: knownHost, ShouldLogin: true, StandAlone: true})
+	knownHostDelete       = base.AppendPower(&base.PowerAction{Action: "delete", Text: "撤销信任", Parent: knownHost, ShouldLogin: true, StandAlone: true})
+	knownHostConfirm      = base.AppendPower(&base.PowerAction{Action: "confirm", Text: "确认信任", Parent: knownHost, ShouldLogin: true, StandAlone: true})
+	knownHostGlobal       = base.AppendPower(&base.PowerAction{Action: "global", Text: "全局", Parent: knownHost, ShouldLogin: true, StandAlone: true, ShouldPower: true})
+	knownHostGlobalImport = base.AppendPower(&base.PowerAction{Action: "import", Text: "全局导入", Parent: knownHostGlobal, ShouldLogin: true, StandAlone: true, ShouldPower: true})
+	knownHostGlobalDelete = base.AppendPower(&base.PowerAction{Action: "delete", Text: "全局撤销信任", Parent: knownHostGlobal, ShouldLogin: true, StandAlone: true, ShouldPower: true})
+
+	sshConfig        = base.AppendPower(&base.PowerAction{Action: "sshConfig", Text: "SSH配置导入", Parent: Power, ShouldLogin: true, StandAlone: true})
+	sshConfigPreview = base.AppendPower(&base.PowerAction{Action: "preview", Text: "预览", Parent: sshConfig, ShouldLogin: true, StandAlone: true})
+	sshConfigImport  = base.AppendPower(&base.PowerAction{Action: "import", Text: "导入", Parent: sshConfig, ShouldLogin: true, StandAlone: true})
 )
 
 func (this_ *ToolboxApi) GetApis() (apis []*base.ApiWorker) {
@@ -101,6 +114,16 @@ func (this_ *ToolboxApi) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: extendLoadFile, Do: this_.extendLoadFile})
 	apis = append(apis, &base.ApiWorker{Power: extendSaveFile, Do: this_.extendSaveFile})
 
+	apis = append(apis, &base.ApiWorker{Power: knownHostList, Do: this_.knownHostList})
+	apis = append(apis, &base.ApiWorker{Power: knownHostImport, Do: this_.knownHostImport})
+	apis = append(apis, &base.ApiWorker{Power: knownHostDelete, Do: this_.knownHostDelete})
+	apis = append(apis, &base.ApiWorker{Power: knownHostConfirm, Do: this_.knownHostConfirm})
+	apis = append(apis, &base.ApiWorker{Power: knownHostGlobalImport, Do: this_.knownHostGlobalImport})
+	apis = append(apis, &base.ApiWorker{Power: knownHostGlobalDelete, Do: this_.knownHostGlobalDelete})
+
+	apis = append(apis, &base.ApiWorker{Power: sshConfigPreview, Do: this_.sshConfigPreview})
+	apis = append(apis, &base.ApiWorker{Power: sshConfigImport, Do: this_.sshConfigImport})
+
 	return
 }
 
@@ -0,0 +1,103 @@
+package module_toolbox
+
+import (
+	"github.com/gin-gonic/gin"
+	"teamide/pkg/base"
+)
+
+type KnownHostRequest struct {
+	KnownHostId int64  `json:"knownHostId,omitempty"`
+	Text        string `json:"text,omitempty"` // 导入的 known_hosts 内容，导入全局公钥时为空则导入服务端 ~/.ssh/known_hosts
+	ConfirmId   string `json:"confirmId,omitempty"`
+	Trust       bool   `json:"trust,omitempty"`
+}
+
+type KnownHostListResponse struct {
+	KnownHosts []*ToolboxKnownHostModel `json:"knownHosts,omitempty"`
+}
+
+type KnownHostImportResponse struct {
+	Inserted int `json:"inserted"`
+	Skipped  int `json:"skipped"`
+}
+
+func (this_ *ToolboxApi) knownHostList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	response := &KnownHostListResponse{}
+
+	response.KnownHosts, err = this_.ToolboxService.QueryKnownHost(requestBean.JWT.UserId)
+	if err != nil {
+		return
+	}
+
+	res = response
+	return
+}
+
+func (this_ *ToolboxApi) knownHostImport(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &KnownHostRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &KnownHostImportResponse{}
+
+	response.Inserted, response.Skipped, err = this_.ToolboxService.ImportKnownHost(requestBean.JWT.UserId, request.Text)
+	if err != nil {
+		return
+	}
+
+	res = response
+	return
+}
+
+func (this_ *ToolboxApi) knownHostDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &KnownHostRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	_, err = this_.ToolboxService.DeleteKnownHost(request.KnownHostId, requestBean.JWT.UserId)
+	return
+}
+
+func (this_ *ToolboxApi) knownHostConfirm(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &KnownHostRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	err = this_.ToolboxService.ConfirmKnownHost(request.ConfirmId, requestBean.JWT.UserId, request.Trust)
+	return
+}
+
+func (this_ *ToolboxApi) knownHostGlobalImport(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &KnownHostRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &KnownHostImportResponse{}
+
+	// 只有全局导入可以读取服务端文件
+	if request.Text == "" {
+		request.Text, err = ReadServerKnownHosts()
+		if err != nil {
+			return
+		}
+	}
+	response.Inserted, response.Skipped, err = this_.ToolboxService.ImportKnownHost(0, request.Text)
+	if err != nil {
+		return
+	}
+
+	res = response
+	return
+}
+
+func (this_ *ToolboxApi) knownHostGlobalDelete(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &KnownHostRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	_, err = this_.ToolboxService.DeleteKnownHost(request.KnownHostId, 0)
+	return
+}
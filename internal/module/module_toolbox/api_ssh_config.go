@@ -0,0 +1,26 @@
+package module_toolbox
+
+import (
+	"github.com/gin-gonic/gin"
+	"teamide/pkg/base"
+)
+
+func (this_ *ToolboxApi) sshConfigPreview(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &SSHConfigImportRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = this_.ToolboxService.PreviewSSHConfig(requestBean.JWT.UserId, request)
+	return
+}
+
+func (this_ *ToolboxApi) sshConfigImport(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &SSHConfigImportRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = this_.ToolboxService.ImportSSHConfig(requestBean.JWT.UserId, request)
+	return
+}
@@ -100,10 +100,7 @@ func (this_ *ToolboxApi) insert(requestBean *base.RequestBean, c *gin.Context) (
 	}
 	response := &InsertResponse{}
 
-	toolbox := request.ToolboxModel
-	toolbox.UserId = requestBean.JWT.UserId
-
-	_, err = this_.ToolboxService.Insert(toolbox)
+	err = this_.ToolboxService.InsertUserToolbox(requestBean.JWT.UserId, request.ToolboxModel)
 	if err != nil {
 		return
 	}
@@ -319,6 +319,48 @@ CREATE TABLE ` + TableToolboxExtend + ` (
 		},
 
 		/** 给工具箱添加 扩展 结束 **/
+
+		/** 给工具箱添加 SSH已信任主机公钥 开始 **/
+
+		// 创建工具箱 SSH已信任主机公钥 表
+		{
+			Version: "1.0.5",
+			Module:  ModuleToolbox,
+			Stage:   `创建表[` + TableToolboxKnownHost + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableToolboxKnownHost + ` (
+	knownHostId bigint(20) NOT NULL COMMENT 'ID',
+	userId bigint(20) NOT NULL COMMENT '用户ID，0为全局',
+	host varchar(500) NOT NULL COMMENT '主机',
+	keyType varchar(100) NOT NULL COMMENT '公钥类型',
+	publicKey varchar(2000) NOT NULL COMMENT '公钥',
+	fingerprint varchar(100) NOT NULL COMMENT '指纹',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	PRIMARY KEY (knownHostId),
+	KEY index_userId (userId),
+	KEY index_fingerprint (fingerprint)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableToolboxKnownHostComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableToolboxKnownHost + ` (
+	knownHostId bigint(20) NOT NULL,
+	userId bigint(20) NOT NULL,
+	host varchar(500) NOT NULL,
+	keyType varchar(100) NOT NULL,
+	publicKey varchar(2000) NOT NULL,
+	fingerprint varchar(100) NOT NULL,
+	createTime datetime NOT NULL,
+	PRIMARY KEY (knownHostId)
+);
+`,
+					`CREATE INDEX ` + TableToolboxKnownHost + `_index_userId on ` + TableToolboxKnownHost + ` (userId);`,
+					`CREATE INDEX ` + TableToolboxKnownHost + `_index_fingerprint on ` + TableToolboxKnownHost + ` (fingerprint);`,
+				},
+			},
+		},
+
+		/** 给工具箱添加 SSH已信任主机公钥 结束 **/
 	}
 
 }
@@ -25,6 +25,9 @@ const (
 	// TableToolboxExtend 工具箱 扩展
 	TableToolboxExtend        = "TM_TOOLBOX_EXTEND"
 	TableToolboxExtendComment = "工具箱扩展"
+	// TableToolboxKnownHost SSH 已信任的主机公钥
+	TableToolboxKnownHost        = "TM_TOOLBOX_KNOWN_HOST"
+	TableToolboxKnownHostComment = "SSH已信任主机公钥"
 )
 
 // ToolboxModel 工具箱模型，和工具箱表对应
@@ -118,3 +121,14 @@ type ToolboxExtendModel struct {
 
 	Extend map[string]interface{} `json:"extend,omitempty"`
 }
+
+// ToolboxKnownHostModel SSH 已信任的主机公钥，userId 为 0 时为服务端全局信任
+type ToolboxKnownHostModel struct {
+	KnownHostId int64     `json:"knownHostId,omitempty"`
+	UserId      int64     `json:"userId,omitempty"`
+	Host        string    `json:"host,omitempty"`
+	KeyType     string    `json:"keyType,omitempty"`
+	PublicKey   string    `json:"publicKey,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	CreateTime  time.Time `json:"createTime,omitempty"`
+}
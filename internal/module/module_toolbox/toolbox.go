@@ -45,6 +45,19 @@ func (this_ *ToolboxService) Get(toolboxId int64) (res *ToolboxModel, err error)
 	return
 }
 
+// QueryByType 查询 某个类型的所有工具
+func (this_ *ToolboxService) QueryByType(toolboxType string) (res []*ToolboxModel, err error) {
+
+	sql := `SELECT * FROM ` + TableToolbox + ` WHERE deleted=2 AND toolboxType=? `
+	err = this_.DatabaseWorker.Query(sql, []interface{}{toolboxType}, &res)
+	if err != nil {
+		this_.Logger.Error("QueryByType Error", zap.Error(err))
+		return
+	}
+
+	return
+}
+
 var visibilityOpen = 1
 
 // QueryVisibility 查询 可见工具
@@ -153,6 +166,13 @@ func (this_ *ToolboxService) GetUserToolboxByName(toolboxType string, name strin
 	return
 }
 
+// InsertUserToolbox 新增用户的工具，新增工具的接口和导入都使用此方法，配置由 Insert 按工具类型格式化
+func (this_ *ToolboxService) InsertUserToolbox(userId int64, toolbox *ToolboxModel) (err error) {
+	toolbox.UserId = userId
+	_, err = this_.Insert(toolbox)
+	return
+}
+
 // Insert 新增
 func (this_ *ToolboxService) Insert(toolbox *ToolboxModel) (rowsAffected int64, err error) {
 
@@ -0,0 +1,250 @@
+package module_toolbox
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"teamide/internal/context"
+	"teamide/internal/module/module_id"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+// QueryKnownHost 查询用户以及全局已信任的主机公钥
+func (this_ *ToolboxService) QueryKnownHost(userId int64) (res []*ToolboxKnownHostModel, err error) {
+
+	sql := `SELECT * FROM ` + TableToolboxKnownHost + ` WHERE userId=? OR userId=0 ORDER BY host ASC `
+	err = this_.DatabaseWorker.Query(sql, []interface{}{userId}, &res)
+	if err != nil {
+		this_.Logger.Error("QueryKnownHost Error", zap.Error(err))
+		return
+	}
+
+	return
+}
+
+// InsertKnownHost 新增，同一用户下主机和公钥都相同的已存在时不新增
+func (this_ *ToolboxService) InsertKnownHost(knownHost *ToolboxKnownHostModel) (rowsAffected int64, err error) {
+
+	sql := `SELECT COUNT(1) FROM ` + TableToolboxKnownHost + ` WHERE userId=? AND host=? AND publicKey=? `
+	count, err := this_.DatabaseWorker.Count(sql, []interface{}{knownHost.UserId, knownHost.Host, knownHost.PublicKey})
+	if err != nil {
+		this_.Logger.Error("InsertKnownHost Error", zap.Error(err))
+		return
+	}
+	if count > 0 {
+		return
+	}
+
+	if knownHost.KnownHostId == 0 {
+		knownHost.KnownHostId, err = this_.idService.GetNextID(module_id.IDTypeToolboxKnownHost)
+		if err != nil {
+			return
+		}
+	}
+	if knownHost.CreateTime.IsZero() {
+		knownHost.CreateTime = time.Now()
+	}
+
+	sql = `INSERT INTO ` + TableToolboxKnownHost + `(knownHostId, userId, host, keyType, publicKey, fingerprint, createTime) VALUES (?, ?, ?, ?, ?, ?, ?) `
+
+	rowsAffected, err = this_.DatabaseWorker.Exec(sql, []interface{}{knownHost.KnownHostId, knownHost.UserId, knownHost.Host, knownHost.KeyType, knownHost.PublicKey, knownHost.Fingerprint, knownHost.CreateTime})
+	if err != nil {
+		this_.Logger.Error("InsertKnownHost Error", zap.Error(err))
+		return
+	}
+
+	return
+}
+
+// DeleteKnownHost 删除，即撤销信任，userId 为 0 时删除全局的
+func (this_ *ToolboxService) DeleteKnownHost(knownHostId int64, userId int64) (rowsAffected int64, err error) {
+
+	sql := `DELETE FROM ` + TableToolboxKnownHost + ` WHERE knownHostId=? AND userId=? `
+	rowsAffected, err = this_.DatabaseWorker.Exec(sql, []interface{}{knownHostId, userId})
+	if err != nil {
+		this_.Logger.Error("DeleteKnownHost Error", zap.Error(err))
+		return
+	}
+
+	return
+}
+
+// ImportKnownHost 导入 known_hosts 文件内容，text 不能为空；导入服务端 ~/.ssh/known_hosts 时由调用方通过 ReadServerKnownHosts 读取
+func (this_ *ToolboxService) ImportKnownHost(userId int64, text string) (inserted int, skipped int, err error) {
+	if strings.TrimSpace(text) == "" {
+		err = errors.New("known_hosts 内容不能为空")
+		return
+	}
+	list, skipped, err := ssh.ParseKnownHosts(text)
+	if err != nil {
+		return
+	}
+	for _, one := range list {
+		var rowsAffected int64
+		rowsAffected, err = this_.InsertKnownHost(&ToolboxKnownHostModel{
+			UserId:      userId,
+			Host:        one.Host,
+			KeyType:     one.KeyType,
+			PublicKey:   one.PublicKey,
+			Fingerprint: one.Fingerprint,
+		})
+		if err != nil {
+			return
+		}
+		if rowsAffected > 0 {
+			inserted++
+		} else {
+			skipped++
+		}
+	}
+	return
+}
+
+// ReadServerKnownHosts 读取服务端 ~/.ssh/known_hosts，只用于管理员导入全局公钥
+func ReadServerKnownHosts() (text string, err error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	bs, err := os.ReadFile(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return
+	}
+	text = string(bs)
+	return
+}
+
+var (
+	// KnownHostConfirmTimeout 等待用户确认主机公钥的时间
+	KnownHostConfirmTimeout = 60 * time.Second
+
+	knownHostConfirmCache     = map[string]*KnownHostConfirm{}
+	knownHostConfirmCacheLock sync.Mutex
+)
+
+// KnownHostConfirm 等待用户确认的主机公钥，通过 ssh-host-key-confirm 事件推送给用户
+type KnownHostConfirm struct {
+	*ssh.KnownHost
+	ConfirmId string `json:"confirmId"`
+	userId    int64
+	key       string
+	trusted   bool
+	done      chan struct{}
+}
+
+// NewKnownHostStore 用户以及全局已信任的主机公钥，confirm 为 true 时未信任的主机公钥推送给在线的用户确认，确认信任后保存到用户下
+func (this_ *ToolboxService) NewKnownHostStore(userId int64, confirm bool) ssh.HostKeyStore {
+	return &knownHostStore{
+		ToolboxService: this_,
+		userId:         userId,
+		confirm:        confirm,
+	}
+}
+
+type knownHostStore struct {
+	*ToolboxService
+	userId  int64
+	confirm bool
+}
+
+// GetStoreKey 同一用户已信任的主机公钥一致，连接池中的连接可以共用
+func (this_ *knownHostStore) GetStoreKey() string {
+	return "user-" + util.GetStringValue(this_.userId)
+}
+
+func (this_ *knownHostStore) GetKnownHosts() (list []*ssh.KnownHost, err error) {
+	res, err := this_.QueryKnownHost(this_.userId)
+	if err != nil {
+		return
+	}
+	for _, one := range res {
+		list = append(list, &ssh.KnownHost{
+			Host:        one.Host,
+			KeyType:     one.KeyType,
+			PublicKey:   one.PublicKey,
+			Fingerprint: one.Fingerprint,
+		})
+	}
+	return
+}
+
+func (this_ *knownHostStore) OnUnknownHostKey(hostKey *ssh.KnownHost) (err error) {
+	err = &ssh.HostKeyUnknownError{KnownHost: hostKey}
+	if !this_.confirm || this_.userId == 0 || len(context.GetUserListeners(this_.userId)) == 0 {
+		return
+	}
+
+	// 同一主机公钥同时多个连接时只推送一次
+	key := util.GetStringValue(this_.userId) + "-" + hostKey.Host + "-" + hostKey.Fingerprint
+	knownHostConfirmCacheLock.Lock()
+	var find *KnownHostConfirm
+	for _, one := range knownHostConfirmCache {
+		if one.key == key {
+			find = one
+			break
+		}
+	}
+	if find == nil {
+		find = &KnownHostConfirm{
+			KnownHost: hostKey,
+			ConfirmId: util.GetUUID(),
+			userId:    this_.userId,
+			key:       key,
+			done:      make(chan struct{}),
+		}
+		knownHostConfirmCache[find.ConfirmId] = find
+		context.CallUserEvent(this_.userId, context.NewListenEvent("ssh-host-key-confirm", find))
+	}
+	knownHostConfirmCacheLock.Unlock()
+
+	select {
+	case <-find.done:
+	case <-time.After(KnownHostConfirmTimeout):
+		knownHostConfirmCacheLock.Lock()
+		delete(knownHostConfirmCache, find.ConfirmId)
+		knownHostConfirmCacheLock.Unlock()
+		return
+	}
+	if find.trusted {
+		err = nil
+	}
+	return
+}
+
+// ConfirmKnownHost 用户确认是否信任主机公钥
+func (this_ *ToolboxService) ConfirmKnownHost(confirmId string, userId int64, trust bool) (err error) {
+	knownHostConfirmCacheLock.Lock()
+	find := knownHostConfirmCache[confirmId]
+	if find != nil && find.userId == userId {
+		delete(knownHostConfirmCache, confirmId)
+	} else {
+		find = nil
+	}
+	knownHostConfirmCacheLock.Unlock()
+
+	if find == nil {
+		err = errors.New("主机公钥确认[" + confirmId + "]不存在或已超时")
+		return
+	}
+	defer close(find.done)
+
+	if trust {
+		_, err = this_.InsertKnownHost(&ToolboxKnownHostModel{
+			UserId:      userId,
+			Host:        find.Host,
+			KeyType:     find.KeyType,
+			PublicKey:   find.PublicKey,
+			Fingerprint: find.Fingerprint,
+		})
+		if err != nil {
+			return
+		}
+		find.trusted = true
+	}
+	return
+}
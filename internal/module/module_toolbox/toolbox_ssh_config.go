@@ -0,0 +1,330 @@
+package module_toolbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"teamide/internal/module/module_id"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+// SSHConfigImportRequest 导入 ssh_config
+type SSHConfigImportRequest struct {
+	Files     []*ssh.SSHConfigFile `json:"files,omitempty"`     // 第一个为 ssh_config，其它为 Include 的文件以及私钥、证书文件，路径为 ~/.ssh 下的相对路径；必须上传，不读取服务端的文件
+	GroupName string               `json:"groupName,omitempty"` // ssh_config 中 Host 的分组，Include 的文件中的 Host 按文件名分组
+	Aliases   []string             `json:"aliases,omitempty"`   // 导入的 Host 别名，为空时导入全部
+}
+
+// SSHConfigImportEntry 预览的 SSH 工具
+type SSHConfigImportEntry struct {
+	Alias           string      `json:"alias"`
+	GroupName       string      `json:"groupName,omitempty"`
+	Config          *ssh.Config `json:"config"`
+	ProxyJump       []string    `json:"proxyJump,omitempty"`
+	IdentityFile    string      `json:"identityFile,omitempty"`
+	CertificateFile string      `json:"certificateFile,omitempty"`
+	Exists          bool        `json:"exists"` // 同名的 SSH 工具已存在，导入时跳过
+	Warnings        []string    `json:"warnings,omitempty"`
+
+	identityContent    string
+	certificateContent string
+}
+
+type SSHConfigImportPreview struct {
+	Entries  []*SSHConfigImportEntry `json:"entries"`
+	Warnings []string                `json:"warnings,omitempty"`
+}
+
+type SSHConfigImportResult struct {
+	Inserted []string `json:"inserted"`
+	Skipped  []string `json:"skipped"`
+}
+
+// PreviewSSHConfig 解析 ssh_config 预览导入的 SSH 工具，每个 Host 别名为一个工具
+func (this_ *ToolboxService) PreviewSSHConfig(userId int64, request *SSHConfigImportRequest) (res *SSHConfigImportPreview, err error) {
+	// 只解析上传的文件，不读取服务端的文件
+	if len(request.Files) == 0 {
+		err = errors.New("请上传 ssh_config 文件")
+		return
+	}
+	main := request.Files[0]
+	reader := ssh.NewSSHConfigFilesReader(request.Files)
+	readFile := func(filePath string) (content string, err error) {
+		content, find := findSSHConfigFile(request.Files, filePath)
+		if !find {
+			err = errors.New("文件[" + filePath + "]未上传")
+		}
+		return
+	}
+
+	parsed, err := ssh.ParseSSHConfig(main, reader)
+	if err != nil {
+		return
+	}
+	res = &SSHConfigImportPreview{
+		Warnings: parsed.Warnings,
+	}
+	for _, host := range parsed.Hosts {
+		entry := &SSHConfigImportEntry{
+			Alias:           host.Alias,
+			GroupName:       request.GroupName,
+			ProxyJump:       host.ProxyJump,
+			IdentityFile:    host.IdentityFile,
+			CertificateFile: host.CertificateFile,
+			Config: &ssh.Config{
+				Type:     "tcp",
+				Address:  host.GetAddress(),
+				Username: host.User,
+				Timeout:  host.ConnectTimeout,
+				Forwards: host.Forwards,
+			},
+		}
+		if host.File != main.Path {
+			name := path.Base(filepath.ToSlash(host.File))
+			entry.GroupName = strings.TrimSuffix(name, path.Ext(name))
+		}
+		// SSH Agent 由服务端配置，不导入 IdentityAgent
+		if host.IdentityAgent != "" && host.IdentityAgent != "none" {
+			entry.Warnings = append(entry.Warnings, "IdentityAgent 未导入，需要时在 SSH 工具中开启使用服务端配置的 SSH Agent")
+		}
+		if host.IdentityFile != "" {
+			var e error
+			entry.identityContent, e = readFile(host.IdentityFile)
+			if e != nil {
+				entry.Warnings = append(entry.Warnings, "私钥文件读取失败："+e.Error())
+			}
+		}
+		if host.CertificateFile != "" && entry.identityContent != "" {
+			var e error
+			entry.certificateContent, e = readFile(host.CertificateFile)
+			if e != nil {
+				entry.Warnings = append(entry.Warnings, "证书文件读取失败："+e.Error())
+			}
+		}
+		entry.Exists, err = this_.CheckUserToolboxExist("ssh", host.Alias, userId)
+		if err != nil {
+			return
+		}
+		res.Entries = append(res.Entries, entry)
+	}
+	return
+}
+
+// findSSHConfigFile 按路径查找上传的文件，路径不一致时按文件名查找
+func findSSHConfigFile(files []*ssh.SSHConfigFile, filePath string) (content string, find bool) {
+	filePath = strings.TrimPrefix(filePath, "~/.ssh/")
+	for _, one := range files {
+		if filepath.ToSlash(one.Path) == filePath {
+			return one.Content, true
+		}
+	}
+	for _, one := range files {
+		if path.Base(filepath.ToSlash(one.Path)) == path.Base(filePath) {
+			return one.Content, true
+		}
+	}
+	return
+}
+
+// ImportSSHConfig 导入 ssh_config，同名的 SSH 工具跳过；私钥和证书保存到文件目录；
+// ProxyJump 为导入或已存在的 Host 别名时引用对应的 SSH 工具，为未导入的 Host 别名时使用别名解析的配置，否则作为跳板机连接信息
+func (this_ *ToolboxService) ImportSSHConfig(userId int64, request *SSHConfigImportRequest) (res *SSHConfigImportResult, err error) {
+	preview, err := this_.PreviewSSHConfig(userId, request)
+	if err != nil {
+		return
+	}
+	res = &SSHConfigImportResult{}
+
+	var aliasCache = make(map[string]bool)
+	for _, alias := range request.Aliases {
+		aliasCache[alias] = true
+	}
+	var entries []*SSHConfigImportEntry
+	var entryCache = make(map[string]*SSHConfigImportEntry)
+	var toolboxIdCache = make(map[string]int64)
+	for _, entry := range preview.Entries {
+		entryCache[entry.Alias] = entry
+		if entry.Exists {
+			var find *ToolboxModel
+			find, err = this_.GetUserToolboxByName("ssh", entry.Alias, userId)
+			if err != nil {
+				return
+			}
+			if find != nil {
+				toolboxIdCache[entry.Alias] = find.ToolboxId
+			}
+		}
+		if len(aliasCache) > 0 && !aliasCache[entry.Alias] {
+			continue
+		}
+		if entry.Exists {
+			res.Skipped = append(res.Skipped, entry.Alias)
+			continue
+		}
+		// 先分配 ID，跳板机可以引用之后导入的工具
+		toolboxIdCache[entry.Alias], err = this_.idService.GetNextID(module_id.IDTypeToolbox)
+		if err != nil {
+			return
+		}
+		entries = append(entries, entry)
+	}
+
+	var groupIdCache = make(map[string]int64)
+	for _, entry := range entries {
+		config := entry.Config
+		for _, jump := range getSSHConfigJumpHosts(entry.ProxyJump, entryCache, toolboxIdCache, map[string]bool{entry.Alias: true}) {
+			if jump.entry != nil {
+				err = this_.saveSSHConfigEntryFiles(jump.entry, jump.config)
+				if err != nil {
+					return
+				}
+			}
+			config.JumpHosts = append(config.JumpHosts, jump.config)
+		}
+		err = this_.saveSSHConfigEntryFiles(entry, config)
+		if err != nil {
+			return
+		}
+
+		toolbox := &ToolboxModel{
+			ToolboxId:   toolboxIdCache[entry.Alias],
+			ToolboxType: "ssh",
+			Name:        entry.Alias,
+		}
+		if entry.GroupName != "" {
+			groupId, find := groupIdCache[entry.GroupName]
+			if !find {
+				groupId, err = this_.getOrInsertGroup(entry.GroupName, userId)
+				if err != nil {
+					return
+				}
+				groupIdCache[entry.GroupName] = groupId
+			}
+			toolbox.GroupId = groupId
+		}
+		var bs []byte
+		bs, err = json.Marshal(config)
+		if err != nil {
+			return
+		}
+		toolbox.Option = string(bs)
+		err = this_.InsertUserToolbox(userId, toolbox)
+		if err != nil {
+			return
+		}
+		res.Inserted = append(res.Inserted, entry.Alias)
+	}
+	return
+}
+
+// sshConfigJumpHost ProxyJump 对应的跳板机，entry 不为空时为未导入的 Host 别名，需要保存私钥和证书
+type sshConfigJumpHost struct {
+	config *ssh.Config
+	entry  *SSHConfigImportEntry
+}
+
+// getSSHConfigJumpHosts ProxyJump 转为跳板机配置，导入或已存在的 Host 别名引用对应的 SSH 工具；
+// 未导入的 Host 别名使用解析的 HostName、User、Port，别名的 ProxyJump 依次展开在它之前；visited 为已展开的别名，循环引用时跳过
+func getSSHConfigJumpHosts(proxyJump []string, entryCache map[string]*SSHConfigImportEntry, toolboxIdCache map[string]int64, visited map[string]bool) (res []*sshConfigJumpHost) {
+	for _, jump := range proxyJump {
+		if visited[jump] {
+			continue
+		}
+		if toolboxId, find := toolboxIdCache[jump]; find {
+			res = append(res, &sshConfigJumpHost{config: &ssh.Config{ToolboxId: toolboxId}})
+			continue
+		}
+		if entry, find := entryCache[jump]; find {
+			visited[jump] = true
+			res = append(res, getSSHConfigJumpHosts(entry.ProxyJump, entryCache, toolboxIdCache, visited)...)
+			res = append(res, &sshConfigJumpHost{
+				config: &ssh.Config{
+					Type:     "tcp",
+					Address:  entry.Config.Address,
+					Username: entry.Config.Username,
+					Timeout:  entry.Config.Timeout,
+				},
+				entry: entry,
+			})
+			continue
+		}
+		res = append(res, &sshConfigJumpHost{config: parseSSHConfigJump(jump)})
+	}
+	return
+}
+
+// parseSSHConfigJump 解析 [ssh://][user@]host[:port]，未指定端口时使用 22
+func parseSSHConfigJump(jump string) (config *ssh.Config) {
+	jump = strings.TrimPrefix(jump, "ssh://")
+	config = &ssh.Config{Type: "tcp", Address: jump}
+	if index := strings.LastIndex(jump, "@"); index >= 0 {
+		config.Username = jump[:index]
+		config.Address = jump[index+1:]
+	}
+	if _, _, err := net.SplitHostPort(config.Address); err != nil {
+		config.Address = net.JoinHostPort(strings.Trim(config.Address, "[]"), "22")
+	}
+	return
+}
+
+// saveSSHConfigEntryFiles 保存 Host 别名的私钥和证书，设置到 config 中
+func (this_ *ToolboxService) saveSSHConfigEntryFiles(entry *SSHConfigImportEntry, config *ssh.Config) (err error) {
+	if entry.identityContent != "" {
+		config.PublicKey, err = this_.saveSSHConfigFile(entry.IdentityFile, entry.identityContent)
+		if err != nil {
+			return
+		}
+	}
+	if entry.certificateContent != "" {
+		config.Certificate, err = this_.saveSSHConfigFile(entry.CertificateFile, entry.certificateContent)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// saveSSHConfigFile 保存私钥、证书到文件目录，返回相对文件目录的路径，与上传的文件一致
+func (this_ *ToolboxService) saveSSHConfigFile(name string, content string) (filePath string, err error) {
+	nowTime := time.Now()
+	filePath = fmt.Sprintf("toolbox/%d/%d/%d/%s", nowTime.Year(), nowTime.Month(), nowTime.Day(), util.GetUUID())
+	fileDir := this_.GetFilesFile(filePath)
+	err = os.MkdirAll(fileDir, 0700)
+	if err != nil {
+		return
+	}
+	filePath += "/" + path.Base(filepath.ToSlash(name))
+	err = os.WriteFile(this_.GetFilesFile(filePath), []byte(content), 0600)
+	return
+}
+
+// getOrInsertGroup 查询用户下同名的分组，不存在时新增
+func (this_ *ToolboxService) getOrInsertGroup(name string, userId int64) (groupId int64, err error) {
+	list, err := this_.QueryGroup(&ToolboxGroupModel{UserId: userId, Name: name})
+	if err != nil {
+		return
+	}
+	for _, one := range list {
+		if one.Name == name {
+			groupId = one.GroupId
+			return
+		}
+	}
+	group := &ToolboxGroupModel{
+		Name:   name,
+		UserId: userId,
+	}
+	_, err = this_.InsertGroup(group)
+	if err != nil {
+		return
+	}
+	groupId = group.GroupId
+	return
+}
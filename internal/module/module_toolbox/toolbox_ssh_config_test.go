@@ -0,0 +1,93 @@
+package module_toolbox
+
+import (
+	"strconv"
+	"teamide/pkg/ssh"
+	"testing"
+)
+
+func TestParseSSHConfigJump(t *testing.T) {
+	for _, one := range []struct {
+		jump     string
+		username string
+		address  string
+	}{
+		{"bastion", "", "bastion:22"},
+		{"bastion:2222", "", "bastion:2222"},
+		{"root@bastion", "root", "bastion:22"},
+		{"root@bastion:2222", "root", "bastion:2222"},
+		{"a@b@bastion:2222", "a@b", "bastion:2222"},
+		{"ssh://root@bastion:2222", "root", "bastion:2222"},
+		{"[::1]", "", "[::1]:22"},
+		{"root@[::1]:2222", "root", "[::1]:2222"},
+		{"fe80::1", "", "[fe80::1]:22"},
+	} {
+		config := parseSSHConfigJump(one.jump)
+		if config.Type != "tcp" || config.Username != one.username || config.Address != one.address {
+			t.Errorf("jump [%s] error: %s %s", one.jump, config.Username, config.Address)
+		}
+	}
+}
+
+func TestGetSSHConfigJumpHosts(t *testing.T) {
+	newEntry := func(alias string, address string, username string, proxyJump ...string) *SSHConfigImportEntry {
+		return &SSHConfigImportEntry{
+			Alias:           alias,
+			ProxyJump:       proxyJump,
+			IdentityFile:    "~/.ssh/" + alias,
+			Config:          &ssh.Config{Type: "tcp", Address: address, Username: username, Timeout: 5},
+			identityContent: "key-" + alias,
+		}
+	}
+	entryCache := map[string]*SSHConfigImportEntry{
+		"gw":      newEntry("gw", "10.0.0.1:22", "gw-user"),
+		"bastion": newEntry("bastion", "10.0.0.2:2222", "ops", "gw"),
+		"loop-a":  newEntry("loop-a", "10.0.0.3:22", "a", "loop-b"),
+		"loop-b":  newEntry("loop-b", "10.0.0.4:22", "b", "loop-a"),
+		"web":     newEntry("web", "10.0.0.5:22", "web"),
+	}
+	toolboxIdCache := map[string]int64{"web": 100}
+
+	for _, one := range []struct {
+		name      string
+		alias     string
+		proxyJump []string
+		want      []string // 地址或引用的工具 ID
+	}{
+		{"toolbox", "app", []string{"web"}, []string{"#100"}},
+		{"address", "app", []string{"root@other:2200"}, []string{"root@other:2200"}},
+		{"alias", "app", []string{"gw"}, []string{"gw-user@10.0.0.1:22"}},
+		{"alias chain", "app", []string{"bastion", "web"}, []string{"gw-user@10.0.0.1:22", "ops@10.0.0.2:2222", "#100"}},
+		{"alias loop", "loop-a", []string{"loop-b"}, []string{"b@10.0.0.4:22"}},
+		{"self", "web", []string{"web", "gw"}, []string{"gw-user@10.0.0.1:22"}},
+	} {
+		var got []string
+		for _, jump := range getSSHConfigJumpHosts(one.proxyJump, entryCache, toolboxIdCache, map[string]bool{one.alias: true}) {
+			if jump.config.ToolboxId != 0 {
+				if jump.entry != nil {
+					t.Errorf("%s: toolbox jump should not save files", one.name)
+				}
+				got = append(got, "#"+strconv.FormatInt(jump.config.ToolboxId, 10))
+				continue
+			}
+			str := jump.config.Address
+			if jump.config.Username != "" {
+				str = jump.config.Username + "@" + str
+			}
+			got = append(got, str)
+			if jump.entry != nil && (jump.entry.Config.Address != jump.config.Address || jump.config.Timeout != 5 || jump.config == jump.entry.Config) {
+				t.Errorf("%s: alias jump [%s] config error", one.name, jump.entry.Alias)
+			}
+		}
+		if len(got) != len(one.want) {
+			t.Errorf("%s: jump hosts error: %v", one.name, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != one.want[i] {
+				t.Errorf("%s: jump hosts error: %v", one.name, got)
+				break
+			}
+		}
+	}
+}
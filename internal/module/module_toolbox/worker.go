@@ -131,6 +131,45 @@ func (this_ *ToolboxService) FormatOption(toolboxData *ToolboxModel) (err error)
 				delete(optionMap, "password")
 			}
 		}
+		// 跳板机内联的密码
+		jumpHosts, _ := optionMap["jumpHosts"].([]interface{})
+		for _, one := range jumpHosts {
+			jumpHost, ok := one.(map[string]interface{})
+			if !ok || jumpHost["password"] == nil {
+				continue
+			}
+			str, ok := jumpHost["password"].(string)
+			if ok {
+				jumpHost["password"] = this_.EncryptOptionAttr(str)
+			} else {
+				delete(jumpHost, "password")
+			}
+		}
+		// 端口转发校验配置，生成转发标识
+		forwards, _ := optionMap["forwards"].([]interface{})
+		for _, one := range forwards {
+			forwardMap, ok := one.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			var forward *ssh.ForwardConfig
+			var bs []byte
+			bs, err = json.Marshal(forwardMap)
+			if err != nil {
+				return
+			}
+			err = json.Unmarshal(bs, &forward)
+			if err != nil {
+				return
+			}
+			err = forward.Check()
+			if err != nil {
+				return
+			}
+			if forward.Id == "" {
+				forwardMap["id"] = util.GetUUID()
+			}
+		}
 		break
 	}
 
@@ -142,16 +181,77 @@ func (this_ *ToolboxService) FormatOption(toolboxData *ToolboxModel) (err error)
 	return
 }
 
-func (this_ *ToolboxService) GetSSHConfig(option string) (config *ssh.Config, err error) {
+// GetSSHConfig 解析 SSH 配置，连接时按 userId 用户以及全局已信任的主机公钥校验
+func (this_ *ToolboxService) GetSSHConfig(option string, userId int64) (config *ssh.Config, err error) {
 	optionBytes := []byte(option)
 	err = json.Unmarshal(optionBytes, &config)
 	if err != nil {
 		return
 	}
+	this_.formatSSHConfig(config, userId)
+	config.JumpHosts, err = this_.getSSHJumpHosts(config.JumpHosts, userId, map[int64]bool{})
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *ToolboxService) formatSSHConfig(config *ssh.Config, userId int64) {
 	config.Password = this_.DecryptOptionAttr(config.Password)
 	if config.PublicKey != "" {
 		config.PublicKey = this_.GetFilesFile(config.PublicKey)
 	}
+	if config.Certificate != "" {
+		config.Certificate = this_.GetFilesFile(config.Certificate)
+	}
+	config.HostKeyStore = this_.NewKnownHostStore(userId, true)
+}
+
+// getSSHJumpHosts 引用 SSH 工具的跳板机替换为对应的配置，被引用的 SSH 工具配置了跳板机时依次放在其前面
+func (this_ *ToolboxService) getSSHJumpHosts(jumpHosts []*ssh.Config, userId int64, toolboxIdCache map[int64]bool) (res []*ssh.Config, err error) {
+	for _, jumpHost := range jumpHosts {
+		if jumpHost == nil {
+			continue
+		}
+		if jumpHost.ToolboxId == 0 {
+			this_.formatSSHConfig(jumpHost, userId)
+			res = append(res, jumpHost)
+			continue
+		}
+		if toolboxIdCache[jumpHost.ToolboxId] {
+			err = errors.New("跳板机SSH[" + strconv.FormatInt(jumpHost.ToolboxId, 10) + "]重复引用")
+			return
+		}
+		toolboxIdCache[jumpHost.ToolboxId] = true
+
+		var toolbox *ToolboxModel
+		toolbox, err = this_.Get(jumpHost.ToolboxId)
+		if err != nil {
+			return
+		}
+		if toolbox == nil || toolbox.Deleted == 1 || toolbox.ToolboxType != "ssh" || toolbox.Option == "" {
+			err = errors.New("跳板机SSH[" + strconv.FormatInt(jumpHost.ToolboxId, 10) + "]配置不存在")
+			return
+		}
+		if toolbox.UserId != 0 && toolbox.UserId != userId && toolbox.Visibility != visibilityOpen {
+			err = errors.New("跳板机SSH[" + toolbox.Name + "]不属于当前用户，无法使用")
+			return
+		}
+		var find *ssh.Config
+		err = json.Unmarshal([]byte(toolbox.Option), &find)
+		if err != nil {
+			return
+		}
+		this_.formatSSHConfig(find, toolbox.UserId)
+		var parents []*ssh.Config
+		parents, err = this_.getSSHJumpHosts(find.JumpHosts, toolbox.UserId, toolboxIdCache)
+		if err != nil {
+			return
+		}
+		res = append(res, parents...)
+		find.JumpHosts = nil
+		res = append(res, find)
+	}
 	return
 }
 
@@ -270,7 +370,7 @@ func (this_ *ToolboxService) BindConfigByOption(option string, config interface{
 						return
 					}
 					if sshToolbox != nil {
-						sshConfig, err = this_.GetSSHConfig(sshToolbox.Option)
+						sshConfig, err = this_.GetSSHConfig(sshToolbox.Option, sshToolbox.UserId)
 						if err != nil {
 							err = errors.New("ssh toolbox config error:" + err.Error())
 							return
@@ -720,7 +820,40 @@ func sshWorker() *ToolboxType {
 				{Label: `发送间隔（秒）`, Name: "idleSendTime", IsNumber: true, Col: 8, DefaultValue: 60, VIf: "idleSendOpen == true"},
 				{Label: `发送字符（^C：Ctrl+C、\n：回车）`, Name: "idleSendChar", Col: 8, DefaultValue: "^C", VIf: "idleSendOpen == true"},
 
+				{Label: "后台采集监控数据（无需安装节点）", Name: "monitorOpen", Type: "switch", Col: 8, DefaultValue: false},
+				{Label: `采集间隔（秒）`, Name: "monitorInterval", IsNumber: true, Col: 8, DefaultValue: 10, VIf: "monitorOpen == true"},
+
 				{Label: "PrivateKey（通常跳板机需要的密钥文件）", Name: "publicKey", Type: "file", Placeholder: "请上传PrivateKey文件"},
+				{Label: "Certificate（PrivateKey对应的OpenSSH用户证书，如 id_ed25519-cert.pub）", Name: "certificate", Type: "file", Placeholder: "请上传用户证书文件", VIf: "publicKey"},
+				{Label: "使用SSH Agent中的密钥（需要服务端配置SSH Agent）", Name: "agent", Type: "switch", Col: 8, DefaultValue: false},
+				{
+					Label: "跳板机（按顺序连接，选择SSH工具或填写连接信息）", Name: "jumpHosts", Type: "list",
+					Fields: []*form.Field{
+						{Label: "SSH工具", Name: "toolboxId", Type: "select", OptionsName: "sshToolboxOptions", IsNumber: true},
+						{Label: "连接地址（127.0.0.1:22）", Name: "address", VIf: "!toolboxId"},
+						{Label: "Username", Name: "username", VIf: "!toolboxId"},
+						{Label: "Password（密码或密钥文件密码）", Name: "password", Type: "password", VIf: "!toolboxId", ShowPlaintextBtn: true},
+						{Label: "PrivateKey", Name: "publicKey", Type: "file", VIf: "!toolboxId", Placeholder: "请上传PrivateKey文件"},
+						{Label: "Certificate", Name: "certificate", Type: "file", VIf: "!toolboxId && publicKey", Placeholder: "请上传用户证书文件"},
+						{Label: "使用SSH Agent中的密钥", Name: "agent", Type: "switch", VIf: "!toolboxId"},
+					},
+				},
+				{
+					Label: "端口转发（后台保持连接，断开后自动重连）", Name: "forwards", Type: "list",
+					Fields: []*form.Field{
+						{Label: "名称", Name: "name"},
+						{Label: "类型", Name: "type", Type: "select", DefaultValue: ssh.ForwardTypeLocal,
+							Options: []*form.Option{
+								{Text: "本地转发（-L）", Value: ssh.ForwardTypeLocal},
+								{Text: "远程转发（-R）", Value: ssh.ForwardTypeRemote},
+								{Text: "动态转发（-D，SOCKS5）", Value: ssh.ForwardTypeDynamic},
+							},
+						},
+						{Label: "监听地址（本地、动态为本机地址，远程为SSH服务器地址，如 127.0.0.1:8080）", Name: "bindAddress"},
+						{Label: "目标地址（本地为SSH服务器可访问的地址，远程为本机可访问的地址）", Name: "targetAddress", VIf: "type != 'dynamic'"},
+						{Label: "开启", Name: "open", Type: "switch", DefaultValue: true},
+					},
+				},
 				{Label: "连接后执行命令(回车执行多条，sleep 5，表示等待5秒执行下一条)", Name: "command", Type: "textarea"},
 			},
 		},
@@ -64,15 +64,19 @@ func getService(zkConfig *zookeeper.Config, sshConfig *ssh.Config) (res zookeepe
 	if sshConfig != nil {
 		key += "-ssh-" + sshConfig.Address
 		key += "-ssh-" + sshConfig.Username
+		if len(sshConfig.JumpHosts) > 0 {
+			key += "-jump-" + sshConfig.GetJumpAddress()
+		}
 	}
 	var serviceInfo *base.ServiceInfo
 	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
 		var s zookeeper.IService
+		var releaseSSH = func() {}
 		if sshConfig != nil {
 			var sshClient *goSSH.Client
-			sshClient, err = ssh.NewClient(*sshConfig)
+			sshClient, releaseSSH, err = ssh.GetPoolClient(*sshConfig)
 			if err != nil {
-				util.Logger.Error("getZKService ssh NewClient error", zap.Any("key", key), zap.Error(err))
+				util.Logger.Error("getZKService ssh GetPoolClient error", zap.Any("key", key), zap.Error(err))
 				return
 			}
 			zkConfig.SSHClient = sshClient
@@ -83,6 +87,7 @@ func getService(zkConfig *zookeeper.Config, sshConfig *ssh.Config) (res zookeepe
 			if s != nil {
 				s.Close()
 			}
+			releaseSSH()
 			return
 		}
 		_, err = s.Exists("/")
@@ -91,13 +96,17 @@ func getService(zkConfig *zookeeper.Config, sshConfig *ssh.Config) (res zookeepe
 			if s != nil {
 				s.Close()
 			}
+			releaseSSH()
 			return
 		}
 		res = &base.ServiceInfo{
 			WaitTime:    10 * 60 * 1000,
 			LastUseTime: util.GetNowMilli(),
 			Service:     s,
-			Stop:        s.Close,
+			Stop: func() {
+				s.Close()
+				releaseSSH()
+			},
 		}
 		return
 	})
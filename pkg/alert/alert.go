@@ -0,0 +1,343 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 告警
+//
+// 调用方按检查间隔采集样本调用 Evaluate，满足规则条件的目标进入 pending，
+// 持续 Duration 秒后进入 firing 并通知，条件不再满足时进入 resolved 并通知；
+// 目标超过 MissingMaxCount 个检查间隔没有样本时（如节点已删除）告警过期，firing 的告警进入 resolved 并通知。
+
+const (
+	MetricCpuPercent    = "cpuPercent"    // CPU 平均使用率
+	MetricMemoryPercent = "memoryPercent" // 内存使用率
+	MetricDiskPercent   = "diskPercent"   // 磁盘最大使用率
+	MetricNetRecvSpeed  = "netRecvSpeed"  // 网卡接收速度合计（B/秒）
+	MetricNetSentSpeed  = "netSentSpeed"  // 网卡发送速度合计（B/秒）
+	MetricNodeOffline   = "nodeOffline"   // 节点离线，离线为 1
+	MetricNetProxyDown  = "netProxyDown"  // 网络代理输入端或输出端未启动，未启动为 1
+
+	StatusPending  = "pending"
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+var (
+	// DefaultInterval 默认检查间隔（秒）
+	DefaultInterval = 30
+	// ResolvedMaxSize 保留已恢复告警的数量
+	ResolvedMaxSize = 200
+	// MissingMaxCount 目标超过该数量的检查间隔没有样本时告警过期
+	MissingMaxCount = 3
+)
+
+// Config 告警配置
+type Config struct {
+	Interval    int        `json:"interval,omitempty"` // 检查间隔（秒）
+	RuleList    []*Rule    `json:"ruleList,omitempty"`
+	ChannelList []*Channel `json:"channelList,omitempty"`
+}
+
+// Rule 告警规则，样本值与阈值比较
+type Rule struct {
+	Name            string   `json:"name,omitempty"`
+	Metric          string   `json:"metric,omitempty"`
+	Operator        string   `json:"operator,omitempty"`        // >、>=、<、<=，默认 >
+	Threshold       float64  `json:"threshold,omitempty"`       // 阈值，节点离线、代理未启动默认为 0
+	Duration        int      `json:"duration,omitempty"`        // 持续时间（秒），持续满足条件后触发
+	TargetList      []string `json:"targetList,omitempty"`      // 节点ID或网络代理ID，为空时所有目标
+	ChannelNameList []string `json:"channelNameList,omitempty"` // 通知渠道，为空时所有渠道
+}
+
+func (this_ *Rule) match(value float64) bool {
+	switch this_.Operator {
+	case ">=":
+		return value >= this_.Threshold
+	case "<":
+		return value < this_.Threshold
+	case "<=":
+		return value <= this_.Threshold
+	}
+	return value > this_.Threshold
+}
+
+func (this_ *Rule) matchTarget(target string) bool {
+	if len(this_.TargetList) == 0 {
+		return true
+	}
+	for _, one := range this_.TargetList {
+		if one == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Sample 采集的样本
+type Sample struct {
+	Metric string
+	Target string
+	Value  float64
+}
+
+// Alert 告警
+type Alert struct {
+	Id          string  `json:"id,omitempty"`
+	RuleName    string  `json:"ruleName,omitempty"`
+	Metric      string  `json:"metric,omitempty"`
+	Target      string  `json:"target,omitempty"`
+	Operator    string  `json:"operator,omitempty"`
+	Threshold   float64 `json:"threshold"`
+	Value       float64 `json:"value"`
+	Status      string  `json:"status,omitempty"`
+	StartTime   int64   `json:"startTime,omitempty"` // 开始满足条件的时间（毫秒）
+	FireTime    int64   `json:"fireTime,omitempty"`
+	ResolveTime int64   `json:"resolveTime,omitempty"`
+	Expired     bool    `json:"expired,omitempty"` // 目标没有样本后过期恢复，Value 为最后一次的样本值
+
+	channelNameList []string
+	sampleTime      int64 // 最后一次样本的时间（毫秒）
+}
+
+// GetMessage 通知内容
+func (this_ *Alert) GetMessage() string {
+	var statusText string
+	switch this_.Status {
+	case StatusFiring:
+		statusText = "告警"
+	case StatusResolved:
+		statusText = "恢复"
+	default:
+		statusText = this_.Status
+	}
+	operator := this_.Operator
+	if operator == "" {
+		operator = ">"
+	}
+	valueText := "当前 " + strconv.FormatFloat(this_.Value, 'f', 2, 64)
+	if this_.Expired {
+		valueText = "目标已没有数据"
+	}
+	return fmt.Sprintf("[%s] %s %s: %s %s %s（%s）", statusText, this_.RuleName, this_.Target, this_.Metric,
+		operator, strconv.FormatFloat(this_.Threshold, 'f', -1, 64), valueText)
+}
+
+// Manager 告警管理，记录各规则、目标的告警状态
+type Manager struct {
+	config        *Config
+	channelCache  map[string]*Channel
+	activeCache   map[string]*Alert
+	resolvedList  []*Alert
+	lock          sync.Mutex
+	OnAlertChange func(alert *Alert) // 告警触发、恢复时回调
+}
+
+// NewManager 校验配置并创建告警管理
+func NewManager(config *Config) (manager *Manager, err error) {
+	manager = &Manager{
+		config:       config,
+		channelCache: make(map[string]*Channel),
+		activeCache:  make(map[string]*Alert),
+	}
+	for _, channel := range config.ChannelList {
+		if err = channel.check(); err != nil {
+			return
+		}
+		if manager.channelCache[channel.Name] != nil {
+			err = errors.New("告警通知渠道[" + channel.Name + "]重复")
+			return
+		}
+		manager.channelCache[channel.Name] = channel
+	}
+	ruleNames := make(map[string]bool)
+	for _, rule := range config.RuleList {
+		if rule.Name == "" || rule.Metric == "" {
+			err = errors.New("告警规则名称、指标不能为空")
+			return
+		}
+		if ruleNames[rule.Name] {
+			err = errors.New("告警规则[" + rule.Name + "]重复")
+			return
+		}
+		ruleNames[rule.Name] = true
+		switch rule.Operator {
+		case "", ">", ">=", "<", "<=":
+		default:
+			err = errors.New("告警规则[" + rule.Name + "]比较符[" + rule.Operator + "]不支持")
+			return
+		}
+		for _, name := range rule.ChannelNameList {
+			if manager.channelCache[name] == nil {
+				err = errors.New("告警规则[" + rule.Name + "]的通知渠道[" + name + "]不存在")
+				return
+			}
+		}
+	}
+	return
+}
+
+// GetInterval 检查间隔
+func (this_ *Manager) GetInterval() time.Duration {
+	if this_.config.Interval > 0 {
+		return time.Duration(this_.config.Interval) * time.Second
+	}
+	return time.Duration(DefaultInterval) * time.Second
+}
+
+// HasMetric 是否有该指标的规则，用于按需采集
+func (this_ *Manager) HasMetric(metric string) bool {
+	for _, rule := range this_.config.RuleList {
+		if rule.Metric == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate 按规则评估样本，本次没有样本的目标保持原状态，超过 MissingMaxCount 个检查间隔没有样本时告警过期
+func (this_ *Manager) Evaluate(sampleList []*Sample, now time.Time) {
+	var changeList []*Alert
+
+	this_.lock.Lock()
+	nowTime := now.UnixMilli()
+	for _, rule := range this_.config.RuleList {
+		for _, sample := range sampleList {
+			if sample.Metric != rule.Metric || !rule.matchTarget(sample.Target) {
+				continue
+			}
+			id := rule.Name + ":" + sample.Target
+			alert := this_.activeCache[id]
+			if !rule.match(sample.Value) {
+				if alert == nil {
+					continue
+				}
+				alert.Value = sample.Value
+				if this_.resolve(alert, nowTime) {
+					changeList = append(changeList, alert)
+				}
+				continue
+			}
+			if alert == nil {
+				alert = &Alert{
+					Id:              id,
+					RuleName:        rule.Name,
+					Metric:          rule.Metric,
+					Target:          sample.Target,
+					Operator:        rule.Operator,
+					Threshold:       rule.Threshold,
+					Status:          StatusPending,
+					StartTime:       nowTime,
+					channelNameList: rule.ChannelNameList,
+				}
+				this_.activeCache[id] = alert
+			}
+			alert.Value = sample.Value
+			alert.sampleTime = nowTime
+			if alert.Status == StatusPending && nowTime-alert.StartTime >= int64(rule.Duration)*1000 {
+				alert.Status = StatusFiring
+				alert.FireTime = nowTime
+				changeList = append(changeList, alert)
+			}
+		}
+	}
+	// 目标没有样本的告警过期，按 ID 排序保证通知顺序一致
+	expireTime := nowTime - int64(MissingMaxCount)*this_.GetInterval().Milliseconds()
+	var expiredList []*Alert
+	for _, alert := range this_.activeCache {
+		if alert.sampleTime < expireTime {
+			expiredList = append(expiredList, alert)
+		}
+	}
+	sort.Slice(expiredList, func(i, j int) bool {
+		return expiredList[i].Id < expiredList[j].Id
+	})
+	for _, alert := range expiredList {
+		alert.Expired = true
+		if this_.resolve(alert, nowTime) {
+			changeList = append(changeList, alert)
+		}
+	}
+	// 通知使用副本，避免与下一次评估竞争
+	var notifyList []*Alert
+	for _, alert := range changeList {
+		one := *alert
+		notifyList = append(notifyList, &one)
+	}
+	this_.lock.Unlock()
+
+	for _, alert := range notifyList {
+		util.Logger.Info("alert change", zap.Any("id", alert.Id), zap.Any("status", alert.Status), zap.Any("value", alert.Value))
+		if this_.OnAlertChange != nil {
+			this_.OnAlertChange(alert)
+		}
+		this_.notify(alert)
+	}
+}
+
+// resolve 移除活动的告警，firing 的告警进入 resolved，返回是否需要通知，调用方持有锁
+func (this_ *Manager) resolve(alert *Alert, nowTime int64) (changed bool) {
+	delete(this_.activeCache, alert.Id)
+	if alert.Status != StatusFiring {
+		return
+	}
+	alert.Status = StatusResolved
+	alert.ResolveTime = nowTime
+	this_.resolvedList = append(this_.resolvedList, alert)
+	if len(this_.resolvedList) > ResolvedMaxSize {
+		this_.resolvedList = this_.resolvedList[len(this_.resolvedList)-ResolvedMaxSize:]
+	}
+	changed = true
+	return
+}
+
+// GetAlertList 告警列表，status 为空时返回所有，按开始时间倒序
+func (this_ *Manager) GetAlertList(status string) (alertList []*Alert) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	for _, alert := range this_.activeCache {
+		if status == "" || alert.Status == status {
+			one := *alert
+			alertList = append(alertList, &one)
+		}
+	}
+	if status == "" || status == StatusResolved {
+		for _, alert := range this_.resolvedList {
+			one := *alert
+			alertList = append(alertList, &one)
+		}
+	}
+	sort.Slice(alertList, func(i, j int) bool {
+		return alertList[i].StartTime > alertList[j].StartTime
+	})
+	return
+}
+
+func (this_ *Manager) getChannelList(alert *Alert) (channelList []*Channel) {
+	if len(alert.channelNameList) == 0 {
+		return this_.config.ChannelList
+	}
+	for _, name := range alert.channelNameList {
+		channelList = append(channelList, this_.channelCache[name])
+	}
+	return
+}
+
+func (this_ *Manager) notify(alert *Alert) {
+	for _, channel := range this_.getChannelList(alert) {
+		go func(channel *Channel) {
+			if err := channel.Send(alert); err != nil {
+				util.Logger.Error("alert notify error", zap.Any("channel", channel.Name), zap.Any("id", alert.Id), zap.Error(err))
+			}
+		}(channel)
+	}
+}
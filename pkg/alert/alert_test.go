@@ -0,0 +1,210 @@
+package alert
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startSmtpServer 本地 SMTP 替身，收到的邮件内容写入 mailChan
+func startSmtpServer(t *testing.T, mailChan chan string) (host string, port int) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, e := listener.Accept()
+			if e != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				reader := bufio.NewReader(conn)
+				write := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+				write("220 localhost ESMTP")
+				for {
+					line, e := reader.ReadString('\n')
+					if e != nil {
+						return
+					}
+					command := strings.ToUpper(strings.TrimSpace(line))
+					switch {
+					case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+						write("250 localhost")
+					case command == "DATA":
+						write("354 end with .")
+						var data []string
+						for {
+							dataLine, e := reader.ReadString('\n')
+							if e != nil {
+								return
+							}
+							if dataLine == ".\r\n" {
+								break
+							}
+							data = append(data, dataLine)
+						}
+						mailChan <- strings.Join(data, "")
+						write("250 ok")
+					case command == "QUIT":
+						write("221 bye")
+						return
+					default:
+						write("250 ok")
+					}
+				}
+			}()
+		}
+	}()
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func TestAlert(t *testing.T) {
+	webhookChan := make(chan map[string]interface{}, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := map[string]interface{}{}
+		_ = json.NewDecoder(r.Body).Decode(&data)
+		webhookChan <- data
+	}))
+	defer webhook.Close()
+	mailChan := make(chan string, 10)
+	smtpHost, smtpPort := startSmtpServer(t, mailChan)
+
+	manager, err := NewManager(&Config{
+		RuleList: []*Rule{
+			{Name: "cpu", Metric: MetricCpuPercent, Threshold: 90, Duration: 60, ChannelNameList: []string{"webhook"}},
+			{Name: "offline", Metric: MetricNodeOffline, TargetList: []string{"node1"}, ChannelNameList: []string{"mail"}},
+		},
+		ChannelList: []*Channel{
+			{Name: "webhook", Type: ChannelTypeWebhook, Url: webhook.URL},
+			{Name: "mail", Type: ChannelTypeSmtp, Host: smtpHost, Port: smtpPort, From: "alert@test", ToList: []string{"ops@test"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changeList []*Alert
+	manager.OnAlertChange = func(alert *Alert) {
+		changeList = append(changeList, alert)
+	}
+
+	now := time.Now()
+	manager.Evaluate([]*Sample{
+		{Metric: MetricCpuPercent, Target: "node1", Value: 95},
+		{Metric: MetricCpuPercent, Target: "node2", Value: 10},
+		{Metric: MetricNodeOffline, Target: "node1", Value: 1},
+		{Metric: MetricNodeOffline, Target: "node2", Value: 1},
+	}, now)
+	// 未达到持续时间的 CPU 告警为 pending，不在规则目标中的节点不告警
+	if list := manager.GetAlertList(StatusPending); len(list) != 1 || list[0].Id != "cpu:node1" {
+		t.Fatal("pending alert error:", list)
+	}
+	if len(changeList) != 1 || changeList[0].Id != "offline:node1" || changeList[0].Status != StatusFiring {
+		t.Fatal("offline alert should be firing:", changeList)
+	}
+	select {
+	case mail := <-mailChan:
+		if !strings.Contains(mail, "To: ops@test") || !strings.Contains(mail, "Subject: =?UTF-8?") {
+			t.Fatal("mail error:", mail)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("mail not received")
+	}
+
+	manager.Evaluate([]*Sample{
+		{Metric: MetricCpuPercent, Target: "node1", Value: 92},
+		{Metric: MetricNodeOffline, Target: "node1", Value: 0},
+	}, now.Add(60*time.Second))
+	select {
+	case data := <-webhookChan:
+		if data["status"] != StatusFiring || !strings.Contains(data["message"].(string), "node1") {
+			t.Fatal("webhook error:", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not received")
+	}
+	if len(manager.GetAlertList(StatusFiring)) != 1 {
+		t.Fatal("cpu alert should be firing")
+	}
+	resolved := manager.GetAlertList(StatusResolved)
+	if len(resolved) != 1 || resolved[0].Id != "offline:node1" || resolved[0].ResolveTime == 0 {
+		t.Fatal("offline alert should be resolved:", resolved)
+	}
+	select {
+	case <-mailChan:
+	case <-time.After(5 * time.Second):
+		t.Fatal("resolved mail not received")
+	}
+	if len(manager.GetAlertList("")) != 2 {
+		t.Fatal("alert list error")
+	}
+
+	_, err = NewManager(&Config{RuleList: []*Rule{{Name: "x", Metric: MetricCpuPercent, ChannelNameList: []string{"none"}}}})
+	if err == nil {
+		t.Fatal("unknown channel should be error")
+	}
+	_, err = NewManager(&Config{ChannelList: []*Channel{{Name: "x", Type: ChannelTypeSmtp, Host: "h", Port: 25}}})
+	if err == nil {
+		t.Fatal("smtp without recipient should be error")
+	}
+}
+
+func TestAlertExpire(t *testing.T) {
+	manager, err := NewManager(&Config{
+		Interval: 10,
+		RuleList: []*Rule{
+			{Name: "cpu", Metric: MetricCpuPercent, Threshold: 90},
+			{Name: "memory", Metric: MetricMemoryPercent, Threshold: 90, Duration: 600},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changeList []*Alert
+	manager.OnAlertChange = func(alert *Alert) {
+		changeList = append(changeList, alert)
+	}
+
+	now := time.Now()
+	manager.Evaluate([]*Sample{
+		{Metric: MetricCpuPercent, Target: "node1", Value: 95},
+		{Metric: MetricCpuPercent, Target: "node2", Value: 95},
+		{Metric: MetricMemoryPercent, Target: "node1", Value: 95},
+	}, now)
+	if len(manager.GetAlertList(StatusFiring)) != 2 || len(manager.GetAlertList(StatusPending)) != 1 {
+		t.Fatal("alert list error:", manager.GetAlertList(""))
+	}
+
+	// node1 已删除，未超过 MissingMaxCount 个检查间隔时保持原状态
+	manager.Evaluate([]*Sample{
+		{Metric: MetricCpuPercent, Target: "node2", Value: 95},
+	}, now.Add(20*time.Second))
+	if len(manager.GetAlertList(StatusFiring)) != 2 || len(changeList) != 2 {
+		t.Fatal("missing target should keep status:", manager.GetAlertList(""))
+	}
+
+	manager.Evaluate([]*Sample{
+		{Metric: MetricCpuPercent, Target: "node2", Value: 95},
+	}, now.Add(40*time.Second))
+	firing := manager.GetAlertList(StatusFiring)
+	if len(firing) != 1 || firing[0].Id != "cpu:node2" || len(manager.GetAlertList(StatusPending)) != 0 {
+		t.Fatal("missing target alert should expire:", manager.GetAlertList(""))
+	}
+	// 只有 firing 的告警恢复时通知
+	if len(changeList) != 3 || changeList[2].Id != "cpu:node1" || changeList[2].Status != StatusResolved || !changeList[2].Expired ||
+		changeList[2].Value != 95 || !strings.Contains(changeList[2].GetMessage(), "目标已没有数据") {
+		t.Fatal("expired alert change error:", changeList)
+	}
+	resolved := manager.GetAlertList(StatusResolved)
+	if len(resolved) != 1 || resolved[0].Id != "cpu:node1" || resolved[0].ResolveTime != now.Add(40*time.Second).UnixMilli() {
+		t.Fatal("expired alert should be resolved:", resolved)
+	}
+}
@@ -0,0 +1,170 @@
+package alert
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ChannelTypeWebhook = "webhook"
+	ChannelTypeSmtp    = "smtp"
+)
+
+var (
+	// NotifyTimeout 通知超时时间
+	NotifyTimeout = 10 * time.Second
+)
+
+// Channel 通知渠道
+type Channel struct {
+	Name string `json:"name,omitempty"`
+	Type string `json:"type,omitempty"` // webhook、smtp
+
+	// webhook：POST JSON {"status","message","alert"}
+	Url     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// smtp
+	Host     string   `json:"host,omitempty"`
+	Port     int      `json:"port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	ToList   []string `json:"toList,omitempty"`
+	TLS      bool     `json:"tls,omitempty"` // 直接使用 TLS 连接（如 465 端口），否则服务支持时使用 STARTTLS
+}
+
+func (this_ *Channel) check() (err error) {
+	if this_.Name == "" {
+		err = errors.New("告警通知渠道名称不能为空")
+		return
+	}
+	switch this_.Type {
+	case ChannelTypeWebhook:
+		if this_.Url == "" {
+			err = errors.New("告警通知渠道[" + this_.Name + "]地址不能为空")
+		}
+	case ChannelTypeSmtp:
+		if this_.Host == "" || this_.Port <= 0 || this_.From == "" || len(this_.ToList) == 0 {
+			err = errors.New("告警通知渠道[" + this_.Name + "]SMTP 主机、端口、发件人、收件人不能为空")
+		}
+	default:
+		err = errors.New("告警通知渠道[" + this_.Name + "]类型[" + this_.Type + "]不支持")
+	}
+	return
+}
+
+// Send 发送通知
+func (this_ *Channel) Send(alert *Alert) (err error) {
+	switch this_.Type {
+	case ChannelTypeWebhook:
+		err = this_.sendWebhook(alert)
+	case ChannelTypeSmtp:
+		err = this_.sendSmtp(alert)
+	default:
+		err = errors.New("告警通知渠道类型[" + this_.Type + "]不支持")
+	}
+	return
+}
+
+func (this_ *Channel) sendWebhook(alert *Alert) (err error) {
+	bs, err := json.Marshal(map[string]interface{}{
+		"status":  alert.Status,
+		"message": alert.GetMessage(),
+		"alert":   alert,
+	})
+	if err != nil {
+		return
+	}
+	request, err := http.NewRequest(http.MethodPost, this_.Url, bytes.NewReader(bs))
+	if err != nil {
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range this_.Headers {
+		request.Header.Set(key, value)
+	}
+	client := &http.Client{Timeout: NotifyTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return
+	}
+	defer func() { _ = response.Body.Close() }()
+	_, _ = io.Copy(io.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		err = fmt.Errorf("webhook 响应状态码 %d", response.StatusCode)
+	}
+	return
+}
+
+func (this_ *Channel) sendSmtp(alert *Alert) (err error) {
+	address := net.JoinHostPort(this_.Host, strconv.Itoa(this_.Port))
+	var conn net.Conn
+	if this_.TLS {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: NotifyTimeout}, "tcp", address, &tls.Config{ServerName: this_.Host})
+	} else {
+		conn, err = net.DialTimeout("tcp", address, NotifyTimeout)
+	}
+	if err != nil {
+		return
+	}
+	_ = conn.SetDeadline(time.Now().Add(NotifyTimeout))
+	client, err := smtp.NewClient(conn, this_.Host)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	defer func() { _ = client.Close() }()
+
+	if !this_.TLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err = client.StartTLS(&tls.Config{ServerName: this_.Host}); err != nil {
+				return
+			}
+		}
+	}
+	if this_.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", this_.Username, this_.Password, this_.Host)); err != nil {
+			return
+		}
+	}
+	if err = client.Mail(this_.From); err != nil {
+		return
+	}
+	for _, to := range this_.ToList {
+		if err = client.Rcpt(to); err != nil {
+			return
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return
+	}
+	message := alert.GetMessage()
+	body := "From: " + this_.From + "\r\n" +
+		"To: " + strings.Join(this_.ToList, ", ") + "\r\n" +
+		"Subject: " + mime.BEncoding.Encode("UTF-8", message) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + message + "\r\n"
+	if _, err = writer.Write([]byte(body)); err != nil {
+		_ = writer.Close()
+		return
+	}
+	if err = writer.Close(); err != nil {
+		return
+	}
+	err = client.Quit()
+	return
+}
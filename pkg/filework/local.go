@@ -283,10 +283,48 @@ func removeFile(path string, onLoad func(), onRemove func()) (err error) {
 }
 
 func (this_ *localService) Count(path string, onDo func(fileCount int)) (fileCount int, err error) {
+	err = countFile(path, func(info os.FileInfo) {
+		fileCount++
+		if onDo != nil {
+			onDo(fileCount)
+		}
+	})
 	return
 }
 
 func (this_ *localService) CountSize(path string, onDo func(fileCount int, fileSize int64)) (fileCount int, fileSize int64, err error) {
+	err = countFile(path, func(info os.FileInfo) {
+		fileCount++
+		if !info.IsDir() {
+			fileSize += info.Size()
+		}
+		if onDo != nil {
+			onDo(fileCount, fileSize)
+		}
+	})
+	return
+}
+
+// countFile 遍历文件和目录，包含 path 本身
+func countFile(path string, onLoad func(info os.FileInfo)) (err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	onLoad(info)
+	if !info.IsDir() {
+		return
+	}
+	ds, err := os.ReadDir(path)
+	if err != nil {
+		return
+	}
+	for _, d := range ds {
+		err = countFile(path+"/"+d.Name(), onLoad)
+		if err != nil {
+			return
+		}
+	}
 	return
 }
 
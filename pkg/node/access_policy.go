@@ -0,0 +1,240 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 访问策略
+//
+// 配置后其它节点的请求在本节点执行前按策略校验，经过本节点转发的请求不校验，由目标节点校验。
+// 本节点自身发起的调用不经过 doMethod，不受策略限制。拒绝时返回 AccessDeniedError。
+
+var (
+	AccessDeniedError = errors.New("节点访问策略拒绝")
+)
+
+const (
+	MethodGroupNode     = "node"
+	MethodGroupUpgrade  = "upgrade"
+	MethodGroupNetProxy = "netProxy"
+	MethodGroupFile     = "file"
+	MethodGroupTerminal = "terminal"
+	MethodGroupSystem   = "system"
+	MethodGroupExec     = "exec"
+)
+
+// AccessPolicy 节点访问策略
+type AccessPolicy struct {
+	AllowMethodList   []string         `json:"allowMethodList,omitempty"`   // 允许的方法分组，为空时允许所有分组，终端、命令执行还需要 AllowTerminal
+	AllowTerminal     bool             `json:"allowTerminal,omitempty"`     // 是否允许终端、命令执行
+	FileRootList      []string         `json:"fileRootList,omitempty"`      // 文件操作允许的目录，为空时不限制
+	NetProxyAllowList []*NetProxyAllow `json:"netProxyAllowList,omitempty"` // 代理输出端允许连接的目标，为空时不限制
+
+	fileRootList      []string
+	netProxyRuleList  []*netProxyAllowRule
+	allowMethodGroups map[string]bool
+}
+
+// LoadAccessPolicy 读取 JSON 格式的访问策略文件
+func LoadAccessPolicy(path string) (policy *AccessPolicy, err error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	policy = &AccessPolicy{}
+	if err = json.Unmarshal(bs, policy); err != nil {
+		err = errors.New("访问策略[" + path + "]格式错误:" + err.Error())
+		return
+	}
+	err = policy.init()
+	return
+}
+
+func (this_ *AccessPolicy) init() (err error) {
+	this_.netProxyRuleList, err = parseNetProxyAllowList(this_.NetProxyAllowList)
+	if err != nil {
+		return
+	}
+	this_.fileRootList = nil
+	for _, root := range this_.FileRootList {
+		root, err = resolvePolicyPath(root)
+		if err != nil {
+			return
+		}
+		this_.fileRootList = append(this_.fileRootList, root)
+	}
+	this_.allowMethodGroups = make(map[string]bool)
+	for _, group := range this_.AllowMethodList {
+		this_.allowMethodGroups[group] = true
+	}
+	return
+}
+
+// SetAccessPolicy 设置访问策略，为空时不限制
+func (this_ *Server) SetAccessPolicy(policy *AccessPolicy) (err error) {
+	if policy != nil {
+		if err = policy.init(); err != nil {
+			return
+		}
+	}
+	this_.accessPolicy = policy
+	return
+}
+
+// getMethodGroup 方法分组，为空表示节点间协议使用的方法，不受策略限制
+func getMethodGroup(method MethodType) string {
+	switch method {
+	case methodNodeGetStatus, methodNodeRouteGossip, methodExecExit:
+		return ""
+	case methodNodeUpgradeInfo, methodNodeUpgrade, methodNodeUpgradeConfirm, methodNodeUpgradeRollback:
+		return MethodGroupUpgrade
+	case methodSystemProcessSignal:
+		// 向进程发送信号与执行命令等同
+		return MethodGroupExec
+	}
+	switch method / 100 {
+	case 1:
+		return MethodGroupNode
+	case 2:
+		return MethodGroupNetProxy
+	case 3:
+		return MethodGroupFile
+	case 4:
+		return MethodGroupTerminal
+	case 5:
+		return MethodGroupSystem
+	case 7:
+		return MethodGroupExec
+	}
+	return ""
+}
+
+// resolvePolicyPath 绝对路径，存在时解析符号链接，避免通过链接访问允许目录以外的文件
+func resolvePolicyPath(path string) (res string, err error) {
+	res, err = filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	dir, name := res, ""
+	for {
+		if resolved, e := filepath.EvalSymlinks(dir); e == nil {
+			res = filepath.Join(resolved, name)
+			return
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return
+		}
+		name = filepath.Join(filepath.Base(dir), name)
+		dir = parent
+	}
+}
+
+func (this_ *AccessPolicy) allowPath(path string) (err error) {
+	if len(this_.fileRootList) == 0 {
+		return
+	}
+	resolved, err := resolvePolicyPath(path)
+	if err != nil {
+		return
+	}
+	for _, root := range this_.fileRootList {
+		if resolved == root || strings.HasPrefix(resolved, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator)) {
+			return
+		}
+	}
+	err = fmt.Errorf("%w，路径[%s]不在允许的目录中", AccessDeniedError, path)
+	return
+}
+
+func (this_ *AccessPolicy) allowFile(data *FileWorkData) (err error) {
+	if data == nil || len(this_.fileRootList) == 0 {
+		return
+	}
+	for _, path := range []string{data.Path, data.OldPath, data.NewPath, data.Dir} {
+		if path == "" {
+			continue
+		}
+		if err = this_.allowPath(path); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (this_ *AccessPolicy) allowMethod(method MethodType, msg *Message) (err error) {
+	group := getMethodGroup(method)
+	if group == "" {
+		return
+	}
+	if len(this_.allowMethodGroups) > 0 && !this_.allowMethodGroups[group] {
+		err = fmt.Errorf("%w，不允许[%s]方法[%d]", AccessDeniedError, group, method)
+		return
+	}
+	if (group == MethodGroupTerminal || group == MethodGroupExec) && !this_.AllowTerminal {
+		err = fmt.Errorf("%w，不允许终端、命令执行", AccessDeniedError)
+		return
+	}
+	if group == MethodGroupFile && msg.FileWorkData != nil {
+		if method == methodFileFiles && msg.FileWorkData.Dir == "" && len(this_.fileRootList) > 0 {
+			// 默认打开用户目录，限制目录时打开第一个允许的目录
+			msg.FileWorkData.Dir = this_.fileRootList[0]
+		}
+		err = this_.allowFile(msg.FileWorkData)
+	}
+	return
+}
+
+// allowNetProxyAddress 代理输出端连接的目标地址，返回解析后的地址
+func (this_ *AccessPolicy) allowNetProxyAddress(ctx context.Context, address string) (allowAddress string, err error) {
+	if len(this_.netProxyRuleList) == 0 {
+		allowAddress = address
+		return
+	}
+	allowAddress, err = resolveAllowAddress(ctx, this_.netProxyRuleList, address)
+	if errors.Is(err, NetProxyNotAllowedError) {
+		err = fmt.Errorf("%w，%s", AccessDeniedError, err.Error())
+	}
+	return
+}
+
+func (this_ *Worker) getAccessPolicy() *AccessPolicy {
+	if this_.server == nil {
+		return nil
+	}
+	return this_.server.accessPolicy
+}
+
+// isLocalTarget 节点线的目标节点是否为本节点，没有节点线的消息在本节点处理
+func (this_ *Worker) isLocalTarget(lineNodeIdList []string) bool {
+	if len(lineNodeIdList) == 0 {
+		return true
+	}
+	target := lineNodeIdList[len(lineNodeIdList)-1]
+	for _, localNode := range this_.server.localNodeList {
+		if localNode.Id == target {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAccessPolicy 其它节点的请求在本节点执行前校验访问策略
+func (this_ *Worker) checkAccessPolicy(method MethodType, msg *Message) (err error) {
+	policy := this_.getAccessPolicy()
+	if policy == nil || !this_.isLocalTarget(msg.LineNodeIdList) {
+		return
+	}
+	err = policy.allowMethod(method, msg)
+	if err != nil {
+		Logger.Warn("access policy denied", zap.Any("method", method), zap.Any("lineNodeIdList", msg.LineNodeIdList), zap.Error(err))
+	}
+	return
+}
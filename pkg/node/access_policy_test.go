@@ -0,0 +1,80 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAccessPolicy(t *testing.T) {
+	root := t.TempDir()
+	other := t.TempDir()
+	if err := os.Symlink(other, filepath.Join(root, "link")); err != nil {
+		t.Skip("symlink not supported:", err)
+	}
+
+	server := testLocalServer()
+	err := server.SetAccessPolicy(&AccessPolicy{
+		AllowMethodList:   []string{MethodGroupFile, MethodGroupTerminal, MethodGroupNetProxy},
+		FileRootList:      []string{root},
+		NetProxyAllowList: []*NetProxyAllow{{Cidr: "127.0.0.1", Ports: "22"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []string{"peer", "root"}
+
+	var denied = []*Message{
+		{LineNodeIdList: line, Method: methodSystemGetInfo},
+		{LineNodeIdList: line, Method: methodTerminalStart},
+		{LineNodeIdList: line, Method: methodExecStart},
+		{LineNodeIdList: line, Method: methodSystemProcessSignal},
+		{LineNodeIdList: line, Method: methodFileRemove, FileWorkData: &FileWorkData{Path: filepath.Join(other, "a")}},
+		{LineNodeIdList: line, Method: methodFileRemove, FileWorkData: &FileWorkData{Path: filepath.Join(root, "..", "a")}},
+		{LineNodeIdList: line, Method: methodFileRead, FileWorkData: &FileWorkData{Path: filepath.Join(root, "link", "a")}},
+		{LineNodeIdList: line, Method: methodFileMove, FileWorkData: &FileWorkData{OldPath: filepath.Join(root, "a"), NewPath: filepath.Join(other, "a")}},
+	}
+	for _, msg := range denied {
+		if err = server.checkAccessPolicy(msg.Method, msg); !errors.Is(err, AccessDeniedError) {
+			t.Fatal("method", msg.Method, "should be denied:", err)
+		}
+		// 异常响应带错误码，上层节点可以还原
+		if res := newErrorMessage(err); !errors.Is(res.getError(msg.Method), AccessDeniedError) {
+			t.Fatal("error code should be restored:", res.ErrorCode)
+		}
+	}
+
+	var allowed = []*Message{
+		{LineNodeIdList: line, Method: methodNodeRouteGossip},
+		{LineNodeIdList: line, Method: methodSendBytes},
+		{LineNodeIdList: line, Method: methodFileRemove, FileWorkData: &FileWorkData{Path: filepath.Join(root, "dir", "a")}},
+		// 经过本节点转发的请求由目标节点校验
+		{LineNodeIdList: []string{"root", "other"}, Method: methodSystemGetInfo},
+	}
+	for _, msg := range allowed {
+		if err = server.checkAccessPolicy(msg.Method, msg); err != nil {
+			t.Fatal("method", msg.Method, "should be allowed:", err)
+		}
+	}
+
+	msg := &Message{LineNodeIdList: line, FileWorkData: &FileWorkData{}}
+	if err = server.checkAccessPolicy(methodFileFiles, msg); err != nil || msg.FileWorkData.Dir == "" {
+		t.Fatal("files default dir error:", msg.FileWorkData.Dir, err)
+	}
+
+	// 允许终端后可以打开终端
+	server.accessPolicy.AllowTerminal = true
+	if err = server.checkAccessPolicy(methodTerminalStart, &Message{LineNodeIdList: line}); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := server.getAccessPolicy()
+	if _, err = policy.allowNetProxyAddress(context.Background(), "127.0.0.1:80"); !errors.Is(err, AccessDeniedError) {
+		t.Fatal("proxy address should be denied:", err)
+	}
+	if address, e := policy.allowNetProxyAddress(context.Background(), "127.0.0.1:22"); e != nil || address != "127.0.0.1:22" {
+		t.Fatal("proxy address should be allowed:", address, e)
+	}
+}
@@ -0,0 +1,256 @@
+package node
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ProtocolVersion 当前节点协议版本
+	// 2：HMAC 挑战应答认证
+	// 3：二进制帧
+	// 4：流多路复用
+	ProtocolVersion = 4
+	// MinProtocolVersion 支持的最低节点协议版本
+	MinProtocolVersion = 2
+
+	// protocolMagic 握手标识，旧版本节点此处发送的是明文 Token
+	protocolMagic = "TEAMIDE-NODE/"
+	// handshakeTimeout 握手超时时间
+	handshakeTimeout = 10 * time.Second
+	// handshakeMaxLength 握手消息最大长度，握手完成前对端未认证，不能按对端发送的长度分配内存
+	handshakeMaxLength = 16 * 1024
+	// nonceByteSize 随机数长度
+	nonceByteSize = 32
+
+	ProtocolVersionError = errors.New("节点协议版本不兼容")
+	AuthError            = errors.New("节点认证失败")
+)
+
+// negotiateVersion 协商协议版本，取双方支持的最高版本
+func negotiateVersion(version int) int {
+	if version > ProtocolVersion {
+		return ProtocolVersion
+	}
+	return version
+}
+
+// newNonce 生成随机数
+func newNonce() (nonce string, err error) {
+	bs := make([]byte, nonceByteSize)
+	_, err = rand.Read(bs)
+	if err != nil {
+		return
+	}
+	nonce = hex.EncodeToString(bs)
+	return
+}
+
+// signProof 使用 Token 对握手数据签名
+func signProof(token string, role string, parts ...string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(role))
+	for _, part := range parts {
+		mac.Write([]byte{0})
+		mac.Write([]byte(part))
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifyProof(proof string, expected string) bool {
+	return hmac.Equal([]byte(proof), []byte(expected))
+}
+
+// clientProof 客户端证明，包含双方随机数、协议版本、压缩算法以及客户端节点信息
+func clientProof(token string, serverNonce string, connData *ConnData) string {
+	parts := []string{serverNonce, connData.Nonce, strconv.Itoa(connData.Version), strconv.Itoa(connData.ConnIndex), strings.Join(connData.NodeIdList, ",")}
+	if len(connData.CompressList) > 0 {
+		parts = append(parts, strings.Join(connData.CompressList, ","))
+	}
+	return signProof(token, "client", parts...)
+}
+
+// serverProof 服务端证明，包含双方随机数、协议版本、压缩算法以及服务端节点ID
+func serverProof(token string, clientNonce string, connData *ConnData) string {
+	parts := []string{clientNonce, connData.Nonce, strconv.Itoa(connData.Version), connData.NodeId}
+	if connData.Compress != "" {
+		parts = append(parts, connData.Compress)
+	}
+	return signProof(token, "server", parts...)
+}
+
+// writeHello 客户端 发送握手标识和协议版本
+func writeHello(conn net.Conn) (err error) {
+	var hello = []byte(fmt.Sprintf("%s%d", protocolMagic, ProtocolVersion))
+	for i := len(hello); i < tokenByteSize; i++ {
+		hello = append(hello, ' ')
+	}
+	_, err = conn.Write(hello)
+	return
+}
+
+// readHello 服务端 读取握手标识和协议版本
+func readHello(conn net.Conn) (version int, err error) {
+	var bytes = make([]byte, tokenByteSize)
+	_, err = io.ReadFull(conn, bytes)
+	if err != nil {
+		return
+	}
+	hello := strings.TrimSpace(string(bytes))
+	if !strings.HasPrefix(hello, protocolMagic) {
+		// 旧版本节点 直接发送 Token
+		err = errors.New(ProtocolVersionError.Error() + "，对端节点版本过旧，请升级节点")
+		return
+	}
+	version, err = strconv.Atoi(strings.TrimPrefix(hello, protocolMagic))
+	if err != nil {
+		err = errors.New(ProtocolVersionError.Error() + "，握手标识[" + hello + "]错误")
+		return
+	}
+	if version < MinProtocolVersion {
+		err = errors.New(ProtocolVersionError.Error() + fmt.Sprintf("，对端节点协议版本[%d]低于最低支持版本[%d]，请升级节点", version, MinProtocolVersion))
+		return
+	}
+	return
+}
+
+// clientHandshake 客户端 握手
+// 1.发送握手标识 2.读取服务端随机数 3.发送客户端证明和随机数 4.校验服务端证明
+func clientHandshake(conn net.Conn, token string, connData *ConnData, MonitorData *MonitorData) (serverConnData *ConnData, err error) {
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer func() {
+		_ = conn.SetDeadline(time.Time{})
+	}()
+
+	err = writeHello(conn)
+	if err != nil {
+		return
+	}
+
+	msg, err := readMessageLimit(conn, MonitorData, handshakeMaxLength)
+	if err != nil {
+		if err == io.EOF {
+			err = errors.New("连接被关闭，对端节点可能为旧版本或已拒绝连接")
+		}
+		return
+	}
+	if msg.Error != "" {
+		err = errors.New(msg.Error)
+		return
+	}
+	if msg.ConnData == nil || msg.ConnData.Nonce == "" {
+		err = errors.New(ProtocolVersionError.Error() + "，服务端未返回握手随机数")
+		return
+	}
+	if msg.ConnData.Version < MinProtocolVersion {
+		err = errors.New(ProtocolVersionError.Error() + fmt.Sprintf("，服务端协议版本[%d]低于最低支持版本[%d]", msg.ConnData.Version, MinProtocolVersion))
+		return
+	}
+	serverNonce := msg.ConnData.Nonce
+
+	connData.Version = ProtocolVersion
+	connData.CompressList = CompressList
+	connData.Nonce, err = newNonce()
+	if err != nil {
+		return
+	}
+	connData.Proof = clientProof(token, serverNonce, connData)
+	err = WriteMessage(conn, &Message{
+		Method:   methodOK,
+		ConnData: connData,
+	}, MonitorData)
+	if err != nil {
+		return
+	}
+
+	msg, err = readMessageLimit(conn, MonitorData, handshakeMaxLength)
+	if err != nil {
+		return
+	}
+	if msg.Error != "" {
+		err = errors.New(msg.Error)
+		return
+	}
+	if msg.ConnData == nil || msg.ConnData.NodeId == "" {
+		err = errors.New(AuthError.Error() + "，服务端未返回节点信息")
+		return
+	}
+	if msg.ConnData.Nonce != serverNonce || !verifyProof(msg.ConnData.Proof, serverProof(token, connData.Nonce, msg.ConnData)) {
+		err = errors.New(AuthError.Error() + "，服务端证明校验失败")
+		return
+	}
+	if msg.ConnData.Compress != "" && negotiateCompress([]string{msg.ConnData.Compress}) == "" {
+		err = errors.New(ProtocolVersionError.Error() + "，服务端选择的压缩算法[" + msg.ConnData.Compress + "]不支持")
+		return
+	}
+	serverConnData = msg.ConnData
+	return
+}
+
+// serverHandshake 服务端 握手，返回的 clientConnData.Compress 为协商的压缩算法
+func serverHandshake(conn net.Conn, localNode *LocalNode, MonitorData *MonitorData) (clientConnData *ConnData, err error) {
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer func() {
+		_ = conn.SetDeadline(time.Time{})
+	}()
+
+	_, err = readHello(conn)
+	if err != nil {
+		_ = WriteMessage(conn, &Message{Error: err.Error()}, MonitorData)
+		return
+	}
+
+	serverNonce, err := newNonce()
+	if err != nil {
+		return
+	}
+	err = WriteMessage(conn, &Message{
+		ConnData: &ConnData{
+			Version: ProtocolVersion,
+			Nonce:   serverNonce,
+		},
+	}, MonitorData)
+	if err != nil {
+		return
+	}
+
+	msg, err := readMessageLimit(conn, MonitorData, handshakeMaxLength)
+	if err != nil {
+		return
+	}
+	if msg.ConnData == nil || msg.ConnData.Nonce == "" || len(msg.ConnData.NodeIdList) == 0 {
+		err = errors.New(AuthError.Error() + "，客户端握手数据异常")
+		_ = WriteMessage(conn, &Message{Error: err.Error()}, MonitorData)
+		return
+	}
+	if !verifyProof(msg.ConnData.Proof, clientProof(localNode.BindToken, serverNonce, msg.ConnData)) {
+		err = errors.New(AuthError.Error() + "，Token验证失败")
+		_ = WriteMessage(conn, &Message{Error: err.Error()}, MonitorData)
+		return
+	}
+	clientConnData = msg.ConnData
+
+	connData := &ConnData{
+		Version: ProtocolVersion,
+		NodeId:  localNode.Id,
+		Nonce:   serverNonce,
+	}
+	if IsBinaryVersion(negotiateVersion(clientConnData.Version)) {
+		connData.Compress = negotiateCompress(clientConnData.CompressList)
+	}
+	clientConnData.Compress = connData.Compress
+	connData.Proof = serverProof(localNode.BindToken, clientConnData.Nonce, connData)
+	err = WriteMessage(conn, &Message{
+		ConnData: connData,
+	}, MonitorData)
+	return
+}
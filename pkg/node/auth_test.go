@@ -0,0 +1,117 @@
+package node
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func testHandshake(clientToken string, serverToken string) (clientErr error, serverErr error) {
+	clientConn, serverConn := net.Pipe()
+	defer func() {
+		_ = clientConn.Close()
+		_ = serverConn.Close()
+	}()
+
+	localNode := &LocalNode{Id: "server", BindToken: serverToken}
+	done := make(chan error, 1)
+	go func() {
+		_, e := serverHandshake(serverConn, localNode, &MonitorData{})
+		if e != nil {
+			_ = serverConn.Close()
+		}
+		done <- e
+	}()
+
+	serverConnData, clientErr := clientHandshake(clientConn, clientToken, &ConnData{
+		NodeIdList: []string{"client"},
+	}, &MonitorData{})
+	if clientErr == nil && (serverConnData.NodeId != "server" || serverConnData.Compress != CompressList[0]) {
+		clientErr = AuthError
+	}
+	serverErr = <-done
+	return
+}
+
+func TestHandshake(t *testing.T) {
+	clientErr, serverErr := testHandshake("token", "token")
+	if clientErr != nil || serverErr != nil {
+		t.Fatal("handshake error:", clientErr, serverErr)
+	}
+
+	clientErr, serverErr = testHandshake("wrong", "token")
+	if clientErr == nil || serverErr == nil || !strings.HasPrefix(serverErr.Error(), AuthError.Error()) {
+		t.Fatal("wrong token should be rejected:", clientErr, serverErr)
+	}
+}
+
+func TestHandshakeOldClient(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer func() {
+		_ = clientConn.Close()
+		_ = serverConn.Close()
+	}()
+
+	go func() {
+		// 旧版本客户端 直接发送 Token
+		var tokenBytes = []byte("token")
+		for i := len(tokenBytes); i < tokenByteSize; i++ {
+			tokenBytes = append(tokenBytes, ' ')
+		}
+		_, _ = clientConn.Write(tokenBytes)
+		msg, _ := ReadMessage(clientConn, &MonitorData{})
+		if msg == nil || msg.Error == "" {
+			t.Error("old client should receive error message")
+		}
+	}()
+
+	_, err := serverHandshake(serverConn, &LocalNode{Id: "server", BindToken: "token"}, &MonitorData{})
+	if err == nil || !strings.HasPrefix(err.Error(), ProtocolVersionError.Error()) {
+		t.Fatal("old client should be rejected with protocol version error:", err)
+	}
+}
+
+func TestHandshakeMaxLength(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer func() {
+		_ = clientConn.Close()
+		_ = serverConn.Close()
+	}()
+
+	go func() {
+		if writeHello(clientConn) != nil {
+			return
+		}
+		if _, err := ReadMessage(clientConn, &MonitorData{}); err != nil {
+			return
+		}
+		// 未认证的客户端发送超长的长度
+		_, _ = clientConn.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}()
+
+	_, err := serverHandshake(serverConn, &LocalNode{Id: "server", BindToken: "token"}, &MonitorData{})
+	if err != LengthError {
+		t.Fatal("handshake message length should be checked:", err)
+	}
+}
+
+func TestReadBytesMaxLength(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteBytes(&buf, []byte("abc"), &MonitorData{}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if _, err := ReadBytes(bytes.NewReader(data), &MonitorData{}, 2); err != LengthError {
+		t.Fatal("length over max should be rejected:", err)
+	}
+	bs, err := ReadBytes(iotest.OneByteReader(bytes.NewReader(data)), &MonitorData{}, 3)
+	if err != nil || string(bs) != "abc" {
+		t.Fatal("read bytes error:", string(bs), err)
+	}
+	if _, err = ReadBytes(bytes.NewReader(data[:5]), &MonitorData{}, 3); err != io.ErrUnexpectedEOF {
+		t.Fatal("short bytes should be error:", err)
+	}
+}
@@ -0,0 +1,126 @@
+package node
+
+import (
+	"errors"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"strings"
+	"sync"
+)
+
+const (
+	CompressZstd   = "zstd"
+	CompressSnappy = "snappy"
+)
+
+var (
+	// CompressList 本节点支持的压缩算法，按优先级排序，为空则不压缩
+	CompressList = []string{CompressZstd, CompressSnappy}
+	// CompressThreshold 帧内容超过该大小才压缩，避免终端等小帧增加开销
+	CompressThreshold = 512
+
+	CompressError = errors.New("压缩数据异常")
+)
+
+// Compressor 节点连接压缩算法
+type Compressor interface {
+	Compress(src []byte) ([]byte, error)
+	Decompress(src []byte) ([]byte, error)
+}
+
+var compressorMap = map[string]Compressor{
+	CompressZstd:   &zstdCompressor{},
+	CompressSnappy: &snappyCompressor{},
+}
+
+// GetCompressor 获取压缩算法，不支持返回 nil
+func GetCompressor(name string) Compressor {
+	return compressorMap[name]
+}
+
+// ParseCompressList 解析逗号分隔的压缩算法，none 表示不压缩
+func ParseCompressList(str string) (list []string, err error) {
+	for _, name := range strings.Split(str, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "none" {
+			continue
+		}
+		if GetCompressor(name) == nil {
+			err = errors.New("不支持的压缩算法[" + name + "]")
+			return
+		}
+		list = append(list, name)
+	}
+	return
+}
+
+// negotiateCompress 服务端 按客户端的优先级选择双方都支持的压缩算法
+func negotiateCompress(clientCompressList []string) string {
+	for _, name := range clientCompressList {
+		for _, one := range CompressList {
+			if name == one {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+type zstdCompressor struct {
+	once    sync.Once
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+	err     error
+}
+
+func (this_ *zstdCompressor) init() error {
+	this_.once.Do(func() {
+		this_.encoder, this_.err = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+		if this_.err != nil {
+			return
+		}
+		this_.decoder, this_.err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(uint64(MaxFrameSize)))
+	})
+	return this_.err
+}
+
+func (this_ *zstdCompressor) Compress(src []byte) (res []byte, err error) {
+	if err = this_.init(); err != nil {
+		return
+	}
+	res = this_.encoder.EncodeAll(src, nil)
+	return
+}
+
+func (this_ *zstdCompressor) Decompress(src []byte) (res []byte, err error) {
+	if err = this_.init(); err != nil {
+		return
+	}
+	res, err = this_.decoder.DecodeAll(src, nil)
+	if err != nil {
+		err = errors.New(CompressError.Error() + "，" + err.Error())
+	}
+	return
+}
+
+type snappyCompressor struct {
+}
+
+func (this_ *snappyCompressor) Compress(src []byte) (res []byte, err error) {
+	res = snappy.Encode(nil, src)
+	return
+}
+
+func (this_ *snappyCompressor) Decompress(src []byte) (res []byte, err error) {
+	size, err := snappy.DecodedLen(src)
+	if err != nil || size > MaxFrameSize {
+		err = CompressError
+		return
+	}
+	res, err = snappy.Decode(nil, src)
+	if err != nil {
+		err = errors.New(CompressError.Error() + "，" + err.Error())
+		return
+	}
+	return
+}
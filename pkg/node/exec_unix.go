@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package node
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setExecProcessGroup 命令在独立的进程组中运行，终止时一起终止 Shell 启动的子进程
+func setExecProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killExecProcess(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
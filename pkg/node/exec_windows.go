@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package node
+
+import (
+	"os/exec"
+)
+
+func setExecProcessGroup(cmd *exec.Cmd) {
+}
+
+func killExecProcess(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	_ = cmd.Process.Kill()
+}
@@ -8,7 +8,7 @@ import (
 	"go.uber.org/zap"
 	"io"
 	"net"
-	"sync"
+	"sync/atomic"
 	"teamide/pkg/filework"
 	"teamide/pkg/system"
 	"teamide/pkg/terminal"
@@ -23,6 +23,8 @@ type Message struct {
 	Id                 string            `json:"id,omitempty"`
 	Method             MethodType        `json:"method,omitempty"`
 	Error              string            `json:"error,omitempty"`
+	ErrorCode          string            `json:"errorCode,omitempty"`
+	CancelId           string            `json:"cancelId,omitempty"`
 	NotifiedNodeIdList []string          `json:"notifiedNodeIdList,omitempty"`
 	LineNodeIdList     []string          `json:"lineNodeIdList,omitempty"`
 	ConnData           *ConnData         `json:"connData,omitempty"`
@@ -31,8 +33,11 @@ type Message struct {
 	FileWorkData       *FileWorkData     `json:"fileWorkData,omitempty"`
 	TerminalWorkData   *TerminalWorkData `json:"terminalWorkData,omitempty"`
 	SystemData         *SystemData       `json:"systemData,omitempty"`
+	ExecWorkData       *ExecWorkData     `json:"execWorkData,omitempty"`
 	HasBytes           bool              `json:"hasBytes,omitempty"`
 	SendKey            string            `json:"sendKey,omitempty"`
+	Priority           int8              `json:"priority,omitempty"`
+	StreamWindow       int64             `json:"streamWindow,omitempty"`
 	Bytes              []byte            `json:"-"`
 	listener           *MessageListener
 }
@@ -40,8 +45,13 @@ type Message struct {
 type ConnData struct {
 	ConnIndex  int      `json:"connIndex,omitempty"`
 	NodeId     string   `json:"nodeId,omitempty"`
-	NodeToken  string   `json:"nodeToken,omitempty"`
 	NodeIdList []string `json:"nodeIdList,omitempty"`
+	Version    int      `json:"version,omitempty"`
+	Nonce      string   `json:"nonce,omitempty"`
+	Proof      string   `json:"proof,omitempty"`
+
+	CompressList []string `json:"compressList,omitempty"` // 客户端支持的压缩算法
+	Compress     string   `json:"compress,omitempty"`     // 服务端选择的压缩算法
 }
 
 type SystemData struct {
@@ -50,6 +60,10 @@ type SystemData struct {
 	QueryResponse *system.QueryResponse `json:"queryResponse,omitempty"`
 	Info          *system.Info          `json:"info,omitempty"`
 	MonitorData   *system.MonitorData   `json:"monitorData,omitempty"`
+
+	ProcessRequest  *system.ProcessQueryRequest  `json:"processRequest,omitempty"`
+	ProcessResponse *system.ProcessQueryResponse `json:"processResponse,omitempty"`
+	ProcessSignal   *system.ProcessSignalRequest `json:"processSignal,omitempty"`
 }
 
 type WorkData struct {
@@ -60,12 +74,17 @@ type WorkData struct {
 	Version     string       `json:"version,omitempty"`
 	MonitorData *MonitorData `json:"monitorData,omitempty"`
 	Status      int8         `json:"status,omitempty"`
+	RouteList   []*RouteNode `json:"routeList,omitempty"`
+
+	UpgradeRequest *UpgradeRequest `json:"upgradeRequest,omitempty"`
+	UpgradeInfo    *UpgradeInfo    `json:"upgradeInfo,omitempty"`
 }
 
 type NetProxyWorkData struct {
 	NetProxyId        string           `json:"netProxyId,omitempty"`
 	ConnId            string           `json:"connId,omitempty"`
 	IsReverse         bool             `json:"isReverse,omitempty"`
+	Address           string           `json:"address,omitempty"` // 动态代理 目标地址
 	MonitorData       *MonitorData     `json:"monitorData,omitempty"`
 	NetProxyInnerList []*NetProxyInner `json:"netProxyInnerList,omitempty"`
 	NetProxyOuterList []*NetProxyOuter `json:"netProxyOuterList,omitempty"`
@@ -84,6 +103,12 @@ type FileWorkData struct {
 	Exist       bool                 `json:"exist,omitempty"`
 	FileCount   int                  `json:"fileCount,omitempty"`
 	RemoveCount int                  `json:"removeCount,omitempty"`
+	Size        int64                `json:"size,omitempty"`
+	Offset      int64                `json:"offset,omitempty"`   // 断点续传 写入、读取的位置
+	Md5         string               `json:"md5,omitempty"`      // 文件 MD5
+	Checksum    uint32               `json:"checksum,omitempty"` // 数据块 CRC32
+	Truncate    bool                 `json:"truncate,omitempty"` // 断点续传 清空已上传的临时文件
+	UploadId    string               `json:"uploadId,omitempty"` // 断点续传 上传 ID，每次上传使用自己的临时文件
 }
 
 type TerminalWorkData struct {
@@ -93,6 +118,12 @@ type TerminalWorkData struct {
 	IsWindows bool           `json:"isWindows,omitempty"`
 }
 
+type ExecWorkData struct {
+	Key     string       `json:"key,omitempty"`
+	Request *ExecRequest `json:"request,omitempty"`
+	Result  *ExecResult  `json:"result,omitempty"`
+}
+
 type StatusChange struct {
 	Id          string `json:"id,omitempty"`
 	Status      int8   `json:"status,omitempty"`
@@ -116,6 +147,7 @@ func (this_ *Message) Return(msg *Message, MonitorData *MonitorData) (err error)
 		return
 	}
 	msg.Id = this_.Id
+	msg.Priority = this_.Priority
 	err = this_.listener.Send(msg, MonitorData)
 	if err != nil {
 		return
@@ -125,23 +157,35 @@ func (this_ *Message) Return(msg *Message, MonitorData *MonitorData) (err error)
 
 type MessageListener struct {
 	conn      net.Conn
+	version   int        // 协商的协议版本
+	compress  Compressor // 协商的压缩算法
 	onMessage func(msg *Message)
-	isClose   bool
-	isStop    bool
-	writeMu   sync.Mutex
+	isClose   int32 // 原子操作，读取消息结束后为 1
+	isStop    int32 // 原子操作，主动停止后为 1
+	writeScheduler
+	streamSpace
 }
 
 func (this_ *MessageListener) stop() {
-	this_.isStop = true
+	atomic.StoreInt32(&this_.isStop, 1)
 	_ = this_.conn.Close()
 }
 
+func (this_ *MessageListener) isClosed() bool {
+	return atomic.LoadInt32(&this_.isClose) == 1
+}
+
+func (this_ *MessageListener) isStopped() bool {
+	return atomic.LoadInt32(&this_.isStop) == 1
+}
+
 func (this_ *MessageListener) listen(onClose func(), MonitorData *MonitorData) {
 	var err error
-	this_.isClose = false
+	atomic.StoreInt32(&this_.isClose, 0)
 	go func() {
 		defer func() {
-			this_.isClose = true
+			atomic.StoreInt32(&this_.isClose, 1)
+			this_.closeStreams()
 			if x := recover(); x != nil {
 				Logger.Error("message listen error", zap.Error(err))
 			}
@@ -150,13 +194,13 @@ func (this_ *MessageListener) listen(onClose func(), MonitorData *MonitorData) {
 		}()
 
 		for {
-			if this_.isStop {
+			if this_.isStopped() {
 				return
 			}
 			var msg *Message
-			msg, err = ReadMessage(this_.conn, MonitorData)
+			msg, err = ReadMessageByVersion(this_.conn, MonitorData, this_.version, this_.compress)
 			if err != nil {
-				if this_.isStop {
+				if this_.isStopped() {
 					return
 				}
 				if err == io.EOF {
@@ -165,6 +209,20 @@ func (this_ *MessageListener) listen(onClose func(), MonitorData *MonitorData) {
 				return
 			}
 			msg.listener = this_
+			if IsStreamVersion(this_.version) {
+				switch msg.Method {
+				case methodStreamWindow:
+					this_.onStreamWindow(msg)
+					continue
+				case methodStreamReset:
+					this_.onStreamReset(msg)
+					continue
+				}
+				if key := msg.getStreamKey(); key != "" {
+					this_.pushStream(key, msg, MonitorData)
+					continue
+				}
+			}
 			go this_.onMessage(msg)
 		}
 	}()
@@ -174,20 +232,31 @@ func (this_ *MessageListener) Send(msg *Message, MonitorData *MonitorData) (err
 	if msg == nil {
 		return
 	}
-	if this_.isClose {
+	if this_.isClosed() {
 		err = ConnClosedError
 		return
 	}
-	this_.writeMu.Lock()
-	defer this_.writeMu.Unlock()
-	err = WriteMessage(this_.conn, msg, MonitorData)
+	if msg.Priority != PriorityNormal && !IsStreamVersion(this_.version) {
+		// 旧版本节点不支持优先级字段
+		sendMsg := *msg
+		sendMsg.Priority = PriorityNormal
+		msg = &sendMsg
+	}
+	this_.acquire(msg.Priority)
+	defer this_.release()
+	err = WriteMessageByVersion(this_.conn, msg, MonitorData, this_.version, this_.compress)
 	return
 }
 
 func ReadMessage(reader io.Reader, MonitorData *MonitorData) (message *Message, err error) {
+	return readMessageLimit(reader, MonitorData, MaxFrameSize)
+}
+
+// readMessageLimit 读取消息，消息和附带的字节长度都不能超过 maxLength
+func readMessageLimit(reader io.Reader, MonitorData *MonitorData, maxLength int) (message *Message, err error) {
 	var bytes []byte
 
-	bytes, err = ReadBytes(reader, MonitorData)
+	bytes, err = ReadBytes(reader, MonitorData, maxLength)
 	if err != nil {
 		return
 	}
@@ -199,7 +268,7 @@ func ReadMessage(reader io.Reader, MonitorData *MonitorData) (message *Message,
 		return
 	}
 	if message.HasBytes {
-		bytes, err = ReadBytes(reader, MonitorData)
+		bytes, err = ReadBytes(reader, MonitorData, maxLength)
 		if err != nil {
 			return
 		}
@@ -226,51 +295,33 @@ func WriteMessage(writer io.Writer, message *Message, MonitorData *MonitorData)
 	return
 }
 
-func ReadBytes(reader io.Reader, MonitorData *MonitorData) (bytes []byte, err error) {
+// ReadBytes 读取长度和字节，长度由对端发送，超过 maxLength 时返回 LengthError，不再分配内存
+func ReadBytes(reader io.Reader, MonitorData *MonitorData, maxLength int) (bytes []byte, err error) {
 
 	start := util.GetNow().UnixNano()
 
-	var buf []byte
-	var n int
-
-	buf = make([]byte, 4)
-	n, err = reader.Read(buf)
+	var buf = make([]byte, 4)
+	_, err = io.ReadFull(reader, buf)
 	if err != nil {
 		return
 	}
-	if n < 4 {
-		err = LengthError
-		return
-	}
 
 	length := int(binary.LittleEndian.Uint32(buf))
-	if length < 0 {
+	if length < 0 || length > maxLength {
 		err = LengthError
 		return
 	}
 
 	if length > 0 {
-		var hasLen = length
-		for {
-
-			bs := make([]byte, hasLen)
-			n, err = reader.Read(bs)
-			if err != nil && err != io.EOF {
-				return
-			}
-			hasLen = hasLen - n
-			bytes = append(bytes, bs[0:n]...)
-			//Logger.Info("ReadBytes", zap.Any("should read", length), zap.Any("has size", hasLen))
-			if hasLen <= 0 {
-				break
-			}
-			if err == io.EOF {
-				break
-			}
+		bytes = make([]byte, length)
+		_, err = io.ReadFull(reader, bytes)
+		if err != nil {
+			return
 		}
 	}
 	end := util.GetNow().UnixNano()
-	MonitorData.monitorWrite(int64(length+4), end-start)
+	MonitorData.MonitorRead(int64(length+4), end-start)
+	MonitorData.MonitorReadRaw(int64(length + 4))
 	return
 }
 
@@ -302,6 +353,7 @@ func WriteBytes(writer io.Writer, bytes []byte, MonitorData *MonitorData) (err e
 		return
 	}
 	end := util.GetNow().UnixNano()
-	MonitorData.monitorWrite(int64(length), end-start)
+	MonitorData.MonitorWrite(int64(length), end-start)
+	MonitorData.MonitorWriteRaw(int64(length))
 	return
 }
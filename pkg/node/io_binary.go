@@ -0,0 +1,469 @@
+package node
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"io"
+	"net"
+)
+
+// 二进制帧格式（协议版本 >= BinaryProtocolVersion 时使用）
+//
+//	uint32 帧长度（不包含自身）
+//	uint16 方法
+//	uint16 标识                     标识 binaryFlagCompress 时以下内容整体压缩
+//	str8   消息ID
+//	int8   优先级                   标识 binaryFlagPriority
+//	str16  错误信息                 标识 binaryFlagError
+//	str8   SendKey                  标识 binaryFlagSendKey
+//	uint8  节点线数量 + str8 * 数量   标识 binaryFlagLine
+//	str8   代理ID + str8 连接ID      标识 binaryFlagNetProxy
+//	str8   终端Key                  标识 binaryFlagTerminal
+//	uint32 元数据长度 + JSON          标识 binaryFlagMeta 其它字段使用 JSON 编码
+//	剩余   Bytes                    标识 binaryFlagBytes
+//
+// 热点方法（代理发送、终端写入、流发送）的字段全部使用二进制编码，不需要 JSON 编解码
+const (
+	binaryFlagBytes uint16 = 1 << iota
+	binaryFlagError
+	binaryFlagSendKey
+	binaryFlagLine
+	binaryFlagNetProxy
+	binaryFlagNetProxyReverse
+	binaryFlagTerminal
+	binaryFlagMeta
+	binaryFlagPriority
+	binaryFlagCompress
+)
+
+var (
+	// BinaryProtocolVersion 开始支持二进制帧的协议版本
+	BinaryProtocolVersion = 3
+	// MaxFrameSize 最大帧长度
+	MaxFrameSize = 64 * 1024 * 1024
+
+	FrameFormatError = errors.New("二进制帧格式错误")
+)
+
+// IsBinaryVersion 协商后的协议版本是否使用二进制帧
+func IsBinaryVersion(version int) bool {
+	return version >= BinaryProtocolVersion
+}
+
+// ReadMessageByVersion 根据协商的协议版本、压缩算法读取消息
+func ReadMessageByVersion(reader io.Reader, MonitorData *MonitorData, version int, compressor Compressor) (message *Message, err error) {
+	if IsBinaryVersion(version) {
+		return ReadBinaryMessage(reader, MonitorData, compressor)
+	}
+	return ReadMessage(reader, MonitorData)
+}
+
+// WriteMessageByVersion 根据协商的协议版本、压缩算法写入消息
+func WriteMessageByVersion(writer io.Writer, message *Message, MonitorData *MonitorData, version int, compressor Compressor) (err error) {
+	if IsBinaryVersion(version) {
+		return WriteBinaryMessage(writer, message, MonitorData, compressor)
+	}
+	return WriteMessage(writer, message, MonitorData)
+}
+
+// splitMeta 拆分出需要 JSON 编码的字段，二进制编码的字段不在元数据中
+func splitMeta(message *Message) (flags uint16, meta *Message) {
+	meta = &Message{}
+	*meta = *message
+	meta.Id = ""
+	meta.Method = 0
+	meta.Error = ""
+	meta.SendKey = ""
+	meta.Priority = 0
+	meta.LineNodeIdList = nil
+	meta.HasBytes = false
+	meta.Bytes = nil
+	meta.listener = nil
+
+	if message.HasBytes {
+		flags |= binaryFlagBytes
+	}
+	if message.Priority != 0 {
+		flags |= binaryFlagPriority
+	}
+	if message.Error != "" {
+		flags |= binaryFlagError
+	}
+	if message.SendKey != "" {
+		flags |= binaryFlagSendKey
+	}
+	if len(message.LineNodeIdList) > 0 {
+		flags |= binaryFlagLine
+	}
+	if data := message.NetProxyWorkData; data != nil && isSimpleNetProxyWorkData(data) {
+		flags |= binaryFlagNetProxy
+		if data.IsReverse {
+			flags |= binaryFlagNetProxyReverse
+		}
+		meta.NetProxyWorkData = nil
+	}
+	if data := message.TerminalWorkData; data != nil && data.Size == nil && data.ReadKey == "" && !data.IsWindows {
+		flags |= binaryFlagTerminal
+		meta.TerminalWorkData = nil
+	}
+	if meta.NotifiedNodeIdList != nil || meta.ConnData != nil || meta.NodeWorkData != nil || meta.NetProxyWorkData != nil ||
+		meta.FileWorkData != nil || meta.TerminalWorkData != nil || meta.SystemData != nil || meta.ExecWorkData != nil || meta.StreamWindow != 0 ||
+		meta.ErrorCode != "" || meta.CancelId != "" {
+		flags |= binaryFlagMeta
+	} else {
+		meta = nil
+	}
+	return
+}
+
+func isSimpleNetProxyWorkData(data *NetProxyWorkData) bool {
+	return data.Address == "" && data.MonitorData == nil && data.NetProxyInnerList == nil && data.NetProxyOuterList == nil &&
+		data.NetProxyIdList == nil && data.Status == 0
+}
+
+func appendUint16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v), byte(v>>8))
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendStr8(buf []byte, str string) ([]byte, error) {
+	if len(str) > 0xFF {
+		return buf, errors.New(FrameFormatError.Error() + "，字段[" + str + "]过长")
+	}
+	buf = append(buf, byte(len(str)))
+	return append(buf, str...), nil
+}
+
+func appendStr16(buf []byte, str string) []byte {
+	if len(str) > 0xFFFF {
+		str = str[:0xFFFF]
+	}
+	buf = appendUint16(buf, uint16(len(str)))
+	return append(buf, str...)
+}
+
+// EncodeBinaryMessage 编码二进制帧，包含帧长度，compressor 不为空时超过阈值的帧进行压缩
+func EncodeBinaryMessage(message *Message, compressor Compressor) (frame []byte, err error) {
+	frame, err = encodeBinaryHeader(message)
+	if err != nil {
+		return
+	}
+	if compressed := compressBinaryFrame(frame, message, compressor); compressed != nil {
+		frame = compressed
+		return
+	}
+	if message.HasBytes {
+		frame = append(frame, message.Bytes...)
+	}
+	return
+}
+
+// compressBinaryFrame 压缩帧头之后的内容（包含 Bytes），未达到阈值或压缩后没有变小时返回 nil
+func compressBinaryFrame(header []byte, message *Message, compressor Compressor) (frame []byte) {
+	if compressor == nil {
+		return
+	}
+	size := len(header) - 8
+	if message.HasBytes {
+		size += len(message.Bytes)
+	}
+	if size < CompressThreshold {
+		return
+	}
+	body := make([]byte, 0, size)
+	body = append(body, header[8:]...)
+	if message.HasBytes {
+		body = append(body, message.Bytes...)
+	}
+	compressed, err := compressor.Compress(body)
+	if err != nil || len(compressed) >= size {
+		return
+	}
+	frame = make([]byte, 8, 8+len(compressed))
+	copy(frame, header[:8])
+	flags := binary.LittleEndian.Uint16(frame[6:])
+	binary.LittleEndian.PutUint16(frame[6:], flags|binaryFlagCompress)
+	frame = append(frame, compressed...)
+	binary.LittleEndian.PutUint32(frame, uint32(len(frame)-4))
+	return
+}
+
+// encodeBinaryHeader 编码二进制帧头，帧长度包含 Bytes，但不拷贝 Bytes
+func encodeBinaryHeader(message *Message) (frame []byte, err error) {
+	flags, meta := splitMeta(message)
+	var metaBytes []byte
+	if meta != nil {
+		metaBytes, err = json.Marshal(meta)
+		if err != nil {
+			return
+		}
+	}
+
+	size := 4 + 2 + 2 + 1 + len(message.Id) + len(metaBytes) + 64
+	frame = make([]byte, 4, size)
+	frame = appendUint16(frame, uint16(message.Method))
+	frame = appendUint16(frame, flags)
+	if frame, err = appendStr8(frame, message.Id); err != nil {
+		return
+	}
+	if flags&binaryFlagPriority != 0 {
+		frame = append(frame, byte(message.Priority))
+	}
+	if flags&binaryFlagError != 0 {
+		frame = appendStr16(frame, message.Error)
+	}
+	if flags&binaryFlagSendKey != 0 {
+		if frame, err = appendStr8(frame, message.SendKey); err != nil {
+			return
+		}
+	}
+	if flags&binaryFlagLine != 0 {
+		if len(message.LineNodeIdList) > 0xFF {
+			err = errors.New(FrameFormatError.Error() + "，节点线过长")
+			return
+		}
+		frame = append(frame, byte(len(message.LineNodeIdList)))
+		for _, nodeId := range message.LineNodeIdList {
+			if frame, err = appendStr8(frame, nodeId); err != nil {
+				return
+			}
+		}
+	}
+	if flags&binaryFlagNetProxy != 0 {
+		if frame, err = appendStr8(frame, message.NetProxyWorkData.NetProxyId); err != nil {
+			return
+		}
+		if frame, err = appendStr8(frame, message.NetProxyWorkData.ConnId); err != nil {
+			return
+		}
+	}
+	if flags&binaryFlagTerminal != 0 {
+		if frame, err = appendStr8(frame, message.TerminalWorkData.Key); err != nil {
+			return
+		}
+	}
+	if flags&binaryFlagMeta != 0 {
+		frame = appendUint32(frame, uint32(len(metaBytes)))
+		frame = append(frame, metaBytes...)
+	}
+	length := len(frame) - 4
+	if flags&binaryFlagBytes != 0 {
+		length += len(message.Bytes)
+	}
+	if length > MaxFrameSize {
+		err = LengthError
+		return
+	}
+	binary.LittleEndian.PutUint32(frame, uint32(length))
+	return
+}
+
+type frameReader struct {
+	buf   []byte
+	index int
+	err   error
+}
+
+func (this_ *frameReader) next(n int) (bs []byte) {
+	if this_.err != nil {
+		return
+	}
+	if n < 0 || this_.index+n > len(this_.buf) {
+		this_.err = FrameFormatError
+		return
+	}
+	bs = this_.buf[this_.index : this_.index+n]
+	this_.index += n
+	return
+}
+
+func (this_ *frameReader) uint8() int {
+	bs := this_.next(1)
+	if bs == nil {
+		return 0
+	}
+	return int(bs[0])
+}
+
+func (this_ *frameReader) uint16() int {
+	bs := this_.next(2)
+	if bs == nil {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint16(bs))
+}
+
+func (this_ *frameReader) uint32() int {
+	bs := this_.next(4)
+	if bs == nil {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint32(bs))
+}
+
+func (this_ *frameReader) str8() string {
+	return string(this_.next(this_.uint8()))
+}
+
+func (this_ *frameReader) str16() string {
+	return string(this_.next(this_.uint16()))
+}
+
+// DecodeBinaryMessage 解码二进制帧，不包含帧长度，未压缩时 Bytes 直接引用 frame
+func DecodeBinaryMessage(frame []byte, compressor Compressor) (message *Message, err error) {
+	message, _, err = decodeBinaryMessage(frame, compressor)
+	return
+}
+
+// decodeBinaryMessage 解码二进制帧，返回解压后的帧长度
+func decodeBinaryMessage(frame []byte, compressor Compressor) (message *Message, rawLength int, err error) {
+	rawLength = len(frame)
+	reader := &frameReader{buf: frame}
+	method := MethodType(reader.uint16())
+	flags := uint16(reader.uint16())
+	if reader.err == nil && flags&binaryFlagCompress != 0 {
+		if compressor == nil {
+			err = errors.New(FrameFormatError.Error() + "，未协商压缩算法")
+			return
+		}
+		var body []byte
+		body, err = compressor.Decompress(frame[reader.index:])
+		if err != nil {
+			return
+		}
+		rawLength = reader.index + len(body)
+		reader = &frameReader{buf: body}
+	}
+	id := reader.str8()
+
+	message = &Message{}
+	if flags&binaryFlagPriority != 0 {
+		message.Priority = int8(reader.uint8())
+	}
+	if flags&binaryFlagError != 0 {
+		message.Error = reader.str16()
+	}
+	if flags&binaryFlagSendKey != 0 {
+		message.SendKey = reader.str8()
+	}
+	if flags&binaryFlagLine != 0 {
+		count := reader.uint8()
+		message.LineNodeIdList = make([]string, 0, count)
+		for i := 0; i < count; i++ {
+			message.LineNodeIdList = append(message.LineNodeIdList, reader.str8())
+		}
+	}
+	var netProxyWorkData *NetProxyWorkData
+	if flags&binaryFlagNetProxy != 0 {
+		netProxyWorkData = &NetProxyWorkData{
+			NetProxyId: reader.str8(),
+			ConnId:     reader.str8(),
+			IsReverse:  flags&binaryFlagNetProxyReverse != 0,
+		}
+	}
+	var terminalWorkData *TerminalWorkData
+	if flags&binaryFlagTerminal != 0 {
+		terminalWorkData = &TerminalWorkData{
+			Key: reader.str8(),
+		}
+	}
+	if flags&binaryFlagMeta != 0 {
+		metaBytes := reader.next(reader.uint32())
+		if reader.err == nil {
+			err = json.Unmarshal(metaBytes, message)
+			if err != nil {
+				return
+			}
+		}
+	}
+	if reader.err != nil {
+		err = reader.err
+		return
+	}
+	message.Id = id
+	message.Method = method
+	if netProxyWorkData != nil {
+		message.NetProxyWorkData = netProxyWorkData
+	}
+	if terminalWorkData != nil {
+		message.TerminalWorkData = terminalWorkData
+	}
+	if flags&binaryFlagBytes != 0 {
+		message.HasBytes = true
+		message.Bytes = reader.buf[reader.index:]
+	}
+	return
+}
+
+// ReadBinaryMessage 读取二进制帧消息
+func ReadBinaryMessage(reader io.Reader, MonitorData *MonitorData, compressor Compressor) (message *Message, err error) {
+	start := util.GetNow().UnixNano()
+
+	var header = make([]byte, 4)
+	_, err = io.ReadFull(reader, header)
+	if err != nil {
+		return
+	}
+	length := int(binary.LittleEndian.Uint32(header))
+	if length <= 0 || length > MaxFrameSize {
+		err = LengthError
+		return
+	}
+	frame := make([]byte, length)
+	_, err = io.ReadFull(reader, frame)
+	if err != nil {
+		return
+	}
+	message, rawLength, err := decodeBinaryMessage(frame, compressor)
+	if err != nil {
+		return
+	}
+	end := util.GetNow().UnixNano()
+	MonitorData.MonitorRead(int64(length+4), end-start)
+	MonitorData.MonitorReadRaw(int64(rawLength + 4))
+	return
+}
+
+// WriteBinaryMessage 写入二进制帧消息，未压缩时帧头和 Bytes 合并写入，不拷贝 Bytes
+func WriteBinaryMessage(writer io.Writer, message *Message, MonitorData *MonitorData, compressor Compressor) (err error) {
+	start := util.GetNow().UnixNano()
+
+	header, err := encodeBinaryHeader(message)
+	if err != nil {
+		return
+	}
+	rawLength := int64(len(header))
+	if message.HasBytes {
+		rawLength += int64(len(message.Bytes))
+	}
+	var buffers net.Buffers
+	var length int64
+	if frame := compressBinaryFrame(header, message, compressor); frame != nil {
+		buffers = net.Buffers{frame}
+		length = int64(len(frame))
+	} else {
+		buffers = net.Buffers{header}
+		length = int64(len(header))
+		if message.HasBytes && len(message.Bytes) > 0 {
+			buffers = append(buffers, message.Bytes)
+			length += int64(len(message.Bytes))
+		}
+	}
+	n, err := buffers.WriteTo(writer)
+	if err != nil {
+		return
+	}
+	if n < length {
+		err = LengthError
+		return
+	}
+	end := util.GetNow().UnixNano()
+	MonitorData.MonitorWrite(length, end-start)
+	MonitorData.MonitorWriteRaw(rawLength)
+	return
+}
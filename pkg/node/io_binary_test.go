@@ -0,0 +1,177 @@
+package node
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBinaryMessage(t *testing.T) {
+	list := []*Message{
+		{
+			Id:             "1",
+			Method:         methodNetProxySend,
+			LineNodeIdList: []string{"a", "b", "c"},
+			NetProxyWorkData: &NetProxyWorkData{
+				NetProxyId: "proxy",
+				ConnId:     "conn",
+				IsReverse:  true,
+			},
+			HasBytes: true,
+			Bytes:    []byte("hello"),
+		},
+		{
+			Id:               "2",
+			Method:           methodTerminalWrite,
+			TerminalWorkData: &TerminalWorkData{Key: "terminal"},
+			Priority:         PriorityHigh,
+			HasBytes:         true,
+			Bytes:            []byte{},
+		},
+		{
+			Id:           "3",
+			Method:       methodFileRename,
+			FileWorkData: &FileWorkData{OldPath: "/a", NewPath: "/b"},
+			SendKey:      "send",
+			Priority:     PriorityLow,
+		},
+		{
+			Method:       methodStreamWindow,
+			SendKey:      "bytes:send",
+			StreamWindow: 128 * 1024,
+		},
+		{
+			Id:    "4",
+			Error: "错误信息",
+			NetProxyWorkData: &NetProxyWorkData{
+				NetProxyIdList: []string{"x"},
+			},
+		},
+	}
+	for _, msg := range list {
+		buf := &bytes.Buffer{}
+		err := WriteBinaryMessage(buf, msg, &MonitorData{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := ReadBinaryMessage(buf, &MonitorData{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(msg, res) {
+			t.Fatalf("message [%s] mismatch: %+v != %+v", msg.Id, msg, res)
+		}
+	}
+
+	_, err := DecodeBinaryMessage([]byte{1, 0, 0xFF, 0, 10}, nil)
+	if err != FrameFormatError {
+		t.Fatal("truncated frame should be rejected:", err)
+	}
+}
+
+func TestBinaryMessageCompress(t *testing.T) {
+	text := []byte(strings.Repeat("drwxr-xr-x 2 root root 4096 Jan  1 00:00 teamide\n", 100))
+	list := []*Message{
+		{
+			Id:               "text",
+			Method:           methodSendBytes,
+			SendKey:          "send",
+			LineNodeIdList:   []string{"a", "b"},
+			TerminalWorkData: &TerminalWorkData{Key: "terminal"},
+			HasBytes:         true,
+			Bytes:            text,
+		},
+		{
+			Id:       "small",
+			Method:   methodTerminalWrite,
+			HasBytes: true,
+			Bytes:    []byte("ls\r"),
+		},
+	}
+	for _, name := range []string{CompressZstd, CompressSnappy} {
+		compressor := GetCompressor(name)
+		for _, msg := range list {
+			buf := &bytes.Buffer{}
+			monitorData := &MonitorData{}
+			err := WriteBinaryMessage(buf, msg, monitorData, compressor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			isCompressed := monitorData.WriteSize < monitorData.WriteRawSize
+			if isCompressed != (len(msg.Bytes) >= CompressThreshold) {
+				t.Fatalf("[%s] message [%s] compress by threshold error, size %d, raw size %d", name, msg.Id, monitorData.WriteSize, monitorData.WriteRawSize)
+			}
+			res, err := ReadBinaryMessage(buf, monitorData, compressor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(msg, res) {
+				t.Fatalf("[%s] message [%s] mismatch", name, msg.Id)
+			}
+			if monitorData.ReadSize != monitorData.WriteSize || monitorData.ReadRawSize != monitorData.WriteRawSize {
+				t.Fatalf("[%s] message [%s] monitor size mismatch: %+v", name, msg.Id, monitorData)
+			}
+		}
+	}
+}
+
+func benchmarkMessage(b *testing.B, msg *Message, version int) {
+	buf := &bytes.Buffer{}
+	monitorData := &MonitorData{}
+	b.SetBytes(int64(len(msg.Bytes)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		err := WriteMessageByVersion(buf, msg, monitorData, version, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, err = ReadMessageByVersion(buf, monitorData, version, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func newNetProxySendMessage() *Message {
+	return &Message{
+		Id:             "netProxySend",
+		Method:         methodNetProxySend,
+		LineNodeIdList: []string{"node-1", "node-2", "node-3"},
+		NetProxyWorkData: &NetProxyWorkData{
+			NetProxyId: "proxy-1",
+			ConnId:     "conn-1",
+		},
+		HasBytes: true,
+		Bytes:    make([]byte, 32*1024),
+	}
+}
+
+func newTerminalWriteMessage() *Message {
+	return &Message{
+		Id:               "terminalWrite",
+		Method:           methodTerminalWrite,
+		LineNodeIdList:   []string{"node-1", "node-2"},
+		TerminalWorkData: &TerminalWorkData{Key: "terminal-1"},
+		HasBytes:         true,
+		Bytes:            []byte("ls -l\r"),
+	}
+}
+
+func BenchmarkNetProxySendJSON(b *testing.B) {
+	benchmarkMessage(b, newNetProxySendMessage(), MinProtocolVersion)
+}
+
+func BenchmarkNetProxySendBinary(b *testing.B) {
+	benchmarkMessage(b, newNetProxySendMessage(), BinaryProtocolVersion)
+}
+
+func BenchmarkTerminalWriteJSON(b *testing.B) {
+	benchmarkMessage(b, newTerminalWriteMessage(), MinProtocolVersion)
+}
+
+func BenchmarkTerminalWriteBinary(b *testing.B) {
+	benchmarkMessage(b, newTerminalWriteMessage(), BinaryProtocolVersion)
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"teamide/pkg/base"
 	"teamide/pkg/node"
+	"teamide/pkg/system"
 )
 
 var (
@@ -25,11 +26,44 @@ func main() {
 	var token string
 	var connAddress string
 	var connToken string
+	var tlsCert string
+	var tlsKey string
+	var tlsCA string
+	var tlsClientAuth bool
+	var connTLS bool
+	var connTLSCert string
+	var connTLSKey string
+	var connTLSCA string
+	var connTLSServerName string
+	var connTLSInsecure bool
+	var plaintext bool
+	var compress string
+	var accessPolicy string
+	var monitorDataDir string
+	var monitorDataSaveDays int
 	flag.StringVar(&id, "id", "", "节点ID，不可变更，需要唯一")
 	flag.StringVar(&address, "address", "", "节点启动监听地址")
-	flag.StringVar(&token, "token", "", "节点Token，用于验证")
+	flag.StringVar(&token, "token", os.Getenv("TEAMIDE_NODE_TOKEN"), "节点Token，用于验证，也可以通过环境变量 TEAMIDE_NODE_TOKEN 配置，避免出现在命令行中")
 	flag.StringVar(&connAddress, "connAddress", "", "上层节点连接地址")
-	flag.StringVar(&connToken, "connToken", "", "上层节点连接Token")
+	flag.StringVar(&connToken, "connToken", os.Getenv("TEAMIDE_NODE_CONN_TOKEN"), "上层节点连接Token，也可以通过环境变量 TEAMIDE_NODE_CONN_TOKEN 配置")
+	flag.StringVar(&tlsCert, "tlsCert", "", "节点服务TLS证书，配置后开启TLS")
+	flag.StringVar(&tlsKey, "tlsKey", "", "节点服务TLS证书密钥")
+	flag.StringVar(&tlsCA, "tlsCA", "", "节点服务校验客户端证书的CA证书")
+	flag.BoolVar(&tlsClientAuth, "tlsClientAuth", false, "节点服务是否要求并校验客户端证书（mTLS），需要配置 -tlsCA")
+	flag.BoolVar(&connTLS, "connTls", false, "连接上层节点是否使用TLS")
+	flag.StringVar(&connTLSCert, "connTlsCert", "", "连接上层节点的客户端证书（mTLS）")
+	flag.StringVar(&connTLSKey, "connTlsKey", "", "连接上层节点的客户端证书密钥（mTLS）")
+	flag.StringVar(&connTLSCA, "connTlsCA", "", "连接上层节点校验服务端证书的CA证书")
+	flag.StringVar(&connTLSServerName, "connTlsServerName", "", "连接上层节点校验服务端证书的名称，默认为连接地址的主机")
+	flag.BoolVar(&connTLSInsecure, "connTlsInsecure", false, "连接上层节点跳过服务端证书校验")
+	flag.BoolVar(&plaintext, "plaintext", false, "开启TLS后是否仍允许明文连接")
+	flag.StringVar(&compress, "compress", "zstd,snappy", "支持的压缩算法，按优先级逗号分隔，none 表示不压缩")
+	flag.IntVar(&node.CompressThreshold, "compressThreshold", node.CompressThreshold, "消息超过该字节数才压缩")
+	flag.StringVar(&node.UpgradePublicKey, "upgradePublicKey", "", "升级程序签名校验公钥（ed25519，base64），未配置时不允许升级")
+	flag.BoolVar(&node.UpgradeInsecure, "upgradeInsecure", false, "未配置升级签名公钥时允许不校验签名升级")
+	flag.StringVar(&monitorDataDir, "monitorDataDir", "", "服务器监控数据持久化目录，配置后可按时间范围查询历史数据")
+	flag.IntVar(&monitorDataSaveDays, "monitorDataSaveDays", 7, "服务器监控数据保留天数，0 永久保留")
+	flag.StringVar(&accessPolicy, "accessPolicy", "", "访问策略文件（JSON），限制其它节点可以调用的方法、文件目录、代理目标")
 
 	//解析
 	flag.Parse()
@@ -50,8 +84,37 @@ func main() {
 		flag.Usage()
 		panic("请设置 -connToken")
 	}
+	if tlsCert != "" && tlsKey == "" {
+		flag.Usage()
+		panic("请设置 -tlsKey")
+	}
+	if tlsClientAuth && tlsCA == "" {
+		flag.Usage()
+		panic("请设置 -tlsCA")
+	}
+	compressList, err := node.ParseCompressList(compress)
+	if err != nil {
+		flag.Usage()
+		panic(err.Error())
+	}
+	node.CompressList = compressList
+
+	if monitorDataDir != "" {
+		if err = system.SetHistory(monitorDataDir, monitorDataSaveDays); err != nil {
+			panic(err.Error())
+		}
+	}
 
 	server := &node.Server{}
+	if accessPolicy != "" {
+		policy, err := node.LoadAccessPolicy(accessPolicy)
+		if err != nil {
+			panic(err.Error())
+		}
+		if err = server.SetAccessPolicy(policy); err != nil {
+			panic(err.Error())
+		}
+	}
 	server.Start()
 	localNode := &node.LocalNode{
 		Id:          id,
@@ -60,6 +123,27 @@ func main() {
 		ConnAddress: connAddress,
 		ConnToken:   connToken,
 	}
+	if tlsCert != "" {
+		localNode.BindTLS = &node.TLSConfig{
+			Open:       true,
+			Cert:       tlsCert,
+			Key:        tlsKey,
+			CA:         tlsCA,
+			ClientAuth: tlsClientAuth,
+			Plaintext:  plaintext,
+		}
+	}
+	if connTLS {
+		localNode.ConnTLS = &node.TLSConfig{
+			Open:               true,
+			Cert:               connTLSCert,
+			Key:                connTLSKey,
+			CA:                 connTLSCA,
+			ServerName:         connTLSServerName,
+			InsecureSkipVerify: connTLSInsecure,
+			Plaintext:          plaintext,
+		}
+	}
 	println("启动节点 [" + id + "][" + address + "] 开始")
 	server.AddLocalNode(localNode)
 	println("启动节点 [" + id + "][" + address + "] 成功")
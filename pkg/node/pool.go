@@ -48,6 +48,14 @@ func (this_ *MessageListenerPool) Put(listener *MessageListener) (size int) {
 	return
 }
 
+// Size 现有监听器数量
+func (this_ *MessageListenerPool) Size() (size int) {
+	this_.listenerMu.Lock()
+	defer this_.listenerMu.Unlock()
+	size = len(this_.listeners)
+	return
+}
+
 func (this_ *MessageListenerPool) Stop() {
 	this_.isStop = true
 	this_.listenerMu.Lock()
@@ -0,0 +1,138 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"teamide/pkg/proxy"
+)
+
+// 动态代理
+//
+// 输入端通过 proxy.ReadDynamicRequest 读取 SOCKS5（无认证，仅 CONNECT）和 HTTP CONNECT 请求，目标地址随 methodNetProxyNewConn 发送至输出端，
+// 输出端解析目标地址后按允许列表校验，只连接允许的地址和端口，允许列表为空时拒绝所有目标。
+
+var (
+	NetProxyNotAllowedError = proxy.NotAllowedError
+	NetProxyRequestError    = proxy.RequestError
+)
+
+// NetProxyAllow 动态代理允许访问的目标
+type NetProxyAllow struct {
+	Cidr  string `json:"cidr,omitempty"`  // 网段或IP，如 10.0.0.0/8、192.168.1.10
+	Ports string `json:"ports,omitempty"` // 端口，逗号分隔，支持范围，如 22,80,8000-8100，为空表示所有端口
+}
+
+type netProxyAllowRule struct {
+	ipNet *net.IPNet
+	ports [][2]int
+}
+
+// parseNetProxyAllowList 解析允许列表
+func parseNetProxyAllowList(allowList []*NetProxyAllow) (ruleList []*netProxyAllowRule, err error) {
+	for _, allow := range allowList {
+		if allow == nil {
+			continue
+		}
+		rule := &netProxyAllowRule{}
+		cidr := strings.TrimSpace(allow.Cidr)
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				err = errors.New("代理允许列表网段[" + allow.Cidr + "]格式错误")
+				return
+			}
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, rule.ipNet, err = net.ParseCIDR(cidr)
+		if err != nil {
+			err = errors.New("代理允许列表网段[" + allow.Cidr + "]格式错误")
+			return
+		}
+		for _, str := range strings.Split(allow.Ports, ",") {
+			str = strings.TrimSpace(str)
+			if str == "" {
+				continue
+			}
+			var port [2]int
+			ss := strings.SplitN(str, "-", 2)
+			port[0], err = strconv.Atoi(strings.TrimSpace(ss[0]))
+			port[1] = port[0]
+			if err == nil && len(ss) == 2 {
+				port[1], err = strconv.Atoi(strings.TrimSpace(ss[1]))
+			}
+			if err != nil || port[0] < 1 || port[1] > 65535 || port[0] > port[1] {
+				err = errors.New("代理允许列表端口[" + allow.Ports + "]格式错误")
+				return
+			}
+			rule.ports = append(rule.ports, port)
+		}
+		ruleList = append(ruleList, rule)
+	}
+	return
+}
+
+// CheckNetProxyAllowList 校验允许列表格式
+func CheckNetProxyAllowList(allowList []*NetProxyAllow) (err error) {
+	_, err = parseNetProxyAllowList(allowList)
+	return
+}
+
+func (this_ *netProxyAllowRule) allow(ip net.IP, port int) bool {
+	if !this_.ipNet.Contains(ip) {
+		return false
+	}
+	if len(this_.ports) == 0 {
+		return true
+	}
+	for _, one := range this_.ports {
+		if port >= one[0] && port <= one[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveAllowAddress 解析目标地址，返回第一个允许访问的 IP 地址，连接使用解析后的地址，避免再次解析得到其它地址
+func resolveAllowAddress(ctx context.Context, ruleList []*netProxyAllowRule, address string) (allowAddress string, err error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		err = errors.New(NetProxyRequestError.Error() + "，目标地址[" + address + "]格式错误")
+		return
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		err = errors.New(NetProxyRequestError.Error() + "，目标端口[" + portStr + "]错误")
+		return
+	}
+	var ipList []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ipList = append(ipList, ip)
+	} else {
+		var addrList []net.IPAddr
+		addrList, err = net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return
+		}
+		for _, one := range addrList {
+			ipList = append(ipList, one.IP)
+		}
+	}
+	for _, ip := range ipList {
+		for _, rule := range ruleList {
+			if rule.allow(ip, port) {
+				allowAddress = net.JoinHostPort(ip.String(), portStr)
+				return
+			}
+		}
+	}
+	err = fmt.Errorf("%w，目标地址[%s]", NetProxyNotAllowedError, address)
+	return
+}
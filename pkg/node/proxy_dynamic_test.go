@@ -0,0 +1,48 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNetProxyAllowList(t *testing.T) {
+	ruleList, err := parseNetProxyAllowList([]*NetProxyAllow{
+		{Cidr: "10.0.0.0/8", Ports: "22, 8000-8100"},
+		{Cidr: "192.168.1.10"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for address, allow := range map[string]bool{
+		"10.1.2.3:22":       true,
+		"10.1.2.3:8050":     true,
+		"10.1.2.3:80":       false,
+		"192.168.1.10:3306": true,
+		"192.168.1.11:3306": false,
+	} {
+		_, err = resolveAllowAddress(context.Background(), ruleList, address)
+		if (err == nil) != allow {
+			t.Fatal("address", address, "allow check error:", err)
+		}
+		if !allow && !errors.Is(err, NetProxyNotAllowedError) {
+			t.Fatal("address", address, "should be not allowed error:", err)
+		}
+	}
+	// 允许列表为空 拒绝所有
+	if _, err = resolveAllowAddress(context.Background(), nil, "10.1.2.3:22"); !errors.Is(err, NetProxyNotAllowedError) {
+		t.Fatal("empty allow list should deny:", err)
+	}
+
+	for _, allow := range []*NetProxyAllow{{Cidr: "10.0.0.0/33"}, {Cidr: "host"}, {Cidr: "10.0.0.1", Ports: "80-70"}} {
+		if CheckNetProxyAllowList([]*NetProxyAllow{allow}) == nil {
+			t.Fatal("allow should be invalid:", allow)
+		}
+	}
+
+	// 不允许异常 跨节点还原后仍可判断
+	msg := newErrorMessage(err)
+	if !errors.Is(msg.getError(methodNetProxyNewConn), NetProxyNotAllowedError) {
+		t.Fatal("not allowed error code lost")
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
+	"io"
 	"net"
+	"teamide/pkg/proxy"
 	"time"
 )
 
@@ -13,9 +15,11 @@ type InnerServer struct {
 	isStop   bool
 	*connCache
 	serverListener net.Listener
-	MonitorData    *MonitorData
-	worker         *Worker
-	status         int8
+	packetConn     net.PacketConn
+	udpSessionCache
+	MonitorData *MonitorData
+	worker      *Worker
+	status      int8
 }
 
 func (this_ *InnerServer) Start() {
@@ -29,7 +33,12 @@ func (this_ *InnerServer) Start() {
 
 func (this_ *InnerServer) Stop() {
 	this_.isStop = true
-	_ = this_.serverListener.Close()
+	if this_.serverListener != nil {
+		_ = this_.serverListener.Close()
+	}
+	if this_.packetConn != nil {
+		_ = this_.packetConn.Close()
+	}
 	this_.connCache.clean()
 	return
 }
@@ -53,7 +62,16 @@ func (this_ *InnerServer) serverListenerKeepAlive() {
 	var err error
 	Logger.Info("代理服务 " + this_.netProxy.GetInfoStr() + " 启动")
 
-	this_.serverListener, err = net.Listen(this_.netProxy.GetType(), this_.netProxy.GetAddress())
+	if this_.netProxy.IsUDP() {
+		this_.serveUDP()
+		return
+	}
+
+	var network = this_.netProxy.GetType()
+	if this_.netProxy.IsDynamic() {
+		network = "tcp"
+	}
+	this_.serverListener, err = net.Listen(network, this_.netProxy.GetAddress())
 	if err != nil {
 		Logger.Error("代理服务 "+this_.netProxy.GetInfoStr()+" 监听异常", zap.Error(err))
 		return
@@ -87,15 +105,39 @@ func (this_ *InnerServer) onConn(conn net.Conn) {
 	//Logger.Info(this_.server.GetServerInfo() + " 代理服务 " + this_.netProxy.Inner.GetInfoStr() + " 新连接")
 	var connId = util.GetUUID()
 	var netProxyId = this_.netProxy.Id
+	var err error
+
+	var reader io.Reader = conn
+	var request *proxy.DynamicRequest
+	if this_.netProxy.IsDynamic() {
+		request, err = proxy.ReadDynamicRequest(conn)
+		if err != nil {
+			Logger.Error("代理服务 "+this_.netProxy.GetInfoStr()+" 读取代理请求异常", zap.Error(err))
+			_ = conn.Close()
+			return
+		}
+		reader = request.Reader
+	}
 	this_.setConn(connId, conn)
 
 	defer func() {
 		_ = this_.closeConn(connId)
 		_ = this_.worker.netProxyCloseConn(false, this_.netProxy.LineNodeIdList, netProxyId, connId)
 	}()
-	var err error
 
-	err = this_.worker.netProxyNewConn(this_.netProxy.LineNodeIdList, netProxyId, connId)
+	if request != nil {
+		// 回复客户端之前 暂停写入输出端返回的数据
+		_, writeLock := this_.getConn(connId)
+		writeLock.Lock()
+		err = this_.worker.netProxyNewConn(this_.netProxy.LineNodeIdList, netProxyId, connId, request.Address)
+		e := request.Reply(conn, err)
+		writeLock.Unlock()
+		if err == nil {
+			err = e
+		}
+	} else {
+		err = this_.worker.netProxyNewConn(this_.netProxy.LineNodeIdList, netProxyId, connId, "")
+	}
 
 	if err != nil {
 		Logger.Error("代理服务 "+this_.netProxy.GetInfoStr()+" 节点线连接创建异常", zap.Error(err))
@@ -105,14 +147,14 @@ func (this_ *InnerServer) onConn(conn net.Conn) {
 	var buf = make([]byte, 1024*32)
 
 	start := util.GetNow().UnixNano()
-	err = util.Read(conn, buf, func(n int) (e error) {
+	err = util.Read(reader, buf, func(n int) (e error) {
 		if this_.isStopped() {
 			e = errors.New("proxy outer is stopped")
 			return
 		}
 
 		end := util.GetNow().UnixNano()
-		this_.MonitorData.monitorRead(int64(n), end-start)
+		this_.MonitorData.MonitorRead(int64(n), end-start)
 
 		e = this_.worker.netProxySend(false, this_.netProxy.LineNodeIdList, netProxyId, connId, buf[:n])
 		if e != nil {
@@ -1,6 +1,7 @@
 package node
 
 import (
+	"context"
 	"errors"
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
@@ -12,12 +13,20 @@ type OuterListener struct {
 	isStop   bool
 	worker   *Worker
 	*connCache
-	MonitorData *MonitorData
+	MonitorData   *MonitorData
+	allowRuleList []*netProxyAllowRule
+	allowErr      error
 }
 
 func (this_ *OuterListener) Start() {
 	this_.MonitorData = &MonitorData{}
 	this_.connCache = newConnCache(this_.MonitorData)
+	if this_.netProxy.IsDynamic() {
+		this_.allowRuleList, this_.allowErr = parseNetProxyAllowList(this_.netProxy.AllowList)
+		if this_.allowErr != nil {
+			Logger.Error(this_.netProxy.GetInfoStr()+" 允许列表异常", zap.Error(this_.allowErr))
+		}
+	}
 
 	return
 }
@@ -34,14 +43,20 @@ func (this_ *OuterListener) isStopped() bool {
 	return this_.isStop
 }
 
-func (this_ *OuterListener) newConn(connId string) (err error) {
+func (this_ *OuterListener) newConn(ctx context.Context, connId string, address string) (err error) {
 	if this_.isStopped() {
 		return
 	}
 
 	//Logger.Info(" OuterListener newConn [" + connId + "]")
 
-	conn, err := net.Dial(this_.netProxy.GetType(), this_.netProxy.GetAddress())
+	network, address, err := this_.getDialAddress(ctx, address)
+	if err != nil {
+		Logger.Error(this_.netProxy.GetInfoStr()+" 连接 ["+connId+"] 目标地址异常", zap.Error(err))
+		return
+	}
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, network, address)
 	if err != nil {
 		Logger.Error(this_.netProxy.GetInfoStr()+" 连接 ["+connId+"] 异常", zap.Error(err))
 		return
@@ -55,7 +70,13 @@ func (this_ *OuterListener) newConn(connId string) (err error) {
 			_ = this_.worker.netProxyCloseConn(true, this_.netProxy.ReverseLineNodeIdList, netProxyId, connId)
 		}()
 
-		var buf = make([]byte, 1024*32)
+		var buf []byte
+		if this_.netProxy.IsUDP() {
+			// 每次读取一个数据报，按数据报转发
+			buf = make([]byte, udpBufferSize)
+		} else {
+			buf = make([]byte, 1024*32)
+		}
 
 		start := util.GetNow().UnixNano()
 		err = util.Read(conn, buf, func(n int) (e error) {
@@ -65,7 +86,7 @@ func (this_ *OuterListener) newConn(connId string) (err error) {
 			}
 
 			end := util.GetNow().UnixNano()
-			this_.MonitorData.monitorRead(int64(n), end-start)
+			this_.MonitorData.MonitorRead(int64(n), end-start)
 
 			e = this_.worker.netProxySend(true, this_.netProxy.ReverseLineNodeIdList, netProxyId, connId, buf[:n])
 			if e != nil {
@@ -79,3 +100,41 @@ func (this_ *OuterListener) newConn(connId string) (err error) {
 	}()
 	return
 }
+
+// getDialAddress 动态代理 校验并解析客户端请求的目标地址，其它代理 使用配置的地址，忽略请求的地址
+func (this_ *OuterListener) getDialAddress(ctx context.Context, requestAddress string) (network string, address string, err error) {
+	if !this_.netProxy.IsDynamic() {
+		network = this_.netProxy.GetType()
+		address = this_.netProxy.GetAddress()
+		err = this_.checkAccessPolicy(ctx, &address)
+		return
+	}
+	if this_.allowErr != nil {
+		err = this_.allowErr
+		return
+	}
+	if requestAddress == "" {
+		err = errors.New(NetProxyRequestError.Error() + "，目标地址为空")
+		return
+	}
+	network = "tcp"
+	address, err = resolveAllowAddress(ctx, this_.allowRuleList, requestAddress)
+	if err != nil {
+		return
+	}
+	err = this_.checkAccessPolicy(ctx, &address)
+	return
+}
+
+// checkAccessPolicy 节点访问策略限制的代理目标
+func (this_ *OuterListener) checkAccessPolicy(ctx context.Context, address *string) (err error) {
+	policy := this_.worker.getAccessPolicy()
+	if policy == nil {
+		return
+	}
+	*address, err = policy.allowNetProxyAddress(ctx, *address)
+	if err != nil {
+		Logger.Warn("access policy denied", zap.Any("netProxy", this_.netProxy.GetInfoStr()), zap.Error(err))
+	}
+	return
+}
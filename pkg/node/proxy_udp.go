@@ -0,0 +1,267 @@
+package node
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UDP 代理
+//
+// 代理服务按客户端地址建立会话，每个会话对应一个 ConnId，数据报通过 methodNetProxySend 逐个转发，
+// 每条消息对应一个数据报，保持数据报边界。会话空闲超过 udpSessionIdleTimeout 后关闭，
+// 代理出口端的 UDP 连接随之关闭。
+
+var (
+	// udpSessionIdleTimeout UDP 会话空闲超时时间
+	udpSessionIdleTimeout = 60 * time.Second
+	// udpSessionQueueSize 每个会话待发送的数据报数量，超出后丢弃
+	udpSessionQueueSize = 256
+	// udpBufferSize UDP 数据报最大长度
+	udpBufferSize = 64 * 1024
+
+	UDPSessionClosedError = errors.New("UDP会话已关闭")
+)
+
+// udpSession 代理服务 UDP 客户端会话，实现 net.Conn 以便放入 connCache，写入时发送数据报至客户端
+type udpSession struct {
+	connId     string
+	packetConn net.PacketConn
+	addr       net.Addr
+	queue      chan []byte
+	done       chan struct{}
+	closeOnce  sync.Once
+	lastActive int64
+}
+
+func newUDPSession(connId string, packetConn net.PacketConn, addr net.Addr) *udpSession {
+	session := &udpSession{
+		connId:     connId,
+		packetConn: packetConn,
+		addr:       addr,
+		queue:      make(chan []byte, udpSessionQueueSize),
+		done:       make(chan struct{}),
+	}
+	session.active()
+	return session
+}
+
+func (this_ *udpSession) active() {
+	atomic.StoreInt64(&this_.lastActive, time.Now().UnixNano())
+}
+
+func (this_ *udpSession) isIdle(now time.Time) bool {
+	return now.Sub(time.Unix(0, atomic.LoadInt64(&this_.lastActive))) > udpSessionIdleTimeout
+}
+
+// push 加入待发送队列，队列已满时丢弃
+func (this_ *udpSession) push(bytes []byte) (ok bool) {
+	this_.active()
+	select {
+	case this_.queue <- bytes:
+		ok = true
+	case <-this_.done:
+	default:
+	}
+	return
+}
+
+func (this_ *udpSession) Read(b []byte) (n int, err error) {
+	err = errors.New("UDP会话不支持读取")
+	return
+}
+
+func (this_ *udpSession) Write(b []byte) (n int, err error) {
+	if this_.isClosed() {
+		err = UDPSessionClosedError
+		return
+	}
+	this_.active()
+	n, err = this_.packetConn.WriteTo(b, this_.addr)
+	return
+}
+
+func (this_ *udpSession) isClosed() bool {
+	select {
+	case <-this_.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close 只结束会话，监听由 InnerServer 关闭
+func (this_ *udpSession) Close() error {
+	this_.closeOnce.Do(func() {
+		close(this_.done)
+	})
+	return nil
+}
+
+func (this_ *udpSession) LocalAddr() net.Addr {
+	return this_.packetConn.LocalAddr()
+}
+
+func (this_ *udpSession) RemoteAddr() net.Addr {
+	return this_.addr
+}
+
+func (this_ *udpSession) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (this_ *udpSession) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (this_ *udpSession) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// udpSessionCache 代理服务 客户端地址与会话
+type udpSessionCache struct {
+	udpSessionMap  map[string]*udpSession
+	udpSessionLock sync.Mutex
+}
+
+func (this_ *udpSessionCache) getUDPSession(addr string) (session *udpSession) {
+	this_.udpSessionLock.Lock()
+	defer this_.udpSessionLock.Unlock()
+
+	session = this_.udpSessionMap[addr]
+	return
+}
+
+func (this_ *udpSessionCache) setUDPSession(addr string, session *udpSession) {
+	this_.udpSessionLock.Lock()
+	defer this_.udpSessionLock.Unlock()
+
+	if this_.udpSessionMap == nil {
+		this_.udpSessionMap = make(map[string]*udpSession)
+	}
+	this_.udpSessionMap[addr] = session
+}
+
+func (this_ *udpSessionCache) removeUDPSession(addr string, session *udpSession) {
+	this_.udpSessionLock.Lock()
+	defer this_.udpSessionLock.Unlock()
+
+	if this_.udpSessionMap[addr] == session {
+		delete(this_.udpSessionMap, addr)
+	}
+}
+
+func (this_ *udpSessionCache) getIdleUDPSessionList(now time.Time) (list []*udpSession) {
+	this_.udpSessionLock.Lock()
+	defer this_.udpSessionLock.Unlock()
+
+	for _, session := range this_.udpSessionMap {
+		if session.isIdle(now) {
+			list = append(list, session)
+		}
+	}
+	return
+}
+
+// serveUDP 代理服务 监听 UDP 并按客户端地址分发数据报
+func (this_ *InnerServer) serveUDP() {
+	var err error
+	this_.packetConn, err = net.ListenPacket(this_.netProxy.GetType(), this_.netProxy.GetAddress())
+	if err != nil {
+		Logger.Error("代理服务 "+this_.netProxy.GetInfoStr()+" 监听异常", zap.Error(err))
+		return
+	}
+	Logger.Info("代理服务 " + this_.netProxy.GetInfoStr() + " 启动成功")
+
+	this_.status = StatusStarted
+	done := make(chan struct{})
+	defer close(done)
+	go this_.expireUDPSession(done)
+
+	var buf = make([]byte, udpBufferSize)
+	for {
+		if this_.isStopped() {
+			break
+		}
+		var n int
+		var addr net.Addr
+		n, addr, err = this_.packetConn.ReadFrom(buf)
+		if err != nil {
+			if this_.isStopped() {
+				break
+			}
+			Logger.Error(this_.netProxy.GetInfoStr()+" 读取数据报异常", zap.Error(err))
+			break
+		}
+		this_.MonitorData.MonitorRead(int64(n), 0)
+
+		var bytes = make([]byte, n)
+		copy(bytes, buf[:n])
+
+		session := this_.getUDPSession(addr.String())
+		if session == nil || session.isClosed() {
+			session = newUDPSession(util.GetUUID(), this_.packetConn, addr)
+			this_.setUDPSession(addr.String(), session)
+			this_.setConn(session.connId, session)
+			go this_.onUDPSession(session)
+		}
+		if !session.push(bytes) {
+			Logger.Warn(this_.netProxy.GetInfoStr() + " 会话 [" + session.connId + "] 发送队列已满，丢弃数据报")
+		}
+	}
+}
+
+// onUDPSession 会话数据报按顺序发送至节点线
+func (this_ *InnerServer) onUDPSession(session *udpSession) {
+	var connId = session.connId
+	var netProxyId = this_.netProxy.Id
+
+	defer func() {
+		this_.removeUDPSession(session.addr.String(), session)
+		_ = this_.closeConn(connId)
+		_ = this_.worker.netProxyCloseConn(false, this_.netProxy.LineNodeIdList, netProxyId, connId)
+	}()
+
+	err := this_.worker.netProxyNewConn(this_.netProxy.LineNodeIdList, netProxyId, connId, "")
+	if err != nil {
+		Logger.Error("代理服务 "+this_.netProxy.GetInfoStr()+" 节点线连接创建异常", zap.Error(err))
+		return
+	}
+
+	for {
+		select {
+		case <-session.done:
+			return
+		case bytes := <-session.queue:
+			if this_.isStopped() {
+				return
+			}
+			err = this_.worker.netProxySend(false, this_.netProxy.LineNodeIdList, netProxyId, connId, bytes)
+			if err != nil {
+				Logger.Error(this_.netProxy.GetInfoStr()+" 节点线流发送异常", zap.Error(err))
+				return
+			}
+		}
+	}
+}
+
+// expireUDPSession 定时关闭空闲会话
+func (this_ *InnerServer) expireUDPSession(done chan struct{}) {
+	ticker := time.NewTicker(udpSessionIdleTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			for _, session := range this_.getIdleUDPSessionList(now) {
+				_ = this_.closeConn(session.connId)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package node
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUDPSession(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }()
+	client, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	session := newUDPSession("conn", server, client.LocalAddr())
+	cache := newConnCache(&MonitorData{})
+	cache.setConn(session.connId, session)
+
+	// 写入按数据报发送至客户端
+	if err = cache.send(session.connId, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := client.ReadFrom(buf)
+	if err != nil || string(buf[:n]) != "hello" {
+		t.Fatal("session write error:", err, string(buf[:n]))
+	}
+
+	if session.isIdle(time.Now()) || !session.isIdle(time.Now().Add(udpSessionIdleTimeout+time.Second)) {
+		t.Fatal("session idle check error")
+	}
+
+	for i := 0; i < udpSessionQueueSize; i++ {
+		session.push([]byte{byte(i)})
+	}
+	if session.push([]byte{0}) {
+		t.Fatal("full session queue should drop datagram")
+	}
+
+	// 关闭会话不关闭监听
+	_ = cache.closeConn(session.connId)
+	if !session.isClosed() {
+		t.Fatal("session should be closed")
+	}
+	if _, err = session.Write([]byte("x")); err != UDPSessionClosedError {
+		t.Fatal("closed session write should fail:", err)
+	}
+	if _, err = server.WriteTo([]byte("x"), client.LocalAddr()); err != nil {
+		t.Fatal("listener should stay open:", err)
+	}
+}
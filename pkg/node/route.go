@@ -0,0 +1,305 @@
+package node
+
+import (
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"sort"
+	"time"
+)
+
+// 路由发现
+//
+// 每个节点定时向相邻节点发送邻居表（自己的和已知的其它节点的），收到后按版本合并，
+// 超过 RouteExpire 未更新的邻居表视为节点离线。
+// Server 方法的节点线只传目标节点ID时，按邻居表计算最短路径；
+// 节点线中有离线的节点时，重新计算到目标节点的最短路径。
+// 没有邻居表的节点（旧版本节点）视为连通，不影响原有的节点线。
+
+var (
+	// RouteGossipInterval 发送邻居表间隔
+	RouteGossipInterval = 5 * time.Second
+	// RouteExpire 邻居表过期时间
+	RouteExpire = 30 * time.Second
+)
+
+// RouteNode 节点的邻居表
+type RouteNode struct {
+	NodeId          string   `json:"nodeId,omitempty"`
+	NeighbourIdList []string `json:"neighbourIdList,omitempty"`
+	Version         int64    `json:"version,omitempty"` // 生成时间（纳秒），版本大的覆盖版本小的
+	updateTime      time.Time
+}
+
+// getNeighbourIdList 有可用连接的相邻节点
+func (this_ *Worker) getNeighbourIdList() (neighbourIdList []string) {
+	for nodeId, pool := range this_.getToNodeListenerPoolMap() {
+		if pool.Size() > 0 && util.StringIndexOf(neighbourIdList, nodeId) < 0 {
+			neighbourIdList = append(neighbourIdList, nodeId)
+		}
+	}
+	for nodeId, pool := range this_.getFromNodeListenerPoolMap() {
+		if pool.Size() > 0 && util.StringIndexOf(neighbourIdList, nodeId) < 0 {
+			neighbourIdList = append(neighbourIdList, nodeId)
+		}
+	}
+	sort.Strings(neighbourIdList)
+	return
+}
+
+func (this_ *Worker) getLocalNodeIdList() (localNodeIdList []string) {
+	if this_.server == nil {
+		return
+	}
+	return this_.server.GetLocalNodeIdList()
+}
+
+// getLocalRouteNodeList 本地节点的邻居表，本地节点之间互为邻居
+func (this_ *Worker) getLocalRouteNodeList() (routeNodeList []*RouteNode) {
+	var localNodeIdList = this_.getLocalNodeIdList()
+	var neighbourIdList = this_.getNeighbourIdList()
+	var version = time.Now().UnixNano()
+	for _, nodeId := range localNodeIdList {
+		routeNode := &RouteNode{
+			NodeId:  nodeId,
+			Version: version,
+		}
+		for _, one := range localNodeIdList {
+			if one != nodeId {
+				routeNode.NeighbourIdList = append(routeNode.NeighbourIdList, one)
+			}
+		}
+		for _, one := range neighbourIdList {
+			if util.StringIndexOf(routeNode.NeighbourIdList, one) < 0 {
+				routeNode.NeighbourIdList = append(routeNode.NeighbourIdList, one)
+			}
+		}
+		routeNodeList = append(routeNodeList, routeNode)
+	}
+	return
+}
+
+// getRouteNodeList 本地节点和其它节点未过期的邻居表
+func (this_ *Worker) getRouteNodeList() (routeNodeList []*RouteNode) {
+	routeNodeList = this_.getLocalRouteNodeList()
+	var localNodeIdList = this_.getLocalNodeIdList()
+	for _, one := range this_.getRouteNodeCacheList() {
+		if util.StringIndexOf(localNodeIdList, one.NodeId) >= 0 {
+			continue
+		}
+		if time.Since(one.updateTime) > RouteExpire {
+			continue
+		}
+		routeNodeList = append(routeNodeList, one)
+	}
+	return
+}
+
+// getRouteGraph 节点ID 对应 邻居节点ID列表
+func (this_ *Worker) getRouteGraph() (graph map[string][]string) {
+	graph = make(map[string][]string)
+	for _, one := range this_.getRouteNodeList() {
+		graph[one.NodeId] = one.NeighbourIdList
+	}
+	return
+}
+
+// mergeRouteNodeList 合并其它节点发送的邻居表，有节点新增或邻居变化时返回 true
+func (this_ *Worker) mergeRouteNodeList(routeNodeList []*RouteNode) (changed bool) {
+	var localNodeIdList = this_.getLocalNodeIdList()
+	var now = time.Now()
+
+	this_.routeNodeCacheLock.Lock()
+	defer this_.routeNodeCacheLock.Unlock()
+
+	for _, one := range routeNodeList {
+		if one == nil || one.NodeId == "" || util.StringIndexOf(localNodeIdList, one.NodeId) >= 0 {
+			continue
+		}
+		find := this_.routeNodeCache[one.NodeId]
+		if find != nil && find.Version >= one.Version {
+			continue
+		}
+		if find == nil || time.Since(find.updateTime) > RouteExpire || !isSameIdList(find.NeighbourIdList, one.NeighbourIdList) {
+			changed = true
+		}
+		this_.routeNodeCache[one.NodeId] = &RouteNode{
+			NodeId:          one.NodeId,
+			NeighbourIdList: one.NeighbourIdList,
+			Version:         one.Version,
+			updateTime:      now,
+		}
+	}
+	return
+}
+
+// sendRouteGossip 向相邻节点发送邻居表
+func (this_ *Worker) sendRouteGossip() {
+	var routeNodeList = this_.getRouteNodeList()
+	var poolList = append(this_.getToNodeListenerPoolList(), this_.getFromNodeListenerPoolList()...)
+	for _, pool := range poolList {
+		listener, err := pool.GetOne("")
+		if err != nil {
+			continue
+		}
+		err = listener.Send(&Message{
+			Method: methodNodeRouteGossip,
+			NodeWorkData: &WorkData{
+				RouteList: routeNodeList,
+			},
+		}, this_.MonitorData)
+		if err != nil {
+			Logger.Warn("route gossip send error", zap.Error(err))
+		}
+	}
+}
+
+func (this_ *Worker) onRouteGossip(routeNodeList []*RouteNode) {
+	if this_.mergeRouteNodeList(routeNodeList) {
+		// 拓扑变化时立即转发，不等待下次定时发送
+		go this_.sendRouteGossip()
+	}
+}
+
+// onNeighbourChange 相邻节点连接变化，通知节点状态变化并发送邻居表
+func (this_ *Worker) onNeighbourChange() {
+	var neighbourIdList = this_.getNeighbourIdList()
+
+	this_.neighbourIdListLock.Lock()
+	var oldNeighbourIdList = this_.neighbourIdList
+	this_.neighbourIdList = neighbourIdList
+	this_.neighbourIdListLock.Unlock()
+
+	if isSameIdList(oldNeighbourIdList, neighbourIdList) {
+		return
+	}
+	if this_.server != nil && this_.server.OnNodeStatusChange != nil {
+		for _, nodeId := range oldNeighbourIdList {
+			if util.StringIndexOf(neighbourIdList, nodeId) < 0 {
+				this_.server.OnNodeStatusChange(nodeId, StatusStopped)
+			}
+		}
+		for _, nodeId := range neighbourIdList {
+			if util.StringIndexOf(oldNeighbourIdList, nodeId) < 0 {
+				this_.server.OnNodeStatusChange(nodeId, StatusStarted)
+			}
+		}
+	}
+	go this_.sendRouteGossip()
+}
+
+func (this_ *Worker) routeGossipKeepAlive() {
+	ticker := time.NewTicker(RouteGossipInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-this_.routeStop:
+			return
+		case <-ticker.C:
+			this_.onNeighbourChange()
+			this_.sendRouteGossip()
+		}
+	}
+}
+
+// GetRoute 到目标节点的最短节点线，没有可用路径时返回空
+func (this_ *Worker) GetRoute(nodeId string) (lineNodeIdList []string) {
+	lineNodeIdList = findRoute(this_.getLocalNodeIdList(), nodeId, this_.getRouteGraph())
+	return
+}
+
+// routeLine 节点线只有目标节点或节点线中有离线节点时，重新计算到目标节点的最短节点线
+func (this_ *Worker) routeLine(lineNodeIdList []string) []string {
+	if len(lineNodeIdList) == 0 {
+		return lineNodeIdList
+	}
+	var localNodeIdList = this_.getLocalNodeIdList()
+	var toNodeId = lineNodeIdList[len(lineNodeIdList)-1]
+	if util.StringIndexOf(localNodeIdList, toNodeId) >= 0 {
+		return lineNodeIdList
+	}
+	var graph = this_.getRouteGraph()
+	if len(lineNodeIdList) > 1 && isLineAvailable(lineNodeIdList, graph) {
+		return lineNodeIdList
+	}
+	route := findRoute(localNodeIdList, toNodeId, graph)
+	if len(route) == 0 {
+		return lineNodeIdList
+	}
+	if len(lineNodeIdList) > 1 {
+		Logger.Info("节点线不可用，重新选择路径", zap.Any("lineNodeIdList", lineNodeIdList), zap.Any("route", route))
+	}
+	return route
+}
+
+// isRouteLinked 两个节点之间是否连通，没有邻居表的节点视为连通
+func isRouteLinked(fromNodeId, toNodeId string, graph map[string][]string) bool {
+	if neighbourIdList, ok := graph[fromNodeId]; ok && util.StringIndexOf(neighbourIdList, toNodeId) < 0 {
+		return false
+	}
+	if neighbourIdList, ok := graph[toNodeId]; ok && util.StringIndexOf(neighbourIdList, fromNodeId) < 0 {
+		return false
+	}
+	return true
+}
+
+func isLineAvailable(lineNodeIdList []string, graph map[string][]string) bool {
+	for i := 0; i < len(lineNodeIdList)-1; i++ {
+		if !isRouteLinked(lineNodeIdList[i], lineNodeIdList[i+1], graph) {
+			return false
+		}
+	}
+	return true
+}
+
+// findRoute 广度优先查找从任一起始节点到目标节点的最短节点线
+func findRoute(fromNodeIdList []string, toNodeId string, graph map[string][]string) (lineNodeIdList []string) {
+	if len(fromNodeIdList) == 0 || toNodeId == "" {
+		return
+	}
+	var parent = make(map[string]string)
+	var queue []string
+	for _, one := range fromNodeIdList {
+		if one == toNodeId {
+			lineNodeIdList = []string{one}
+			return
+		}
+		if _, ok := parent[one]; !ok {
+			parent[one] = ""
+			queue = append(queue, one)
+		}
+	}
+	for len(queue) > 0 {
+		nodeId := queue[0]
+		queue = queue[1:]
+		for _, next := range graph[nodeId] {
+			if _, ok := parent[next]; ok {
+				continue
+			}
+			if !isRouteLinked(nodeId, next, graph) {
+				continue
+			}
+			parent[next] = nodeId
+			if next != toNodeId {
+				queue = append(queue, next)
+				continue
+			}
+			for one := next; one != ""; one = parent[one] {
+				lineNodeIdList = append([]string{one}, lineNodeIdList...)
+			}
+			return
+		}
+	}
+	return
+}
+
+func isSameIdList(list1 []string, list2 []string) bool {
+	if len(list1) != len(list2) {
+		return false
+	}
+	for _, one := range list1 {
+		if util.StringIndexOf(list2, one) < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindRoute(t *testing.T) {
+	graph := map[string][]string{
+		"root": {"n1", "n2"},
+		"n1":   {"root", "n3"},
+		"n2":   {"root", "n3", "n4"},
+		"n3":   {"n1", "n2", "n5"},
+		"n4":   {"n2"},
+		"n5":   {"n3"},
+	}
+	line := findRoute([]string{"root"}, "n5", graph)
+	if len(line) != 4 || line[0] != "root" || line[3] != "n5" {
+		t.Fatal("route error:", line)
+	}
+	line = findRoute([]string{"root"}, "n4", graph)
+	if !reflect.DeepEqual(line, []string{"root", "n2", "n4"}) {
+		t.Fatal("route error:", line)
+	}
+
+	// n2 不再连接 n3，n3 的邻居表未更新时也不能经过
+	graph["n2"] = []string{"root", "n4"}
+	line = findRoute([]string{"root"}, "n5", graph)
+	if !reflect.DeepEqual(line, []string{"root", "n1", "n3", "n5"}) {
+		t.Fatal("route error:", line)
+	}
+
+	// 没有邻居表的节点视为连通
+	graph["n5"] = []string{"n3", "old"}
+	line = findRoute([]string{"root"}, "old", graph)
+	if !reflect.DeepEqual(line, []string{"root", "n1", "n3", "n5", "old"}) {
+		t.Fatal("route error:", line)
+	}
+
+	if line = findRoute([]string{"root"}, "none", graph); len(line) != 0 {
+		t.Fatal("route should be empty:", line)
+	}
+}
+
+func TestRouteLine(t *testing.T) {
+	server := &Server{localNodeList: []*LocalNode{{Id: "root"}}}
+	worker := &Worker{server: server, Space: newSpace(), MonitorData: &MonitorData{}}
+	server.Worker = worker
+
+	version := time.Now().UnixNano()
+	worker.mergeRouteNodeList([]*RouteNode{
+		{NodeId: "n1", NeighbourIdList: []string{"root", "n3"}, Version: version},
+		{NodeId: "n2", NeighbourIdList: []string{"root"}, Version: version},
+		{NodeId: "n3", NeighbourIdList: []string{"n1"}, Version: version},
+	})
+
+	// 本地节点没有连接，经过 root 的节点线不可用时保持原样
+	line := worker.routeLine([]string{"root", "n2", "n3"})
+	if !reflect.DeepEqual(line, []string{"root", "n2", "n3"}) {
+		t.Fatal("line error:", line)
+	}
+
+	// 模拟 root 与 n1、n2 已连接
+	worker.getToNodeListenerPoolIfAbsentCreate("n1").Put(&MessageListener{})
+	worker.getToNodeListenerPoolIfAbsentCreate("n2").Put(&MessageListener{})
+
+	line = worker.routeLine([]string{"n3"})
+	if !reflect.DeepEqual(line, []string{"root", "n1", "n3"}) {
+		t.Fatal("line error:", line)
+	}
+	line = worker.routeLine([]string{"root", "n2", "n3"})
+	if !reflect.DeepEqual(line, []string{"root", "n1", "n3"}) {
+		t.Fatal("line error:", line)
+	}
+	line = worker.routeLine([]string{"root", "n2"})
+	if !reflect.DeepEqual(line, []string{"root", "n2"}) {
+		t.Fatal("line error:", line)
+	}
+
+	// 旧版本的邻居表不覆盖新版本
+	if worker.mergeRouteNodeList([]*RouteNode{
+		{NodeId: "n3", NeighbourIdList: []string{"n2"}, Version: version - 1},
+	}) {
+		t.Fatal("old version should not change route")
+	}
+	if !worker.mergeRouteNodeList([]*RouteNode{
+		{NodeId: "n3", NeighbourIdList: []string{"n2"}, Version: version + 1},
+	}) {
+		t.Fatal("new version should change route")
+	}
+}
+
+func TestNeighbourChange(t *testing.T) {
+	var changes []int8
+	server := &Server{localNodeList: []*LocalNode{{Id: "root"}}}
+	server.OnNodeStatusChange = func(id string, status int8) {
+		if id == "n1" {
+			changes = append(changes, status)
+		}
+	}
+	worker := &Worker{server: server, Space: newSpace(), MonitorData: &MonitorData{}}
+	server.Worker = worker
+
+	listener, received := testRecordListener(t)
+	pool := worker.getToNodeListenerPoolIfAbsentCreate("n1")
+	pool.Put(listener)
+	worker.onNeighbourChange()
+
+	// 邻居变化时立即发送邻居表
+	msg := testWaitMessage(t, received, methodNodeRouteGossip)
+	if msg.NodeWorkData == nil || len(msg.NodeWorkData.RouteList) != 1 ||
+		!reflect.DeepEqual(msg.NodeWorkData.RouteList[0].NeighbourIdList, []string{"n1"}) {
+		t.Fatal("route gossip error:", msg.NodeWorkData)
+	}
+
+	pool.listeners = nil
+	worker.onNeighbourChange()
+	if !reflect.DeepEqual(changes, []int8{StatusStarted, StatusStopped}) {
+		t.Fatal("status change error:", changes)
+	}
+}
@@ -1,22 +1,26 @@
 package node
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"sync"
 	"teamide/pkg/system"
 )
 
+// tokenByteSize 握手标识长度
 var tokenByteSize = 128
 
 type LocalNode struct {
-	Id             string `json:"id"`
-	BindAddress    string `json:"bindAddress"`
-	BindToken      string `json:"-"`
-	ConnAddress    string `json:"connAddress"`
-	ConnToken      string `json:"-"`
-	ConnSize       int    `json:"connSize"`
-	IsStop         bool   `json:"isStop"`
+	Id             string     `json:"id"`
+	BindAddress    string     `json:"bindAddress"`
+	BindToken      string     `json:"-"`
+	ConnAddress    string     `json:"connAddress"`
+	ConnToken      string     `json:"-"`
+	ConnSize       int        `json:"connSize"`
+	IsStop         bool       `json:"isStop"`
+	BindTLS        *TLSConfig `json:"-"` // 节点服务 TLS 配置
+	ConnTLS        *TLSConfig `json:"-"` // 连接上层节点 TLS 配置
 	serverListener net.Listener
 }
 
@@ -24,15 +28,18 @@ func (this_ *LocalNode) GetServerInfo() (str string) {
 	return fmt.Sprintf("节点服务[%s][%s]", this_.Id, this_.BindAddress)
 }
 
+// Server 节点服务
+// 方法的节点线 lineNodeIdList 可以只传目标节点ID，由路由表选择最短路径，节点线中有离线节点时自动重新选择路径
 type Server struct {
 	localNodeList []*LocalNode
 	serverInfo    string
 
-	OnNodeStatusChange    func(id string, status int8)
+	OnNodeStatusChange    func(id string, status int8) // 相邻节点连接状态变化
 	OnNetProxyInnerChange func(id string, status int8)
 	OnNetProxyOuterChange func(id string, status int8)
 
 	connNodeListenerKeepAliveLock sync.Mutex
+	accessPolicy                  *AccessPolicy // 其它节点请求的访问策略
 	*Worker
 }
 
@@ -58,7 +65,7 @@ func (this_ *Server) AddLocalNode(localNode *LocalNode) {
 	}
 
 	if localNode.ConnAddress != "" {
-		this_.connNodeListenerKeepAlive(localNode.ConnAddress, localNode.ConnToken, localNode.ConnSize)
+		this_.connNodeListenerKeepAlive(localNode.ConnAddress, localNode.ConnToken, localNode.ConnSize, localNode.ConnTLS)
 	}
 }
 
@@ -91,6 +98,8 @@ func (this_ *Server) Start() {
 		MonitorData: &MonitorData{},
 	}
 	go system.StartCollectMonitorData()
+	go this_.routeGossipKeepAlive()
+	this_.upgradeWatch()
 	return
 }
 
@@ -112,7 +121,13 @@ func (this_ *Server) GetServerInfo() (str string) {
 }
 
 func (this_ *Server) SystemGetInfo(lineNodeIdList []string) (info *system.Info) {
-	res := this_.systemGetInfo(lineNodeIdList)
+	return this_.SystemGetInfoContext(context.Background(), lineNodeIdList)
+}
+
+// SystemGetInfoContext 节点系统信息，ctx 取消后不再等待节点返回
+func (this_ *Server) SystemGetInfoContext(ctx context.Context, lineNodeIdList []string) (info *system.Info) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	res := this_.Worker.WithContext(ctx).systemGetInfo(lineNodeIdList)
 	if res != nil {
 		info = res.Info
 	}
@@ -120,7 +135,13 @@ func (this_ *Server) SystemGetInfo(lineNodeIdList []string) (info *system.Info)
 }
 
 func (this_ *Server) SystemMonitorData(lineNodeIdList []string) (monitorData *system.MonitorData) {
-	res := this_.systemMonitorData(lineNodeIdList)
+	return this_.SystemMonitorDataContext(context.Background(), lineNodeIdList)
+}
+
+// SystemMonitorDataContext 节点当前监控数据，ctx 取消后不再等待节点返回
+func (this_ *Server) SystemMonitorDataContext(ctx context.Context, lineNodeIdList []string) (monitorData *system.MonitorData) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	res := this_.Worker.WithContext(ctx).systemMonitorData(lineNodeIdList)
 	if res != nil {
 		monitorData = res.MonitorData
 	}
@@ -128,7 +149,13 @@ func (this_ *Server) SystemMonitorData(lineNodeIdList []string) (monitorData *sy
 }
 
 func (this_ *Server) SystemQueryMonitorData(lineNodeIdList []string, request *system.QueryRequest) (response *system.QueryResponse) {
-	res := this_.systemQueryMonitorData(lineNodeIdList, &SystemData{
+	return this_.SystemQueryMonitorDataContext(context.Background(), lineNodeIdList, request)
+}
+
+// SystemQueryMonitorDataContext 查询节点监控数据，ctx 取消后不再等待节点返回
+func (this_ *Server) SystemQueryMonitorDataContext(ctx context.Context, lineNodeIdList []string, request *system.QueryRequest) (response *system.QueryResponse) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	res := this_.Worker.WithContext(ctx).systemQueryMonitorData(lineNodeIdList, &SystemData{
 		QueryRequest: request,
 	})
 	if res != nil {
@@ -138,71 +165,129 @@ func (this_ *Server) SystemQueryMonitorData(lineNodeIdList []string, request *sy
 }
 
 func (this_ *Server) SystemCleanMonitorData(lineNodeIdList []string) {
-	_ = this_.systemCleanMonitorData(lineNodeIdList)
+	this_.SystemCleanMonitorDataContext(context.Background(), lineNodeIdList)
+}
+
+// SystemCleanMonitorDataContext 清理节点监控数据，ctx 取消后不再等待节点返回
+func (this_ *Server) SystemCleanMonitorDataContext(ctx context.Context, lineNodeIdList []string) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	_ = this_.Worker.WithContext(ctx).systemCleanMonitorData(lineNodeIdList)
+	return
+}
+
+// SystemProcessList 节点进程列表
+func (this_ *Server) SystemProcessList(lineNodeIdList []string, request *system.ProcessQueryRequest) (response *system.ProcessQueryResponse, err error) {
+	return this_.SystemProcessListContext(context.Background(), lineNodeIdList, request)
+}
+
+// SystemProcessListContext 节点进程列表，ctx 取消后返回 ctx 的错误
+func (this_ *Server) SystemProcessListContext(ctx context.Context, lineNodeIdList []string, request *system.ProcessQueryRequest) (response *system.ProcessQueryResponse, err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	response, err = this_.Worker.WithContext(ctx).systemProcessList(lineNodeIdList, request)
+	return
+}
+
+// SystemProcessSignal 向节点进程发送信号
+func (this_ *Server) SystemProcessSignal(lineNodeIdList []string, request *system.ProcessSignalRequest) (err error) {
+	return this_.SystemProcessSignalContext(context.Background(), lineNodeIdList, request)
+}
+
+// SystemProcessSignalContext 向节点进程发送信号，ctx 取消后返回 ctx 的错误
+func (this_ *Server) SystemProcessSignalContext(ctx context.Context, lineNodeIdList []string, request *system.ProcessSignalRequest) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	err = this_.Worker.WithContext(ctx).systemProcessSignal(lineNodeIdList, request)
 	return
 }
 
 func (this_ *Server) GetNodeVersion(lineNodeIdList []string) (version string) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	version = this_.getVersion(lineNodeIdList)
 	return
 }
 
 func (this_ *Server) GetNodeStatus(lineNodeIdList []string) (status int8) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	status = this_.getNodeStatus(lineNodeIdList)
 	return
 }
 
 func (this_ *Server) GetNodeMonitorData(lineNodeIdList []string) (monitorData *MonitorData) {
-	monitorData = this_.getNodeMonitorData(lineNodeIdList)
+	return this_.GetNodeMonitorDataContext(context.Background(), lineNodeIdList)
+}
+
+// GetNodeMonitorDataContext 节点连接的流量数据，ctx 取消后不再等待节点返回
+func (this_ *Server) GetNodeMonitorDataContext(ctx context.Context, lineNodeIdList []string) (monitorData *MonitorData) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	monitorData = this_.Worker.WithContext(ctx).getNodeMonitorData(lineNodeIdList)
 	return
 }
 
 func (this_ *Server) GetNetProxyInnerMonitorData(lineNodeIdList []string, netProxyId string) (monitorData *MonitorData) {
-	monitorData = this_.getNetProxyInnerMonitorData(lineNodeIdList, netProxyId)
+	return this_.GetNetProxyInnerMonitorDataContext(context.Background(), lineNodeIdList, netProxyId)
+}
+
+// GetNetProxyInnerMonitorDataContext 网络代理输入端的流量数据，ctx 取消后不再等待节点返回
+func (this_ *Server) GetNetProxyInnerMonitorDataContext(ctx context.Context, lineNodeIdList []string, netProxyId string) (monitorData *MonitorData) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	monitorData = this_.Worker.WithContext(ctx).getNetProxyInnerMonitorData(lineNodeIdList, netProxyId)
 	return
 }
 
 func (this_ *Server) GetNetProxyOuterMonitorData(lineNodeIdList []string, netProxyId string) (monitorData *MonitorData) {
-	monitorData = this_.getNetProxyOuterMonitorData(lineNodeIdList, netProxyId)
+	return this_.GetNetProxyOuterMonitorDataContext(context.Background(), lineNodeIdList, netProxyId)
+}
+
+// GetNetProxyOuterMonitorDataContext 网络代理输出端的流量数据，ctx 取消后不再等待节点返回
+func (this_ *Server) GetNetProxyOuterMonitorDataContext(ctx context.Context, lineNodeIdList []string, netProxyId string) (monitorData *MonitorData) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	monitorData = this_.Worker.WithContext(ctx).getNetProxyOuterMonitorData(lineNodeIdList, netProxyId)
 	return
 }
 
 func (this_ *Server) AddToNodeList(lineNodeIdList []string, toNodeList []*ToNode) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	this_.addToNodeList(lineNodeIdList, toNodeList)
 	return
 }
 
 func (this_ *Server) RemoveToNodeList(lineNodeIdList []string, toNodeIdList []string) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	this_.removeToNodeList(lineNodeIdList, toNodeIdList)
 	return
 }
 
 func (this_ *Server) GetNetProxyInnerStatus(lineNodeIdList []string, netProxyId string) (status int8) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	status = this_.getNetProxyInnerStatus(lineNodeIdList, netProxyId)
 	return
 }
 
 func (this_ *Server) AddNetProxyInnerList(lineNodeIdList []string, netProxyList []*NetProxyInner) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	this_.addNetProxyInnerList(lineNodeIdList, netProxyList)
 	return
 }
 
 func (this_ *Server) RemoveNetProxyInnerList(lineNodeIdList []string, netProxyIdList []string) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	this_.removeNetProxyInnerList(lineNodeIdList, netProxyIdList)
 	return
 }
 
 func (this_ *Server) GetNetProxyOuterStatus(lineNodeIdList []string, netProxyId string) (status int8) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	status = this_.getNetProxyOuterStatus(lineNodeIdList, netProxyId)
 	return
 }
 
 func (this_ *Server) AddNetProxyOuterList(lineNodeIdList []string, netProxyList []*NetProxyOuter) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	this_.addNetProxyOuterList(lineNodeIdList, netProxyList)
 	return
 }
 
 func (this_ *Server) RemoveNetProxyOuterList(lineNodeIdList []string, netProxyIdList []string) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	this_.removeNetProxyOuterList(lineNodeIdList, netProxyIdList)
 	return
 }
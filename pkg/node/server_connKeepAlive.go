@@ -1,6 +1,6 @@
 package node
 
-func (this_ *Server) connNodeListenerKeepAlive(connAddress, connToken string, connSize int) {
+func (this_ *Server) connNodeListenerKeepAlive(connAddress, connToken string, connSize int, connTLS *TLSConfig) {
 	if connAddress == "" {
 		Logger.Warn("连接 [" + connAddress + "] 连接地址为空")
 		return
@@ -12,7 +12,7 @@ func (this_ *Server) connNodeListenerKeepAlive(connAddress, connToken string, co
 		connSize = 5
 	}
 	for connIndex := 0; connIndex < connSize; connIndex++ {
-		go this_.connNodeListener(nil, connAddress, connToken, connIndex)
+		go this_.connNodeListener(nil, connAddress, connToken, connTLS, connIndex)
 	}
 	return
 }
@@ -0,0 +1,132 @@
+package node
+
+import (
+	"bytes"
+	"github.com/team-ide/go-tool/util"
+	"sync"
+	"time"
+)
+
+// ExecStart 在节点上执行命令，输出按顺序回调 onStdout、onStderr，命令结束后回调 onExit
+func (this_ *Server) ExecStart(lineNodeIdList []string, request *ExecRequest, onStdout func(buf []byte) (err error), onStderr func(buf []byte) (err error), onExit func(result *ExecResult)) (key string, err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	if request.Key == "" {
+		request.Key = util.GetUUID()
+	}
+	key = request.Key
+
+	this_.addOnBytesCache(getExecStdoutKey(key), newExecOnBytes(onStdout))
+	this_.addOnBytesCache(getExecStderrKey(key), newExecOnBytes(onStderr))
+	this_.addExecExit(key, func(result *ExecResult) {
+		if onExit != nil {
+			onExit(result)
+		}
+	})
+
+	err = this_.workExecStart(lineNodeIdList, request)
+	if err != nil {
+		this_.removeOnBytesCache(getExecStdoutKey(key))
+		this_.removeOnBytesCache(getExecStderrKey(key))
+		this_.removeExecExit(key)
+		return
+	}
+	return
+}
+
+func newExecOnBytes(on func(buf []byte) (err error)) *OnBytes {
+	return &OnBytes{
+		start: func() (err error) {
+			return
+		},
+		on: func(buf []byte) (err error) {
+			if on != nil {
+				err = on(buf)
+			}
+			return
+		},
+		end: func() (err error) {
+			return
+		},
+	}
+}
+
+// ExecKill 终止节点上执行中的命令
+func (this_ *Server) ExecKill(lineNodeIdList []string, key string) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	err = this_.workExecKill(lineNodeIdList, key)
+	return
+}
+
+// execOutputBuffer 收集输出，最多 ExecOutputMaxSize，超过时丢弃之后的输出
+type execOutputBuffer struct {
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (this_ *execOutputBuffer) write(bs []byte) {
+	rest := ExecOutputMaxSize - this_.buf.Len()
+	if len(bs) > rest {
+		bs = bs[:rest]
+		this_.truncated = true
+	}
+	this_.buf.Write(bs)
+}
+
+// Exec 在节点上执行命令并等待结束，返回标准输出、标准错误以及退出码，输出较多时使用 ExecStart 按流接收
+// 超过超时时间 DefaultCallTimeout 后仍未收到结果则终止命令并返回 ExecTimeoutError，未设置超时时间时最多等待 DefaultCallTimeout，
+// 需要执行更长时间的命令使用 ExecStart
+func (this_ *Server) Exec(lineNodeIdList []string, request *ExecRequest) (result *ExecResult, err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+
+	var stdout execOutputBuffer
+	var stderr execOutputBuffer
+	var outputLock sync.Mutex
+	var exitChan = make(chan *ExecResult, 1)
+
+	key, err := this_.ExecStart(lineNodeIdList, request, func(buf []byte) (err error) {
+		outputLock.Lock()
+		defer outputLock.Unlock()
+		stdout.write(buf)
+		return
+	}, func(buf []byte) (err error) {
+		outputLock.Lock()
+		defer outputLock.Unlock()
+		stderr.write(buf)
+		return
+	}, func(result *ExecResult) {
+		exitChan <- result
+	})
+	if err != nil {
+		return
+	}
+
+	// 结果丢失时不能一直等待，未设置超时时间时最多等待 DefaultCallTimeout
+	var waitTimeout = DefaultCallTimeout
+	if request.Timeout > 0 {
+		waitTimeout += time.Duration(request.Timeout) * time.Millisecond
+	}
+	timer := time.NewTimer(waitTimeout)
+	defer timer.Stop()
+
+	select {
+	case result = <-exitChan:
+	case <-timer.C:
+		err = ExecTimeoutError
+	case <-this_.getContext().Done():
+		err = this_.getContext().Err()
+	}
+	if err != nil {
+		_ = this_.workExecKill(lineNodeIdList, key)
+		this_.removeOnBytesCache(getExecStdoutKey(key))
+		this_.removeOnBytesCache(getExecStderrKey(key))
+		this_.removeExecExit(key)
+		return
+	}
+
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	result.Stdout = stdout.buf.String()
+	result.Stderr = stderr.buf.String()
+	result.Truncated = stdout.truncated || stderr.truncated
+	return
+}
@@ -0,0 +1,284 @@
+package node
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"hash"
+	"hash/crc32"
+	"io"
+	"teamide/pkg/base"
+	"time"
+)
+
+var (
+	// FileTransferRetry 断点续传 失败后重试次数
+	FileTransferRetry = 5
+	// FileTransferRetryInterval 断点续传 重试间隔，等待连接恢复
+	FileTransferRetryInterval = 3 * time.Second
+)
+
+// fileReaderError 本地读取异常，不重试
+type fileReaderError struct {
+	err error
+}
+
+func (this_ *fileReaderError) Error() string {
+	return this_.err.Error()
+}
+
+func (this_ *fileReaderError) Unwrap() error {
+	return this_.err
+}
+
+func isFileTransferRetryable(err error) bool {
+	var readerError *fileReaderError
+	if errors.As(err, &readerError) {
+		return false
+	}
+	return !errors.Is(err, base.ProgressCallStoppedError) && !errors.Is(err, FileResumeNotSupportError)
+}
+
+// fileWriteTransfer 断点续传 上传状态
+type fileWriteTransfer struct {
+	server    *Server
+	path      string
+	uploadId  string
+	reader    io.Reader
+	hash      hash.Hash
+	buf       []byte
+	pending   []byte // 已读取、未确认写入的数据
+	readSize  int64  // 已读取的大小
+	writeSize int64  // 目标节点确认写入的大小
+	truncate  bool
+	onDo      func(readSize int64, writeSize int64)
+	callStop  *bool
+}
+
+// rewind 重新开始上传，读取器需要支持 Seek
+func (this_ *fileWriteTransfer) rewind(cause error) (err error) {
+	seeker, ok := this_.reader.(io.Seeker)
+	if !ok {
+		err = cause
+		return
+	}
+	_, err = seeker.Seek(0, io.SeekStart)
+	if err != nil {
+		err = &fileReaderError{err: err}
+		return
+	}
+	this_.hash.Reset()
+	this_.pending = nil
+	this_.readSize = 0
+	this_.writeSize = 0
+	this_.truncate = true
+	return
+}
+
+// skip 目标节点已有上传的部分，读取跳过并计算摘要，与已上传部分的 MD5 不一致时重新上传
+func (this_ *fileWriteTransfer) skip(offset int64, partMd5 string) (err error) {
+	n, err := io.CopyN(this_.hash, this_.reader, offset-this_.readSize)
+	this_.readSize += n
+	if err == io.EOF {
+		return this_.rewind(FileChecksumError)
+	}
+	if err != nil {
+		err = &fileReaderError{err: err}
+		return
+	}
+	if fmt.Sprintf("%x", this_.hash.Sum(nil)) != partMd5 {
+		return this_.rewind(FileChecksumError)
+	}
+	this_.writeSize = offset
+	Logger.Info("file write resume", zap.Any("path", this_.path), zap.Any("offset", offset))
+	return
+}
+
+func (this_ *fileWriteTransfer) run(lineNodeIdList []string) (err error) {
+	data, err := this_.server.workFileWriteStart(lineNodeIdList, this_.path, this_.uploadId, this_.truncate)
+	if err != nil {
+		return
+	}
+	this_.truncate = false
+
+	var offset = data.Offset
+	if len(this_.pending) > 0 && offset == this_.writeSize+int64(len(this_.pending)) {
+		// 数据已写入，响应丢失
+		this_.writeSize = offset
+		this_.pending = nil
+	}
+	if offset != this_.writeSize {
+		if this_.writeSize == 0 && this_.readSize == 0 {
+			err = this_.skip(offset, data.Md5)
+		} else {
+			err = this_.rewind(FileOffsetError)
+		}
+		if err != nil {
+			return
+		}
+		if this_.truncate {
+			return this_.run(lineNodeIdList)
+		}
+	}
+
+	for {
+		if *this_.callStop {
+			err = base.ProgressCallStoppedError
+			return
+		}
+		if len(this_.pending) == 0 {
+			var n int
+			n, err = io.ReadFull(this_.reader, this_.buf)
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				err = nil
+			} else if err != nil {
+				err = &fileReaderError{err: err}
+				return
+			}
+			if n == 0 {
+				break
+			}
+			this_.hash.Write(this_.buf[:n])
+			this_.readSize += int64(n)
+			this_.pending = this_.buf[:n]
+			this_.onDo(this_.readSize, this_.writeSize)
+		}
+		offset, err = this_.server.workFileWriteChunk(lineNodeIdList, this_.path, this_.uploadId, this_.writeSize, crc32.ChecksumIEEE(this_.pending), this_.pending)
+		if err != nil {
+			return
+		}
+		this_.writeSize = offset
+		this_.pending = nil
+		this_.onDo(this_.readSize, this_.writeSize)
+	}
+
+	err = this_.server.workFileWriteEnd(lineNodeIdList, this_.path, this_.uploadId, fmt.Sprintf("%x", this_.hash.Sum(nil)))
+	if errors.Is(err, FileChecksumError) {
+		// 目标节点已删除临时文件
+		if e := this_.rewind(err); e != nil {
+			return e
+		}
+		return this_.run(lineNodeIdList)
+	}
+	return
+}
+
+// FileWorkWriteResume 断点续传上传文件，每块数据校验 CRC32，完成后校验整个文件的 MD5
+// 连接断开时等待重连后从目标节点已写入的位置继续上传，旧版本节点使用 FileWorkWrite 上传；
+// uploadKey 标识上传的内容（如本地文件大小和修改时间、文件摘要），失败后保留临时文件，使用相同的 key 重新上传时继续上传，
+// 为空时失败后删除临时文件
+func (this_ *Server) FileWorkWriteResume(lineNodeIdList []string, path string, uploadKey string, reader io.Reader, onDo func(readSize int64, writeSize int64), callStop *bool) (err error) {
+	uploadId := GetFileUploadId(path, uploadKey)
+	err = this_.fileWorkWriteResume(lineNodeIdList, path, uploadId, reader, onDo, callStop)
+	if err != nil && uploadKey == "" && !errors.Is(err, FileResumeNotSupportError) {
+		_, _ = this_.workFileWriteStart(this_.routeLine(lineNodeIdList), path, uploadId, true)
+	}
+	return
+}
+
+// fileWorkWriteResume 按上传 ID 上传
+func (this_ *Server) fileWorkWriteResume(lineNodeIdList []string, path string, uploadId string, reader io.Reader, onDo func(readSize int64, writeSize int64), callStop *bool) (err error) {
+	transfer := &fileWriteTransfer{
+		server:   this_,
+		path:     path,
+		uploadId: uploadId,
+		reader:   reader,
+		hash:     md5.New(),
+		buf:      make([]byte, FileChunkSize),
+		onDo:     onDo,
+		callStop: callStop,
+	}
+	for retry := 0; ; retry++ {
+		err = transfer.run(this_.routeLine(lineNodeIdList))
+		if errors.Is(err, FileResumeNotSupportError) && transfer.readSize == 0 {
+			return this_.FileWorkWrite(lineNodeIdList, path, reader, onDo, callStop)
+		}
+		if err == nil {
+			return
+		}
+		if retry >= FileTransferRetry || !isFileTransferRetryable(err) {
+			return
+		}
+		Logger.Warn("file write resume retry", zap.Any("path", path), zap.Any("writeSize", transfer.writeSize), zap.Error(err))
+		time.Sleep(FileTransferRetryInterval)
+	}
+}
+
+// FileWorkReadResume 断点续传下载文件，每块数据校验 CRC32，完成后校验整个文件的 MD5
+// 连接断开时等待重连后从已写入的位置继续下载，旧版本节点使用 FileWorkRead 下载
+func (this_ *Server) FileWorkReadResume(lineNodeIdList []string, path string, writer io.Writer, onDo func(readSize int64, writeSize int64), callStop *bool) (err error) {
+	var data *FileWorkData
+	for retry := 0; ; retry++ {
+		data, err = this_.workFileMd5(this_.routeLine(lineNodeIdList), path)
+		if errors.Is(err, FileResumeNotSupportError) {
+			return this_.FileWorkRead(lineNodeIdList, path, writer, onDo, callStop)
+		}
+		if err == nil || retry >= FileTransferRetry {
+			break
+		}
+		time.Sleep(FileTransferRetryInterval)
+	}
+	if err != nil {
+		return
+	}
+	if !data.Exist {
+		err = errors.New("文件[" + path + "]不存在")
+		return
+	}
+
+	var hash = md5.New()
+	var offset int64
+	var retry int
+	for offset < data.Size {
+		if *callStop {
+			err = base.ProgressCallStoppedError
+			return
+		}
+		var buf []byte
+		var checksum uint32
+		buf, checksum, err = this_.workFileReadChunk(this_.routeLine(lineNodeIdList), path, offset, FileChunkSize)
+		if err == nil && crc32.ChecksumIEEE(buf) != checksum {
+			err = FileChecksumError
+		}
+		if err != nil {
+			if retry >= FileTransferRetry || !isFileTransferRetryable(err) {
+				return
+			}
+			retry++
+			Logger.Warn("file read resume retry", zap.Any("path", path), zap.Any("offset", offset), zap.Error(err))
+			time.Sleep(FileTransferRetryInterval)
+			continue
+		}
+		retry = 0
+		if len(buf) == 0 {
+			err = errors.New("文件[" + path + "]大小已变化")
+			return
+		}
+		offset += int64(len(buf))
+		onDo(offset, offset-int64(len(buf)))
+		_, err = writer.Write(buf)
+		if err != nil {
+			return
+		}
+		hash.Write(buf)
+		onDo(offset, offset)
+	}
+	if fmt.Sprintf("%x", hash.Sum(nil)) != data.Md5 {
+		err = FileChecksumError
+		return
+	}
+	return
+}
+
+// FileWorkMd5 文件是否存在以及 MD5
+func (this_ *Server) FileWorkMd5(lineNodeIdList []string, path string) (exist bool, md5Str string, err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	data, err := this_.workFileMd5(lineNodeIdList, path)
+	if err != nil {
+		return
+	}
+	exist = data.Exist
+	md5Str = data.Md5
+	return
+}
@@ -9,36 +9,43 @@ import (
 )
 
 func (this_ *Server) FileWorkExist(lineNodeIdList []string, path string) (exist bool, err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	exist, err = this_.workExist(lineNodeIdList, path)
 	return
 }
 
 func (this_ *Server) FileWorkCreate(lineNodeIdList []string, path string, isDir bool) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	err = this_.workFileCreate(lineNodeIdList, path, isDir)
 	return
 }
 
 func (this_ *Server) FileWorkRename(lineNodeIdList []string, oldPath string, newPath string) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	err = this_.workFileRename(lineNodeIdList, oldPath, newPath)
 	return
 }
 
 func (this_ *Server) FileWorkMove(lineNodeIdList []string, oldPath string, newPath string) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	err = this_.workFileMove(lineNodeIdList, oldPath, newPath)
 	return
 }
 
 func (this_ *Server) FileWorkFiles(lineNodeIdList []string, dir string) (parentPath string, files []*filework.FileInfo, err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	parentPath, files, err = this_.workFiles(lineNodeIdList, dir)
 	return
 }
 
 func (this_ *Server) FileWorkFile(lineNodeIdList []string, path string) (file *filework.FileInfo, err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	file, err = this_.workFile(lineNodeIdList, path)
 	return
 }
 
 func (this_ *Server) FileWorkWrite(lineNodeIdList []string, path string, reader io.Reader, onDo func(readSize int64, writeSize int64), callStop *bool) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 
 	sendKey, err := this_.workFileWrite(lineNodeIdList, path)
 	if err != nil {
@@ -61,7 +68,7 @@ func (this_ *Server) FileWorkWrite(lineNodeIdList []string, path string, reader
 		}
 		readSize += int64(n)
 		onDo(readSize, writeSize)
-		e = this_.workSendBytes(lineNodeIdList, sendKey, buf[:n])
+		e = this_.workSendBytes(lineNodeIdList, sendKey, PriorityLow, buf[:n])
 		writeSize += int64(n)
 		onDo(readSize, writeSize)
 		return
@@ -76,6 +83,7 @@ func (this_ *Server) FileWorkWrite(lineNodeIdList []string, path string, reader
 }
 
 func (this_ *Server) FileWorkRead(lineNodeIdList []string, path string, writer io.Writer, onDo func(readSize int64, writeSize int64), callStop *bool) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 
 	sendKey := util.GetUUID()
 
@@ -119,6 +127,7 @@ func (this_ *Server) FileWorkRead(lineNodeIdList []string, path string, writer i
 }
 
 func (this_ *Server) FileWorkRemove(lineNodeIdList []string, path string, onDo func(fileCount int, removeCount int)) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	fileCount, removeCount, err := this_.workFileRemove(lineNodeIdList, path)
 	onDo(fileCount, removeCount)
 	if err != nil {
@@ -128,9 +137,19 @@ func (this_ *Server) FileWorkRemove(lineNodeIdList []string, path string, onDo f
 }
 
 func (this_ *Server) FileWorkCount(lineNodeIdList []string, path string, onDo func(fileCount int)) (fileCount int, err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	fileCount, err = this_.workFileCount(lineNodeIdList, path)
+	if onDo != nil {
+		onDo(fileCount)
+	}
 	return
 }
 
 func (this_ *Server) FileWorkCountSize(lineNodeIdList []string, path string, onDo func(fileCount int, fileSize int64)) (fileCount int, fileSize int64, err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	fileCount, fileSize, err = this_.workFileCountSize(lineNodeIdList, path)
+	if onDo != nil {
+		onDo(fileCount, fileSize)
+	}
 	return
 }
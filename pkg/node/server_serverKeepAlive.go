@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"go.uber.org/zap"
 	"net"
-	"strings"
 	"time"
 )
 
@@ -32,79 +31,71 @@ func (this_ *Server) serverListenerKeepAlive(localNode *LocalNode) {
 			Logger.Error("本地节点 监听 异常", zap.Any("localNode", localNode), zap.Error(err))
 			break
 		}
-		_ = this_.onServerConn(localNode, conn)
+		go func(conn net.Conn) {
+			_ = this_.onServerConn(localNode, conn)
+		}(conn)
 	}
 	return
 }
 
 func (this_ *Server) onServerConn(localNode *LocalNode, conn net.Conn) (err error) {
 
-	var bytes = make([]byte, tokenByteSize)
-	_, err = conn.Read(bytes)
+	var tlsConn net.Conn
+	tlsConn, err = serverTLSConn(conn, localNode.BindTLS)
 	if err != nil {
+		Logger.Error(localNode.GetServerInfo()+" 来之客户端连接 TLS验证异常", zap.Error(err))
 		_ = conn.Close()
 		return
 	}
-	token := strings.TrimSpace(string(bytes))
-	if localNode.BindToken != token {
-		Logger.Error(localNode.GetServerInfo() + " 来之客户端连接 Token验证异常")
-		_ = conn.Close()
-		return
-	}
+	conn = tlsConn
 
-	var clientMsg *Message
-	clientMsg, err = ReadMessage(conn, this_.MonitorData)
+	var clientConnData *ConnData
+	clientConnData, err = serverHandshake(conn, localNode, this_.MonitorData)
 	if err != nil {
-		Logger.Error(localNode.GetServerInfo() + " 来之客户端连接 接口异常")
-		_ = conn.Close()
-		return
-	}
-	if clientMsg.ConnData == nil || clientMsg.ConnData.NodeId == "" {
-		Logger.Error(localNode.GetServerInfo() + " 来之客户端连接 接口异常")
+		Logger.Error(localNode.GetServerInfo()+" 来之客户端连接 握手异常", zap.Error(err))
 		_ = conn.Close()
 		return
 	}
 	var fromNodeIdList []string
-	if clientMsg.ConnData.NodeId != "" {
-		fromNodeIdList = append(fromNodeIdList, clientMsg.ConnData.NodeId)
+	if clientConnData.NodeId != "" {
+		fromNodeIdList = append(fromNodeIdList, clientConnData.NodeId)
 	}
-	for _, id := range clientMsg.ConnData.NodeIdList {
+	for _, id := range clientConnData.NodeIdList {
 		fromNodeIdList = append(fromNodeIdList, id)
 	}
 
-	// 发送当前节点ID
-	err = WriteMessage(conn, &Message{
-		ConnData: &ConnData{
-			NodeId:    localNode.Id,
-			NodeToken: localNode.BindToken,
-		},
-	}, this_.MonitorData)
-	if err != nil {
-		Logger.Error(localNode.GetServerInfo() + " 来之客户端连接 接口异常")
-		_ = conn.Close()
-		return
+	// 一个连接只能有一个监听器读取，多个节点共用同一个监听器
+	messageListener := &MessageListener{
+		conn:      conn,
+		version:   negotiateVersion(clientConnData.Version),
+		compress:  GetCompressor(clientConnData.Compress),
+		onMessage: this_.onMessage,
 	}
-	for _, fromNodeId := range fromNodeIdList {
-		pool := this_.getFromNodeListenerPoolIfAbsentCreate(fromNodeId)
-
-		if pool != nil && pool.isStop {
-			return
-		}
-		messageListener := &MessageListener{
-			conn:      conn,
-			onMessage: this_.onMessage,
-		}
-		messageListener.listen(func() {
-			messageListener.stop()
+	messageListener.listen(func() {
+		messageListener.stop()
+		for _, fromNodeId := range fromNodeIdList {
+			pool := this_.getFromNodeListenerPool(fromNodeId)
+			if pool == nil {
+				continue
+			}
 			pool.Remove(messageListener)
 			Logger.Info(localNode.GetServerInfo() + " 移除 来至 [" + fromNodeId + "] 节点的连接 现有连接 " + fmt.Sprint(len(pool.listeners)))
 			if len(pool.listeners) == 0 {
 				this_.removeFromNodeListenerPool(fromNodeId)
 			}
-		}, this_.MonitorData)
+		}
+		this_.onNeighbourChange()
+	}, this_.MonitorData)
+
+	for _, fromNodeId := range fromNodeIdList {
+		pool := this_.getFromNodeListenerPoolIfAbsentCreate(fromNodeId)
+		if pool == nil || pool.isStop {
+			continue
+		}
 		size := pool.Put(messageListener)
 		Logger.Info(localNode.GetServerInfo() + " 添加 来至 [" + fromNodeId + "] 节点的连接 现有连接 " + fmt.Sprint(size))
 	}
+	this_.onNeighbourChange()
 
 	return
 }
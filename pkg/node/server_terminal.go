@@ -7,6 +7,7 @@ import (
 )
 
 func (this_ *Server) TerminalStart(lineNodeIdList []string, size *terminal.Size, onRead func(buf []byte) (err error)) (key string, err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	readKey := util.GetUUID()
 	this_.addOnBytesCache(readKey, &OnBytes{
 		start: func() (err error) {
@@ -32,6 +33,7 @@ func (this_ *Server) TerminalStart(lineNodeIdList []string, size *terminal.Size,
 }
 
 func (this_ *Server) TerminalWrite(lineNodeIdList []string, key string, buf []byte) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 
 	err = this_.workTerminalWrite(lineNodeIdList, key, buf)
 	if err != nil {
@@ -41,6 +43,7 @@ func (this_ *Server) TerminalWrite(lineNodeIdList []string, key string, buf []by
 }
 
 func (this_ *Server) TerminalChangeSize(lineNodeIdList []string, key string, size *terminal.Size) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 
 	err = this_.workTerminalChangeSize(lineNodeIdList, key, size)
 	if err != nil {
@@ -50,6 +53,7 @@ func (this_ *Server) TerminalChangeSize(lineNodeIdList []string, key string, siz
 }
 
 func (this_ *Server) TerminalIsWindows(lineNodeIdList []string) (isWindows bool, err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 	isWindows, err = this_.workTerminalIsWindows(lineNodeIdList)
 	if err != nil {
 		return
@@ -58,6 +62,7 @@ func (this_ *Server) TerminalIsWindows(lineNodeIdList []string) (isWindows bool,
 }
 
 func (this_ *Server) TerminalStop(lineNodeIdList []string, key string) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
 
 	err = this_.workTerminalStop(lineNodeIdList, key)
 	if err != nil {
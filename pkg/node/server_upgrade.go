@@ -0,0 +1,58 @@
+package node
+
+import (
+	"errors"
+	"io"
+)
+
+// GetVersion 节点版本
+func (this_ *Server) GetVersion(lineNodeIdList []string) (version string) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	version = this_.getVersion(lineNodeIdList)
+	return
+}
+
+// GetUpgradeInfo 节点程序信息，用于选择对应系统、架构的升级程序以及确认升级后的进程
+func (this_ *Server) GetUpgradeInfo(lineNodeIdList []string) (info *UpgradeInfo, err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	info, err = this_.getUpgradeInfo(lineNodeIdList)
+	return
+}
+
+// Upgrade 上传新版本程序至节点，校验后替换并重启节点
+// 节点重启后需要在 UpgradeConfirmTimeout 内调用 UpgradeConfirm 确认，否则节点回滚至升级前的程序
+func (this_ *Server) Upgrade(lineNodeIdList []string, request *UpgradeRequest, reader io.Reader, onDo func(readSize int64, writeSize int64), callStop *bool) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	if request.Sha256 == "" {
+		err = errors.New("升级程序 SHA256 不能为空")
+		return
+	}
+	info, err := this_.getUpgradeInfo(lineNodeIdList)
+	if err != nil {
+		return
+	}
+	if info.Pending {
+		err = UpgradeRunningError
+		return
+	}
+	err = this_.FileWorkWriteResume(lineNodeIdList, info.Executable+UpgradeSuffix, request.Sha256, reader, onDo, callStop)
+	if err != nil {
+		return
+	}
+	err = this_.workUpgrade(this_.routeLine(lineNodeIdList), request)
+	return
+}
+
+// UpgradeConfirm 确认节点升级
+func (this_ *Server) UpgradeConfirm(lineNodeIdList []string) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	err = this_.workUpgradeConfirm(lineNodeIdList)
+	return
+}
+
+// UpgradeRollback 回滚未确认的节点升级
+func (this_ *Server) UpgradeRollback(lineNodeIdList []string) (err error) {
+	lineNodeIdList = this_.routeLine(lineNodeIdList)
+	err = this_.workUpgradeRollback(lineNodeIdList)
+	return
+}
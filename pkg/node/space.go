@@ -1,9 +1,12 @@
 package node
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"teamide/pkg/terminal"
+	"time"
 )
 
 type Space struct {
@@ -34,13 +37,37 @@ type Space struct {
 	callbackCache     map[string]func(msg *Message)
 	callbackCacheLock sync.Mutex
 
+	callCancelCache     map[string]context.CancelFunc
+	callCancelCacheLock sync.Mutex
+
 	terminalServiceCache     map[string]terminal.Service
 	terminalServiceCacheLock sync.Mutex
 
+	execProcessCache     map[string]*execProcess
+	execProcessCacheLock sync.Mutex
+
+	execExitCache     map[string]func(result *ExecResult)
+	execExitCacheLock sync.Mutex
+
 	toNodeListenerKeepAliveLock sync.Mutex
 
 	onBytesCache     map[string]*OnBytes
 	onBytesCacheLock sync.Mutex
+
+	routeNodeCache     map[string]*RouteNode
+	routeNodeCacheLock sync.Mutex
+
+	neighbourIdList     []string
+	neighbourIdListLock sync.Mutex
+
+	routeStop     chan struct{}
+	routeStopOnce sync.Once
+
+	upgradeId     string      // 本进程由该升级启动
+	upgradeBackup string      // 升级前程序的备份
+	upgradeTimer  *time.Timer // 升级待确认，超时回滚
+	upgrading     bool        // 已替换程序，等待重启
+	upgradeLock   sync.Mutex
 }
 
 type OnBytes struct {
@@ -97,24 +124,78 @@ func (this_ *Space) removeTerminalService(key string) {
 	return
 }
 
+func (this_ *Space) addExecProcess(key string, one *execProcess) {
+	this_.execProcessCacheLock.Lock()
+	defer this_.execProcessCacheLock.Unlock()
+
+	this_.execProcessCache[key] = one
+	return
+}
+
+func (this_ *Space) getExecProcess(key string) (res *execProcess) {
+	this_.execProcessCacheLock.Lock()
+	defer this_.execProcessCacheLock.Unlock()
+
+	res = this_.execProcessCache[key]
+	return
+}
+
+func (this_ *Space) removeExecProcess(key string) {
+	this_.execProcessCacheLock.Lock()
+	defer this_.execProcessCacheLock.Unlock()
+
+	delete(this_.execProcessCache, key)
+	return
+}
+
+func (this_ *Space) addExecExit(key string, onExit func(result *ExecResult)) {
+	this_.execExitCacheLock.Lock()
+	defer this_.execExitCacheLock.Unlock()
+
+	this_.execExitCache[key] = onExit
+	return
+}
+
+func (this_ *Space) getExecExit(key string) (onExit func(result *ExecResult)) {
+	this_.execExitCacheLock.Lock()
+	defer this_.execExitCacheLock.Unlock()
+
+	onExit = this_.execExitCache[key]
+	return
+}
+
+func (this_ *Space) removeExecExit(key string) {
+	this_.execExitCacheLock.Lock()
+	defer this_.execExitCacheLock.Unlock()
+
+	delete(this_.execExitCache, key)
+	return
+}
+
 func newSpace() *Space {
 	return &Space{
 		toNodeListenerPoolCache:   make(map[string]*MessageListenerPool),
 		fromNodeListenerPoolCache: make(map[string]*MessageListenerPool),
 		callbackCache:             make(map[string]func(msg *Message)),
+		callCancelCache:           make(map[string]context.CancelFunc),
 		netProxyInnerCache:        make(map[string]*InnerServer),
 		netProxyOuterCache:        make(map[string]*OuterListener),
 		onBytesCache:              make(map[string]*OnBytes),
 		terminalServiceCache:      make(map[string]terminal.Service),
+		execProcessCache:          make(map[string]*execProcess),
+		execExitCache:             make(map[string]func(result *ExecResult)),
+		routeNodeCache:            make(map[string]*RouteNode),
+		routeStop:                 make(chan struct{}),
 	}
 }
 
 type ToNode struct {
-	Id          string `json:"id,omitempty"`
-	ConnAddress string `json:"connAddress,omitempty"`
-	ConnToken   string `json:"connToken,omitempty"`
-	ConnSize    int    `json:"connSize,omitempty"`
-	Enabled     int8   `json:"enabled,omitempty"`
+	Id          string     `json:"id,omitempty"`
+	ConnAddress string     `json:"connAddress,omitempty"`
+	ConnToken   string     `json:"connToken,omitempty"`
+	ConnSize    int        `json:"connSize,omitempty"`
+	ConnTLS     *TLSConfig `json:"connTLS,omitempty"`
+	Enabled     int8       `json:"enabled,omitempty"`
 }
 
 func (this_ *ToNode) IsEnabled() bool {
@@ -156,17 +237,28 @@ func (this_ *NetProxyInner) GetType() (str string) {
 	return t
 }
 
+// IsUDP 是否为 UDP 代理
+func (this_ *NetProxyInner) IsUDP() bool {
+	return IsUDPType(this_.GetType())
+}
+
+// IsDynamic 是否为动态代理
+func (this_ *NetProxyInner) IsDynamic() bool {
+	return this_.GetType() == NetProxyTypeDynamic
+}
+
 func (this_ *NetProxyInner) GetAddress() (str string) {
 	return GetAddress(this_.Address)
 }
 
 type NetProxyOuter struct {
-	Id                    string   `json:"id,omitempty"`
-	NodeId                string   `json:"nodeId,omitempty"`
-	Type                  string   `json:"type,omitempty"`
-	Address               string   `json:"address,omitempty"`
-	ReverseLineNodeIdList []string `json:"reverseLineNodeIdList,omitempty"`
-	Enabled               int8     `json:"enabled,omitempty"`
+	Id                    string           `json:"id,omitempty"`
+	NodeId                string           `json:"nodeId,omitempty"`
+	Type                  string           `json:"type,omitempty"`
+	Address               string           `json:"address,omitempty"`
+	ReverseLineNodeIdList []string         `json:"reverseLineNodeIdList,omitempty"`
+	Enabled               int8             `json:"enabled,omitempty"`
+	AllowList             []*NetProxyAllow `json:"allowList,omitempty"` // 动态代理 允许访问的目标地址
 }
 
 func (this_ *NetProxyOuter) IsEnabled() bool {
@@ -185,10 +277,28 @@ func (this_ *NetProxyOuter) GetType() (str string) {
 	return t
 }
 
+// IsUDP 是否为 UDP 代理
+func (this_ *NetProxyOuter) IsUDP() bool {
+	return IsUDPType(this_.GetType())
+}
+
+// IsDynamic 是否为动态代理
+func (this_ *NetProxyOuter) IsDynamic() bool {
+	return this_.GetType() == NetProxyTypeDynamic
+}
+
 func (this_ *NetProxyOuter) GetAddress() (str string) {
 	return GetAddress(this_.Address)
 }
 
+// NetProxyTypeDynamic 动态代理，输入端作为 SOCKS5、HTTP CONNECT 代理服务，输出端连接客户端请求的目标地址
+const NetProxyTypeDynamic = "dynamic"
+
+// IsUDPType 代理类型是否为 UDP，支持 udp、udp4、udp6
+func IsUDPType(t string) bool {
+	return strings.HasPrefix(t, "udp")
+}
+
 func GetAddress(address string) (str string) {
 	if address == "" {
 		return ""
@@ -289,6 +399,17 @@ func (this_ *Space) getToNodeListenerPoolList() (poolList []*MessageListenerPool
 	return
 }
 
+func (this_ *Space) getToNodeListenerPoolMap() (poolMap map[string]*MessageListenerPool) {
+	this_.toNodeListenerPoolCacheLock.Lock()
+	defer this_.toNodeListenerPoolCacheLock.Unlock()
+
+	poolMap = make(map[string]*MessageListenerPool)
+	for nodeId, one := range this_.toNodeListenerPoolCache {
+		poolMap[nodeId] = one
+	}
+	return
+}
+
 func (this_ *Space) getFromNodeListenerPoolIfAbsentCreate(fromNodeId string) (pool *MessageListenerPool) {
 	this_.fromNodeListenerPoolCacheLock.Lock()
 	defer this_.fromNodeListenerPoolCacheLock.Unlock()
@@ -342,6 +463,33 @@ func (this_ *Space) getFromNodeListenerPoolList() (poolList []*MessageListenerPo
 	return
 }
 
+func (this_ *Space) getFromNodeListenerPoolMap() (poolMap map[string]*MessageListenerPool) {
+	this_.fromNodeListenerPoolCacheLock.Lock()
+	defer this_.fromNodeListenerPoolCacheLock.Unlock()
+
+	poolMap = make(map[string]*MessageListenerPool)
+	for nodeId, one := range this_.fromNodeListenerPoolCache {
+		poolMap[nodeId] = one
+	}
+	return
+}
+
+func (this_ *Space) getRouteNodeCacheList() (routeNodeList []*RouteNode) {
+	this_.routeNodeCacheLock.Lock()
+	defer this_.routeNodeCacheLock.Unlock()
+
+	for _, one := range this_.routeNodeCache {
+		routeNodeList = append(routeNodeList, one)
+	}
+	return
+}
+
+func (this_ *Space) stopRoute() {
+	this_.routeStopOnce.Do(func() {
+		close(this_.routeStop)
+	})
+}
+
 func (this_ *Space) getCallback(id string) (callback func(msg *Message), ok bool) {
 	this_.callbackCacheLock.Lock()
 	defer this_.callbackCacheLock.Unlock()
@@ -364,6 +512,31 @@ func (this_ *Space) removeCallback(id string) {
 	delete(this_.callbackCache, id)
 }
 
+func (this_ *Space) setCallCancel(id string, cancel context.CancelFunc) {
+	this_.callCancelCacheLock.Lock()
+	defer this_.callCancelCacheLock.Unlock()
+
+	this_.callCancelCache[id] = cancel
+}
+
+func (this_ *Space) removeCallCancel(id string) {
+	this_.callCancelCacheLock.Lock()
+	defer this_.callCancelCacheLock.Unlock()
+
+	delete(this_.callCancelCache, id)
+}
+
+func (this_ *Space) doCallCancel(id string) (find bool) {
+	this_.callCancelCacheLock.Lock()
+	cancel, find := this_.callCancelCache[id]
+	this_.callCancelCacheLock.Unlock()
+
+	if find {
+		cancel()
+	}
+	return
+}
+
 func (this_ *Space) getNetProxyInnerIfAbsentCreate(netProxy *NetProxyInner, worker *Worker) (inner *InnerServer) {
 	this_.netProxyInnerCacheLock.Lock()
 	defer this_.netProxyInnerCacheLock.Unlock()
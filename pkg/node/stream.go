@@ -0,0 +1,389 @@
+package node
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// 流多路复用（协议版本 >= StreamProtocolVersion 时使用）
+//
+// 代理、文件、终端输出的数据按流（SendKey、ConnId）划分，每条连接上的每个流有独立的发送窗口：
+// 发送端每发送一帧扣减窗口，窗口用完后阻塞等待；接收端按流顺序处理，处理完成后回复窗口更新。
+// 慢速的消费端只会阻塞自己的流，接收端每个流缓存的数据不超过一个窗口。
+// 连接写入按优先级调度，终端数据优先于代理数据，代理数据优先于文件数据。
+
+var (
+	// StreamProtocolVersion 开始支持流多路复用的协议版本
+	StreamProtocolVersion = 4
+
+	// streamWindowSize 每个流的窗口大小
+	streamWindowSize int64 = 256 * 1024
+	// streamFrameOverhead 每帧额外占用的窗口，避免大量小帧绕过窗口限制
+	streamFrameOverhead int64 = 64
+	// streamWindowTimeout 等待窗口更新超时时间
+	streamWindowTimeout = 60 * time.Second
+	// writeSkipLimit 低优先级连续被跳过的最大次数，避免饿死
+	writeSkipLimit = 8
+
+	StreamWindowTimeoutError = errors.New("等待流窗口超时，对端处理过慢")
+)
+
+const (
+	PriorityLow    int8 = -1 // 文件等大量数据
+	PriorityNormal int8 = 0  // 控制消息、代理数据
+	PriorityHigh   int8 = 1  // 终端数据
+)
+
+// IsStreamVersion 协商后的协议版本是否支持流多路复用
+func IsStreamVersion(version int) bool {
+	return version >= StreamProtocolVersion
+}
+
+// getStreamKey 需要按流顺序处理的消息返回流标识
+func (this_ *Message) getStreamKey() string {
+	switch this_.Method {
+	case methodSendBytesStart, methodSendBytes, methodSendBytesEnd:
+		if this_.SendKey != "" {
+			return "bytes:" + this_.SendKey
+		}
+	case methodNetProxyNewConn, methodNetProxySend, methodNetProxyCloseConn:
+		if this_.NetProxyWorkData != nil && this_.NetProxyWorkData.ConnId != "" {
+			return "proxy:" + this_.NetProxyWorkData.ConnId
+		}
+	}
+	return ""
+}
+
+func (this_ *Message) isStreamClose() bool {
+	return this_.Method == methodSendBytesEnd || this_.Method == methodNetProxyCloseConn
+}
+
+func (this_ *Message) getStreamCost() int64 {
+	return int64(len(this_.Bytes)) + streamFrameOverhead
+}
+
+// streamWindow 发送端 流窗口
+type streamWindow struct {
+	size   int64
+	err    error
+	notify chan struct{}
+}
+
+func (this_ *streamWindow) wakeup() {
+	select {
+	case this_.notify <- struct{}{}:
+	default:
+	}
+}
+
+// streamRecv 接收端 流消息队列，按顺序处理
+type streamRecv struct {
+	list     []*Message
+	running  bool
+	closed   bool
+	err      error
+	consumed int64
+}
+
+type streamSpace struct {
+	streamLock    sync.Mutex
+	sendWindowMap map[string]*streamWindow
+	recvStreamMap map[string]*streamRecv
+}
+
+func (this_ *streamSpace) getSendWindow(key string) (window *streamWindow) {
+	if this_.sendWindowMap == nil {
+		this_.sendWindowMap = make(map[string]*streamWindow)
+	}
+	window, ok := this_.sendWindowMap[key]
+	if !ok {
+		window = &streamWindow{
+			size:   streamWindowSize,
+			notify: make(chan struct{}, 1),
+		}
+		this_.sendWindowMap[key] = window
+	}
+	return
+}
+
+// acquireWindow 发送端 扣减窗口，窗口不足时等待窗口更新
+func (this_ *MessageListener) acquireWindow(key string, cost int64) (err error) {
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		this_.streamLock.Lock()
+		if this_.isClosed() {
+			this_.streamLock.Unlock()
+			err = ConnClosedError
+			return
+		}
+		window := this_.getSendWindow(key)
+		if window.err != nil {
+			err = window.err
+			this_.streamLock.Unlock()
+			return
+		}
+		if window.size > 0 {
+			window.size -= cost
+			this_.streamLock.Unlock()
+			return
+		}
+		this_.streamLock.Unlock()
+
+		if timer == nil {
+			timer = time.NewTimer(streamWindowTimeout)
+		}
+		select {
+		case <-window.notify:
+		case <-timer.C:
+			err = StreamWindowTimeoutError
+			return
+		}
+	}
+}
+
+// getStreamError 发送端 获取对端重置流的异常
+func (this_ *MessageListener) getStreamError(key string) (err error) {
+	this_.streamLock.Lock()
+	defer this_.streamLock.Unlock()
+
+	if window, ok := this_.sendWindowMap[key]; ok {
+		err = window.err
+	}
+	return
+}
+
+// closeStream 流结束 移除发送窗口和空闲的接收队列
+func (this_ *MessageListener) closeStream(key string) {
+	this_.streamLock.Lock()
+	defer this_.streamLock.Unlock()
+
+	delete(this_.sendWindowMap, key)
+	if stream, ok := this_.recvStreamMap[key]; ok && !stream.running {
+		delete(this_.recvStreamMap, key)
+	}
+}
+
+// closeStreams 连接关闭 唤醒所有等待窗口的发送端
+func (this_ *MessageListener) closeStreams() {
+	this_.streamLock.Lock()
+	defer this_.streamLock.Unlock()
+
+	for _, window := range this_.sendWindowMap {
+		window.err = ConnClosedError
+		window.wakeup()
+	}
+	this_.sendWindowMap = nil
+	this_.recvStreamMap = nil
+}
+
+func (this_ *MessageListener) onStreamWindow(msg *Message) {
+	this_.streamLock.Lock()
+	defer this_.streamLock.Unlock()
+
+	if window, ok := this_.sendWindowMap[msg.SendKey]; ok {
+		window.size += msg.StreamWindow
+		window.wakeup()
+	}
+}
+
+func (this_ *MessageListener) onStreamReset(msg *Message) {
+	this_.streamLock.Lock()
+	defer this_.streamLock.Unlock()
+
+	if window, ok := this_.sendWindowMap[msg.SendKey]; ok {
+		window.err = errors.New(msg.Error)
+		window.wakeup()
+	}
+}
+
+// resetStream 接收端 流数据处理异常，丢弃后续数据并通知发送端停止发送
+func (this_ *MessageListener) resetStream(msg *Message, err error, MonitorData *MonitorData) {
+	key := msg.getStreamKey()
+	if key == "" {
+		return
+	}
+	this_.streamLock.Lock()
+	if stream, ok := this_.recvStreamMap[key]; ok && stream.err == nil {
+		stream.err = err
+	}
+	this_.streamLock.Unlock()
+
+	_ = this_.Send(&Message{
+		Method:   methodStreamReset,
+		Error:    err.Error(),
+		SendKey:  key,
+		Priority: PriorityHigh,
+	}, MonitorData)
+}
+
+// pushStream 接收端 消息加入流队列，每个流同时只有一个协程按顺序处理
+func (this_ *MessageListener) pushStream(key string, msg *Message, MonitorData *MonitorData) {
+	this_.streamLock.Lock()
+	defer this_.streamLock.Unlock()
+
+	if this_.recvStreamMap == nil {
+		this_.recvStreamMap = make(map[string]*streamRecv)
+	}
+	stream, ok := this_.recvStreamMap[key]
+	if !ok {
+		stream = &streamRecv{}
+		this_.recvStreamMap[key] = stream
+	}
+	stream.list = append(stream.list, msg)
+	if !stream.running {
+		stream.running = true
+		go this_.runStream(key, stream, MonitorData)
+	}
+}
+
+func (this_ *MessageListener) runStream(key string, stream *streamRecv, MonitorData *MonitorData) {
+	for {
+		this_.streamLock.Lock()
+		if len(stream.list) == 0 {
+			stream.running = false
+			if stream.closed && this_.recvStreamMap[key] == stream {
+				delete(this_.recvStreamMap, key)
+			}
+			this_.streamLock.Unlock()
+			return
+		}
+		msg := stream.list[0]
+		stream.list = stream.list[1:]
+		streamErr := stream.err
+		this_.streamLock.Unlock()
+
+		isData := msg.Id == ""
+		if !isData || streamErr == nil {
+			this_.onMessage(msg)
+		}
+		if msg.isStreamClose() {
+			this_.streamLock.Lock()
+			stream.closed = true
+			delete(this_.sendWindowMap, key)
+			this_.streamLock.Unlock()
+		}
+		if !isData {
+			continue
+		}
+
+		var update int64
+		this_.streamLock.Lock()
+		stream.consumed += msg.getStreamCost()
+		if stream.consumed >= streamWindowSize/2 {
+			update = stream.consumed
+			stream.consumed = 0
+		}
+		this_.streamLock.Unlock()
+		if update > 0 {
+			_ = this_.Send(&Message{
+				Method:       methodStreamWindow,
+				SendKey:      key,
+				StreamWindow: update,
+				Priority:     PriorityHigh,
+			}, MonitorData)
+		}
+	}
+}
+
+// writeScheduler 连接写入调度，按优先级排队写入
+// 高优先级优先写入，低优先级连续被跳过 writeSkipLimit 次后优先写入一次
+type writeScheduler struct {
+	lock    sync.Mutex
+	writing bool
+	waiters [3][]chan struct{}
+	skipped [3]int
+}
+
+func getPriorityIndex(priority int8) int {
+	if priority > PriorityNormal {
+		return 0
+	}
+	if priority < PriorityNormal {
+		return 2
+	}
+	return 1
+}
+
+func (this_ *writeScheduler) acquire(priority int8) {
+	this_.lock.Lock()
+	if !this_.writing {
+		this_.writing = true
+		this_.lock.Unlock()
+		return
+	}
+	wait := make(chan struct{})
+	index := getPriorityIndex(priority)
+	this_.waiters[index] = append(this_.waiters[index], wait)
+	this_.lock.Unlock()
+	<-wait
+}
+
+func (this_ *writeScheduler) release() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	var next = -1
+	for index := len(this_.waiters) - 1; index > 0; index-- {
+		if len(this_.waiters[index]) > 0 && this_.skipped[index] >= writeSkipLimit {
+			next = index
+			break
+		}
+	}
+	if next < 0 {
+		for index := range this_.waiters {
+			if len(this_.waiters[index]) > 0 {
+				next = index
+				break
+			}
+		}
+	}
+	if next < 0 {
+		this_.writing = false
+		return
+	}
+	wait := this_.waiters[next][0]
+	this_.waiters[next] = this_.waiters[next][1:]
+	this_.skipped[next] = 0
+	for index := next + 1; index < len(this_.waiters); index++ {
+		if len(this_.waiters[index]) > 0 {
+			this_.skipped[index]++
+		}
+	}
+	close(wait)
+}
+
+// Stream 发送流数据，对端支持流多路复用时不等待响应，受流窗口控制，否则等同于 Call
+func (this_ *Worker) Stream(listener *MessageListener, method MethodType, msg *Message) (err error) {
+	if !IsStreamVersion(listener.version) {
+		_, err = this_.Call(listener, method, msg)
+		return
+	}
+	msg.Id = ""
+	msg.Method = method
+	err = listener.acquireWindow(msg.getStreamKey(), msg.getStreamCost())
+	if err != nil {
+		return
+	}
+	err = listener.Send(msg, this_.MonitorData)
+	return
+}
+
+// CallStreamClose 发送流结束消息并等待响应，返回流处理过程中对端的异常
+func (this_ *Worker) CallStreamClose(listener *MessageListener, method MethodType, msg *Message) (err error) {
+	msg.Method = method
+	key := msg.getStreamKey()
+	defer listener.closeStream(key)
+
+	_, err = this_.Call(listener, method, msg)
+	if err != nil {
+		return
+	}
+	err = listener.getStreamError(key)
+	return
+}
@@ -0,0 +1,138 @@
+package node
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testStreamListener(t *testing.T, onMessage func(msg *Message)) (sender *MessageListener) {
+	senderConn, receiverConn := net.Pipe()
+	sender = &MessageListener{conn: senderConn, version: StreamProtocolVersion, onMessage: func(msg *Message) {}}
+	receiver := &MessageListener{conn: receiverConn, version: StreamProtocolVersion, onMessage: onMessage}
+	sender.listen(func() {}, &MonitorData{})
+	receiver.listen(func() {}, &MonitorData{})
+	t.Cleanup(func() {
+		sender.stop()
+		receiver.stop()
+	})
+	return
+}
+
+func testStreamMessage(index int) *Message {
+	bytes := make([]byte, 32*1024)
+	bytes[0] = byte(index)
+	return &Message{
+		SendKey:  "test",
+		HasBytes: true,
+		Bytes:    bytes,
+	}
+}
+
+func TestStreamWindow(t *testing.T) {
+	gate := make(chan struct{})
+	var lock sync.Mutex
+	var received []int
+	sender := testStreamListener(t, func(msg *Message) {
+		<-gate
+		lock.Lock()
+		received = append(received, int(msg.Bytes[0]))
+		lock.Unlock()
+	})
+	worker := &Worker{MonitorData: &MonitorData{}}
+
+	var sendLock sync.Mutex
+	var sent int
+	var sendErr error
+	count := 32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < count; i++ {
+			e := worker.Stream(sender, methodSendBytes, testStreamMessage(i))
+			if e != nil {
+				sendErr = e
+				return
+			}
+			sendLock.Lock()
+			sent++
+			sendLock.Unlock()
+		}
+	}()
+
+	// 接收端未处理 发送端发送一个窗口后阻塞
+	time.Sleep(200 * time.Millisecond)
+	sendLock.Lock()
+	blocked := sent
+	sendLock.Unlock()
+	maxFrames := int(streamWindowSize/(32*1024+streamFrameOverhead)) + 1
+	if blocked == 0 || blocked > maxFrames {
+		t.Fatalf("sender should be blocked by window, sent %d, window frames %d", blocked, maxFrames)
+	}
+
+	close(gate)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sender not resumed by window update")
+	}
+	if sendErr != nil {
+		t.Fatal(sendErr)
+	}
+	time.Sleep(100 * time.Millisecond)
+	lock.Lock()
+	defer lock.Unlock()
+	if len(received) != count {
+		t.Fatalf("received %d, expected %d", len(received), count)
+	}
+	for i, index := range received {
+		if i != index {
+			t.Fatalf("stream out of order at %d: %d", i, index)
+		}
+	}
+}
+
+func TestStreamReset(t *testing.T) {
+	resetErr := errors.New("流读取器[test]不存在")
+	sender := testStreamListener(t, func(msg *Message) {
+		msg.listener.resetStream(msg, resetErr, &MonitorData{})
+	})
+	worker := &Worker{MonitorData: &MonitorData{}}
+
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		err = worker.Stream(sender, methodSendBytes, testStreamMessage(i))
+		time.Sleep(time.Millisecond)
+	}
+	if err == nil || err.Error() != resetErr.Error() {
+		t.Fatal("sender should receive stream reset error:", err)
+	}
+}
+
+func TestWriteScheduler(t *testing.T) {
+	scheduler := &writeScheduler{}
+	scheduler.acquire(PriorityNormal)
+
+	var lock sync.Mutex
+	var order []int8
+	var wait sync.WaitGroup
+	for _, priority := range []int8{PriorityLow, PriorityNormal, PriorityHigh} {
+		wait.Add(1)
+		go func(priority int8) {
+			defer wait.Done()
+			scheduler.acquire(priority)
+			lock.Lock()
+			order = append(order, priority)
+			lock.Unlock()
+			scheduler.release()
+		}(priority)
+		time.Sleep(20 * time.Millisecond)
+	}
+	scheduler.release()
+	wait.Wait()
+	if len(order) != 3 || order[0] != PriorityHigh || order[1] != PriorityNormal || order[2] != PriorityLow {
+		t.Fatal("write order should follow priority:", order)
+	}
+}
@@ -0,0 +1,231 @@
+package node
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	TLSConfigError    = errors.New("TLS配置异常")
+	PlaintextNotAllow = errors.New("未开启明文连接，拒绝非TLS连接")
+
+	// tlsHandshakeTimeout TLS 握手超时时间
+	tlsHandshakeTimeout = 10 * time.Second
+	// tlsRecordTypeHandshake TLS 握手记录的首字节，用于区分 TLS 连接和明文连接
+	tlsRecordTypeHandshake byte = 0x16
+)
+
+// TLSConfig 节点间连接的 TLS 配置
+// Cert、Key、CA 可以是文件路径，也可以直接是 PEM 内容（方便下发至远程节点）
+type TLSConfig struct {
+	Open               bool   `json:"open,omitempty"`               // 是否开启 TLS
+	Cert               string `json:"cert,omitempty"`               // 证书
+	Key                string `json:"key,omitempty"`                // 证书 密钥
+	CA                 string `json:"ca,omitempty"`                 // 用于校验对端证书的 CA 证书
+	ClientAuth         bool   `json:"clientAuth,omitempty"`         // 服务端 是否要求并校验客户端证书（mTLS）
+	ServerName         string `json:"serverName,omitempty"`         // 客户端 校验服务端证书使用的名称
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"` // 客户端 跳过服务端证书校验
+	Plaintext          bool   `json:"plaintext,omitempty"`          // 是否允许明文连接 服务端接受明文连接 客户端TLS失败后降级为明文
+}
+
+func (this_ *TLSConfig) IsOpen() bool {
+	return this_ != nil && this_.Open
+}
+
+func (this_ *TLSConfig) IsAllowPlaintext() bool {
+	return this_ == nil || !this_.Open || this_.Plaintext
+}
+
+func (this_ *TLSConfig) Equal(other *TLSConfig) bool {
+	if this_ == nil || other == nil {
+		return this_ == other
+	}
+	return *this_ == *other
+}
+
+// readPEM 读取 PEM 内容，如果是文件路径则读取文件
+func readPEM(value string) (bs []byte, err error) {
+	if strings.Contains(value, "-----BEGIN") {
+		bs = []byte(value)
+		return
+	}
+	bs, err = os.ReadFile(value)
+	return
+}
+
+func (this_ *TLSConfig) loadCertificate() (certificates []tls.Certificate, err error) {
+	if this_.Cert == "" && this_.Key == "" {
+		return
+	}
+	if this_.Cert == "" || this_.Key == "" {
+		err = errors.New(TLSConfigError.Error() + "，证书和证书密钥需要同时配置")
+		return
+	}
+	certBytes, err := readPEM(this_.Cert)
+	if err != nil {
+		return
+	}
+	keyBytes, err := readPEM(this_.Key)
+	if err != nil {
+		return
+	}
+	certificate, err := tls.X509KeyPair(certBytes, keyBytes)
+	if err != nil {
+		return
+	}
+	certificates = append(certificates, certificate)
+	return
+}
+
+func (this_ *TLSConfig) loadCertPool() (pool *x509.CertPool, err error) {
+	if this_.CA == "" {
+		return
+	}
+	caBytes, err := readPEM(this_.CA)
+	if err != nil {
+		return
+	}
+	pool = x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caBytes) {
+		err = errors.New(TLSConfigError.Error() + "，CA证书解析失败")
+		return
+	}
+	return
+}
+
+// GetServerConfig 获取服务端 TLS 配置
+func (this_ *TLSConfig) GetServerConfig() (config *tls.Config, err error) {
+	certificates, err := this_.loadCertificate()
+	if err != nil {
+		return
+	}
+	if len(certificates) == 0 {
+		err = errors.New(TLSConfigError.Error() + "，服务端需要配置证书")
+		return
+	}
+	pool, err := this_.loadCertPool()
+	if err != nil {
+		return
+	}
+	config = &tls.Config{
+		Certificates: certificates,
+		MinVersion:   tls.VersionTLS12,
+	}
+	if this_.ClientAuth {
+		if pool == nil {
+			err = errors.New(TLSConfigError.Error() + "，校验客户端证书需要配置CA证书")
+			return
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return
+}
+
+// GetClientConfig 获取客户端 TLS 配置
+func (this_ *TLSConfig) GetClientConfig(address string) (config *tls.Config, err error) {
+	certificates, err := this_.loadCertificate()
+	if err != nil {
+		return
+	}
+	pool, err := this_.loadCertPool()
+	if err != nil {
+		return
+	}
+	serverName := this_.ServerName
+	if serverName == "" {
+		serverName, _, _ = net.SplitHostPort(address)
+	}
+	config = &tls.Config{
+		Certificates:       certificates,
+		RootCAs:            pool,
+		ServerName:         serverName,
+		InsecureSkipVerify: this_.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	return
+}
+
+// peekConn 可预读首字节的连接
+type peekConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (this_ *peekConn) Read(p []byte) (n int, err error) {
+	return this_.reader.Read(p)
+}
+
+// serverTLSConn 服务端 包装连接 根据首字节判断是否为 TLS 连接
+func serverTLSConn(conn net.Conn, tlsConfig *TLSConfig) (res net.Conn, err error) {
+	if !tlsConfig.IsOpen() {
+		res = conn
+		return
+	}
+	config, err := tlsConfig.GetServerConfig()
+	if err != nil {
+		return
+	}
+	_ = conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	defer func() {
+		_ = conn.SetDeadline(time.Time{})
+	}()
+
+	reader := bufio.NewReader(conn)
+	first, err := reader.Peek(1)
+	if err != nil {
+		return
+	}
+	conn = &peekConn{Conn: conn, reader: reader}
+	if first[0] != tlsRecordTypeHandshake {
+		if !tlsConfig.IsAllowPlaintext() {
+			err = PlaintextNotAllow
+			return
+		}
+		res = conn
+		return
+	}
+	tlsConn := tls.Server(conn, config)
+	err = tlsConn.Handshake()
+	if err != nil {
+		return
+	}
+	res = tlsConn
+	return
+}
+
+// dialNode 客户端 连接节点 开启 TLS 时进行握手，失败且允许明文时降级为明文连接
+func dialNode(address string, tlsConfig *TLSConfig) (conn net.Conn, err error) {
+	conn, err = net.Dial("tcp", address)
+	if err != nil || !tlsConfig.IsOpen() {
+		return
+	}
+	config, err := tlsConfig.GetClientConfig(address)
+	if err != nil {
+		_ = conn.Close()
+		conn = nil
+		return
+	}
+	_ = conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	tlsConn := tls.Client(conn, config)
+	err = tlsConn.Handshake()
+	if err != nil {
+		_ = conn.Close()
+		conn = nil
+		if !tlsConfig.Plaintext {
+			return
+		}
+		Logger.Warn("连接 [" + address + "] TLS握手失败，降级为明文连接，" + err.Error())
+		conn, err = net.Dial("tcp", address)
+		return
+	}
+	_ = tlsConn.SetDeadline(time.Time{})
+	conn = tlsConn
+	return
+}
@@ -0,0 +1,125 @@
+package node
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func testCreateCert(t *testing.T, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (cert *x509.Certificate, key *ecdsa.PrivateKey, certPEM string, keyPEM string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "teamide-node"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if isCA {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	}
+	if parent == nil {
+		parent = template
+		parentKey = key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ = x509.ParseCertificate(der)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+	return
+}
+
+func testTLSDial(t *testing.T, serverTLS *TLSConfig, clientTLS *TLSConfig) (err error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, e := listener.Accept()
+		if e != nil {
+			serverErr <- e
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		serverConn, e := serverTLSConn(conn, serverTLS)
+		if e != nil {
+			serverErr <- e
+			return
+		}
+		buf := make([]byte, 4)
+		_, e = serverConn.Read(buf)
+		if e == nil {
+			_, e = serverConn.Write(buf)
+		}
+		serverErr <- e
+	}()
+
+	conn, err := dialNode(listener.Addr().String(), clientTLS)
+	if err != nil {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	_, _ = conn.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	_, _ = conn.Read(buf)
+	err = <-serverErr
+	if err == nil && string(buf) != "ping" {
+		t.Fatal("echo mismatch:", string(buf))
+	}
+	return
+}
+
+func TestTLS(t *testing.T) {
+	caCert, caKey, caPEM, _ := testCreateCert(t, true, nil, nil)
+	_, _, serverCertPEM, serverKeyPEM := testCreateCert(t, false, caCert, caKey)
+	_, _, clientCertPEM, clientKeyPEM := testCreateCert(t, false, caCert, caKey)
+
+	serverTLS := &TLSConfig{Open: true, Cert: serverCertPEM, Key: serverKeyPEM, CA: caPEM, ClientAuth: true}
+
+	// mTLS
+	err := testTLSDial(t, serverTLS, &TLSConfig{Open: true, Cert: clientCertPEM, Key: clientKeyPEM, CA: caPEM})
+	if err != nil {
+		t.Fatal("mTLS dial error:", err)
+	}
+
+	// 缺少客户端证书
+	err = testTLSDial(t, serverTLS, &TLSConfig{Open: true, CA: caPEM})
+	if err == nil {
+		t.Fatal("client without certificate should be rejected")
+	}
+
+	// 明文连接 未开启明文
+	err = testTLSDial(t, serverTLS, nil)
+	if err != PlaintextNotAllow {
+		t.Fatal("plaintext should be rejected, error:", err)
+	}
+
+	// 明文连接 开启明文
+	plaintextServerTLS := &TLSConfig{}
+	*plaintextServerTLS = *serverTLS
+	plaintextServerTLS.Plaintext = true
+	err = testTLSDial(t, plaintextServerTLS, nil)
+	if err != nil {
+		t.Fatal("plaintext dial error:", err)
+	}
+}
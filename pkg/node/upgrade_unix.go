@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package node
+
+import (
+	"os"
+	"syscall"
+)
+
+// swapExecutable 保留当前程序至 backup 后，使用 path 原子替换当前程序
+func swapExecutable(executable string, path string, backup string) (err error) {
+	_ = os.Remove(backup)
+	if err = os.Link(executable, backup); err != nil {
+		if err = copyUpgradeFile(executable, backup); err != nil {
+			return
+		}
+	}
+	err = os.Rename(path, executable)
+	return
+}
+
+// restartExecutable 替换当前进程，进程号不变
+func restartExecutable(executable string, env []string) (err error) {
+	return syscall.Exec(executable, os.Args, env)
+}
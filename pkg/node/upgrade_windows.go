@@ -0,0 +1,36 @@
+//go:build windows
+// +build windows
+
+package node
+
+import (
+	"os"
+	"os/exec"
+)
+
+// swapExecutable 运行中的程序不能覆盖，可以重命名，先重命名为 backup 再替换
+func swapExecutable(executable string, path string, backup string) (err error) {
+	_ = os.Remove(backup)
+	if err = os.Rename(executable, backup); err != nil {
+		return
+	}
+	if err = os.Rename(path, executable); err != nil {
+		_ = os.Rename(backup, executable)
+		return
+	}
+	return
+}
+
+// restartExecutable 启动新进程后退出当前进程
+func restartExecutable(executable string, env []string) (err error) {
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err = cmd.Start(); err != nil {
+		return
+	}
+	os.Exit(0)
+	return
+}
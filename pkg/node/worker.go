@@ -1,6 +1,7 @@
 package node
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/team-ide/go-tool/util"
@@ -11,9 +12,35 @@ type Worker struct {
 	server *Server
 	*Space
 	MonitorData *MonitorData
+	ctx         context.Context // 处理请求时的上下文，请求被取消或超时时结束
+}
+
+// WithContext 返回使用该上下文发起调用的 Worker，上下文结束时取消进行中的调用
+func (this_ *Worker) WithContext(ctx context.Context) *Worker {
+	worker := *this_
+	worker.ctx = ctx
+	return &worker
+}
+
+// background 返回不使用请求上下文的 Worker，用于请求处理结束后仍在运行的任务，如终端输出、文件读取、代理
+func (this_ *Worker) background() *Worker {
+	if this_.ctx == nil {
+		return this_
+	}
+	worker := *this_
+	worker.ctx = nil
+	return &worker
+}
+
+func (this_ *Worker) getContext() context.Context {
+	if this_.ctx == nil {
+		return context.Background()
+	}
+	return this_.ctx
 }
 
 func (this_ *Worker) Stop() {
+	this_.stopRoute()
 	this_.removeToNodeListenerPoolList()
 	this_.removeFromNodeListenerPoolList()
 }
@@ -24,6 +51,7 @@ func (this_ *Worker) getVersion(lineNodeIdList []string) (version string) {
 	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
 		resMsg, e = this_.Call(listener, methodGetVersion, &Message{
 			LineNodeIdList: lineNodeIdList,
+			NodeWorkData:   &WorkData{},
 		})
 		return
 	})
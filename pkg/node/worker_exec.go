@@ -0,0 +1,252 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// 命令执行
+//
+// 目标节点启动命令后，标准输出、标准错误按流（Key + ":stdout"、Key + ":stderr"）沿反向节点线发送，
+// 输出发送完成后通过 methodExecExit 发送退出码，发起节点收到后回调 onExit。
+
+var (
+	ExecNotFoundError = errors.New("命令不存在或已结束")
+	ExecKilledError   = errors.New("命令已终止")
+	ExecTimeoutError  = errors.New("命令执行超时")
+
+	// ExecOutputMaxSize Server.Exec 收集的标准输出、标准错误的最大大小，超过时丢弃之后的输出
+	ExecOutputMaxSize = 1024 * 1024
+)
+
+// ExecRequest 执行命令请求
+type ExecRequest struct {
+	Key     string   `json:"key,omitempty"`     // 命令标识，为空时自动生成，用于终止命令
+	Command string   `json:"command,omitempty"` // 命令，Args 为空时使用系统 Shell 执行
+	Args    []string `json:"args,omitempty"`
+	Env     []string `json:"env,omitempty"` // 追加的环境变量，格式 KEY=VALUE
+	Dir     string   `json:"dir,omitempty"`
+	Timeout int64    `json:"timeout,omitempty"` // 超时时间（毫秒），为 0 时不超时
+}
+
+// ExecResult 命令执行结果
+type ExecResult struct {
+	Key       string `json:"key,omitempty"`
+	ExitCode  int    `json:"exitCode"`
+	Error     string `json:"error,omitempty"`
+	IsTimeout bool   `json:"isTimeout,omitempty"`
+	IsKilled  bool   `json:"isKilled,omitempty"`
+	Stdout    string `json:"stdout,omitempty"`    // Server.Exec 收集的标准输出
+	Stderr    string `json:"stderr,omitempty"`    // Server.Exec 收集的标准错误
+	Truncated bool   `json:"truncated,omitempty"` // 输出超过 ExecOutputMaxSize，已截断
+}
+
+type execProcess struct {
+	cmd      *exec.Cmd
+	cancel   context.CancelFunc
+	isKilled bool
+	killLock sync.Mutex
+}
+
+func (this_ *execProcess) kill() {
+	this_.killLock.Lock()
+	defer this_.killLock.Unlock()
+
+	this_.isKilled = true
+	this_.cancel()
+}
+
+func getExecStdoutKey(key string) string {
+	return key + ":stdout"
+}
+
+func getExecStderrKey(key string) string {
+	return key + ":stderr"
+}
+
+func newExecCmd(request *ExecRequest) (cmd *exec.Cmd) {
+	if len(request.Args) > 0 {
+		cmd = exec.Command(request.Command, request.Args...)
+	} else if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", request.Command)
+	} else {
+		cmd = exec.Command("sh", "-c", request.Command)
+	}
+	setExecProcessGroup(cmd)
+	cmd.Dir = request.Dir
+	if len(request.Env) > 0 {
+		cmd.Env = append(os.Environ(), request.Env...)
+	}
+	return
+}
+
+func (this_ *Worker) workExecStart(lineNodeIdList []string, request *ExecRequest) (err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		_, e = this_.Call(listener, methodExecStart, &Message{
+			LineNodeIdList: lineNodeIdList,
+			ExecWorkData: &ExecWorkData{
+				Request: request,
+			},
+		})
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	var ctx = context.Background()
+	var cancel context.CancelFunc
+	if request.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(request.Timeout)*time.Millisecond)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	cmd := newExecCmd(request)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		cancel()
+		return
+	}
+	err = cmd.Start()
+	if err != nil {
+		cancel()
+		return
+	}
+	Logger.Info("exec start success", zap.Any("key", request.Key), zap.Any("command", request.Command))
+
+	process := &execProcess{
+		cmd:    cmd,
+		cancel: cancel,
+	}
+	this_.addExecProcess(request.Key, process)
+	var done = make(chan struct{})
+	go func() {
+		// 超时或终止时结束整个进程组，子进程持有的输出管道随之关闭
+		select {
+		case <-ctx.Done():
+			select {
+			case <-done:
+			default:
+				killExecProcess(cmd)
+			}
+		case <-done:
+		}
+	}()
+
+	var line []string
+	for i := len(lineNodeIdList) - 1; i >= 0; i-- {
+		line = append(line, lineNodeIdList[i])
+	}
+	worker := this_.background()
+	go func() {
+		defer func() {
+			this_.removeExecProcess(request.Key)
+			cancel()
+		}()
+
+		var waitGroup sync.WaitGroup
+		waitGroup.Add(2)
+		go func() {
+			defer waitGroup.Done()
+			if e := worker.workSend(line, getExecStdoutKey(request.Key), PriorityNormal, stdout.Read); e != nil {
+				Logger.Error("exec stdout send error", zap.Error(e))
+				// 输出无法发送时终止命令，避免管道写满后阻塞
+				cancel()
+			}
+		}()
+		go func() {
+			defer waitGroup.Done()
+			if e := worker.workSend(line, getExecStderrKey(request.Key), PriorityNormal, stderr.Read); e != nil {
+				Logger.Error("exec stderr send error", zap.Error(e))
+				cancel()
+			}
+		}()
+		waitGroup.Wait()
+
+		result := &ExecResult{
+			Key: request.Key,
+		}
+		e := cmd.Wait()
+		close(done)
+		if cmd.ProcessState != nil {
+			result.ExitCode = cmd.ProcessState.ExitCode()
+		}
+		process.killLock.Lock()
+		result.IsKilled = process.isKilled
+		process.killLock.Unlock()
+		if ctx.Err() == context.DeadlineExceeded {
+			result.IsTimeout = true
+			result.Error = ExecTimeoutError.Error()
+		} else if result.IsKilled {
+			result.Error = ExecKilledError.Error()
+		} else if e != nil && result.ExitCode == 0 {
+			result.Error = e.Error()
+		}
+		Logger.Info("exec end", zap.Any("key", request.Key), zap.Any("exitCode", result.ExitCode))
+
+		if e = worker.workExecExit(line, result); e != nil {
+			Logger.Error("exec exit send error", zap.Error(e))
+		}
+	}()
+	return
+}
+
+func (this_ *Worker) workExecKill(lineNodeIdList []string, key string) (err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		_, e = this_.Call(listener, methodExecKill, &Message{
+			LineNodeIdList: lineNodeIdList,
+			ExecWorkData: &ExecWorkData{
+				Key: key,
+			},
+		})
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	process := this_.getExecProcess(key)
+	if process == nil {
+		err = ExecNotFoundError
+		return
+	}
+	process.kill()
+	return
+}
+
+// workExecExit 目标节点发送退出结果至发起节点
+func (this_ *Worker) workExecExit(lineNodeIdList []string, result *ExecResult) (err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		_, e = this_.Call(listener, methodExecExit, &Message{
+			LineNodeIdList: lineNodeIdList,
+			ExecWorkData: &ExecWorkData{
+				Key:    result.Key,
+				Result: result,
+			},
+		})
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	onExit := this_.getExecExit(result.Key)
+	if onExit == nil {
+		err = ExecNotFoundError
+		return
+	}
+	this_.removeExecExit(result.Key)
+	onExit(result)
+	return
+}
@@ -0,0 +1,117 @@
+package node
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testLocalServer() (server *Server) {
+	server = &Server{localNodeList: []*LocalNode{{Id: "root"}}}
+	server.Worker = &Worker{server: server, Space: newSpace(), MonitorData: &MonitorData{}}
+	return
+}
+
+func TestExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("exec test uses sh")
+	}
+	server := testLocalServer()
+
+	result, err := server.Exec([]string{"root"}, &ExecRequest{
+		Command: "echo $NAME; pwd; echo err >&2; exit 3",
+		Env:     []string{"NAME=teamide"},
+		Dir:     "/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 3 || result.Stdout != "teamide\n/\n" || result.Stderr != "err\n" {
+		t.Fatalf("exec result error: %+v", result)
+	}
+
+	result, err = server.Exec([]string{"root"}, &ExecRequest{
+		Command: "echo",
+		Args:    []string{"a b", "c"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 0 || result.Stdout != "a b c\n" {
+		t.Fatalf("exec result error: %+v", result)
+	}
+
+	// 输出超过最大大小时截断
+	outputMaxSize := ExecOutputMaxSize
+	ExecOutputMaxSize = 10
+	result, err = server.Exec([]string{"root"}, &ExecRequest{
+		Command: "echo 0123456789abcdef",
+	})
+	ExecOutputMaxSize = outputMaxSize
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stdout != "0123456789" || !result.Truncated {
+		t.Fatalf("exec output should truncate: %+v", result)
+	}
+
+	result, err = server.Exec([]string{"root"}, &ExecRequest{
+		Command: "sleep 5",
+		Timeout: 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsTimeout || result.ExitCode == 0 {
+		t.Fatalf("exec should timeout: %+v", result)
+	}
+
+	// 未设置超时时间时最多等待 DefaultCallTimeout
+	callTimeout := DefaultCallTimeout
+	DefaultCallTimeout = 200 * time.Millisecond
+	start := time.Now()
+	_, err = server.Exec([]string{"root"}, &ExecRequest{
+		Command: "sleep 5",
+	})
+	DefaultCallTimeout = callTimeout
+	if err != ExecTimeoutError || time.Since(start) > 3*time.Second {
+		t.Fatal("exec without timeout should wait at most DefaultCallTimeout:", err, time.Since(start))
+	}
+}
+
+func TestExecKill(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("exec test uses sh")
+	}
+	server := testLocalServer()
+
+	exitChan := make(chan *ExecResult, 1)
+	var output strings.Builder
+	key, err := server.ExecStart([]string{"root"}, &ExecRequest{
+		Command: "echo start; sleep 5",
+	}, func(buf []byte) (err error) {
+		output.Write(buf)
+		return
+	}, nil, func(result *ExecResult) {
+		exitChan <- result
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err = server.ExecKill([]string{"root"}, key); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case result := <-exitChan:
+		if !result.IsKilled || output.String() != "start\n" {
+			t.Fatalf("exec should be killed: %+v %s", result, output.String())
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("exec kill timeout")
+	}
+	if err = server.ExecKill([]string{"root"}, key); err != ExecNotFoundError {
+		t.Fatal("kill ended exec should return not found:", err)
+	}
+}
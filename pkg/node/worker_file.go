@@ -188,6 +188,57 @@ func (this_ *Worker) workFileRemove(lineNodeIdList []string, path string) (fileC
 	return
 }
 
+func (this_ *Worker) workFileCount(lineNodeIdList []string, path string) (fileCount int, err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		res, e := this_.Call(listener, methodFileCount, &Message{
+			LineNodeIdList: lineNodeIdList,
+			FileWorkData: &FileWorkData{
+				Path: path,
+			},
+		})
+		if e != nil {
+			return
+		}
+
+		if res != nil && res.FileWorkData != nil {
+			fileCount = res.FileWorkData.FileCount
+		}
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	fileCount, err = filework.NewLocalService().Count(path, nil)
+	return
+}
+
+func (this_ *Worker) workFileCountSize(lineNodeIdList []string, path string) (fileCount int, fileSize int64, err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		res, e := this_.Call(listener, methodFileCountSize, &Message{
+			LineNodeIdList: lineNodeIdList,
+			FileWorkData: &FileWorkData{
+				Path: path,
+			},
+		})
+		if e != nil {
+			return
+		}
+
+		if res != nil && res.FileWorkData != nil {
+			fileCount = res.FileWorkData.FileCount
+			fileSize = res.FileWorkData.Size
+		}
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	fileCount, fileSize, err = filework.NewLocalService().CountSize(path, nil)
+	return
+}
+
 func (this_ *Worker) workFileRead(lineNodeIdList []string, path string, sendKey string) (err error) {
 	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
 		_, e = this_.Call(listener, methodFileRead, &Message{
@@ -220,7 +271,7 @@ func (this_ *Worker) workFileRead(lineNodeIdList []string, path string, sendKey
 			line = append(line, lineNodeIdList[i])
 		}
 
-		err = this_.workSend(line, sendKey, f.Read)
+		err = this_.background().workSend(line, sendKey, PriorityLow, f.Read)
 		if err != nil {
 			Logger.Error("file read send error", zap.Error(err))
 		}
@@ -278,7 +329,7 @@ func (this_ *Worker) workFileWrite(lineNodeIdList []string, path string) (sendKe
 	return
 }
 
-func (this_ *Worker) workSend(lineNodeIdList []string, key string, read func(p []byte) (n int, err error)) (err error) {
+func (this_ *Worker) workSend(lineNodeIdList []string, key string, priority int8, read func(p []byte) (n int, err error)) (err error) {
 
 	err = this_.workSendBytesStart(lineNodeIdList, key)
 	if err != nil {
@@ -289,7 +340,7 @@ func (this_ *Worker) workSend(lineNodeIdList []string, key string, read func(p [
 	err = util.ReadByFunc(read, buf, func(n int) (e error) {
 		//Logger.Info("workSend read", zap.Any("key", key), zap.Any("n", n), zap.Any("str", string(buf[:n])))
 		if n > 0 {
-			e = this_.workSendBytes(lineNodeIdList, key, buf[:n])
+			e = this_.workSendBytes(lineNodeIdList, key, priority, buf[:n])
 		}
 		return
 	})
@@ -334,7 +385,7 @@ func (this_ *Worker) workSendBytesStart(lineNodeIdList []string, key string) (er
 
 func (this_ *Worker) workSendBytesEnd(lineNodeIdList []string, key string) (err error) {
 	send, err := this_.sendToNext(lineNodeIdList, key, func(listener *MessageListener) (e error) {
-		_, e = this_.Call(listener, methodSendBytesEnd, &Message{
+		e = this_.CallStreamClose(listener, methodSendBytesEnd, &Message{
 			LineNodeIdList: lineNodeIdList,
 			SendKey:        key,
 		})
@@ -360,11 +411,12 @@ func (this_ *Worker) workSendBytesEnd(lineNodeIdList []string, key string) (err
 	return
 }
 
-func (this_ *Worker) workSendBytes(lineNodeIdList []string, key string, buf []byte) (err error) {
+func (this_ *Worker) workSendBytes(lineNodeIdList []string, key string, priority int8, buf []byte) (err error) {
 	send, err := this_.sendToNext(lineNodeIdList, key, func(listener *MessageListener) (e error) {
-		_, e = this_.Call(listener, methodSendBytes, &Message{
+		e = this_.Stream(listener, methodSendBytes, &Message{
 			LineNodeIdList: lineNodeIdList,
 			SendKey:        key,
+			Priority:       priority,
 			HasBytes:       true,
 			Bytes:          buf,
 		})
@@ -0,0 +1,381 @@
+package node
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// 断点续传
+//
+// 上传时目标节点先写入临时文件（路径 + 上传 ID + FilePartSuffix），每块数据带 CRC32 校验值，写入前校验，
+// 全部写入后校验整个文件的 MD5，一致时重命名为目标文件。临时文件保存在目标节点的磁盘上，
+// 连接断开重连后根据临时文件大小继续写入。上传 ID 由目标路径和上传方提供的 key（如本地文件大小和修改时间）生成，
+// 重新上传同一文件时继续使用上次的临时文件，不同内容的上传各自使用自己的临时文件。
+// 开始上传和上传完成时删除同一文件超过 FilePartExpireTime 未修改的临时文件。
+// 下载时按偏移读取，每块数据带 CRC32 校验值，全部读取后与源文件的 MD5 比较。
+
+var (
+	// FilePartSuffix 上传中的临时文件后缀
+	FilePartSuffix = ".teamide-part"
+	// FileOldSuffix Windows 下替换目标文件时，原文件先重命名的后缀
+	FileOldSuffix = ".teamide-old"
+	// FileChunkSize 断点续传 每块数据大小
+	FileChunkSize = 1024 * 1024
+	// FilePartExpireTime 临时文件超过该时间未修改时删除
+	FilePartExpireTime = 7 * 24 * time.Hour
+
+	FileChecksumError         = errors.New("文件校验失败")
+	FileOffsetError           = errors.New("文件写入位置不一致")
+	FileChunkSizeError        = errors.New("读取大小不合法")
+	FileResumeNotSupportError = errors.New("节点不支持断点续传，请升级节点")
+)
+
+// getFilePartPath 上传的临时文件，上传 ID 为空时（旧版本节点发起的上传）所有上传共用一个临时文件
+func getFilePartPath(path string, uploadId string) (partPath string, err error) {
+	if uploadId == "" {
+		partPath = path + FilePartSuffix
+		return
+	}
+	if uploadId == "." || uploadId == ".." || strings.ContainsAny(uploadId, `/\`) {
+		err = errors.New("上传ID[" + uploadId + "]不合法")
+		return
+	}
+	partPath = path + "." + uploadId + FilePartSuffix
+	return
+}
+
+// GetFileUploadId 上传 ID，key 标识上传的内容，相同的目标路径和 key 使用同一个临时文件，可以跨调用继续上传；
+// key 为空时使用随机 ID，只能在一次调用内继续上传
+func GetFileUploadId(path string, key string) string {
+	if key == "" {
+		return util.GetUUID()
+	}
+	return fmt.Sprintf("%x", md5.Sum([]byte(path+"\n"+key)))
+}
+
+// removeExpiredFileParts 删除目标文件超过 FilePartExpireTime 未修改的临时文件
+func removeExpiredFileParts(path string) {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), name+".") || !strings.HasSuffix(entry.Name(), FilePartSuffix) {
+			continue
+		}
+		info, e := entry.Info()
+		if e != nil || time.Since(info.ModTime()) < FilePartExpireTime {
+			continue
+		}
+		_ = os.Remove(filepath.Join(dir, entry.Name()))
+	}
+}
+
+// replaceFile 临时文件重命名为目标文件，Windows 下目标文件存在时重命名失败，先将原文件重命名，失败时还原
+func replaceFile(partPath string, path string) (err error) {
+	if runtime.GOOS != "windows" {
+		err = os.Rename(partPath, path)
+		return
+	}
+	oldPath := partPath + FileOldSuffix
+	err = os.Rename(path, oldPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return
+		}
+		err = os.Rename(partPath, path)
+		return
+	}
+	err = os.Rename(partPath, path)
+	if err != nil {
+		_ = os.Rename(oldPath, path)
+		return
+	}
+	_ = os.Remove(oldPath)
+	return
+}
+
+// getFileMd5 文件 MD5，size 小于 0 时计算整个文件
+func getFileMd5(path string, size int64) (md5Str string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	hash := md5.New()
+	if size < 0 {
+		_, err = io.Copy(hash, f)
+	} else {
+		_, err = io.CopyN(hash, f, size)
+	}
+	if err != nil {
+		return
+	}
+	md5Str = fmt.Sprintf("%x", hash.Sum(nil))
+	return
+}
+
+// getResumeFileWorkData 断点续传的响应，旧版本节点不认识该方法时响应为空
+func getResumeFileWorkData(res *Message) (data *FileWorkData, err error) {
+	if res == nil || res.FileWorkData == nil {
+		err = FileResumeNotSupportError
+		return
+	}
+	data = res.FileWorkData
+	return
+}
+
+func (this_ *Worker) workFileMd5(lineNodeIdList []string, path string) (data *FileWorkData, err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		res, e := this_.Call(listener, methodFileMd5, &Message{
+			LineNodeIdList: lineNodeIdList,
+			FileWorkData: &FileWorkData{
+				Path: path,
+			},
+		})
+		if e != nil {
+			return
+		}
+		data, e = getResumeFileWorkData(res)
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	data = &FileWorkData{}
+	stat, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	if stat.IsDir() {
+		err = errors.New("[" + path + "]为目录")
+		return
+	}
+	data.Exist = true
+	data.Size = stat.Size()
+	data.Md5, err = getFileMd5(path, -1)
+	return
+}
+
+// workFileWriteStart 开始上传，返回临时文件已写入的大小以及已写入部分的 MD5，truncate 时清空临时文件
+func (this_ *Worker) workFileWriteStart(lineNodeIdList []string, path string, uploadId string, truncate bool) (data *FileWorkData, err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		res, e := this_.Call(listener, methodFileWriteStart, &Message{
+			LineNodeIdList: lineNodeIdList,
+			FileWorkData: &FileWorkData{
+				Path:     path,
+				UploadId: uploadId,
+				Truncate: truncate,
+			},
+		})
+		if e != nil {
+			return
+		}
+		data, e = getResumeFileWorkData(res)
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	partPath, err := getFilePartPath(path, uploadId)
+	if err != nil {
+		return
+	}
+	removeExpiredFileParts(path)
+	data = &FileWorkData{}
+	if truncate {
+		err = os.Remove(partPath)
+		if err != nil && !os.IsNotExist(err) {
+			return
+		}
+		err = nil
+	}
+	stat, err := os.Stat(partPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	data.Offset = stat.Size()
+	if data.Offset > 0 {
+		data.Md5, err = getFileMd5(partPath, data.Offset)
+	}
+	return
+}
+
+// workFileWriteChunk 校验并写入一块数据，返回写入后的大小
+func (this_ *Worker) workFileWriteChunk(lineNodeIdList []string, path string, uploadId string, offset int64, checksum uint32, buf []byte) (newOffset int64, err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		res, e := this_.Call(listener, methodFileWriteChunk, &Message{
+			LineNodeIdList: lineNodeIdList,
+			Priority:       PriorityLow,
+			HasBytes:       true,
+			Bytes:          buf,
+			FileWorkData: &FileWorkData{
+				Path:     path,
+				UploadId: uploadId,
+				Offset:   offset,
+				Checksum: checksum,
+			},
+		})
+		if e != nil {
+			return
+		}
+		data, e := getResumeFileWorkData(res)
+		if e != nil {
+			return
+		}
+		newOffset = data.Offset
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	if crc32.ChecksumIEEE(buf) != checksum {
+		err = FileChecksumError
+		return
+	}
+	partPath, err := getFilePartPath(path, uploadId)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(partPath, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return
+	}
+	if stat.Size() != offset {
+		err = FileOffsetError
+		return
+	}
+	_, err = f.WriteAt(buf, offset)
+	if err != nil {
+		return
+	}
+	newOffset = offset + int64(len(buf))
+	return
+}
+
+// workFileWriteEnd 校验临时文件的 MD5，一致时重命名为目标文件，不一致时删除临时文件
+func (this_ *Worker) workFileWriteEnd(lineNodeIdList []string, path string, uploadId string, md5Str string) (err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		res, e := this_.Call(listener, methodFileWriteEnd, &Message{
+			LineNodeIdList: lineNodeIdList,
+			FileWorkData: &FileWorkData{
+				Path:     path,
+				UploadId: uploadId,
+				Md5:      md5Str,
+			},
+		})
+		if e != nil {
+			return
+		}
+		_, e = getResumeFileWorkData(res)
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	partPath, err := getFilePartPath(path, uploadId)
+	if err != nil {
+		return
+	}
+	partMd5, err := getFileMd5(partPath, -1)
+	if err != nil {
+		return
+	}
+	if partMd5 != md5Str {
+		_ = os.Remove(partPath)
+		err = FileChecksumError
+		return
+	}
+	err = replaceFile(partPath, path)
+	if err != nil {
+		return
+	}
+	removeExpiredFileParts(path)
+	return
+}
+
+// workFileReadChunk 从偏移位置读取一块数据，大小由对端指定，最大为 FileChunkSize
+func (this_ *Worker) workFileReadChunk(lineNodeIdList []string, path string, offset int64, size int) (buf []byte, checksum uint32, err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		res, e := this_.Call(listener, methodFileReadChunk, &Message{
+			LineNodeIdList: lineNodeIdList,
+			Priority:       PriorityLow,
+			FileWorkData: &FileWorkData{
+				Path:   path,
+				Offset: offset,
+				Size:   int64(size),
+			},
+		})
+		if e != nil {
+			return
+		}
+		data, e := getResumeFileWorkData(res)
+		if e != nil {
+			return
+		}
+		buf = res.Bytes
+		checksum = data.Checksum
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	if size <= 0 || offset < 0 {
+		err = FileChunkSizeError
+		return
+	}
+	if size > FileChunkSize {
+		size = FileChunkSize
+	}
+	if size > MaxFrameSize {
+		size = MaxFrameSize
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	buf = make([]byte, size)
+	n, err := f.ReadAt(buf, offset)
+	if err == io.EOF {
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	buf = buf[:n]
+	checksum = crc32.ChecksumIEEE(buf)
+	return
+}
@@ -0,0 +1,275 @@
+package node
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testFileResumeContent(size int) []byte {
+	buf := make([]byte, size)
+	for i := range buf {
+		buf[i] = byte(i % 251)
+	}
+	return buf
+}
+
+func TestFileWorkWriteResume(t *testing.T) {
+	server := testLocalServer()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.bin")
+	content := testFileResumeContent(FileChunkSize*2 + 100)
+	var callStop bool
+	onDo := func(readSize int64, writeSize int64) {}
+
+	partPath, err := getFilePartPath(path, "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 上次上传中断，临时文件保留已写入的部分
+	if err = os.WriteFile(partPath, content[:FileChunkSize+10], 0644); err != nil {
+		t.Fatal(err)
+	}
+	var lastWriteSize int64
+	err = server.fileWorkWriteResume([]string{"root"}, path, "upload", bytes.NewReader(content), func(readSize int64, writeSize int64) {
+		lastWriteSize = writeSize
+	}, &callStop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastWriteSize != int64(len(content)) {
+		t.Fatal("write size error:", lastWriteSize)
+	}
+	written, _ := os.ReadFile(path)
+	if !bytes.Equal(written, content) {
+		t.Fatal("resume content error")
+	}
+	if _, err = os.Stat(partPath); !os.IsNotExist(err) {
+		t.Fatal("part file should be removed")
+	}
+
+	// 临时文件内容与本地不一致时重新上传
+	if err = os.WriteFile(partPath, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	content = testFileResumeContent(1000)
+	err = server.fileWorkWriteResume([]string{"root"}, path, "upload", bytes.NewReader(content), onDo, &callStop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, _ = os.ReadFile(path)
+	if !bytes.Equal(written, content) {
+		t.Fatal("rewrite content error")
+	}
+
+	// 目标文件已存在时替换
+	content = testFileResumeContent(10)
+	err = server.FileWorkWriteResume([]string{"root"}, path, "", bytes.NewReader(content), onDo, &callStop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, _ = os.ReadFile(path)
+	if !bytes.Equal(written, content) {
+		t.Fatal("replace content error")
+	}
+
+	exist, md5Str, err := server.FileWorkMd5([]string{"root"}, path)
+	if err != nil || !exist {
+		t.Fatal("md5 error:", err)
+	}
+	if localMd5, _ := getFileMd5(path, -1); localMd5 != md5Str {
+		t.Fatal("md5 not equal:", md5Str, localMd5)
+	}
+}
+
+// testFailReader 读取 size 字节后返回异常
+type testFailReader struct {
+	reader io.Reader
+	size   int
+}
+
+func (this_ *testFailReader) Read(p []byte) (n int, err error) {
+	if this_.size <= 0 {
+		return 0, errors.New("read error")
+	}
+	if len(p) > this_.size {
+		p = p[:this_.size]
+	}
+	n, err = this_.reader.Read(p)
+	this_.size -= n
+	return
+}
+
+func TestFileWorkWriteResumeKey(t *testing.T) {
+	server := testLocalServer()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.bin")
+	content := testFileResumeContent(FileChunkSize*2 + 100)
+	var callStop bool
+	onDo := func(readSize int64, writeSize int64) {}
+
+	// 相同的 key 失败后保留临时文件，重新上传时继续上传
+	err := server.FileWorkWriteResume([]string{"root"}, path, "key1", &testFailReader{reader: bytes.NewReader(content), size: FileChunkSize + 10}, onDo, &callStop)
+	if err == nil {
+		t.Fatal("read error should fail")
+	}
+	partPath, _ := getFilePartPath(path, GetFileUploadId(path, "key1"))
+	if stat, e := os.Stat(partPath); e != nil || stat.Size() != int64(FileChunkSize) {
+		t.Fatal("part file should be kept:", e)
+	}
+	var firstWriteSize int64 = -1
+	err = server.FileWorkWriteResume([]string{"root"}, path, "key1", bytes.NewReader(content), func(readSize int64, writeSize int64) {
+		if firstWriteSize < 0 {
+			firstWriteSize = writeSize
+		}
+	}, &callStop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if firstWriteSize != int64(FileChunkSize) {
+		t.Fatal("should resume from part file:", firstWriteSize)
+	}
+	if written, _ := os.ReadFile(path); !bytes.Equal(written, content) {
+		t.Fatal("resume content error")
+	}
+
+	// key 为空时失败后删除临时文件
+	err = server.FileWorkWriteResume([]string{"root"}, path, "", &testFailReader{reader: bytes.NewReader(content), size: FileChunkSize + 10}, onDo, &callStop)
+	if err == nil {
+		t.Fatal("read error should fail")
+	}
+	if matches, _ := filepath.Glob(path + ".*" + FilePartSuffix); len(matches) != 0 {
+		t.Fatal("part file should be removed:", matches)
+	}
+
+	// 开始上传时删除过期的临时文件
+	expired, _ := getFilePartPath(path, "expired")
+	fresh, _ := getFilePartPath(path, "fresh")
+	other, _ := getFilePartPath(path+"x", "expired")
+	for _, one := range []string{expired, fresh, other} {
+		if err = os.WriteFile(one, []byte("part"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldTime := time.Now().Add(-FilePartExpireTime - time.Hour)
+	_ = os.Chtimes(expired, oldTime, oldTime)
+	_ = os.Chtimes(other, oldTime, oldTime)
+	if _, err = server.workFileWriteStart([]string{"root"}, path, "upload", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(expired); !os.IsNotExist(err) {
+		t.Fatal("expired part file should be removed")
+	}
+	for _, one := range []string{fresh, other} {
+		if _, err = os.Stat(one); err != nil {
+			t.Fatal("part file should be kept:", one, err)
+		}
+	}
+}
+
+func TestFileWorkChunkChecksum(t *testing.T) {
+	server := testLocalServer()
+	path := filepath.Join(t.TempDir(), "a.bin")
+
+	buf := []byte("hello")
+	if _, err := server.workFileWriteChunk([]string{"root"}, path, "a", 0, 1, buf); !errors.Is(err, FileChecksumError) {
+		t.Fatal("checksum should fail:", err)
+	}
+	offset, err := server.workFileWriteChunk([]string{"root"}, path, "a", 0, crc32.ChecksumIEEE(buf), buf)
+	if err != nil || offset != 5 {
+		t.Fatal("write chunk error:", offset, err)
+	}
+	if _, err = server.workFileWriteChunk([]string{"root"}, path, "a", 0, crc32.ChecksumIEEE(buf), buf); !errors.Is(err, FileOffsetError) {
+		t.Fatal("offset should fail:", err)
+	}
+
+	// 同一文件的其它上传使用自己的临时文件
+	offset, err = server.workFileWriteChunk([]string{"root"}, path, "b", 0, crc32.ChecksumIEEE(buf), buf)
+	if err != nil || offset != 5 {
+		t.Fatal("other upload write chunk error:", offset, err)
+	}
+	if _, err = server.workFileWriteChunk([]string{"root"}, path, "../b", 0, crc32.ChecksumIEEE(buf), buf); err == nil {
+		t.Fatal("upload id should be checked")
+	}
+
+	if err = server.workFileWriteEnd([]string{"root"}, path, "a", "0"); !errors.Is(err, FileChecksumError) {
+		t.Fatal("md5 should fail:", err)
+	}
+	partPath, _ := getFilePartPath(path, "a")
+	if _, err = os.Stat(partPath); !os.IsNotExist(err) {
+		t.Fatal("part file should be removed")
+	}
+	partPath, _ = getFilePartPath(path, "b")
+	if _, err = os.Stat(partPath); err != nil {
+		t.Fatal("other part file should not be removed:", err)
+	}
+}
+
+func TestFileWorkReadChunkSize(t *testing.T) {
+	server := testLocalServer()
+	path := filepath.Join(t.TempDir(), "a.bin")
+	content := testFileResumeContent(FileChunkSize + 100)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{0, -1} {
+		if _, _, err := server.workFileReadChunk([]string{"root"}, path, 0, size); !errors.Is(err, FileChunkSizeError) {
+			t.Fatal("size should be checked:", size, err)
+		}
+	}
+	// 对端指定的大小超过 FileChunkSize 时只读取 FileChunkSize
+	buf, checksum, err := server.workFileReadChunk([]string{"root"}, path, 0, MaxFrameSize*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf) != FileChunkSize || checksum != crc32.ChecksumIEEE(content[:FileChunkSize]) {
+		t.Fatal("chunk size should be clamped:", len(buf))
+	}
+}
+
+func TestFileWorkReadResume(t *testing.T) {
+	server := testLocalServer()
+	path := filepath.Join(t.TempDir(), "a.bin")
+	content := testFileResumeContent(FileChunkSize + 100)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var callStop bool
+	var out bytes.Buffer
+	err := server.FileWorkReadResume([]string{"root"}, path, &out, func(readSize int64, writeSize int64) {}, &callStop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), content) {
+		t.Fatal("read content error")
+	}
+}
+
+func TestFileWorkCount(t *testing.T) {
+	server := testLocalServer()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.WriteFile(filepath.Join(dir, "a.txt"), []byte("12345"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("123"), 0644)
+
+	fileCount, err := server.FileWorkCount([]string{"root"}, dir, nil)
+	if err != nil || fileCount != 4 {
+		t.Fatal("count error:", fileCount, err)
+	}
+	var lastSize int64
+	fileCount, fileSize, err := server.FileWorkCountSize([]string{"root"}, dir, func(fileCount int, fileSize int64) {
+		lastSize = fileSize
+	})
+	if err != nil || fileCount != 4 || fileSize != 8 || lastSize != 8 {
+		t.Fatal("count size error:", fileCount, fileSize, err)
+	}
+}
@@ -1,22 +1,30 @@
 package node
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"teamide/pkg/filework"
+	"teamide/pkg/system"
 	"time"
 )
 
 var (
 	methodOK         MethodType = 1
 	methodGetVersion MethodType = 2
+	methodCallCancel MethodType = 3
 
 	methodNodeAddToNodeList      MethodType = 101
 	methodNodeRemoveToNodeList   MethodType = 102
 	methodNodeGetNodeMonitorData MethodType = 103
 	methodNodeGetStatus          MethodType = 104
+	methodNodeRouteGossip        MethodType = 105
+	methodNodeUpgradeInfo        MethodType = 106
+	methodNodeUpgrade            MethodType = 107
+	methodNodeUpgradeConfirm     MethodType = 108
+	methodNodeUpgradeRollback    MethodType = 109
 
 	methodNetProxyNewConn                 MethodType = 201
 	methodNetProxyCloseConn               MethodType = 202
@@ -30,17 +38,22 @@ var (
 	methodNetProxyGetInnerStatus          MethodType = 210
 	methodNetProxyGetOuterStatus          MethodType = 211
 
-	methodFileExist     MethodType = 301
-	methodFileFile      MethodType = 302
-	methodFileFiles     MethodType = 303
-	methodFileCreate    MethodType = 304
-	methodFileRemove    MethodType = 305
-	methodFileRename    MethodType = 306
-	methodFileMove      MethodType = 307
-	methodFileWrite     MethodType = 308
-	methodFileRead      MethodType = 309
-	methodFileCount     MethodType = 310
-	methodFileCountSize MethodType = 311
+	methodFileExist      MethodType = 301
+	methodFileFile       MethodType = 302
+	methodFileFiles      MethodType = 303
+	methodFileCreate     MethodType = 304
+	methodFileRemove     MethodType = 305
+	methodFileRename     MethodType = 306
+	methodFileMove       MethodType = 307
+	methodFileWrite      MethodType = 308
+	methodFileRead       MethodType = 309
+	methodFileCount      MethodType = 310
+	methodFileCountSize  MethodType = 311
+	methodFileMd5        MethodType = 312
+	methodFileWriteStart MethodType = 313
+	methodFileWriteChunk MethodType = 314
+	methodFileWriteEnd   MethodType = 315
+	methodFileReadChunk  MethodType = 316
 
 	methodTerminalStart      MethodType = 401
 	methodTerminalWrite      MethodType = 402
@@ -52,14 +65,150 @@ var (
 	methodSystemQueryMonitorData MethodType = 502
 	methodSystemCleanMonitorData MethodType = 503
 	methodSystemMonitorData      MethodType = 504
+	methodSystemProcessList      MethodType = 505
+	methodSystemProcessSignal    MethodType = 506
 
 	methodSendBytesStart MethodType = 601
 	methodSendBytes      MethodType = 602
 	methodSendBytesEnd   MethodType = 603
+	methodStreamWindow   MethodType = 604
+	methodStreamReset    MethodType = 605
+
+	methodExecStart MethodType = 701
+	methodExecKill  MethodType = 702
+	methodExecExit  MethodType = 703
 )
 
 type MethodType int
 
+const (
+	errorCodeTimeout    = "timeout"
+	errorCodeNotAllowed = "notAllowed"
+	errorCodeChecksum   = "checksum"
+	errorCodeOffset     = "offset"
+	errorCodeSignature  = "signature"
+	errorCodeUpgrading  = "upgrading"
+	errorCodeDenied     = "denied"
+)
+
+var (
+	// DefaultCallTimeout 默认调用超时时间
+	DefaultCallTimeout = 60 * time.Second
+	// MethodCallTimeout 方法调用超时时间，未配置的方法使用 DefaultCallTimeout，上下文设置了截止时间时以上下文为准
+	MethodCallTimeout = map[MethodType]time.Duration{
+		methodOK:                          10 * time.Second,
+		methodGetVersion:                  10 * time.Second,
+		methodNodeUpgrade:                 time.Minute,
+		methodNodeGetNodeMonitorData:      10 * time.Second,
+		methodNodeGetStatus:               10 * time.Second,
+		methodNetProxyNewConn:             30 * time.Second,
+		methodNetProxyGetInnerMonitorData: 10 * time.Second,
+		methodNetProxyGetOuterMonitorData: 10 * time.Second,
+		methodNetProxyGetInnerStatus:      10 * time.Second,
+		methodNetProxyGetOuterStatus:      10 * time.Second,
+		methodFileRemove:                  30 * time.Minute,
+		methodFileMove:                    30 * time.Minute,
+		methodFileCount:                   30 * time.Minute,
+		methodFileCountSize:               30 * time.Minute,
+		methodFileMd5:                     30 * time.Minute,
+		methodFileWriteStart:              30 * time.Minute,
+		methodFileWriteEnd:                30 * time.Minute,
+		methodTerminalStart:               30 * time.Second,
+		methodSystemGetInfo:               10 * time.Second,
+		methodSystemMonitorData:           10 * time.Second,
+		methodSystemProcessList:           30 * time.Second,
+		methodSystemProcessSignal:         10 * time.Second,
+		methodExecStart:                   30 * time.Second,
+		methodExecKill:                    10 * time.Second,
+	}
+)
+
+func getCallTimeout(method MethodType) time.Duration {
+	if timeout, ok := MethodCallTimeout[method]; ok && timeout > 0 {
+		return timeout
+	}
+	return DefaultCallTimeout
+}
+
+// CallTimeoutError 调用超时异常，可通过 IsCallTimeout 判断
+type CallTimeoutError struct {
+	Method  MethodType
+	Timeout time.Duration
+	message string // 其它节点返回的超时信息
+}
+
+func (this_ *CallTimeoutError) Error() string {
+	if this_.message != "" {
+		return this_.message
+	}
+	return fmt.Sprintf("请求超时，方法[%d]超时时间%s", this_.Method, this_.Timeout)
+}
+
+func (this_ *CallTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// IsCallTimeout 是否为调用超时异常，包括其它节点返回的超时
+func IsCallTimeout(err error) bool {
+	var timeoutError *CallTimeoutError
+	return errors.As(err, &timeoutError)
+}
+
+// codeErrorMap 带错误码的异常，上层节点还原后可通过 errors.Is 判断
+var codeErrorMap = map[string]error{
+	errorCodeNotAllowed: NetProxyNotAllowedError,
+	errorCodeChecksum:   FileChecksumError,
+	errorCodeOffset:     FileOffsetError,
+	errorCodeSignature:  UpgradeSignatureError,
+	errorCodeUpgrading:  UpgradeRunningError,
+	errorCodeDenied:     AccessDeniedError,
+}
+
+// codeError 其它节点返回的带错误码的异常
+type codeError struct {
+	message string
+	err     error
+}
+
+func (this_ *codeError) Error() string {
+	return this_.message
+}
+
+func (this_ *codeError) Unwrap() error {
+	return this_.err
+}
+
+// newErrorMessage 异常响应，超时等异常带上错误码，便于上层节点还原
+func newErrorMessage(err error) (msg *Message) {
+	msg = &Message{
+		Error: err.Error(),
+	}
+	if IsCallTimeout(err) {
+		msg.ErrorCode = errorCodeTimeout
+		return
+	}
+	for code, codeErr := range codeErrorMap {
+		if errors.Is(err, codeErr) {
+			msg.ErrorCode = code
+			return
+		}
+	}
+	return
+}
+
+func (this_ *Message) getError(method MethodType) (err error) {
+	if this_.ErrorCode == errorCodeTimeout {
+		err = &CallTimeoutError{Method: method, message: this_.Error}
+		return
+	}
+	if codeErr, ok := codeErrorMap[this_.ErrorCode]; ok {
+		err = &codeError{message: this_.Error, err: codeErr}
+		return
+	}
+	err = errors.New(this_.Error)
+	return
+}
+
 func (this_ *Worker) onMessage(msg *Message) {
 	if msg == nil {
 		return
@@ -67,11 +216,31 @@ func (this_ *Worker) onMessage(msg *Message) {
 	callback, ok := this_.getCallback(msg.Id)
 	if ok {
 		callback(msg)
+	} else if msg.Method == methodCallCancel {
+		if msg.CancelId != "" {
+			this_.doCallCancel(msg.CancelId)
+		}
 	} else {
-		res, err := this_.doMethod(msg.Method, msg)
+		worker := this_
+		if msg.Id != "" {
+			// 请求的上下文，收到取消消息时结束，处理中发起的调用也会取消
+			ctx, cancel := context.WithCancel(this_.getContext())
+			this_.setCallCancel(msg.Id, cancel)
+			defer func() {
+				this_.removeCallCancel(msg.Id)
+				cancel()
+			}()
+			worker = this_.WithContext(ctx)
+		}
+		res, err := worker.doMethod(msg.Method, msg)
+		if msg.Id == "" && err != nil && msg.listener != nil {
+			// 流数据不等待响应，处理异常时重置流
+			msg.listener.resetStream(msg, err, this_.MonitorData)
+			return
+		}
 		if msg.Id != "" {
 			if err != nil {
-				err = msg.ReturnError(err.Error(), this_.MonitorData)
+				err = msg.Return(newErrorMessage(err), this_.MonitorData)
 				if err != nil {
 					Logger.Error("message return error", zap.Error(err))
 					return
@@ -88,58 +257,94 @@ func (this_ *Worker) onMessage(msg *Message) {
 
 }
 
+// Call 发起调用并等待响应，使用 Worker 的上下文
 func (this_ *Worker) Call(listener *MessageListener, method MethodType, msg *Message) (result *Message, err error) {
+	result, err = this_.CallContext(this_.getContext(), listener, method, msg)
+	return
+}
+
+// CallContext 发起调用并等待响应
+// 上下文设置了截止时间时以截止时间为准，否则使用方法的超时时间，超时返回 CallTimeoutError
+// 上下文取消或超时后向下一个节点发送取消消息，由其继续沿节点线取消
+func (this_ *Worker) CallContext(ctx context.Context, listener *MessageListener, method MethodType, msg *Message) (result *Message, err error) {
 	msg.Id = uuid.NewString()
 	msg.Method = method
 
-	defer func() {
-		this_.removeCallback(msg.Id)
-		if e := recover(); e != nil {
-			Logger.Error("call error", zap.Any("error", e))
-		}
-	}()
+	err = ctx.Err()
+	if err != nil {
+		return
+	}
 
 	waitResult := make(chan *Message, 1)
 	this_.setCallback(msg.Id, func(msg *Message) {
-		waitResult <- msg
+		select {
+		case waitResult <- msg:
+		default:
+		}
 	})
+	defer this_.removeCallback(msg.Id)
+
 	err = listener.Send(msg, this_.MonitorData)
 	if err != nil {
 		return
 	}
-	var isEnd bool
-	go func() {
-		defer func() {
-			if e := recover(); e != nil {
-				Logger.Error("call wait result error", zap.Any("error", e))
-			}
-		}()
-		time.Sleep(time.Second * 60 * 1)
-		if isEnd || waitResult == nil {
+
+	var timeout time.Duration
+	var timeoutC <-chan time.Time
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	} else {
+		timeout = getCallTimeout(method)
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+
+	select {
+	case res := <-waitResult:
+		if res.Error != "" {
+			err = res.getError(method)
 			return
 		}
-		waitResult <- &Message{
-			Error: fmt.Sprintf("请求超时，超时时间%d秒", 5),
+		result = res
+	case <-timeoutC:
+		err = &CallTimeoutError{Method: method, Timeout: timeout}
+		this_.sendCallCancel(listener, msg)
+	case <-ctx.Done():
+		err = ctx.Err()
+		if err == context.DeadlineExceeded {
+			err = &CallTimeoutError{Method: method, Timeout: timeout}
 		}
-	}()
-	res := <-waitResult
-
-	close(waitResult)
-	waitResult = nil
-
-	isEnd = true
-	if res.Error != "" {
-		err = errors.New(res.Error)
-		return
+		this_.sendCallCancel(listener, msg)
 	}
-	result = res
 	return
 }
 
+// sendCallCancel 通知下一个节点取消调用
+func (this_ *Worker) sendCallCancel(listener *MessageListener, msg *Message) {
+	err := listener.Send(&Message{
+		Method:         methodCallCancel,
+		LineNodeIdList: msg.LineNodeIdList,
+		CancelId:       msg.Id,
+		Priority:       PriorityHigh,
+	}, this_.MonitorData)
+	if err != nil {
+		Logger.Warn("call cancel send error", zap.Error(err))
+	}
+}
+
 func (this_ *Worker) doMethod(method MethodType, msg *Message) (res *Message, err error) {
 	if msg == nil {
 		return
 	}
+	err = this_.getContext().Err()
+	if err != nil {
+		return
+	}
+	err = this_.checkAccessPolicy(method, msg)
+	if err != nil {
+		return
+	}
 	res = &Message{}
 	switch method {
 	case methodOK:
@@ -169,6 +374,32 @@ func (this_ *Worker) doMethod(method MethodType, msg *Message) (res *Message, er
 			Status: status,
 		}
 		return
+	case methodNodeRouteGossip:
+		if msg.NodeWorkData != nil {
+			this_.onRouteGossip(msg.NodeWorkData.RouteList)
+		}
+		return
+	case methodNodeUpgradeInfo:
+		var info *UpgradeInfo
+		info, err = this_.getUpgradeInfo(msg.LineNodeIdList)
+		if err != nil {
+			return
+		}
+		res.NodeWorkData = &WorkData{
+			UpgradeInfo: info,
+		}
+		return
+	case methodNodeUpgrade:
+		if msg.NodeWorkData != nil && msg.NodeWorkData.UpgradeRequest != nil {
+			err = this_.workUpgrade(msg.LineNodeIdList, msg.NodeWorkData.UpgradeRequest)
+		}
+		return
+	case methodNodeUpgradeConfirm:
+		err = this_.workUpgradeConfirm(msg.LineNodeIdList)
+		return
+	case methodNodeUpgradeRollback:
+		err = this_.workUpgradeRollback(msg.LineNodeIdList)
+		return
 	case methodNodeAddToNodeList:
 		if msg.NodeWorkData != nil {
 			this_.addToNodeList(msg.LineNodeIdList, msg.NodeWorkData.ToNodeList)
@@ -218,7 +449,7 @@ func (this_ *Worker) doMethod(method MethodType, msg *Message) (res *Message, er
 		return
 	case methodNetProxyNewConn:
 		if msg.NetProxyWorkData != nil {
-			err = this_.netProxyNewConn(msg.LineNodeIdList, msg.NetProxyWorkData.NetProxyId, msg.NetProxyWorkData.ConnId)
+			err = this_.netProxyNewConn(msg.LineNodeIdList, msg.NetProxyWorkData.NetProxyId, msg.NetProxyWorkData.ConnId, msg.NetProxyWorkData.Address)
 		}
 		return
 	case methodNetProxyCloseConn:
@@ -277,6 +508,23 @@ func (this_ *Worker) doMethod(method MethodType, msg *Message) (res *Message, er
 			res.SystemData = response
 		}
 		return
+	case methodSystemProcessList:
+		if msg.SystemData != nil {
+			var response *system.ProcessQueryResponse
+			response, err = this_.systemProcessList(msg.LineNodeIdList, msg.SystemData.ProcessRequest)
+			if err != nil {
+				return
+			}
+			res.SystemData = &SystemData{
+				ProcessResponse: response,
+			}
+		}
+		return
+	case methodSystemProcessSignal:
+		if msg.SystemData != nil {
+			err = this_.systemProcessSignal(msg.LineNodeIdList, msg.SystemData.ProcessSignal)
+		}
+		return
 
 	case methodFileExist:
 		if msg.FileWorkData != nil {
@@ -372,9 +620,77 @@ func (this_ *Worker) doMethod(method MethodType, msg *Message) (res *Message, er
 			res.SendKey = sendKey
 		}
 		return
+	case methodFileMd5:
+		if msg.FileWorkData != nil {
+			res.FileWorkData, err = this_.workFileMd5(msg.LineNodeIdList, msg.FileWorkData.Path)
+		}
+		return
+	case methodFileWriteStart:
+		if msg.FileWorkData != nil {
+			res.FileWorkData, err = this_.workFileWriteStart(msg.LineNodeIdList, msg.FileWorkData.Path, msg.FileWorkData.UploadId, msg.FileWorkData.Truncate)
+		}
+		return
+	case methodFileWriteChunk:
+		if msg.FileWorkData != nil {
+			var offset int64
+			offset, err = this_.workFileWriteChunk(msg.LineNodeIdList, msg.FileWorkData.Path, msg.FileWorkData.UploadId, msg.FileWorkData.Offset, msg.FileWorkData.Checksum, msg.Bytes)
+			if err != nil {
+				return
+			}
+			res.FileWorkData = &FileWorkData{
+				Offset: offset,
+			}
+		}
+		return
+	case methodFileWriteEnd:
+		if msg.FileWorkData != nil {
+			err = this_.workFileWriteEnd(msg.LineNodeIdList, msg.FileWorkData.Path, msg.FileWorkData.UploadId, msg.FileWorkData.Md5)
+			if err != nil {
+				return
+			}
+			res.FileWorkData = &FileWorkData{}
+		}
+		return
+	case methodFileReadChunk:
+		if msg.FileWorkData != nil {
+			var buf []byte
+			var checksum uint32
+			buf, checksum, err = this_.workFileReadChunk(msg.LineNodeIdList, msg.FileWorkData.Path, msg.FileWorkData.Offset, int(msg.FileWorkData.Size))
+			if err != nil {
+				return
+			}
+			res.FileWorkData = &FileWorkData{
+				Checksum: checksum,
+			}
+			res.HasBytes = true
+			res.Bytes = buf
+		}
+		return
 	case methodFileCount:
+		if msg.FileWorkData != nil {
+			var fileCount int
+			fileCount, err = this_.workFileCount(msg.LineNodeIdList, msg.FileWorkData.Path)
+			if err != nil {
+				return
+			}
+			res.FileWorkData = &FileWorkData{
+				FileCount: fileCount,
+			}
+		}
 		return
 	case methodFileCountSize:
+		if msg.FileWorkData != nil {
+			var fileCount int
+			var fileSize int64
+			fileCount, fileSize, err = this_.workFileCountSize(msg.LineNodeIdList, msg.FileWorkData.Path)
+			if err != nil {
+				return
+			}
+			res.FileWorkData = &FileWorkData{
+				FileCount: fileCount,
+				Size:      fileSize,
+			}
+		}
 		return
 
 	case methodTerminalStart:
@@ -426,6 +742,31 @@ func (this_ *Worker) doMethod(method MethodType, msg *Message) (res *Message, er
 		}
 		return
 
+	case methodExecStart:
+		if msg.ExecWorkData != nil && msg.ExecWorkData.Request != nil {
+			err = this_.workExecStart(msg.LineNodeIdList, msg.ExecWorkData.Request)
+			if err != nil {
+				return
+			}
+		}
+		return
+	case methodExecKill:
+		if msg.ExecWorkData != nil {
+			err = this_.workExecKill(msg.LineNodeIdList, msg.ExecWorkData.Key)
+			if err != nil {
+				return
+			}
+		}
+		return
+	case methodExecExit:
+		if msg.ExecWorkData != nil && msg.ExecWorkData.Result != nil {
+			err = this_.workExecExit(msg.LineNodeIdList, msg.ExecWorkData.Result)
+			if err != nil {
+				return
+			}
+		}
+		return
+
 	case methodSendBytesStart:
 		err = this_.workSendBytesStart(msg.LineNodeIdList, msg.SendKey)
 		if err != nil {
@@ -433,7 +774,7 @@ func (this_ *Worker) doMethod(method MethodType, msg *Message) (res *Message, er
 		}
 		return
 	case methodSendBytes:
-		err = this_.workSendBytes(msg.LineNodeIdList, msg.SendKey, msg.Bytes)
+		err = this_.workSendBytes(msg.LineNodeIdList, msg.SendKey, msg.Priority, msg.Bytes)
 		if err != nil {
 			return
 		}
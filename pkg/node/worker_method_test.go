@@ -0,0 +1,154 @@
+package node
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// testRecordListener 只记录收到的消息，不响应
+func testRecordListener(t *testing.T) (listener *MessageListener, received chan *Message) {
+	conn, recordConn := net.Pipe()
+	received = make(chan *Message, 10)
+	listener = &MessageListener{conn: conn, version: ProtocolVersion, onMessage: func(msg *Message) {}}
+	record := &MessageListener{conn: recordConn, version: ProtocolVersion, onMessage: func(msg *Message) {
+		received <- msg
+	}}
+	listener.listen(func() {}, &MonitorData{})
+	record.listen(func() {}, &MonitorData{})
+	t.Cleanup(func() {
+		listener.stop()
+		record.stop()
+	})
+	return
+}
+
+func testWaitMessage(t *testing.T, received chan *Message, method MethodType) (msg *Message) {
+	for {
+		select {
+		case msg = <-received:
+			if msg.Method == method {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("wait method [%d] timeout", method)
+		}
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	worker := &Worker{Space: newSpace(), MonitorData: &MonitorData{}}
+	listener, received := testRecordListener(t)
+
+	timeout := MethodCallTimeout[methodOK]
+	MethodCallTimeout[methodOK] = 50 * time.Millisecond
+	defer func() {
+		MethodCallTimeout[methodOK] = timeout
+	}()
+
+	_, err := worker.Call(listener, methodOK, &Message{})
+	if !IsCallTimeout(err) {
+		t.Fatal("call should return timeout error:", err)
+	}
+	call := testWaitMessage(t, received, methodOK)
+	cancel := testWaitMessage(t, received, methodCallCancel)
+	if cancel.CancelId != call.Id {
+		t.Fatal("cancel id mismatch:", cancel.CancelId, call.Id)
+	}
+
+	// 其它节点返回的超时
+	err = (&Message{Error: "请求超时", ErrorCode: errorCodeTimeout}).getError(methodOK)
+	if !IsCallTimeout(err) || err.Error() != "请求超时" {
+		t.Fatal("remote timeout should be call timeout error:", err)
+	}
+}
+
+func TestCallCancel(t *testing.T) {
+	// A -> B -> C，C 不响应，A 取消后 B 取消至 C 的调用
+	serverB := &Server{}
+	serverB.Worker = &Worker{server: serverB, Space: newSpace(), MonitorData: &MonitorData{}}
+	serverB.localNodeList = []*LocalNode{{Id: "B"}}
+
+	listenerC, receivedC := testRecordListener(t)
+	serverB.getToNodeListenerPoolIfAbsentCreate("C").Put(listenerC)
+
+	connA, connB := net.Pipe()
+	listenerA := &MessageListener{conn: connA, version: ProtocolVersion}
+	listenerB := &MessageListener{conn: connB, version: ProtocolVersion, onMessage: serverB.onMessage}
+	workerA := &Worker{Space: newSpace(), MonitorData: &MonitorData{}}
+	listenerA.onMessage = workerA.onMessage
+	listenerA.listen(func() {}, &MonitorData{})
+	listenerB.listen(func() {}, &MonitorData{})
+	defer func() {
+		listenerA.stop()
+		listenerB.stop()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		testWaitMessage(t, receivedC, methodNodeGetStatus)
+		cancel()
+	}()
+	_, err := workerA.CallContext(ctx, listenerA, methodNodeGetStatus, &Message{
+		LineNodeIdList: []string{"A", "B", "C"},
+	})
+	if err != context.Canceled {
+		t.Fatal("call should be canceled:", err)
+	}
+	msg := testWaitMessage(t, receivedC, methodCallCancel)
+	if msg.CancelId == "" {
+		t.Fatal("cancel should propagate to C")
+	}
+}
+
+func TestWorkerBackground(t *testing.T) {
+	worker := &Worker{Space: newSpace(), MonitorData: &MonitorData{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	requestWorker := worker.WithContext(ctx)
+	cancel()
+
+	// 请求结束后仍在运行的任务不受请求上下文影响
+	if requestWorker.getContext().Err() == nil {
+		t.Fatal("request context should be canceled")
+	}
+	if err := requestWorker.background().getContext().Err(); err != nil {
+		t.Fatal("background context should not be canceled:", err)
+	}
+	if requestWorker.background().Space != worker.Space {
+		t.Fatal("background worker should share space")
+	}
+}
+
+func TestServerCallContext(t *testing.T) {
+	// B -> C，C 不响应，请求上下文取消后 Server 的调用立即返回
+	server := &Server{}
+	server.Worker = &Worker{server: server, Space: newSpace(), MonitorData: &MonitorData{}}
+	server.localNodeList = []*LocalNode{{Id: "B"}}
+
+	listenerC, receivedC := testRecordListener(t)
+	server.getToNodeListenerPoolIfAbsentCreate("C").Put(listenerC)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		testWaitMessage(t, receivedC, methodSystemProcessList)
+		cancel()
+	}()
+	done := make(chan error, 1)
+	go func() {
+		_, err := server.SystemProcessListContext(ctx, []string{"B", "C"}, nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatal("call should be canceled:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("call should return after context canceled")
+	}
+	testWaitMessage(t, receivedC, methodCallCancel)
+	if server.Worker.ctx != nil {
+		t.Fatal("server worker should not keep request context")
+	}
+}
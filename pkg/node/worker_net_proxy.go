@@ -3,6 +3,7 @@ package node
 import (
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
+	"reflect"
 )
 
 func (this_ *Worker) doAddNetProxyInnerList(netProxyList []*NetProxyInner) (err error) {
@@ -20,7 +21,7 @@ func (this_ *Worker) doAddNetProxyInnerList(netProxyList []*NetProxyInner) (err
 			this_.netProxyInnerList = append(this_.netProxyInnerList, netProxy)
 
 			if netProxy.IsEnabled() {
-				_ = this_.getNetProxyInnerIfAbsentCreate(netProxy, this_)
+				_ = this_.getNetProxyInnerIfAbsentCreate(netProxy, this_.background())
 			}
 		} else {
 
@@ -44,7 +45,7 @@ func (this_ *Worker) doAddNetProxyInnerList(netProxyList []*NetProxyInner) (err
 				Logger.Info(this_.server.GetServerInfo()+" 更新网络代理 ", zap.Any("netProxy", netProxy))
 				_ = this_.removeNetProxyInner(netProxy.Id)
 				if find.IsEnabled() {
-					_ = this_.getNetProxyInnerIfAbsentCreate(netProxy, this_)
+					_ = this_.getNetProxyInnerIfAbsentCreate(netProxy, this_.background())
 				}
 			}
 
@@ -69,7 +70,7 @@ func (this_ *Worker) doAddNetProxyOuterList(netProxyList []*NetProxyOuter) (err
 			this_.netProxyOuterList = append(this_.netProxyOuterList, netProxy)
 
 			if netProxy.IsEnabled() {
-				_ = this_.getNetProxyOuterIfAbsentCreate(netProxy, this_)
+				_ = this_.getNetProxyOuterIfAbsentCreate(netProxy, this_.background())
 			}
 		} else {
 
@@ -88,12 +89,16 @@ func (this_ *Worker) doAddNetProxyOuterList(netProxyList []*NetProxyOuter) (err
 				hasChange = true
 				find.Address = netProxy.Address
 			}
+			if !reflect.DeepEqual(netProxy.AllowList, find.AllowList) {
+				hasChange = true
+				find.AllowList = netProxy.AllowList
+			}
 
 			if hasChange {
 				Logger.Info(this_.server.GetServerInfo()+" 更新网络代理 ", zap.Any("netProxy", netProxy))
 				_ = this_.removeNetProxyOuter(netProxy.Id)
 				if find.IsEnabled() {
-					_ = this_.getNetProxyOuterIfAbsentCreate(netProxy, this_)
+					_ = this_.getNetProxyOuterIfAbsentCreate(netProxy, this_.background())
 				}
 			}
 
@@ -21,10 +21,11 @@ func (this_ *Worker) doAddToNodeList(toNodeList []*ToNode) (err error) {
 		var find = this_.findToNode(toNode.Id)
 
 		if find == nil {
-			Logger.Info(this_.server.GetServerInfo()+" 添加节点 ", zap.Any("toNode", toNode))
+			// 连接 Token 以及 TLS 私钥不输出到日志
+			Logger.Info(this_.server.GetServerInfo()+" 添加节点 ", zap.Any("toNodeId", toNode.Id), zap.Any("connAddress", toNode.ConnAddress))
 			this_.toNodeList = append(this_.toNodeList, toNode)
 
-			this_.toNodeListenerKeepAlive(toNode.Id, toNode.ConnAddress, toNode.ConnToken, toNode.ConnSize)
+			this_.toNodeListenerKeepAlive(toNode.Id, toNode.ConnAddress, toNode.ConnToken, toNode.ConnSize, toNode.ConnTLS)
 		} else {
 			var hasChange bool
 			if toNode.Enabled != 0 {
@@ -41,15 +42,19 @@ func (this_ *Worker) doAddToNodeList(toNodeList []*ToNode) (err error) {
 				find.ConnToken = toNode.ConnToken
 				hasChange = true
 			}
+			if !toNode.ConnTLS.Equal(find.ConnTLS) {
+				find.ConnTLS = toNode.ConnTLS
+				hasChange = true
+			}
 			if toNode.ConnSize != 0 && toNode.ConnSize != find.ConnSize {
 				find.ConnSize = toNode.ConnSize
 				hasChange = true
 			}
 			if hasChange {
-				Logger.Info(this_.server.GetServerInfo()+" 更新节点 ", zap.Any("toNode", toNode))
+				Logger.Info(this_.server.GetServerInfo()+" 更新节点 ", zap.Any("toNodeId", toNode.Id), zap.Any("connAddress", toNode.ConnAddress))
 				this_.removeToNodeListenerPool(toNode.Id)
 				if find.IsEnabled() {
-					this_.toNodeListenerKeepAlive(find.Id, find.ConnAddress, find.ConnToken, find.ConnSize)
+					this_.toNodeListenerKeepAlive(find.Id, find.ConnAddress, find.ConnToken, find.ConnSize, find.ConnTLS)
 				}
 			}
 		}
@@ -83,7 +88,7 @@ func (this_ *Worker) doRemoveToNodeList(removeToNodeIdList []string) (err error)
 	return
 }
 
-func (this_ *Worker) toNodeListenerKeepAlive(toNodeId string, connAddress, connToken string, connSize int) {
+func (this_ *Worker) toNodeListenerKeepAlive(toNodeId string, connAddress, connToken string, connSize int, connTLS *TLSConfig) {
 	if connAddress == "" {
 		Logger.Warn("连接 [" + toNodeId + "] [" + connAddress + "] 连接地址为空")
 		return
@@ -101,12 +106,12 @@ func (this_ *Worker) toNodeListenerKeepAlive(toNodeId string, connAddress, connT
 		connSize = 5
 	}
 	for connIndex := 0; connIndex < connSize; connIndex++ {
-		go this_.connNodeListener(pool, connAddress, connToken, connIndex)
+		go this_.connNodeListener(pool, connAddress, connToken, connTLS, connIndex)
 	}
 	return
 }
 
-func (this_ *Worker) connNodeListener(pool *MessageListenerPool, connAddress, connToken string, connIndex int) {
+func (this_ *Worker) connNodeListener(pool *MessageListenerPool, connAddress, connToken string, connTLS *TLSConfig, connIndex int) {
 	if pool != nil && pool.isStop {
 		return
 	}
@@ -119,78 +124,52 @@ func (this_ *Worker) connNodeListener(pool *MessageListenerPool, connAddress, co
 			return
 		}
 		time.Sleep(5 * time.Second)
-		go this_.connNodeListener(pool, connAddress, connToken, connIndex)
+		go this_.connNodeListener(pool, connAddress, connToken, connTLS, connIndex)
 	}()
 	var err error
 	var conn net.Conn
 	Logger.Info("连接 [" + connAddress + "] 开始")
-	conn, err = net.Dial("tcp", GetAddress(connAddress))
+	conn, err = dialNode(GetAddress(connAddress), connTLS)
 	if err != nil {
 		Logger.Warn("连接 ["+connAddress+"] 异常", zap.Any("error", err.Error()))
 		return
 	}
 
-	var tokenBytes = []byte(connToken)
-	if len(tokenBytes) > tokenByteSize {
-		tokenBytes = tokenBytes[:tokenByteSize]
-	} else if len(tokenBytes) < tokenByteSize {
-		for i := len(tokenBytes); i < tokenByteSize; i++ {
-			tokenBytes = append(tokenBytes, []byte(" ")...)
-		}
-	}
-
-	_, err = conn.Write(tokenBytes)
-	if err != nil {
-		_ = conn.Close()
-		return
-	}
-
-	var msg = &Message{
-		Method: methodOK,
-		ConnData: &ConnData{
-			ConnIndex:  connIndex,
-			NodeIdList: this_.server.GetLocalNodeIdList(),
-		},
-	}
-
-	err = WriteMessage(conn, msg, this_.MonitorData)
-	if err != nil {
-		Logger.Warn("连接 [" + connAddress + "] 异常")
-		_ = conn.Close()
-		return
-	}
-	msg, err = ReadMessage(conn, this_.MonitorData)
+	var serverConnData *ConnData
+	serverConnData, err = clientHandshake(conn, connToken, &ConnData{
+		ConnIndex:  connIndex,
+		NodeIdList: this_.server.GetLocalNodeIdList(),
+	}, this_.MonitorData)
 	if err != nil {
-		Logger.Warn("连接 [" + connAddress + "] 接口异常")
-		_ = conn.Close()
-		return
-	}
-	if msg.ConnData == nil || msg.ConnData.NodeId == "" {
-		Logger.Warn("连接 [" + connAddress + "] 接口异常")
+		Logger.Warn("连接 ["+connAddress+"] 握手异常", zap.Any("error", err.Error()))
 		_ = conn.Close()
 		return
 	}
-	toNodeId := msg.ConnData.NodeId
+	toNodeId := serverConnData.NodeId
 	pool = this_.getToNodeListenerPoolIfAbsentCreate(toNodeId)
 	Logger.Info("连接 [" + toNodeId + "] [" + connAddress + "] 成功")
 
 	messageListener = &MessageListener{
 		conn:      conn,
+		version:   negotiateVersion(serverConnData.Version),
+		compress:  GetCompressor(serverConnData.Compress),
 		onMessage: this_.onMessage,
 	}
 
 	messageListener.listen(func() {
 		messageListener.stop()
 		pool.Remove(messageListener)
+		this_.onNeighbourChange()
 		Logger.Info("移除 连接至 [" + toNodeId + "] [" + connAddress + "] 节点的连接 现有连接 " + fmt.Sprint(len(pool.listeners)))
 
 		if !pool.isStop {
 			time.Sleep(5 * time.Second)
-			go this_.connNodeListener(pool, connAddress, connToken, connIndex)
+			go this_.connNodeListener(pool, connAddress, connToken, connTLS, connIndex)
 		}
 	}, this_.MonitorData)
 	size := pool.Put(messageListener)
 	Logger.Info("连接 [" + toNodeId + "] [" + connAddress + "] 成功 现有连接 " + fmt.Sprint(size))
+	this_.onNeighbourChange()
 
 	return
 }
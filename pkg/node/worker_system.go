@@ -103,3 +103,46 @@ func (this_ *Worker) systemMonitorData(lineNodeIdList []string) (response *Syste
 	return
 
 }
+
+func (this_ *Worker) systemProcessList(lineNodeIdList []string, request *system.ProcessQueryRequest) (response *system.ProcessQueryResponse, err error) {
+	var resMsg *Message
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		resMsg, e = this_.Call(listener, methodSystemProcessList, &Message{
+			LineNodeIdList: lineNodeIdList,
+			SystemData: &SystemData{
+				ProcessRequest: request,
+			},
+		})
+		return
+	})
+	if err != nil {
+		return
+	}
+	if send {
+		if resMsg != nil && resMsg.SystemData != nil {
+			response = resMsg.SystemData.ProcessResponse
+		}
+		return
+	}
+
+	response, err = system.QueryProcess(request)
+	return
+}
+
+func (this_ *Worker) systemProcessSignal(lineNodeIdList []string, request *system.ProcessSignalRequest) (err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		_, e = this_.Call(listener, methodSystemProcessSignal, &Message{
+			LineNodeIdList: lineNodeIdList,
+			SystemData: &SystemData{
+				ProcessSignal: request,
+			},
+		})
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	err = system.SignalProcess(request)
+	return
+}
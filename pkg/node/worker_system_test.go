@@ -0,0 +1,30 @@
+package node
+
+import (
+	"os"
+	"teamide/pkg/system"
+	"testing"
+)
+
+func TestSystemProcess(t *testing.T) {
+	server := testLocalServer()
+
+	response, err := server.SystemProcessList([]string{"root"}, &system.ProcessQueryRequest{SortBy: system.ProcessSortByPid, Size: 100000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, one := range response.ProcessList {
+		if int(one.Pid) == os.Getpid() {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("current process not found")
+	}
+
+	err = server.SystemProcessSignal([]string{"root"}, &system.ProcessSignalRequest{Pid: int32(os.Getpid()), Signal: "KILL"})
+	if err == nil {
+		t.Fatal("signal self should be error")
+	}
+}
@@ -52,7 +52,7 @@ func (this_ *Worker) workTerminalStart(lineNodeIdList []string, size *terminal.S
 			service.Stop()
 			Logger.Info("local service stopped")
 		}()
-		err = this_.workSend(line, readKey, service.Read)
+		err = this_.background().workSend(line, readKey, PriorityHigh, service.Read)
 		if err != nil {
 			Logger.Error("terminal read send error", zap.Error(err))
 		}
@@ -150,6 +150,7 @@ func (this_ *Worker) workTerminalWrite(lineNodeIdList []string, key string, buf
 	send, err := this_.sendToNext(lineNodeIdList, key, func(listener *MessageListener) (e error) {
 		_, e = this_.Call(listener, methodTerminalWrite, &Message{
 			LineNodeIdList: lineNodeIdList,
+			Priority:       PriorityHigh,
 			Bytes:          buf,
 			HasBytes:       true,
 			TerminalWorkData: &TerminalWorkData{
@@ -0,0 +1,368 @@
+package node
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"teamide/pkg/base"
+	"time"
+)
+
+// 节点升级
+//
+// 新版本程序通过文件断点续传写入目标节点（程序路径 + UpgradeSuffix），目标节点校验 SHA256 和签名、
+// 执行 -version 检查后，备份当前程序（程序路径 + UpgradeBackupSuffix）并替换，然后重启。
+// 重启后的进程通过环境变量得知升级标识，在 UpgradeConfirmTimeout 内未收到确认时恢复备份并重启，
+// 发起升级的节点在重新连接后确认升级。
+// 新程序在启动等待确认之前崩溃时无法自动回滚，需要守护进程或手动将备份恢复为程序路径。
+
+var (
+	// UpgradeSuffix 上传中的新版本程序后缀
+	UpgradeSuffix = ".upgrade"
+	// UpgradeBackupSuffix 升级前程序的备份后缀，用于回滚
+	UpgradeBackupSuffix = ".backup"
+	// UpgradeConfirmTimeout 升级后等待确认的时间，超时未确认时回滚
+	UpgradeConfirmTimeout = 5 * time.Minute
+	// UpgradeRestartDelay 响应升级请求后延迟重启，确保响应发送完成
+	UpgradeRestartDelay = time.Second
+	// UpgradePublicKey 升级程序签名校验公钥（ed25519，base64），升级必须带签名
+	UpgradePublicKey = ""
+	// UpgradeInsecure 未配置 UpgradePublicKey 时允许不校验签名升级
+	UpgradeInsecure = false
+
+	upgradeIdEnv     = "TEAMIDE_NODE_UPGRADE_ID"
+	upgradeBackupEnv = "TEAMIDE_NODE_UPGRADE_BACKUP"
+
+	UpgradeSignatureError = errors.New("升级程序签名校验失败")
+	UpgradePublicKeyError = errors.New("节点未配置升级签名公钥，不允许升级")
+	UpgradeRunningError   = errors.New("节点正在升级或升级未确认")
+	UpgradeNotFoundError  = errors.New("节点没有待确认的升级")
+
+	// getUpgradeExecutable 当前程序路径
+	getUpgradeExecutable = func() (path string, err error) {
+		path, err = os.Executable()
+		if err != nil {
+			return
+		}
+		path, err = filepath.EvalSymlinks(path)
+		return
+	}
+	// restartUpgradeExecutable 使用新的环境变量重启当前程序
+	restartUpgradeExecutable = restartExecutable
+)
+
+// UpgradeRequest 升级请求
+type UpgradeRequest struct {
+	Id        string `json:"id,omitempty"`        // 升级标识，重启后的进程通过 UpgradeInfo.UpgradeId 返回
+	Version   string `json:"version,omitempty"`   // 新版本号，不为空时校验程序 -version 输出
+	Sha256    string `json:"sha256,omitempty"`    // 程序 SHA256（十六进制）
+	Signature string `json:"signature,omitempty"` // 程序 SHA256 摘要的 ed25519 签名（base64）
+}
+
+// UpgradeInfo 节点程序信息
+type UpgradeInfo struct {
+	Version    string `json:"version,omitempty"`
+	Os         string `json:"os,omitempty"`
+	Arch       string `json:"arch,omitempty"`
+	Executable string `json:"executable,omitempty"`
+	UpgradeId  string `json:"upgradeId,omitempty"` // 本进程由该升级启动
+	Pending    bool   `json:"pending,omitempty"`   // 升级待确认，超时未确认时回滚
+}
+
+func getSha256(path string) (sha256Str string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return
+	}
+	sha256Str = hex.EncodeToString(hash.Sum(nil))
+	return
+}
+
+// verifyUpgradeSignature 校验签名，未配置公钥时只有开启 UpgradeInsecure 才允许升级
+func verifyUpgradeSignature(sha256Str string, signature string) (err error) {
+	if UpgradePublicKey == "" {
+		if !UpgradeInsecure {
+			err = UpgradePublicKeyError
+		}
+		return
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(UpgradePublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		err = errors.New("升级签名公钥格式错误")
+		return
+	}
+	digest, err := hex.DecodeString(sha256Str)
+	if err != nil {
+		return
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(publicKey, digest, sig) {
+		err = UpgradeSignatureError
+		return
+	}
+	return
+}
+
+// checkUpgradeVersion 执行新程序 -version，确认可以在该节点上运行
+func checkUpgradeVersion(path string, version string) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "-version").CombinedOutput()
+	if err != nil {
+		err = errors.New("升级程序无法执行:" + err.Error())
+		return
+	}
+	if version != "" && strings.TrimSpace(string(out)) != version {
+		err = fmt.Errorf("升级程序版本[%s]与[%s]不一致", strings.TrimSpace(string(out)), version)
+		return
+	}
+	return
+}
+
+func copyUpgradeFile(from string, to string) (err error) {
+	src, err := os.Open(from)
+	if err != nil {
+		return
+	}
+	defer func() { _ = src.Close() }()
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(dst, src)
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	return
+}
+
+func getUpgradeEnv(id string, backup string) (env []string) {
+	for _, one := range os.Environ() {
+		if strings.HasPrefix(one, upgradeIdEnv+"=") || strings.HasPrefix(one, upgradeBackupEnv+"=") {
+			continue
+		}
+		env = append(env, one)
+	}
+	if id != "" {
+		env = append(env, upgradeIdEnv+"="+id, upgradeBackupEnv+"="+backup)
+	}
+	return
+}
+
+// upgradeWatch 由升级启动的进程，等待确认，超时未确认时回滚
+func (this_ *Worker) upgradeWatch() {
+	id := os.Getenv(upgradeIdEnv)
+	backup := os.Getenv(upgradeBackupEnv)
+	if id == "" || backup == "" {
+		return
+	}
+	Logger.Info("upgrade wait confirm", zap.Any("upgradeId", id), zap.Any("timeout", UpgradeConfirmTimeout))
+
+	this_.upgradeLock.Lock()
+	defer this_.upgradeLock.Unlock()
+	this_.upgradeId = id
+	this_.upgradeBackup = backup
+	this_.upgradeTimer = time.AfterFunc(UpgradeConfirmTimeout, func() {
+		Logger.Warn("upgrade confirm timeout, rollback", zap.Any("upgradeId", id))
+		if e := this_.doUpgradeRollback(); e != nil {
+			Logger.Error("upgrade rollback error", zap.Error(e))
+		}
+	})
+}
+
+func (this_ *Worker) getUpgradeInfo(lineNodeIdList []string) (info *UpgradeInfo, err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		res, e := this_.Call(listener, methodNodeUpgradeInfo, &Message{
+			LineNodeIdList: lineNodeIdList,
+			NodeWorkData:   &WorkData{},
+		})
+		if e != nil {
+			return
+		}
+		if res == nil || res.NodeWorkData == nil || res.NodeWorkData.UpgradeInfo == nil {
+			e = errors.New("节点不支持升级，请手动升级节点")
+			return
+		}
+		info = res.NodeWorkData.UpgradeInfo
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	executable, err := getUpgradeExecutable()
+	if err != nil {
+		return
+	}
+	this_.upgradeLock.Lock()
+	defer this_.upgradeLock.Unlock()
+	info = &UpgradeInfo{
+		Version:    base.GetVersion(),
+		Os:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		Executable: executable,
+		UpgradeId:  this_.upgradeId,
+		Pending:    this_.upgradeTimer != nil,
+	}
+	return
+}
+
+// workUpgrade 校验已上传的新版本程序，替换后重启
+func (this_ *Worker) workUpgrade(lineNodeIdList []string, request *UpgradeRequest) (err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		_, e = this_.Call(listener, methodNodeUpgrade, &Message{
+			LineNodeIdList: lineNodeIdList,
+			NodeWorkData: &WorkData{
+				UpgradeRequest: request,
+			},
+		})
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	this_.upgradeLock.Lock()
+	defer this_.upgradeLock.Unlock()
+	if this_.upgrading || this_.upgradeTimer != nil {
+		err = UpgradeRunningError
+		return
+	}
+
+	executable, err := getUpgradeExecutable()
+	if err != nil {
+		return
+	}
+	upgradePath := executable + UpgradeSuffix
+	backupPath := executable + UpgradeBackupSuffix
+
+	sha256Str, err := getSha256(upgradePath)
+	if err != nil {
+		return
+	}
+	if !strings.EqualFold(sha256Str, request.Sha256) {
+		_ = os.Remove(upgradePath)
+		err = FileChecksumError
+		return
+	}
+	if err = verifyUpgradeSignature(sha256Str, request.Signature); err != nil {
+		_ = os.Remove(upgradePath)
+		return
+	}
+	if err = os.Chmod(upgradePath, 0755); err != nil {
+		return
+	}
+	if err = checkUpgradeVersion(upgradePath, request.Version); err != nil {
+		return
+	}
+	if err = swapExecutable(executable, upgradePath, backupPath); err != nil {
+		return
+	}
+	Logger.Info("upgrade swap success", zap.Any("upgradeId", request.Id), zap.Any("version", request.Version))
+
+	this_.upgrading = true
+	env := getUpgradeEnv(request.Id, backupPath)
+	go func() {
+		time.Sleep(UpgradeRestartDelay)
+		this_.restartUpgrade(executable, env)
+	}()
+	return
+}
+
+func (this_ *Worker) restartUpgrade(executable string, env []string) {
+	if this_.server != nil {
+		this_.server.Stop()
+	}
+	if e := restartUpgradeExecutable(executable, env); e != nil {
+		Logger.Error("upgrade restart error", zap.Error(e))
+	}
+}
+
+// workUpgradeConfirm 确认升级，不再回滚
+func (this_ *Worker) workUpgradeConfirm(lineNodeIdList []string) (err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		_, e = this_.Call(listener, methodNodeUpgradeConfirm, &Message{
+			LineNodeIdList: lineNodeIdList,
+		})
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	this_.upgradeLock.Lock()
+	defer this_.upgradeLock.Unlock()
+	if this_.upgradeTimer == nil {
+		err = UpgradeNotFoundError
+		return
+	}
+	this_.upgradeTimer.Stop()
+	this_.upgradeTimer = nil
+	// 之后的重启不再视为升级
+	_ = os.Unsetenv(upgradeIdEnv)
+	_ = os.Unsetenv(upgradeBackupEnv)
+	Logger.Info("upgrade confirm success", zap.Any("upgradeId", this_.upgradeId))
+	return
+}
+
+// workUpgradeRollback 恢复升级前的程序并重启
+func (this_ *Worker) workUpgradeRollback(lineNodeIdList []string) (err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		_, e = this_.Call(listener, methodNodeUpgradeRollback, &Message{
+			LineNodeIdList: lineNodeIdList,
+		})
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	return this_.doUpgradeRollback()
+}
+
+func (this_ *Worker) doUpgradeRollback() (err error) {
+	this_.upgradeLock.Lock()
+	defer this_.upgradeLock.Unlock()
+	if this_.upgradeTimer == nil || this_.upgrading {
+		err = UpgradeNotFoundError
+		return
+	}
+
+	executable, err := getUpgradeExecutable()
+	if err != nil {
+		return
+	}
+	// 新版本程序移至 UpgradeSuffix，便于排查
+	if err = swapExecutable(executable, this_.upgradeBackup, executable+UpgradeSuffix); err != nil {
+		return
+	}
+	this_.upgradeTimer.Stop()
+	this_.upgradeTimer = nil
+	this_.upgrading = true
+	Logger.Info("upgrade rollback success", zap.Any("upgradeId", this_.upgradeId))
+
+	env := getUpgradeEnv("", "")
+	go func() {
+		time.Sleep(UpgradeRestartDelay)
+		this_.restartUpgrade(executable, env)
+	}()
+	return
+}
@@ -0,0 +1,150 @@
+package node
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testUpgradeExecutable(t *testing.T) (executable string, restartChan chan []string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("upgrade test uses sh script")
+	}
+	executable = filepath.Join(t.TempDir(), "node")
+	if err := os.WriteFile(executable, []byte("#!/bin/sh\necho v1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	restartChan = make(chan []string, 1)
+
+	oldGetExecutable, oldRestart, oldDelay, oldPublicKey, oldInsecure := getUpgradeExecutable, restartUpgradeExecutable, UpgradeRestartDelay, UpgradePublicKey, UpgradeInsecure
+	getUpgradeExecutable = func() (string, error) {
+		return executable, nil
+	}
+	restartUpgradeExecutable = func(path string, env []string) (err error) {
+		restartChan <- env
+		return
+	}
+	UpgradeRestartDelay = 0
+	t.Cleanup(func() {
+		getUpgradeExecutable, restartUpgradeExecutable, UpgradeRestartDelay, UpgradePublicKey, UpgradeInsecure = oldGetExecutable, oldRestart, oldDelay, oldPublicKey, oldInsecure
+	})
+	return
+}
+
+func TestUpgrade(t *testing.T) {
+	executable, restartChan := testUpgradeExecutable(t)
+	server := testLocalServer()
+	var callStop bool
+	onDo := func(readSize int64, writeSize int64) {}
+
+	content := []byte("#!/bin/sh\necho v2\n")
+	sum := sha256.Sum256(content)
+	request := &UpgradeRequest{
+		Id:      "u1",
+		Version: "v2",
+		Sha256:  hex.EncodeToString(sum[:]),
+	}
+
+	// 未配置公钥时不允许升级
+	UpgradePublicKey = ""
+	err := server.Upgrade([]string{"root"}, request, bytes.NewReader(content), onDo, &callStop)
+	if !errors.Is(err, UpgradePublicKeyError) {
+		t.Fatal("upgrade without public key should fail:", err)
+	}
+	UpgradeInsecure = true
+	if err = verifyUpgradeSignature(request.Sha256, ""); err != nil {
+		t.Fatal("insecure upgrade should skip signature:", err)
+	}
+	UpgradeInsecure = false
+
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	UpgradePublicKey = base64.StdEncoding.EncodeToString(publicKey)
+	err = server.Upgrade([]string{"root"}, request, bytes.NewReader(content), onDo, &callStop)
+	if !errors.Is(err, UpgradeSignatureError) {
+		t.Fatal("signature should fail:", err)
+	}
+
+	request.Version = "v3"
+	request.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, sum[:]))
+	err = server.Upgrade([]string{"root"}, request, bytes.NewReader(content), onDo, &callStop)
+	if err == nil || !strings.Contains(err.Error(), "v3") {
+		t.Fatal("version should fail:", err)
+	}
+
+	request.Version = "v2"
+	err = server.Upgrade([]string{"root"}, request, bytes.NewReader(content), onDo, &callStop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env []string
+	select {
+	case env = <-restartChan:
+	case <-time.After(5 * time.Second):
+		t.Fatal("restart timeout")
+	}
+	bs, _ := os.ReadFile(executable)
+	if !bytes.Equal(bs, content) {
+		t.Fatal("executable not replaced")
+	}
+	bs, _ = os.ReadFile(executable + UpgradeBackupSuffix)
+	if !strings.Contains(string(bs), "v1") {
+		t.Fatal("backup error")
+	}
+	if !strings.Contains(strings.Join(env, "\n"), upgradeIdEnv+"=u1") {
+		t.Fatal("restart env error")
+	}
+}
+
+func TestUpgradeRollback(t *testing.T) {
+	executable, restartChan := testUpgradeExecutable(t)
+	if err := os.WriteFile(executable+UpgradeBackupSuffix, []byte("#!/bin/sh\necho v0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(upgradeIdEnv, "u1")
+	t.Setenv(upgradeBackupEnv, executable+UpgradeBackupSuffix)
+	oldTimeout := UpgradeConfirmTimeout
+	UpgradeConfirmTimeout = 100 * time.Millisecond
+	defer func() { UpgradeConfirmTimeout = oldTimeout }()
+
+	// 未确认时超时回滚
+	server := testLocalServer()
+	server.upgradeWatch()
+	info, err := server.GetUpgradeInfo([]string{"root"})
+	if err != nil || info.UpgradeId != "u1" || !info.Pending {
+		t.Fatalf("upgrade info error: %+v %v", info, err)
+	}
+	select {
+	case <-restartChan:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rollback timeout")
+	}
+	bs, _ := os.ReadFile(executable)
+	if !strings.Contains(string(bs), "v0") {
+		t.Fatal("executable not rollback")
+	}
+
+	// 确认后不再回滚
+	t.Setenv(upgradeIdEnv, "u2")
+	t.Setenv(upgradeBackupEnv, executable+UpgradeBackupSuffix)
+	server = testLocalServer()
+	server.upgradeWatch()
+	if err = server.UpgradeConfirm([]string{"root"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = server.UpgradeRollback([]string{"root"}); !errors.Is(err, UpgradeNotFoundError) {
+		t.Fatal("rollback should fail after confirm:", err)
+	}
+	if os.Getenv(upgradeIdEnv) != "" {
+		t.Fatal("upgrade env should be removed")
+	}
+}
@@ -1,12 +1,14 @@
 package node
 
-func (this_ *Worker) netProxyNewConn(lineNodeIdList []string, netProxyId string, connId string) (err error) {
+// netProxyNewConn 输出端新建连接，address 为动态代理的目标地址
+func (this_ *Worker) netProxyNewConn(lineNodeIdList []string, netProxyId string, connId string, address string) (err error) {
 	send, err := this_.sendToNext(lineNodeIdList, connId, func(listener *MessageListener) (e error) {
 		_, e = this_.Call(listener, methodNetProxyNewConn, &Message{
 			LineNodeIdList: lineNodeIdList,
 			NetProxyWorkData: &NetProxyWorkData{
 				NetProxyId: netProxyId,
 				ConnId:     connId,
+				Address:    address,
 			},
 		})
 		return
@@ -19,7 +21,7 @@ func (this_ *Worker) netProxyNewConn(lineNodeIdList []string, netProxyId string,
 	}
 	outer := this_.getNetProxyOuter(netProxyId)
 	if outer != nil {
-		err = outer.newConn(connId)
+		err = outer.newConn(this_.getContext(), connId, address)
 	}
 	if err != nil {
 		return
@@ -29,7 +31,7 @@ func (this_ *Worker) netProxyNewConn(lineNodeIdList []string, netProxyId string,
 
 func (this_ *Worker) netProxyCloseConn(isReverse bool, lineNodeIdList []string, netProxyId string, connId string) (err error) {
 	send, err := this_.sendToNext(lineNodeIdList, connId, func(listener *MessageListener) (e error) {
-		_, e = this_.Call(listener, methodNetProxyCloseConn, &Message{
+		e = this_.CallStreamClose(listener, methodNetProxyCloseConn, &Message{
 			LineNodeIdList: lineNodeIdList,
 			NetProxyWorkData: &NetProxyWorkData{
 				NetProxyId: netProxyId,
@@ -64,7 +66,7 @@ func (this_ *Worker) netProxyCloseConn(isReverse bool, lineNodeIdList []string,
 
 func (this_ *Worker) netProxySend(isReverse bool, lineNodeIdList []string, netProxyId string, connId string, bytes []byte) (err error) {
 	send, err := this_.sendToNext(lineNodeIdList, connId, func(listener *MessageListener) (e error) {
-		_, e = this_.Call(listener, methodNetProxySend, &Message{
+		e = this_.Stream(listener, methodNetProxySend, &Message{
 			LineNodeIdList: lineNodeIdList,
 			HasBytes:       true,
 			Bytes:          bytes,
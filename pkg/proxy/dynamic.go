@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 动态代理 请求解析，节点动态代理和 SSH 动态转发共用
+//
+// 仅支持 SOCKS5（无认证，仅 CONNECT）和 HTTP CONNECT 请求，目标地址是否允许由使用方校验。
+
+var (
+	NotAllowedError = errors.New("目标地址不在代理允许列表中")
+	RequestError    = errors.New("代理请求异常")
+
+	// RequestTimeout 读取请求超时时间
+	RequestTimeout = 10 * time.Second
+)
+
+const (
+	socks5Version = 0x05
+
+	socks5MethodNoAuth       = 0x00
+	socks5MethodNoAcceptable = 0xFF
+
+	socks5CmdConnect = 0x01
+
+	socks5AddrIPv4   = 0x01
+	socks5AddrDomain = 0x03
+	socks5AddrIPv6   = 0x04
+
+	socks5ReplySuccess         = 0x00
+	socks5ReplyFailure         = 0x01
+	socks5ReplyNotAllowed      = 0x02
+	socks5ReplyCmdNotSupported = 0x07
+	socks5ReplyAddrNotSupport  = 0x08
+)
+
+// DynamicRequest 动态代理 客户端请求
+type DynamicRequest struct {
+	Address string
+	Reader  io.Reader // 读取请求后的数据，包含已缓冲的数据
+	isHttp  bool
+}
+
+// ReadDynamicRequest 读取 SOCKS5 或 HTTP CONNECT 请求，按首字节区分
+func ReadDynamicRequest(conn net.Conn) (request *DynamicRequest, err error) {
+	_ = conn.SetDeadline(time.Now().Add(RequestTimeout))
+	defer func() {
+		_ = conn.SetDeadline(time.Time{})
+	}()
+
+	reader := bufio.NewReader(conn)
+	first, err := reader.Peek(1)
+	if err != nil {
+		return
+	}
+	request = &DynamicRequest{
+		Reader: reader,
+	}
+	if first[0] == socks5Version {
+		request.Address, err = readSocks5Request(conn, reader)
+	} else {
+		request.isHttp = true
+		request.Address, err = readHttpConnectRequest(conn, reader)
+	}
+	return
+}
+
+func readSocks5Request(conn net.Conn, reader *bufio.Reader) (address string, err error) {
+	var header = make([]byte, 2)
+	if _, err = io.ReadFull(reader, header); err != nil {
+		return
+	}
+	var methods = make([]byte, header[1])
+	if _, err = io.ReadFull(reader, methods); err != nil {
+		return
+	}
+	var method byte = socks5MethodNoAcceptable
+	for _, one := range methods {
+		if one == socks5MethodNoAuth {
+			method = socks5MethodNoAuth
+			break
+		}
+	}
+	if _, err = conn.Write([]byte{socks5Version, method}); err != nil {
+		return
+	}
+	if method == socks5MethodNoAcceptable {
+		err = errors.New(RequestError.Error() + "，SOCKS5 客户端不支持无认证方式")
+		return
+	}
+
+	header = make([]byte, 4)
+	if _, err = io.ReadFull(reader, header); err != nil {
+		return
+	}
+	if header[0] != socks5Version {
+		err = errors.New(RequestError.Error() + "，SOCKS5 版本错误")
+		return
+	}
+	if header[1] != socks5CmdConnect {
+		_ = writeSocks5Reply(conn, socks5ReplyCmdNotSupported)
+		err = errors.New(RequestError.Error() + fmt.Sprintf("，SOCKS5 不支持的命令[%d]", header[1]))
+		return
+	}
+	var host string
+	switch header[3] {
+	case socks5AddrIPv4, socks5AddrIPv6:
+		var ip = make([]byte, net.IPv4len)
+		if header[3] == socks5AddrIPv6 {
+			ip = make([]byte, net.IPv6len)
+		}
+		if _, err = io.ReadFull(reader, ip); err != nil {
+			return
+		}
+		host = net.IP(ip).String()
+	case socks5AddrDomain:
+		var size byte
+		if size, err = reader.ReadByte(); err != nil {
+			return
+		}
+		var domain = make([]byte, size)
+		if _, err = io.ReadFull(reader, domain); err != nil {
+			return
+		}
+		host = string(domain)
+	default:
+		_ = writeSocks5Reply(conn, socks5ReplyAddrNotSupport)
+		err = errors.New(RequestError.Error() + fmt.Sprintf("，SOCKS5 不支持的地址类型[%d]", header[3]))
+		return
+	}
+	var port = make([]byte, 2)
+	if _, err = io.ReadFull(reader, port); err != nil {
+		return
+	}
+	address = net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	return
+}
+
+func writeSocks5Reply(conn net.Conn, reply byte) (err error) {
+	_, err = conn.Write([]byte{socks5Version, reply, 0x00, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+	return
+}
+
+func readHttpConnectRequest(conn net.Conn, reader *bufio.Reader) (address string, err error) {
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	if req.Method != http.MethodConnect {
+		_, _ = conn.Write([]byte("HTTP/1.1 405 Method Not Allowed\r\nConnection: close\r\n\r\n"))
+		err = errors.New(RequestError.Error() + "，HTTP 代理仅支持 CONNECT 请求")
+		return
+	}
+	address = req.Host
+	if _, _, e := net.SplitHostPort(address); e != nil {
+		address = net.JoinHostPort(address, "443")
+	}
+	return
+}
+
+// Reply 回复客户端连接结果
+func (this_ *DynamicRequest) Reply(conn net.Conn, connErr error) (err error) {
+	if this_.isHttp {
+		var status = "200 Connection Established"
+		if connErr != nil {
+			status = "502 Bad Gateway"
+			if errors.Is(connErr, NotAllowedError) {
+				status = "403 Forbidden"
+			}
+		}
+		_, err = conn.Write([]byte("HTTP/1.1 " + status + "\r\n\r\n"))
+		return
+	}
+	var reply byte = socks5ReplySuccess
+	if connErr != nil {
+		reply = socks5ReplyFailure
+		if errors.Is(connErr, NotAllowedError) {
+			reply = socks5ReplyNotAllowed
+		}
+	}
+	err = writeSocks5Reply(conn, reply)
+	return
+}
@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+func TestDynamicRequest(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer func() {
+		_ = clientConn.Close()
+		_ = serverConn.Close()
+	}()
+
+	go func() {
+		_, _ = clientConn.Write([]byte{socks5Version, 1, socks5MethodNoAuth})
+		_, _ = io.ReadFull(clientConn, make([]byte, 2))
+		_, _ = clientConn.Write([]byte{socks5Version, socks5CmdConnect, 0, socks5AddrDomain, 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x01, 0xBB})
+		_, _ = clientConn.Write([]byte("data"))
+	}()
+	request, err := ReadDynamicRequest(serverConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.isHttp || request.Address != "example:443" {
+		t.Fatal("socks5 request error:", request.Address)
+	}
+	var data = make([]byte, 4)
+	if _, err = io.ReadFull(request.Reader, data); err != nil || string(data) != "data" {
+		t.Fatal("read data after request error:", err, string(data))
+	}
+}
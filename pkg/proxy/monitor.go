@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"github.com/team-ide/go-tool/util"
+	"sync"
+)
+
+// MonitorData 代理读写统计，节点连接、网络代理、SSH 端口转发共用
+type MonitorData struct {
+	ReadSize           int64 `json:"readSize,omitempty"`
+	ReadTime           int64 `json:"readTime,omitempty"`
+	ReadLastSize       int64 `json:"readLastSize,omitempty"`
+	ReadLastTime       int64 `json:"readLastTime,omitempty"`
+	ReadLastTimestamp  int64 `json:"readLastTimestamp,omitempty"`
+	readLock           sync.Mutex
+	WriteSize          int64 `json:"writeSize,omitempty"`
+	WriteTime          int64 `json:"writeTime,omitempty"`
+	WriteLastSize      int64 `json:"writeLastSize,omitempty"`
+	WriteLastTime      int64 `json:"writeLastTime,omitempty"`
+	WriteLastTimestamp int64 `json:"writeLastTimestamp,omitempty"`
+	writeLock          sync.Mutex
+	ReadRawSize        int64 `json:"readRawSize,omitempty"`  // 读取的原始大小（解压后），与 ReadSize 对比为压缩效果
+	WriteRawSize       int64 `json:"writeRawSize,omitempty"` // 写入的原始大小（压缩前），与 WriteSize 对比为压缩效果
+}
+
+// MonitorRead 记录读取的大小和耗时（纳秒）
+func (this_ *MonitorData) MonitorRead(bytesSize int64, useTime int64) {
+	this_.readLock.Lock()
+	defer this_.readLock.Unlock()
+
+	var nowTime = util.GetNowMilli()
+	if this_.ReadLastTimestamp == 0 {
+		this_.ReadLastTimestamp = nowTime
+	}
+	if (nowTime - this_.ReadLastTimestamp) <= 1000 {
+		this_.ReadLastSize += bytesSize
+		this_.ReadLastTime += useTime
+	} else {
+		this_.ReadLastTimestamp = nowTime
+		this_.ReadLastSize = bytesSize
+		this_.ReadLastTime = useTime
+	}
+
+	this_.ReadSize += bytesSize
+	this_.ReadTime += useTime
+}
+
+// MonitorWrite 记录写入的大小和耗时（纳秒）
+func (this_ *MonitorData) MonitorWrite(bytesSize int64, useTime int64) {
+	this_.writeLock.Lock()
+	defer this_.writeLock.Unlock()
+
+	var nowTime = util.GetNowMilli()
+	if this_.WriteLastTimestamp == 0 {
+		this_.WriteLastTimestamp = nowTime
+	}
+	if (nowTime - this_.WriteLastTimestamp) <= 1000 {
+		this_.WriteLastSize += bytesSize
+		this_.WriteLastTime += useTime
+	} else {
+		this_.WriteLastTimestamp = nowTime
+		this_.WriteLastSize = bytesSize
+		this_.WriteLastTime = useTime
+	}
+
+	this_.WriteSize += bytesSize
+	this_.WriteTime += useTime
+}
+
+// Copy 复制当前的统计数据，统计过程中读取时使用
+func (this_ *MonitorData) Copy() (res *MonitorData) {
+	res = &MonitorData{}
+	this_.readLock.Lock()
+	res.ReadSize = this_.ReadSize
+	res.ReadTime = this_.ReadTime
+	res.ReadLastSize = this_.ReadLastSize
+	res.ReadLastTime = this_.ReadLastTime
+	res.ReadLastTimestamp = this_.ReadLastTimestamp
+	res.ReadRawSize = this_.ReadRawSize
+	this_.readLock.Unlock()
+
+	this_.writeLock.Lock()
+	res.WriteSize = this_.WriteSize
+	res.WriteTime = this_.WriteTime
+	res.WriteLastSize = this_.WriteLastSize
+	res.WriteLastTime = this_.WriteLastTime
+	res.WriteLastTimestamp = this_.WriteLastTimestamp
+	res.WriteRawSize = this_.WriteRawSize
+	this_.writeLock.Unlock()
+	return
+}
+
+// MonitorReadRaw 记录读取的原始大小（解压后）
+func (this_ *MonitorData) MonitorReadRaw(bytesSize int64) {
+	this_.readLock.Lock()
+	defer this_.readLock.Unlock()
+
+	this_.ReadRawSize += bytesSize
+}
+
+// MonitorWriteRaw 记录写入的原始大小（压缩前）
+func (this_ *MonitorData) MonitorWriteRaw(bytesSize int64) {
+	this_.writeLock.Lock()
+	defer this_.writeLock.Unlock()
+
+	this_.WriteRawSize += bytesSize
+}
@@ -0,0 +1,204 @@
+package ssh
+
+import (
+	"errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"io"
+	"net"
+	"os"
+	"unicode/utf8"
+)
+
+// SSH 认证
+//
+// 按顺序提供：用户证书、私钥、SSH Agent 中的密钥、密码、键盘交互，服务端只尝试其允许的方式，
+// 要求多种方式时（如 AuthenticationMethods publickey,keyboard-interactive）依次完成。
+
+var (
+	KeyboardInteractiveCancelError = errors.New("键盘交互认证已取消")
+	AgentDisabledError             = errors.New("服务端未开启 SSH Agent")
+
+	// AgentSocket 服务端配置的 SSH Agent 地址，SSH_AUTH_SOCK 表示使用服务端环境变量，为空时不允许使用 SSH Agent；
+	// Agent 中的密钥属于服务端，只由管理员配置，工具配置中只能选择是否使用
+	AgentSocket string
+)
+
+// newAuthMethods 按配置创建认证方式，closeAuth 在连接完成后调用，关闭 SSH Agent 的连接
+func newAuthMethods(config Config) (auth []ssh.AuthMethod, closeAuth func(), err error) {
+	closeAuth = func() {}
+
+	if config.PublicKey != "" {
+		var signers []ssh.Signer
+		signers, err = loadPrivateKeySigners(config)
+		if err != nil {
+			return
+		}
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+
+	if config.Agent {
+		socket := AgentSocket
+		if socket == "SSH_AUTH_SOCK" {
+			socket = os.Getenv("SSH_AUTH_SOCK")
+		}
+		if socket == "" {
+			err = AgentDisabledError
+			return
+		}
+		var conn net.Conn
+		conn, err = net.Dial("unix", socket)
+		if err != nil {
+			err = errors.New("SSH Agent[" + socket + "]连接失败：" + err.Error())
+			return
+		}
+		closeAuth = func() { _ = conn.Close() }
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	// 配置私钥时密码为私钥的密码
+	if config.PublicKey == "" && config.Password != "" {
+		auth = append(auth, ssh.Password(config.Password))
+	}
+
+	if config.KeyboardInteractive != nil {
+		auth = append(auth, ssh.KeyboardInteractive(config.KeyboardInteractive))
+	} else if config.PublicKey == "" && config.Password != "" {
+		auth = append(auth, ssh.KeyboardInteractive(passwordKeyboardInteractive(config.Password)))
+	}
+	return
+}
+
+// loadPrivateKeySigners 读取私钥，配置了用户证书时证书在前
+func loadPrivateKeySigners(config Config) (signers []ssh.Signer, err error) {
+	publicKeyBytes, err := os.ReadFile(config.PublicKey)
+	if err != nil {
+		return
+	}
+	var signer ssh.Signer
+	if config.Password != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(publicKeyBytes, []byte(config.Password))
+	} else {
+		signer, err = ssh.ParsePrivateKey(publicKeyBytes)
+	}
+	if err != nil {
+		return
+	}
+	if config.Certificate != "" {
+		var certSigner ssh.Signer
+		certSigner, err = loadCertSigner(config.Certificate, signer)
+		if err != nil {
+			return
+		}
+		signers = append(signers, certSigner)
+	}
+	signers = append(signers, signer)
+	return
+}
+
+// loadCertSigner 读取 OpenSSH 用户证书（如 id_ed25519-cert.pub），与私钥组合
+func loadCertSigner(certificate string, signer ssh.Signer) (certSigner ssh.Signer, err error) {
+	bs, err := os.ReadFile(certificate)
+	if err != nil {
+		return
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(bs)
+	if err != nil {
+		err = errors.New("用户证书[" + certificate + "]解析失败：" + err.Error())
+		return
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok || cert.CertType != ssh.UserCert {
+		err = errors.New("文件[" + certificate + "]不是用户证书")
+		return
+	}
+	certSigner, err = ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		err = errors.New("用户证书[" + certificate + "]与私钥不匹配：" + err.Error())
+		return
+	}
+	return
+}
+
+// passwordKeyboardInteractive 没有交互终端时，不回显的问题使用密码回答，兼容使用 PAM 密码认证的服务器
+func passwordKeyboardInteractive(password string) ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) (answers []string, err error) {
+		for index := range questions {
+			if echos[index] {
+				err = errors.New("键盘交互认证需要在终端中输入")
+				return
+			}
+			answers = append(answers, password)
+		}
+		return
+	}
+}
+
+// NewTerminalKeyboardInteractive 在终端中回答键盘交互认证的问题，如动态验证码；
+// 问题写入 terminal，从 terminal 读取用户输入的一行作为回答，Ctrl+C 取消
+func NewTerminalKeyboardInteractive(terminal io.ReadWriter) ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) (answers []string, err error) {
+		var header string
+		if name != "" {
+			header += name + "\r\n"
+		}
+		if instruction != "" {
+			header += instruction + "\r\n"
+		}
+		if header != "" {
+			if _, err = terminal.Write([]byte(header)); err != nil {
+				return
+			}
+		}
+		for index, question := range questions {
+			if _, err = terminal.Write([]byte(question)); err != nil {
+				return
+			}
+			var answer string
+			answer, err = readTerminalLine(terminal, echos[index])
+			_, _ = terminal.Write([]byte("\r\n"))
+			if err != nil {
+				return
+			}
+			answers = append(answers, answer)
+		}
+		return
+	}
+}
+
+// readTerminalLine 读取一行输入，支持退格，echo 为 false 时不回显
+func readTerminalLine(terminal io.ReadWriter, echo bool) (line string, err error) {
+	var bs []byte
+	var buf = make([]byte, 1024)
+	for {
+		var n int
+		n, err = terminal.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, b := range buf[:n] {
+			switch b {
+			case '\r', '\n':
+				line = string(bs)
+				return
+			case 0x03:
+				err = KeyboardInteractiveCancelError
+				return
+			case 0x7f, 0x08:
+				if len(bs) == 0 {
+					continue
+				}
+				_, size := utf8.DecodeLastRune(bs)
+				bs = bs[:len(bs)-size]
+				if echo {
+					_, _ = terminal.Write([]byte("\b \b"))
+				}
+			default:
+				bs = append(bs, b)
+				if echo {
+					_, _ = terminal.Write([]byte{b})
+				}
+			}
+		}
+	}
+}
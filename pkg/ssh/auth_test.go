@@ -0,0 +1,245 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testTerminal 按顺序返回输入，记录输出
+type testTerminal struct {
+	inputs []string
+	output bytes.Buffer
+}
+
+func (this_ *testTerminal) Read(p []byte) (n int, err error) {
+	if len(this_.inputs) == 0 {
+		err = io.EOF
+		return
+	}
+	n = copy(p, this_.inputs[0])
+	this_.inputs = this_.inputs[1:]
+	return
+}
+
+func (this_ *testTerminal) Write(p []byte) (n int, err error) {
+	return this_.output.Write(p)
+}
+
+func TestTerminalKeyboardInteractive(t *testing.T) {
+	terminal := &testTerminal{inputs: []string{"12", "3x\x7f4\r", "admin\r"}}
+	challenge := NewTerminalKeyboardInteractive(terminal)
+	answers, err := challenge("", "请输入验证码", []string{"Code: ", "User: "}, []bool{false, true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(answers) != 2 || answers[0] != "1234" || answers[1] != "admin" {
+		t.Fatal("answers error:", answers)
+	}
+	if terminal.output.String() != "请输入验证码\r\nCode: \r\nUser: admin\r\n" {
+		t.Fatalf("output error: %q", terminal.output.String())
+	}
+
+	terminal = &testTerminal{inputs: []string{"12\x03"}}
+	_, err = NewTerminalKeyboardInteractive(terminal)("", "", []string{"Code: "}, []bool{false})
+	if !errors.Is(err, KeyboardInteractiveCancelError) {
+		t.Fatal("ctrl+c should cancel:", err)
+	}
+}
+
+// newTestKeyboardInteractiveCallback 服务端只允许键盘交互认证，问题的回答为 answer
+func newTestKeyboardInteractiveCallback(question string, answer string) func(ssh.ConnMetadata, ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+	return func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+		answers, err := client("", "", []string{question}, []bool{false})
+		if err != nil {
+			return nil, err
+		}
+		if len(answers) != 1 || answers[0] != answer {
+			return nil, errors.New("wrong answer")
+		}
+		return nil, nil
+	}
+}
+
+func TestKeyboardInteractiveAuth(t *testing.T) {
+	server := newTestServer(t, "otp")
+	server.SetConfig(func(config *ssh.ServerConfig) {
+		config.PasswordCallback = nil
+		config.KeyboardInteractiveCallback = newTestKeyboardInteractiveCallback("Verification code: ", "123456")
+	})
+
+	terminal := &testTerminal{inputs: []string{"123456\r"}}
+	config := server.Config()
+	config.Password = ""
+	config.KeyboardInteractive = NewTerminalKeyboardInteractive(terminal)
+	client, err := NewClient(*config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = client.Close()
+	if !strings.Contains(terminal.output.String(), "Verification code: ") {
+		t.Fatal("question should write to terminal:", terminal.output.String())
+	}
+
+	// 没有交互终端时使用密码回答
+	server.SetConfig(func(config *ssh.ServerConfig) {
+		config.KeyboardInteractiveCallback = newTestKeyboardInteractiveCallback("Password: ", server.Password)
+	})
+	client, err = NewClient(*server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = client.Close()
+}
+
+func newTestSigner(t *testing.T) (ssh.Signer, ed25519.PrivateKey) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, private
+}
+
+func TestCertificateAuth(t *testing.T) {
+	caSigner, _ := newTestSigner(t)
+	userSigner, userPrivate := newTestSigner(t)
+
+	server := newTestServer(t, "cert")
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), caSigner.PublicKey().Marshal())
+		},
+	}
+	server.SetConfig(func(config *ssh.ServerConfig) {
+		config.PasswordCallback = nil
+		config.PublicKeyCallback = checker.Authenticate
+	})
+
+	dir := t.TempDir()
+	block, err := ssh.MarshalPrivateKey(userPrivate, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "id_ed25519")
+	if err = os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := server.Config()
+	config.Password = ""
+	config.PublicKey = keyPath
+	if _, err = NewClient(*config); err == nil {
+		t.Fatal("private key without certificate should fail")
+	}
+
+	cert := &ssh.Certificate{
+		Key:             userSigner.PublicKey(),
+		CertType:        ssh.UserCert,
+		KeyId:           "test",
+		ValidPrincipals: []string{server.Username},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err = cert.SignCert(rand.Reader, caSigner); err != nil {
+		t.Fatal(err)
+	}
+	certPath := filepath.Join(dir, "id_ed25519-cert.pub")
+	if err = os.WriteFile(certPath, ssh.MarshalAuthorizedKey(cert), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config.Certificate = certPath
+	client, err := NewClient(*config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = client.Close()
+
+	// 证书与私钥不匹配
+	_, otherPrivate := newTestSigner(t)
+	block, _ = ssh.MarshalPrivateKey(otherPrivate, "")
+	_ = os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600)
+	if _, err = NewClient(*config); err == nil || !strings.Contains(err.Error(), "不匹配") {
+		t.Fatal("mismatched certificate should fail:", err)
+	}
+}
+
+func TestAgentAuth(t *testing.T) {
+	userSigner, userPrivate := newTestSigner(t)
+
+	server := newTestServer(t, "agent")
+	server.SetConfig(func(config *ssh.ServerConfig) {
+		config.PasswordCallback = nil
+		config.PublicKeyCallback = func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), userSigner.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		}
+	})
+
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: userPrivate}); err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skip("unix socket not supported:", err)
+	}
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			conn, e := listener.Accept()
+			if e != nil {
+				return
+			}
+			go func() {
+				_ = agent.ServeAgent(keyring, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	defer func() { AgentSocket = "" }()
+
+	// 服务端未配置时不使用 SSH Agent，也不读取环境变量
+	t.Setenv("SSH_AUTH_SOCK", socket)
+	config := server.Config()
+	config.Password = ""
+	config.Agent = true
+	if _, err = NewClient(*config); !errors.Is(err, AgentDisabledError) {
+		t.Fatal("agent should be disabled:", err)
+	}
+
+	AgentSocket = socket
+	client, err := NewClient(*config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = client.Close()
+
+	AgentSocket = "SSH_AUTH_SOCK"
+	client, err = NewClient(*config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = client.Close()
+
+	AgentSocket = filepath.Join(t.TempDir(), "not-exist.sock")
+	if _, err = NewClient(*config); err == nil {
+		t.Fatal("agent socket not exist should fail")
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/ssh"
-	"os"
 	"sync"
 	"time"
 )
@@ -21,11 +20,24 @@ type Config struct {
 	Username     string `json:"username"`
 	Password     string `json:"password"`
 	PublicKey    string `json:"publicKey"`
+	Certificate  string `json:"certificate,omitempty"` // 私钥对应的 OpenSSH 用户证书文件
+	Agent        bool   `json:"agent,omitempty"`       // 使用服务端配置的 SSH Agent 中的密钥
 	Command      string `json:"command"`
 	Timeout      int    `json:"timeout"`
 	IdleSendOpen bool   `json:"idleSendOpen"`
 	IdleSendTime int    `json:"idleSendTime"`
 	IdleSendChar string `json:"idleSendChar"`
+
+	MonitorOpen     bool `json:"monitorOpen"`     // 后台采集监控数据
+	MonitorInterval int  `json:"monitorInterval"` // 采集间隔（秒）
+
+	JumpHosts []*Config `json:"jumpHosts,omitempty"` // 跳板机，按顺序连接，跳板机的配置中不再配置跳板机
+	ToolboxId int64     `json:"toolboxId,omitempty"` // 作为跳板机时引用的 SSH 工具，由使用方替换为对应的配置
+
+	Forwards []*ForwardConfig `json:"forwards,omitempty"` // 端口转发
+
+	HostKeyStore        HostKeyStore                     `json:"-"` // 主机公钥校验，为空时不校验
+	KeyboardInteractive ssh.KeyboardInteractiveChallenge `json:"-"` // 键盘交互认证，如终端中输入动态验证码，为空时使用密码回答
 }
 
 type Client struct {
@@ -132,52 +144,55 @@ func (this_ *Client) WSWriteData(obj interface{}) {
 	return
 }
 
+// NewClient 创建连接，配置了跳板机时依次经过跳板机连接
 func NewClient(config Config) (client *ssh.Client, err error) {
-	var (
-		auth         []ssh.AuthMethod
-		clientConfig *ssh.ClientConfig
-		sshConfig    ssh.Config
-	)
-	auth = []ssh.AuthMethod{}
-
-	if config.PublicKey != "" {
-		var publicKeyBytes []byte
-		publicKeyBytes, err = os.ReadFile(config.PublicKey)
-		if err != nil {
-			return
-		}
-		var publicKeySigner ssh.Signer
-		if config.Password != "" {
-			publicKeySigner, err = ssh.ParsePrivateKeyWithPassphrase(publicKeyBytes, []byte(config.Password))
-		} else {
-			publicKeySigner, err = ssh.ParsePrivateKey(publicKeyBytes)
-		}
-		if err != nil {
-			return
-		}
-		auth = append(auth, ssh.PublicKeys(publicKeySigner))
+	if len(config.JumpHosts) > 0 {
+		client, err = newJumpClient(config)
+		return
+	}
+	clientConfig, closeAuth, err := newClientConfig(config)
+	if err != nil {
+		return
+	}
+	defer closeAuth()
+	client, err = ssh.Dial(config.Type, config.Address, clientConfig)
+	if err != nil {
+		return
+	}
+	return
+}
 
-	} else if config.Password != "" {
-		auth = append(auth, ssh.Password(config.Password))
+// newClientConfig 创建连接配置，closeAuth 在连接完成后调用
+func newClientConfig(config Config) (clientConfig *ssh.ClientConfig, closeAuth func(), err error) {
+	auth, closeAuth, err := newAuthMethods(config)
+	if err != nil {
+		return
 	}
 
-	sshConfig = ssh.Config{
+	sshConfig := ssh.Config{
 		Ciphers: Ciphers,
 	}
 	var timeout = 5 * time.Second
 	if config.Timeout > 0 {
 		timeout = time.Duration(config.Timeout) * time.Second
 	}
-	clientConfig = &ssh.ClientConfig{
-		User:            config.Username,
-		Auth:            auth,
-		Timeout:         timeout,
-		Config:          sshConfig,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), //这个可以, 但是不够安全
+	var hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	var hostKeyAlgorithms []string
+	if config.HostKeyStore != nil {
+		hostKeyCallback = NewHostKeyCallback(config.HostKeyStore)
+		hostKeyAlgorithms, err = getHostKeyAlgorithms(config.HostKeyStore, config.Address)
+		if err != nil {
+			closeAuth()
+			return
+		}
 	}
-	client, err = ssh.Dial(config.Type, config.Address, clientConfig)
-	if err != nil {
-		return
+	clientConfig = &ssh.ClientConfig{
+		User:              config.Username,
+		Auth:              auth,
+		Timeout:           timeout,
+		Config:            sshConfig,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
 	}
 	return
 }
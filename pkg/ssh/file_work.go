@@ -49,9 +49,10 @@ func CloseFileService(key string) {
 }
 
 type fileService struct {
-	config      *Config
-	sshClient   *ssh.Client
-	newSftpLock sync.Mutex
+	config        *Config
+	sshClient     *ssh.Client
+	releaseClient func()
+	newSftpLock   sync.Mutex
 
 	sftpClient *sftp.Client
 }
@@ -72,8 +73,8 @@ func (this_ *fileService) getSftp() (sftpClient *sftp.Client, err error) {
 	if this_.sftpClient == nil {
 		this_.sftpClient, err = sftp.NewClient(this_.sshClient)
 		if err != nil {
-			this_.sshClient = nil
 			this_.sftpClient = nil
+			this_.closeClient()
 			return
 		}
 	}
@@ -84,27 +85,41 @@ func (this_ *fileService) getSftp() (sftpClient *sftp.Client, err error) {
 }
 
 func (this_ *fileService) Close() {
+	this_.newSftpLock.Lock()
+	defer this_.newSftpLock.Unlock()
+
 	this_.closeClient()
 	return
 }
 
 func (this_ *fileService) closeClient() {
-	if this_.sshClient != nil {
-		_ = this_.sshClient.Close()
-		this_.sshClient = nil
+	if this_.sftpClient != nil {
+		_ = this_.sftpClient.Close()
+		this_.sftpClient = nil
+	}
+	if this_.releaseClient != nil {
+		this_.releaseClient()
+		this_.releaseClient = nil
 	}
+	this_.sshClient = nil
 	return
 }
 
+// createClient 使用连接池中的连接，与终端等共用，连接断开后下次使用时重新获取
 func (this_ *fileService) createClient() (err error) {
 
-	if this_.sshClient, err = NewClient(*this_.config); err != nil {
+	if this_.sshClient, this_.releaseClient, err = GetPoolClient(*this_.config); err != nil {
 		util.Logger.Error("createClient error", zap.Error(err))
 		return
 	}
+	sshClient := this_.sshClient
 	go func() {
-		err = this_.sshClient.Wait()
-		this_.Close()
+		_ = sshClient.Wait()
+		this_.newSftpLock.Lock()
+		defer this_.newSftpLock.Unlock()
+		if this_.sshClient == sshClient {
+			this_.closeClient()
+		}
 	}()
 	return
 }
@@ -0,0 +1,481 @@
+package ssh
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"teamide/pkg/proxy"
+	"time"
+)
+
+// SSH 端口转发
+//
+// 一个 SSH 工具的所有转发共用一个连接，连接断开后关闭所有监听，间隔 ForwardRetryInterval 重新连接后再重新监听；
+// 连接期间每 ForwardKeepAliveInterval 发送一次 keepalive 请求，超时没有响应时主动断开重连。
+// 本地转发和动态转发在本机监听，通过 SSH 服务器连接目标地址；远程转发在 SSH 服务器上监听，连接本机可以访问的目标地址，
+// 目标地址需要在服务端配置的 ForwardTargetHostList 中。
+// 状态和读写统计与节点代理一致，读为从监听端连接读取的数据，写为写入监听端连接的数据。
+
+const (
+	ForwardTypeLocal   = "local"   // 本地转发，同 ssh -L
+	ForwardTypeRemote  = "remote"  // 远程转发，同 ssh -R
+	ForwardTypeDynamic = "dynamic" // 动态转发，同 ssh -D，支持 SOCKS5 和 HTTP CONNECT
+)
+
+// 端口转发状态，与节点状态值一致
+const (
+	ForwardStatusStarted int8 = 1
+	ForwardStatusStopped int8 = 2
+	ForwardStatusError   int8 = 3
+)
+
+var (
+	// ForwardRetryInterval 连接断开后重新连接的间隔
+	ForwardRetryInterval = 5 * time.Second
+	// ForwardKeepAliveInterval 发送 keepalive 请求的间隔，也是等待响应的超时时间
+	ForwardKeepAliveInterval = 30 * time.Second
+	// ForwardDialTimeout 远程转发连接本机目标地址的超时时间
+	ForwardDialTimeout = 10 * time.Second
+	// ForwardBindHostList 本地和动态转发允许监听的非回环地址，由服务端配置，默认只允许监听回环地址
+	ForwardBindHostList []string
+	// ForwardTargetHostList 远程转发允许连接的目标地址，为主机名、IP 或 CIDR，由服务端配置，默认不允许远程转发连接任何地址
+	ForwardTargetHostList []string
+)
+
+// ForwardConfig 端口转发配置
+type ForwardConfig struct {
+	Id            string `json:"id"`
+	Name          string `json:"name,omitempty"`
+	Type          string `json:"type"`                    // local、remote、dynamic
+	BindAddress   string `json:"bindAddress"`             // 监听地址，本地和动态转发为本机地址，远程转发为 SSH 服务器上的地址
+	TargetAddress string `json:"targetAddress,omitempty"` // 目标地址，本地转发为 SSH 服务器可以访问的地址，远程转发为本机可以访问的地址，动态转发不需要
+	Open          bool   `json:"open"`                    // 是否开启
+}
+
+// Check 校验配置
+func (this_ *ForwardConfig) Check() (err error) {
+	switch this_.Type {
+	case ForwardTypeLocal, ForwardTypeRemote, ForwardTypeDynamic:
+	default:
+		err = errors.New("端口转发类型[" + this_.Type + "]不支持")
+		return
+	}
+	if _, err = this_.getListenAddress(); err != nil {
+		return
+	}
+	if this_.Type == ForwardTypeDynamic {
+		return
+	}
+	if _, _, e := net.SplitHostPort(this_.TargetAddress); e != nil {
+		err = errors.New("端口转发目标地址[" + this_.TargetAddress + "]格式错误，如 127.0.0.1:80")
+		return
+	}
+	return
+}
+
+// getListenAddress 监听地址，本地和动态转发未指定地址时监听 127.0.0.1，监听非回环地址需要服务端配置允许
+func (this_ *ForwardConfig) getListenAddress() (address string, err error) {
+	host, port, e := net.SplitHostPort(this_.BindAddress)
+	if e != nil {
+		err = errors.New("端口转发监听地址[" + this_.BindAddress + "]格式错误，如 127.0.0.1:8080")
+		return
+	}
+	if this_.Type == ForwardTypeRemote {
+		address = this_.BindAddress
+		return
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	address = net.JoinHostPort(host, port)
+	if host == "localhost" {
+		return
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return
+	}
+	for _, one := range ForwardBindHostList {
+		if one == host {
+			return
+		}
+	}
+	err = errors.New("端口转发监听地址[" + this_.BindAddress + "]不是回环地址，需要服务端配置允许")
+	return
+}
+
+// checkTargetAddress 远程转发由服务端连接目标地址，目标地址需要服务端配置允许，防止通过远程转发访问服务端所在的内部网络
+func (this_ *ForwardConfig) checkTargetAddress() (err error) {
+	if this_.Type != ForwardTypeRemote {
+		return
+	}
+	host, _, e := net.SplitHostPort(this_.TargetAddress)
+	if e != nil {
+		err = errors.New("端口转发目标地址[" + this_.TargetAddress + "]格式错误，如 127.0.0.1:80")
+		return
+	}
+	ip := net.ParseIP(host)
+	for _, one := range ForwardTargetHostList {
+		if one == host {
+			return
+		}
+		if ip == nil {
+			continue
+		}
+		if oneIp := net.ParseIP(one); oneIp != nil && oneIp.Equal(ip) {
+			return
+		}
+		if _, ipNet, e := net.ParseCIDR(one); e == nil && ipNet.Contains(ip) {
+			return
+		}
+	}
+	err = errors.New("远程转发目标地址[" + this_.TargetAddress + "]不在服务端配置允许的地址中")
+	return
+}
+
+// GetInfoStr 转发信息，用于日志
+func (this_ *ForwardConfig) GetInfoStr() string {
+	if this_.Type == ForwardTypeDynamic {
+		return this_.Type + " " + this_.BindAddress
+	}
+	return this_.Type + " " + this_.BindAddress + " -> " + this_.TargetAddress
+}
+
+// ForwardStatus 端口转发状态
+type ForwardStatus struct {
+	*ForwardConfig
+	Status      int8               `json:"status"` // ForwardStatusStarted 等
+	StatusError string             `json:"statusError,omitempty"`
+	ConnCount   int64              `json:"connCount"`             // 当前连接数
+	MonitorData *proxy.MonitorData `json:"monitorData,omitempty"` // 查询时的统计数据
+}
+
+// PortForwarder SSH 端口转发，只启动配置中开启的转发，连接断开后自动重连
+type PortForwarder struct {
+	Config *Config
+
+	forwards  []*forward
+	client    *ssh.Client
+	lastError string
+	isStopped bool
+	stopChan  chan struct{}
+	lock      sync.Mutex
+}
+
+func NewPortForwarder(config *Config) *PortForwarder {
+	forwarder := &PortForwarder{
+		Config:   config,
+		stopChan: make(chan struct{}),
+	}
+	for _, one := range config.Forwards {
+		if one == nil || !one.Open {
+			continue
+		}
+		forwarder.forwards = append(forwarder.forwards, &forward{
+			config:      one,
+			monitorData: &proxy.MonitorData{},
+			status:      ForwardStatusStopped,
+		})
+	}
+	return forwarder
+}
+
+// Start 开始后台连接并监听
+func (this_ *PortForwarder) Start() {
+	go this_.run()
+}
+
+// Stop 停止转发，关闭监听和连接
+func (this_ *PortForwarder) Stop() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.isStopped {
+		return
+	}
+	this_.isStopped = true
+	close(this_.stopChan)
+	if this_.client != nil {
+		_ = this_.client.Close()
+		this_.client = nil
+	}
+}
+
+// GetLastError 最近一次连接的异常，连接正常时为空
+func (this_ *PortForwarder) GetLastError() string {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	return this_.lastError
+}
+
+func (this_ *PortForwarder) setLastError(lastError string) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.lastError = lastError
+}
+
+// GetStatusList 所有开启的转发的状态，按配置顺序
+func (this_ *PortForwarder) GetStatusList() (list []*ForwardStatus) {
+	for _, one := range this_.forwards {
+		list = append(list, one.getStatus())
+	}
+	return
+}
+
+func (this_ *PortForwarder) run() {
+	if len(this_.forwards) == 0 {
+		return
+	}
+	for {
+		err := this_.serve()
+		select {
+		case <-this_.stopChan:
+			return
+		default:
+		}
+		if err == nil {
+			err = errors.New("连接已断开")
+		}
+		util.Logger.Error("ssh forward error", zap.Any("address", this_.Config.Address), zap.Error(err))
+		this_.setLastError(err.Error())
+
+		select {
+		case <-this_.stopChan:
+			return
+		case <-time.After(ForwardRetryInterval):
+		}
+	}
+}
+
+// serve 连接后启动所有转发，直到连接断开或停止
+func (this_ *PortForwarder) serve() (err error) {
+	client, err := NewClient(*this_.Config)
+	if err != nil {
+		return
+	}
+	this_.lock.Lock()
+	if this_.isStopped {
+		this_.lock.Unlock()
+		_ = client.Close()
+		return
+	}
+	this_.client = client
+	this_.lastError = ""
+	this_.lock.Unlock()
+
+	for _, one := range this_.forwards {
+		one.start(client)
+	}
+	defer func() {
+		_ = client.Close()
+		var stopError string
+		if err != nil {
+			stopError = err.Error()
+		}
+		for _, one := range this_.forwards {
+			one.stop(stopError)
+		}
+	}()
+
+	var waitChan = make(chan error, 1)
+	go func() {
+		waitChan <- client.Wait()
+	}()
+	ticker := time.NewTicker(ForwardKeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-this_.stopChan:
+			return
+		case err = <-waitChan:
+			return
+		case <-ticker.C:
+			if err = keepAlive(client, ForwardKeepAliveInterval); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// keepAlive 发送 keepalive 请求，服务端不支持该请求时也会响应，超时没有响应时返回异常
+func keepAlive(client *ssh.Client, timeout time.Duration) (err error) {
+	var done = make(chan error, 1)
+	go func() {
+		_, _, e := client.SendRequest("keepalive@openssh.com", true, nil)
+		done <- e
+	}()
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		err = errors.New("keepalive 请求超时")
+	}
+	return
+}
+
+type forward struct {
+	config      *ForwardConfig
+	monitorData *proxy.MonitorData
+	connCount   int64
+
+	listener    net.Listener
+	status      int8
+	statusError string
+	lock        sync.Mutex
+}
+
+func (this_ *forward) getStatus() *ForwardStatus {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	return &ForwardStatus{
+		ForwardConfig: this_.config,
+		Status:        this_.status,
+		StatusError:   this_.statusError,
+		ConnCount:     atomic.LoadInt64(&this_.connCount),
+		MonitorData:   this_.monitorData.Copy(),
+	}
+}
+
+func (this_ *forward) setStatus(status int8, statusError string) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.status = status
+	this_.statusError = statusError
+}
+
+// start 开始监听，监听失败时记录异常，等待下次重连时再监听
+func (this_ *forward) start(client *ssh.Client) {
+	var listener net.Listener
+	var address string
+	err := this_.config.Check()
+	if err == nil {
+		err = this_.config.checkTargetAddress()
+	}
+	if err == nil {
+		address, err = this_.config.getListenAddress()
+	}
+	if err == nil {
+		if this_.config.Type == ForwardTypeRemote {
+			listener, err = client.Listen("tcp", address)
+		} else {
+			listener, err = net.Listen("tcp", address)
+		}
+	}
+	if err != nil {
+		util.Logger.Error("ssh forward "+this_.config.GetInfoStr()+" listen error", zap.Error(err))
+		this_.setStatus(ForwardStatusError, err.Error())
+		return
+	}
+
+	this_.lock.Lock()
+	this_.listener = listener
+	this_.status = ForwardStatusStarted
+	this_.statusError = ""
+	this_.lock.Unlock()
+
+	go func() {
+		for {
+			conn, e := listener.Accept()
+			if e != nil {
+				return
+			}
+			go this_.onConn(client, conn)
+		}
+	}()
+}
+
+// stop 关闭监听，连接断开时已有的连接也随之关闭
+func (this_ *forward) stop(stopError string) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.listener != nil {
+		_ = this_.listener.Close()
+		this_.listener = nil
+	}
+	if this_.status == ForwardStatusError {
+		return
+	}
+	this_.status = ForwardStatusStopped
+	this_.statusError = stopError
+}
+
+func (this_ *forward) onConn(client *ssh.Client, conn net.Conn) {
+	atomic.AddInt64(&this_.connCount, 1)
+	defer func() {
+		atomic.AddInt64(&this_.connCount, -1)
+		_ = conn.Close()
+	}()
+
+	var reader io.Reader = conn
+	var target net.Conn
+	var err error
+	switch this_.config.Type {
+	case ForwardTypeLocal:
+		target, err = client.Dial("tcp", this_.config.TargetAddress)
+	case ForwardTypeRemote:
+		target, err = net.DialTimeout("tcp", this_.config.TargetAddress, ForwardDialTimeout)
+	case ForwardTypeDynamic:
+		var request *proxy.DynamicRequest
+		request, err = proxy.ReadDynamicRequest(conn)
+		if err != nil {
+			util.Logger.Error("ssh forward "+this_.config.GetInfoStr()+" read request error", zap.Error(err))
+			return
+		}
+		reader = request.Reader
+		target, err = client.Dial("tcp", request.Address)
+		if e := request.Reply(conn, err); e != nil && err == nil {
+			err = e
+		}
+	}
+	if err != nil {
+		util.Logger.Error("ssh forward "+this_.config.GetInfoStr()+" dial error", zap.Error(err))
+		if target != nil {
+			_ = target.Close()
+		}
+		return
+	}
+	defer func() { _ = target.Close() }()
+
+	go func() {
+		var buf = make([]byte, 1024*32)
+		for {
+			n, e := target.Read(buf)
+			if n > 0 {
+				start := util.GetNow().UnixNano()
+				_, we := conn.Write(buf[:n])
+				this_.monitorData.MonitorWrite(int64(n), util.GetNow().UnixNano()-start)
+				if we != nil {
+					break
+				}
+			}
+			if e != nil {
+				break
+			}
+		}
+		_ = conn.Close()
+	}()
+
+	var buf = make([]byte, 1024*32)
+	start := util.GetNow().UnixNano()
+	for {
+		n, e := reader.Read(buf)
+		if n > 0 {
+			this_.monitorData.MonitorRead(int64(n), util.GetNow().UnixNano()-start)
+			if _, we := target.Write(buf[:n]); we != nil {
+				break
+			}
+			start = util.GetNow().UnixNano()
+		}
+		if e != nil {
+			break
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package ssh
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestEchoServer 原样返回读取的数据
+func newTestEchoServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, e := listener.Accept()
+			if e != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(conn, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// newTestFreeAddress 获取一个空闲的本地地址
+func newTestFreeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+	return address
+}
+
+func testEcho(t *testing.T, conn net.Conn, text string) {
+	if _, err := conn.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	var bs = make([]byte, len(text))
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(conn, bs); err != nil || string(bs) != text {
+		t.Fatal("echo error:", string(bs), err)
+	}
+}
+
+func testDialEcho(t *testing.T, address string, text string) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	testEcho(t, conn, text)
+}
+
+func waitForwardStatus(t *testing.T, forwarder *PortForwarder, status int8) {
+	for i := 0; i < 500; i++ {
+		var ok = true
+		for _, one := range forwarder.GetStatusList() {
+			if one.Status != status {
+				ok = false
+			}
+		}
+		if ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, one := range forwarder.GetStatusList() {
+		t.Log(one.GetInfoStr(), one.Status, one.StatusError)
+	}
+	t.Fatal("wait forward status timeout:", status, forwarder.GetLastError())
+}
+
+func TestPortForwarder(t *testing.T) {
+	retryInterval := ForwardRetryInterval
+	ForwardRetryInterval = 300 * time.Millisecond
+	defer func() { ForwardRetryInterval = retryInterval }()
+
+	server := newTestServer(t, "forward")
+	echoAddress := newTestEchoServer(t)
+	ForwardTargetHostList = []string{"127.0.0.0/8"}
+	defer func() { ForwardTargetHostList = nil }()
+	config := server.Config()
+	config.Forwards = []*ForwardConfig{
+		{Id: "local", Type: ForwardTypeLocal, BindAddress: newTestFreeAddress(t), TargetAddress: echoAddress, Open: true},
+		{Id: "remote", Type: ForwardTypeRemote, BindAddress: newTestFreeAddress(t), TargetAddress: echoAddress, Open: true},
+		{Id: "dynamic", Type: ForwardTypeDynamic, BindAddress: newTestFreeAddress(t), Open: true},
+		{Id: "closed", Type: ForwardTypeLocal, BindAddress: newTestFreeAddress(t), TargetAddress: echoAddress},
+	}
+	forwarder := NewPortForwarder(config)
+	if len(forwarder.GetStatusList()) != 3 {
+		t.Fatal("closed forward should not start")
+	}
+	forwarder.Start()
+	waitForwardStatus(t, forwarder, ForwardStatusStarted)
+
+	testDialEcho(t, config.Forwards[0].BindAddress, "local")
+	testDialEcho(t, config.Forwards[1].BindAddress, "remote")
+
+	conn, err := net.Dial("tcp", config.Forwards[2].BindAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, portStr, _ := net.SplitHostPort(echoAddress)
+	port, _ := strconv.Atoi(portStr)
+	request := []byte{0x05, 0x01, 0x00, 0x05, 0x01, 0x00, 0x01}
+	request = append(request, net.ParseIP(host).To4()...)
+	var portBytes = make([]byte, 2)
+	binary.BigEndian.PutUint16(portBytes, uint16(port))
+	request = append(request, portBytes...)
+	if _, err = conn.Write(request); err != nil {
+		t.Fatal(err)
+	}
+	var reply = make([]byte, 12)
+	if _, err = io.ReadFull(conn, reply); err != nil || reply[3] != 0x00 {
+		t.Fatal("socks5 reply error:", reply, err)
+	}
+	testEcho(t, conn, "dynamic")
+	_ = conn.Close()
+
+	// 写入统计在写入完成之后记录，等待统计完成
+	for index := range forwarder.GetStatusList() {
+		one := forwarder.GetStatusList()[index]
+		for i := 0; i < 100 && one.MonitorData.WriteSize == 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+			one = forwarder.GetStatusList()[index]
+		}
+		if one.MonitorData.ReadSize == 0 || one.MonitorData.WriteSize == 0 {
+			t.Fatal("monitor data error:", one.Id, one.MonitorData.ReadSize, one.MonitorData.WriteSize)
+		}
+	}
+
+	// 连接断开后自动重连并重新监听
+	server.CloseConns()
+	waitForwardStatus(t, forwarder, ForwardStatusStopped)
+	waitForwardStatus(t, forwarder, ForwardStatusStarted)
+	testDialEcho(t, config.Forwards[0].BindAddress, "reconnect")
+
+	forwarder.Stop()
+	waitForwardStatus(t, forwarder, ForwardStatusStopped)
+	if conn, err = net.Dial("tcp", config.Forwards[0].BindAddress); err == nil {
+		_ = conn.Close()
+		t.Fatal("listener should be closed after stop")
+	}
+}
+
+func TestForwardConfigTargetAddress(t *testing.T) {
+	defer func() { ForwardTargetHostList = nil }()
+
+	// 默认不允许远程转发连接任何地址
+	config := &ForwardConfig{Type: ForwardTypeRemote, BindAddress: "127.0.0.1:8080", TargetAddress: "127.0.0.1:80"}
+	if err := config.checkTargetAddress(); err == nil {
+		t.Fatal("remote forward target should not be allowed by default")
+	}
+
+	ForwardTargetHostList = []string{"db.internal", "10.0.0.0/24", "::1"}
+	for _, one := range []struct {
+		forwardType   string
+		targetAddress string
+		allowed       bool
+	}{
+		{ForwardTypeRemote, "db.internal:3306", true},
+		{ForwardTypeRemote, "10.0.0.8:80", true},
+		{ForwardTypeRemote, "[::1]:80", true},
+		{ForwardTypeRemote, "[0:0:0:0:0:0:0:1]:80", true},
+		{ForwardTypeRemote, "10.0.1.8:80", false},
+		{ForwardTypeRemote, "127.0.0.1:80", false},
+		{ForwardTypeRemote, "169.254.169.254:80", false},
+		{ForwardTypeRemote, "other.internal:80", false},
+		{ForwardTypeRemote, "10.0.0.8", false},
+		// 本地转发的目标地址由 SSH 服务器连接
+		{ForwardTypeLocal, "127.0.0.1:80", true},
+	} {
+		config = &ForwardConfig{Type: one.forwardType, TargetAddress: one.targetAddress}
+		if err := config.checkTargetAddress(); (err == nil) != one.allowed {
+			t.Errorf("%s target [%s] error: %v", one.forwardType, one.targetAddress, err)
+		}
+	}
+}
+
+func TestForwardConfigListenAddress(t *testing.T) {
+	defer func() { ForwardBindHostList = nil }()
+
+	for _, one := range []struct {
+		forwardType string
+		bindAddress string
+		address     string
+	}{
+		{ForwardTypeLocal, ":8080", "127.0.0.1:8080"},
+		{ForwardTypeLocal, "localhost:8080", "localhost:8080"},
+		{ForwardTypeDynamic, "[::1]:1080", "[::1]:1080"},
+		{ForwardTypeDynamic, "127.0.0.2:1080", "127.0.0.2:1080"},
+		{ForwardTypeLocal, "0.0.0.0:8080", ""},
+		{ForwardTypeDynamic, "192.168.1.10:1080", ""},
+		// 远程转发在 SSH 服务器上监听，由服务器配置控制
+		{ForwardTypeRemote, "0.0.0.0:8080", "0.0.0.0:8080"},
+	} {
+		config := &ForwardConfig{Type: one.forwardType, BindAddress: one.bindAddress}
+		address, err := config.getListenAddress()
+		if one.address == "" {
+			if err == nil {
+				t.Errorf("bind address [%s] should not be allowed", one.bindAddress)
+			}
+			continue
+		}
+		if err != nil || address != one.address {
+			t.Errorf("bind address [%s] error: %s %v", one.bindAddress, address, err)
+		}
+	}
+
+	// 服务端配置允许后可以监听
+	ForwardBindHostList = []string{"0.0.0.0"}
+	config := &ForwardConfig{Type: ForwardTypeLocal, BindAddress: "0.0.0.0:8080", TargetAddress: "127.0.0.1:80"}
+	if err := config.Check(); err != nil {
+		t.Fatal(err)
+	}
+	config.BindAddress = "192.168.1.10:8080"
+	if err := config.Check(); err == nil {
+		t.Fatal("bind address not in list should not be allowed")
+	}
+}
@@ -0,0 +1,113 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// MaxJumpHosts 跳板机最大数量
+var MaxJumpHosts = 8
+
+// GetJumpAddress 跳板机的用户和地址，如 root@10.0.0.1:22>root@10.0.0.2:22，用于区分经过不同跳板机的相同地址
+func (this_ *Config) GetJumpAddress() string {
+	var list []string
+	for _, jump := range this_.JumpHosts {
+		if jump != nil {
+			list = append(list, jump.Username+"@"+jump.Address)
+		}
+	}
+	return strings.Join(list, ">")
+}
+
+// newJumpClient 依次连接跳板机，每一级通过上一级的连接建立到下一级地址的连接；
+// 最后的连接关闭或断开后，逐级关闭跳板机的连接
+func newJumpClient(config Config) (client *ssh.Client, err error) {
+	if len(config.JumpHosts) > MaxJumpHosts {
+		err = errors.New("跳板机数量不能超过" + strconv.Itoa(MaxJumpHosts) + "个")
+		return
+	}
+	var jumpClient *ssh.Client
+	defer func() {
+		if err != nil && jumpClient != nil {
+			_ = jumpClient.Close()
+		}
+	}()
+	for index, jump := range config.JumpHosts {
+		if jump == nil || jump.Address == "" {
+			err = errors.New("第" + strconv.Itoa(index+1) + "个跳板机连接地址不能为空")
+			return
+		}
+		if len(jump.JumpHosts) > 0 {
+			err = errors.New("跳板机[" + jump.Address + "]的配置中不能再配置跳板机")
+			return
+		}
+		var jumpConfig = *jump
+		// 键盘交互认证与目标使用同一个终端
+		if jumpConfig.KeyboardInteractive == nil {
+			jumpConfig.KeyboardInteractive = config.KeyboardInteractive
+		}
+		var next *ssh.Client
+		next, err = dialVia(jumpClient, jumpConfig)
+		if err != nil {
+			err = fmt.Errorf("跳板机[%s]连接失败: %w", jump.Address, err)
+			return
+		}
+		jumpClient = next
+	}
+	client, err = dialVia(jumpClient, config)
+	return
+}
+
+// dialVia 通过 via 连接到 config 的地址，via 为空时直接连接
+func dialVia(via *ssh.Client, config Config) (client *ssh.Client, err error) {
+	clientConfig, closeAuth, err := newClientConfig(config)
+	if err != nil {
+		return
+	}
+	defer closeAuth()
+	if via == nil {
+		network := config.Type
+		if network == "" {
+			network = "tcp"
+		}
+		client, err = ssh.Dial(network, config.Address, clientConfig)
+		return
+	}
+	conn, err := via.Dial("tcp", config.Address)
+	if err != nil {
+		return
+	}
+	// 经过跳板机的连接不支持设置超时，超时后关闭连接结束握手；
+	// 与直接连接一致只限制到收到主机公钥，之后的认证可能需要等待用户输入
+	var isTimeout int32
+	timer := time.AfterFunc(clientConfig.Timeout, func() {
+		atomic.StoreInt32(&isTimeout, 1)
+		_ = conn.Close()
+	})
+	hostKeyCallback := clientConfig.HostKeyCallback
+	clientConfig.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		timer.Stop()
+		return hostKeyCallback(hostname, remote, key)
+	}
+	c, channels, requests, err := ssh.NewClientConn(conn, config.Address, clientConfig)
+	timer.Stop()
+	if err != nil && atomic.LoadInt32(&isTimeout) == 1 {
+		err = fmt.Errorf("连接超时: %w", err)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	client = ssh.NewClient(c, channels, requests)
+	go func() {
+		_ = client.Wait()
+		_ = via.Close()
+	}()
+	return
+}
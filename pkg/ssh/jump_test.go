@@ -0,0 +1,73 @@
+package ssh
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJumpClient(t *testing.T) {
+	jump1 := newTestServer(t, "jump1")
+	jump2 := newTestServer(t, "jump2")
+	target := newTestServer(t, "target")
+
+	store := &testHostKeyStore{trust: true}
+	config := target.Config()
+	config.HostKeyStore = store
+	config.JumpHosts = []*Config{jump1.Config(), jump2.Config()}
+	config.JumpHosts[0].Type = ""
+	config.JumpHosts[1].HostKeyStore = store
+
+	if config.GetJumpAddress() != "test@"+jump1.Address+">test@"+jump2.Address {
+		t.Fatal("jump address error:", config.GetJumpAddress())
+	}
+
+	client, err := NewClient(*config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := testExec(t, client); name != "target" {
+		t.Fatal("exec error:", name)
+	}
+	if jump1.ConnCount() != 1 || jump2.ConnCount() != 1 || target.ConnCount() != 1 {
+		t.Fatal("conn count error")
+	}
+	// 每一级按连接的地址校验主机公钥
+	if len(store.unknown) != 2 || store.unknown[0].Host != NewKnownHost(jump2.Address, jump2.HostKey).Host ||
+		store.unknown[1].Fingerprint != NewKnownHost(target.Address, target.HostKey).Fingerprint {
+		t.Fatalf("host key error: %+v", store.unknown)
+	}
+
+	// 关闭后逐级关闭跳板机的连接
+	_ = client.Close()
+	for i := 0; i < 100 && jump1.ConnCount() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if jump1.ConnCount() != 0 || jump2.ConnCount() != 0 {
+		t.Fatal("jump conn should be closed")
+	}
+
+	// 中间的跳板机认证失败时返回对应的跳板机，已建立的连接关闭
+	config.JumpHosts[1].Password = "error"
+	if _, err = NewClient(*config); err == nil {
+		t.Fatal("jump auth should be error")
+	}
+	for i := 0; i < 100 && jump1.ConnCount() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if jump1.ConnCount() != 0 {
+		t.Fatal("jump conn should be closed")
+	}
+
+	config.JumpHosts[1] = &Config{Address: jump2.Address, JumpHosts: []*Config{jump1.Config()}}
+	if _, err = NewClient(*config); err == nil {
+		t.Fatal("nested jump should be error")
+	}
+
+	// 跳板机同样需要服务端开启 SSH Agent
+	config.JumpHosts[1] = jump2.Config()
+	config.JumpHosts[1].Agent = true
+	if _, err = NewClient(*config); !errors.Is(err, AgentDisabledError) {
+		t.Fatal("jump agent should be disabled:", err)
+	}
+}
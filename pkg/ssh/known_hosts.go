@@ -0,0 +1,215 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io"
+	"net"
+	"strings"
+)
+
+// KnownHost 已信任的主机公钥
+type KnownHost struct {
+	Host        string `json:"host"`        // 端口不是 22 时为 [host]:port，与 known_hosts 文件一致，哈希后的主机以 |1| 开头
+	KeyType     string `json:"keyType"`     // 公钥类型，如 ssh-ed25519
+	PublicKey   string `json:"publicKey"`   // Base64 编码的公钥
+	Fingerprint string `json:"fingerprint"` // SHA256 指纹
+}
+
+// NewKnownHost 由连接地址和公钥创建
+func NewKnownHost(address string, key ssh.PublicKey) *KnownHost {
+	return &KnownHost{
+		Host:        knownhosts.Normalize(address),
+		KeyType:     key.Type(),
+		PublicKey:   base64.StdEncoding.EncodeToString(key.Marshal()),
+		Fingerprint: ssh.FingerprintSHA256(key),
+	}
+}
+
+// Line 转为 known_hosts 文件中的一行
+func (this_ *KnownHost) Line() string {
+	return this_.Host + " " + this_.KeyType + " " + this_.PublicKey
+}
+
+// Match 是否与连接地址匹配，host 为 knownhosts.Normalize 之后的地址
+func (this_ *KnownHost) Match(host string) bool {
+	if !strings.HasPrefix(this_.Host, "|1|") {
+		return this_.Host == host
+	}
+	// 哈希格式：|1|Base64(salt)|Base64(HMAC-SHA1(salt, host))
+	parts := strings.Split(this_.Host[len("|1|"):], "|")
+	if len(parts) != 2 {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	hash, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(host))
+	return hmac.Equal(mac.Sum(nil), hash)
+}
+
+// ParseKnownHosts 解析 known_hosts 文件，一行多个主机时拆分为多条；
+// 带 @cert-authority、@revoked 标记以及通配符、否定模式的主机无法按主机精确校验，跳过并计入 skipped
+func ParseKnownHosts(text string) (list []*KnownHost, skipped int, err error) {
+	rest := []byte(text)
+	for {
+		var marker string
+		var hosts []string
+		var key ssh.PublicKey
+		marker, hosts, key, _, rest, err = ssh.ParseKnownHosts(rest)
+		if err == io.EOF {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+		if marker != "" {
+			skipped++
+			continue
+		}
+		for _, host := range hosts {
+			if strings.ContainsAny(host, "*?!") {
+				skipped++
+				continue
+			}
+			one := NewKnownHost(host, key)
+			if strings.HasPrefix(host, "|1|") {
+				one.Host = host
+			}
+			list = append(list, one)
+		}
+	}
+}
+
+// HostKeyStore 主机公钥存储
+type HostKeyStore interface {
+	// GetKnownHosts 查询已信任的主机公钥
+	GetKnownHosts() (list []*KnownHost, err error)
+	// OnUnknownHostKey 主机没有已信任的公钥时调用，返回 nil 表示信任并继续连接
+	OnUnknownHostKey(hostKey *KnownHost) (err error)
+}
+
+// HostKeyUnknownError 主机公钥未信任
+type HostKeyUnknownError struct {
+	*KnownHost
+}
+
+func (this_ *HostKeyUnknownError) Error() string {
+	return "主机[" + this_.Host + "]公钥[" + this_.KeyType + " " + this_.Fingerprint + "]未信任"
+}
+
+// HostKeyChangedError 主机公钥与已信任的不一致，可能存在中间人攻击
+type HostKeyChangedError struct {
+	*KnownHost
+	KnownFingerprints []string `json:"knownFingerprints"`
+}
+
+func (this_ *HostKeyChangedError) Error() string {
+	return "主机[" + this_.Host + "]公钥[" + this_.KeyType + " " + this_.Fingerprint + "]与已信任的公钥[" +
+		strings.Join(this_.KnownFingerprints, ",") + "]不一致，可能存在中间人攻击，确认主机公钥已更换后删除已信任的公钥再连接"
+}
+
+// IsHostKeyUnknown 是否为主机公钥未信任的异常
+func IsHostKeyUnknown(err error) bool {
+	var e *HostKeyUnknownError
+	return errors.As(err, &e)
+}
+
+// IsHostKeyChanged 是否为主机公钥变更的异常
+func IsHostKeyChanged(err error) bool {
+	var e *HostKeyChangedError
+	return errors.As(err, &e)
+}
+
+// NewHostKeyCallback 按已信任的主机公钥校验，只比较同一类型的公钥：公钥一致时通过；有同一类型的已信任公钥但都不一致时返回 HostKeyChangedError；
+// 主机没有该类型的已信任公钥时由 OnUnknownHostKey 决定是否信任
+func NewHostKeyCallback(store HostKeyStore) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) (err error) {
+		hostKey := NewKnownHost(hostname, key)
+		list, err := store.GetKnownHosts()
+		if err != nil {
+			return
+		}
+		keyBytes := key.Marshal()
+		var knownFingerprints []string
+		for _, one := range list {
+			if one.KeyType != hostKey.KeyType || !one.Match(hostKey.Host) {
+				continue
+			}
+			bs, e := base64.StdEncoding.DecodeString(one.PublicKey)
+			if e == nil && bytes.Equal(bs, keyBytes) {
+				return
+			}
+			knownFingerprints = append(knownFingerprints, one.KeyType+" "+one.Fingerprint)
+		}
+		if len(knownFingerprints) > 0 {
+			err = &HostKeyChangedError{
+				KnownHost:         hostKey,
+				KnownFingerprints: knownFingerprints,
+			}
+			return
+		}
+		err = store.OnUnknownHostKey(hostKey)
+		return
+	}
+}
+
+// HostKeyAlgorithms 主机公钥算法，与 x/crypto 默认的顺序一致
+var HostKeyAlgorithms = []string{
+	ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSASHA512v01,
+	ssh.CertAlgoRSAv01, ssh.CertAlgoDSAv01, ssh.CertAlgoECDSA256v01,
+	ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01, ssh.CertAlgoED25519v01,
+
+	ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+	ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512,
+	ssh.KeyAlgoRSA, ssh.KeyAlgoDSA,
+
+	ssh.KeyAlgoED25519,
+}
+
+// getHostKeyAlgorithms 主机已信任公钥的类型优先协商，避免服务器提供其它类型的公钥；没有已信任的公钥时返回空，使用默认顺序
+func getHostKeyAlgorithms(store HostKeyStore, address string) (algorithms []string, err error) {
+	list, err := store.GetKnownHosts()
+	if err != nil {
+		return
+	}
+	host := knownhosts.Normalize(address)
+	var cache = make(map[string]bool)
+	for _, one := range list {
+		if !one.Match(host) {
+			continue
+		}
+		keyAlgorithms := []string{one.KeyType}
+		// RSA 公钥可以使用 SHA2 签名
+		if one.KeyType == ssh.KeyAlgoRSA {
+			keyAlgorithms = []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+		}
+		for _, algorithm := range keyAlgorithms {
+			if !cache[algorithm] {
+				cache[algorithm] = true
+				algorithms = append(algorithms, algorithm)
+			}
+		}
+	}
+	if len(algorithms) == 0 {
+		return
+	}
+	for _, algorithm := range HostKeyAlgorithms {
+		if !cache[algorithm] {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	return
+}
@@ -0,0 +1,156 @@
+package ssh
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"testing"
+)
+
+type testHostKeyStore struct {
+	list    []*KnownHost
+	unknown []*KnownHost
+	trust   bool
+}
+
+func (this_ *testHostKeyStore) GetKnownHosts() ([]*KnownHost, error) {
+	return this_.list, nil
+}
+
+func (this_ *testHostKeyStore) OnUnknownHostKey(hostKey *KnownHost) error {
+	this_.unknown = append(this_.unknown, hostKey)
+	if !this_.trust {
+		return &HostKeyUnknownError{KnownHost: hostKey}
+	}
+	this_.list = append(this_.list, hostKey)
+	return nil
+}
+
+func newTestPublicKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestParseKnownHosts(t *testing.T) {
+	key1 := newTestPublicKey(t)
+	key2 := newTestPublicKey(t)
+	text := "# comment\n" +
+		knownhosts.Line([]string{"host1", "10.0.0.1:2222"}, key1) + "\n" +
+		knownhosts.Line([]string{knownhosts.HashHostname("host2")}, key2) + "\n" +
+		knownhosts.Line([]string{"*.example.com"}, key2) + "\n" +
+		"@revoked " + knownhosts.Line([]string{"host3"}, key2) + "\n"
+	list, skipped, err := ParseKnownHosts(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || skipped != 2 {
+		t.Fatalf("parse error: %d %d", len(list), skipped)
+	}
+	if list[0].Host != "host1" || list[1].Host != "[10.0.0.1]:2222" || list[0].Fingerprint != ssh.FingerprintSHA256(key1) {
+		t.Fatalf("host error: %+v %+v", list[0], list[1])
+	}
+	if !list[2].Match("host2") || list[2].Match("host1") {
+		t.Fatal("hashed host match error")
+	}
+	if list[0].Line() != knownhosts.Line([]string{"host1"}, key1) {
+		t.Fatal("line error:", list[0].Line())
+	}
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	key1 := newTestPublicKey(t)
+	key2 := newTestPublicKey(t)
+	store := &testHostKeyStore{
+		list: []*KnownHost{NewKnownHost("host1:22", key1)},
+	}
+	callback := NewHostKeyCallback(store)
+
+	if err := callback("host1:22", nil, key1); err != nil {
+		t.Fatal(err)
+	}
+	err := callback("host1:22", nil, key2)
+	if !IsHostKeyChanged(err) {
+		t.Fatal("changed key should be error:", err)
+	}
+	if len(store.unknown) != 0 {
+		t.Fatal("changed key should not be trusted")
+	}
+
+	err = callback("host2:2222", nil, key2)
+	if !IsHostKeyUnknown(err) || len(store.unknown) != 1 || store.unknown[0].Host != "[host2]:2222" {
+		t.Fatal("unknown key should be error:", err)
+	}
+	// 与 ssh.Dial 一样包装后依然可以判断
+	if !IsHostKeyUnknown(fmt.Errorf("ssh: handshake failed: %w", err)) {
+		t.Fatal("wrapped error should be unknown")
+	}
+
+	store.trust = true
+	if err = callback("host2:2222", nil, key2); err != nil {
+		t.Fatal(err)
+	}
+	if err = callback("host2:2222", nil, key2); err != nil || len(store.unknown) != 2 {
+		t.Fatal("trusted key should pass:", err)
+	}
+}
+
+func TestHostKeyType(t *testing.T) {
+	ed25519Key := newTestPublicKey(t)
+	ecdsaPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaSigner, err := ssh.NewSignerFromKey(ecdsaPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 只信任 ed25519 公钥时，其它类型的公钥按未信任处理，而不是公钥变更
+	store := &testHostKeyStore{
+		list: []*KnownHost{NewKnownHost("host1:22", ed25519Key)},
+	}
+	err = NewHostKeyCallback(store)("host1:22", nil, ecdsaSigner.PublicKey())
+	if !IsHostKeyUnknown(err) {
+		t.Fatal("other key type should be unknown:", err)
+	}
+
+	// 服务器同时提供 ECDSA 和 ed25519 公钥时，按已信任的 ed25519 协商
+	server := newTestServer(t, "host-key-type")
+	server.SetConfig(func(config *ssh.ServerConfig) {
+		config.AddHostKey(ecdsaSigner)
+	})
+	store = &testHostKeyStore{
+		list: []*KnownHost{NewKnownHost(server.Address, server.HostKey)},
+	}
+	config := server.Config()
+	config.HostKeyStore = store
+	client, err := NewClient(*config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = client.Close()
+	if len(store.unknown) != 0 {
+		t.Fatal("trusted key type should be used:", store.unknown)
+	}
+
+	// 没有已信任的公钥时使用默认顺序
+	algorithms, err := getHostKeyAlgorithms(&testHostKeyStore{}, server.Address)
+	if err != nil || algorithms != nil {
+		t.Fatal("algorithms should be default:", algorithms, err)
+	}
+	algorithms, _ = getHostKeyAlgorithms(store, server.Address)
+	if len(algorithms) != len(HostKeyAlgorithms) || algorithms[0] != ssh.KeyAlgoED25519 {
+		t.Fatal("trusted key type should be first:", algorithms)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
 	"math"
 	"os"
 	"regexp"
@@ -757,6 +758,45 @@ func ParseProcDiskStats(diskStatsText string) (map[string]disk.IOCountersStat, e
 	}
 	return ret, nil
 }
+
+// ParseProcNetDev 解析 /proc/net/dev
+func ParseProcNetDev(netDevText string) ([]net.IOCountersStat, error) {
+	var ret []net.IOCountersStat
+	for _, line := range strings.Split(netDevText, "\n") {
+		separatorPos := strings.LastIndex(line, ":")
+		if separatorPos < 0 {
+			continue
+		}
+		name := strings.TrimSpace(line[:separatorPos])
+		fields := strings.Fields(line[separatorPos+1:])
+		if name == "" || len(fields) < 16 {
+			continue
+		}
+		var values [16]uint64
+		for i := range values {
+			v, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return ret, err
+			}
+			values[i] = v
+		}
+		ret = append(ret, net.IOCountersStat{
+			Name:        name,
+			BytesRecv:   values[0],
+			PacketsRecv: values[1],
+			Errin:       values[2],
+			Dropin:      values[3],
+			Fifoin:      values[4],
+			BytesSent:   values[8],
+			PacketsSent: values[9],
+			Errout:      values[10],
+			Dropout:     values[11],
+			Fifoout:     values[12],
+		})
+	}
+	return ret, nil
+}
+
 func calculateAllBusy(t1, t2 []cpu.TimesStat) ([]float64, error) {
 	// Make sure the CPU measurements have the same length.
 	if len(t1) != len(t2) {
@@ -0,0 +1,183 @@
+package ssh
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/shirou/gopsutil/v3/process"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ProcPidStat /proc/[pid]/stat 中使用的字段
+type ProcPidStat struct {
+	Pid       int32
+	Name      string
+	State     string
+	Ppid      int32
+	Utime     uint64 // 用户态时间（clock ticks）
+	Stime     uint64 // 内核态时间（clock ticks）
+	StartTime uint64 // 系统启动后进程开始的时间（clock ticks）
+}
+
+// ParseProcPidStat 解析 /proc/[pid]/stat，进程名可能包含空格和括号，以最后一个 ) 分隔
+func ParseProcPidStat(statText string) (res *ProcPidStat, err error) {
+	statText = strings.TrimSpace(statText)
+	start := strings.Index(statText, "(")
+	end := strings.LastIndex(statText, ")")
+	if start < 0 || end < start {
+		err = errors.New("stat format error:" + statText)
+		return
+	}
+	// 括号后从第 3 个字段 state 开始
+	fields := strings.Fields(statText[end+1:])
+	if len(fields) < 20 {
+		err = errors.New("stat format error:" + statText)
+		return
+	}
+	pid, err := strconv.ParseInt(strings.TrimSpace(statText[:start]), 10, 32)
+	if err != nil {
+		return
+	}
+	ppid, err := strconv.ParseInt(fields[1], 10, 32)
+	if err != nil {
+		return
+	}
+	res = &ProcPidStat{
+		Pid:   int32(pid),
+		Name:  statText[start+1 : end],
+		State: fields[0],
+		Ppid:  int32(ppid),
+	}
+	if res.Utime, err = strconv.ParseUint(fields[11], 10, 64); err != nil {
+		return
+	}
+	if res.Stime, err = strconv.ParseUint(fields[12], 10, 64); err != nil {
+		return
+	}
+	if res.StartTime, err = strconv.ParseUint(fields[19], 10, 64); err != nil {
+		return
+	}
+	return
+}
+
+// ConvertProcState 进程状态字母转为与 gopsutil 一致的状态
+func ConvertProcState(state string) string {
+	switch state {
+	case "R":
+		return process.Running
+	case "S":
+		return process.Sleep
+	case "D":
+		return process.Blocked
+	case "I":
+		return process.Idle
+	case "T", "t":
+		return process.Stop
+	case "Z":
+		return process.Zombie
+	case "W":
+		return process.Wait
+	}
+	return state
+}
+
+// ParseProcPidStatus 解析 /proc/[pid]/status 中的真实 UID 和常驻内存（B）
+func ParseProcPidStatus(statusText string) (uid string, rss uint64) {
+	for _, line := range strings.Split(statusText, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "Uid:":
+			uid = fields[1]
+		case "VmRSS:":
+			v, _ := strconv.ParseUint(fields[1], 10, 64)
+			rss = v * 1024
+		}
+	}
+	return
+}
+
+// ParseEtcPasswd 解析 /etc/passwd，返回 UID 与用户名的对应关系
+func ParseEtcPasswd(passwdText string) (res map[string]string) {
+	res = make(map[string]string)
+	for _, line := range strings.Split(passwdText, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ":")
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if _, ok := res[fields[2]]; !ok {
+			res[fields[2]] = fields[0]
+		}
+	}
+	return
+}
+
+// ProcNetSocket /proc/net/tcp、udp 等文件中的套接字
+type ProcNetSocket struct {
+	Protocol string
+	Ip       string
+	Port     int
+	Inode    string
+	Listen   bool // tcp 为 LISTEN 状态，udp 为未连接
+}
+
+// Address 格式如 tcp:0.0.0.0:22，与 system.ProcessStat.PortList 一致
+func (this_ *ProcNetSocket) Address() string {
+	return fmt.Sprintf("%s:%s:%d", this_.Protocol, this_.Ip, this_.Port)
+}
+
+// ParseProcNetSockets 解析 /proc/net/tcp、tcp6、udp、udp6，protocol 为 tcp 或 udp
+func ParseProcNetSockets(netText string, protocol string) (res []*ProcNetSocket, err error) {
+	for _, line := range strings.Split(netText, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 10 || fields[0] == "sl" {
+			continue
+		}
+		socket := &ProcNetSocket{
+			Protocol: protocol,
+			Inode:    fields[9],
+		}
+		if socket.Ip, socket.Port, err = parseProcNetAddress(fields[1]); err != nil {
+			return
+		}
+		if protocol == "tcp" {
+			socket.Listen = fields[3] == "0A"
+		} else {
+			_, remotePort, e := parseProcNetAddress(fields[2])
+			socket.Listen = e == nil && remotePort == 0
+		}
+		res = append(res, socket)
+	}
+	return
+}
+
+// parseProcNetAddress 解析如 0100007F:0016 的地址，IP 按 4 字节小端存储
+func parseProcNetAddress(address string) (ip string, port int, err error) {
+	index := strings.Index(address, ":")
+	if index < 0 {
+		err = errors.New("address format error:" + address)
+		return
+	}
+	bs, err := hex.DecodeString(address[:index])
+	if err != nil {
+		return
+	}
+	if len(bs) != net.IPv4len && len(bs) != net.IPv6len {
+		err = errors.New("address format error:" + address)
+		return
+	}
+	for i := 0; i < len(bs); i += 4 {
+		bs[i], bs[i+1], bs[i+2], bs[i+3] = bs[i+3], bs[i+2], bs[i+1], bs[i]
+	}
+	ip = net.IP(bs).String()
+	p, err := strconv.ParseUint(address[index+1:], 16, 16)
+	if err != nil {
+		return
+	}
+	port = int(p)
+	return
+}
@@ -0,0 +1,83 @@
+package ssh
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+func TestParseProcPidStat(t *testing.T) {
+	stat, err := ParseProcPidStat("1234 (my (odd) name) S 1 1234 1234 0 -1 4194560 500 0 0 0 250 50 0 0 20 0 1 0 9000 10000000 300 18446744073709551615")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Pid != 1234 || stat.Name != "my (odd) name" || stat.State != "S" || stat.Ppid != 1 ||
+		stat.Utime != 250 || stat.Stime != 50 || stat.StartTime != 9000 {
+		t.Fatalf("stat error: %+v", stat)
+	}
+	if _, err = ParseProcPidStat("1234 bad"); err == nil {
+		t.Fatal("bad stat should be error")
+	}
+}
+
+func TestParseProcNetSockets(t *testing.T) {
+	tcp := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:D2F0 01 00000000:00000000 00:00000000 00000000  1000        0 1002 1 0000000000000000 20 4 30 10 -1`
+	list, err := ParseProcNetSockets(tcp, "tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || !list[0].Listen || list[0].Address() != "tcp:0.0.0.0:22" || list[0].Inode != "1001" {
+		t.Fatalf("tcp socket error: %+v", list[0])
+	}
+	if list[1].Listen || list[1].Address() != "tcp:127.0.0.1:8080" {
+		t.Fatalf("tcp socket error: %+v", list[1])
+	}
+
+	udp6 := `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  10: 00000000000000000000000001000000:0035 00000000000000000000000000000000:0000 07 00000000:00000000 00:00000000 00000000   101        0 2001 2 0000000000000000 0`
+	list, err = ParseProcNetSockets(udp6, "udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !list[0].Listen || list[0].Address() != "udp:::1:53" {
+		t.Fatalf("udp6 socket error: %+v", list)
+	}
+}
+
+func TestParseProcPidStatus(t *testing.T) {
+	uid, rss := ParseProcPidStatus("Name:\tsshd\nUid:\t1000\t1000\t1000\t1000\nVmRSS:\t    2048 kB\n")
+	if uid != "1000" || rss != 2048*1024 {
+		t.Fatal("status error:", uid, rss)
+	}
+	users := ParseEtcPasswd("root:x:0:0:root:/root:/bin/bash\n# comment\nteam:x:1000:1000::/home/team:/bin/sh\n")
+	if users["0"] != "root" || users["1000"] != "team" || len(users) != 2 {
+		t.Fatal("passwd error:", users)
+	}
+}
+
+// TestProcessDump 在本机执行进程信息脚本，校验解析结果
+func TestProcessDump(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process dump reads /proc")
+	}
+	bs, err := exec.Command("sh", "-c", processDumpScript).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := parseProcessDump(string(bs))
+	var found bool
+	for _, one := range list {
+		if int(one.Pid) == os.Getpid() {
+			found = true
+			if one.Rss == 0 || one.User == "" || one.Cmdline == "" || one.CreateTime == 0 {
+				t.Fatalf("current process error: %+v", one)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("current process not found")
+	}
+}
@@ -0,0 +1,265 @@
+package ssh
+
+import (
+	"bufio"
+	"errors"
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"teamide/pkg/system"
+	"time"
+)
+
+// SSH 服务器监控数据采集
+//
+// 一个 SSH 会话中循环输出 /proc/stat、/proc/meminfo、/proc/diskstats、/proc/net/dev，每次输出为一帧，
+// 与上一帧比较计算 CPU 使用率和磁盘、网卡速度后写入 MonitorStore，数据格式与本机采集的一致。
+
+// monitorDumpMark 监控数据脚本输出的分段标记
+const monitorDumpMark = "#teamide-monitor:"
+
+// monitorFrameScript 输出一帧数据
+var monitorFrameScript = `M='` + monitorDumpMark + `'
+echo "${M}uptime"; cat /proc/uptime
+echo "${M}stat"; cat /proc/stat
+echo "${M}meminfo"; cat /proc/meminfo
+echo "${M}diskstats"; cat /proc/diskstats 2>/dev/null
+echo "${M}netdev"; cat /proc/net/dev
+echo "${M}end"
+`
+
+var (
+	// DefaultMonitorInterval 默认采集间隔（秒）
+	DefaultMonitorInterval = 10
+	// MonitorRetryInterval 连接断开后重新连接的间隔
+	MonitorRetryInterval = 30 * time.Second
+)
+
+// readMonitorFrames 按分段标记读取帧，每读取完一帧回调 on
+func readMonitorFrames(reader io.Reader, on func(frame map[string]string)) (err error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	frame := make(map[string]string)
+	var section string
+	var builder strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, monitorDumpMark) {
+			builder.WriteString(line + "\n")
+			continue
+		}
+		if section != "" {
+			frame[section] = builder.String()
+		}
+		builder.Reset()
+		section = strings.TrimPrefix(line, monitorDumpMark)
+		if section == "end" {
+			on(frame)
+			frame = make(map[string]string)
+			section = ""
+		}
+	}
+	err = scanner.Err()
+	return
+}
+
+// monitorSampler 与上一帧比较计算监控数据
+type monitorSampler struct {
+	counter     *system.MonitorCounter
+	lastCpu     []cpu.TimesStat
+	firstTime   time.Time
+	firstUptime float64
+}
+
+func newMonitorSampler() *monitorSampler {
+	return &monitorSampler{
+		counter: &system.MonitorCounter{},
+	}
+}
+
+// getSampleTime 按服务器运行时间推算帧的采集时间，避免读取延迟影响速度的计算
+func (this_ *monitorSampler) getSampleTime(frame map[string]string, now time.Time) time.Time {
+	fields := strings.Fields(frame["uptime"])
+	if len(fields) == 0 {
+		return now
+	}
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return now
+	}
+	if this_.firstTime.IsZero() || uptime < this_.firstUptime {
+		this_.firstTime = now
+		this_.firstUptime = uptime
+	}
+	return this_.firstTime.Add(time.Duration((uptime - this_.firstUptime) * float64(time.Second)))
+}
+
+// sample 转换一帧数据，第一帧只作为比较的基准，返回 nil
+func (this_ *monitorSampler) sample(frame map[string]string, now time.Time) (monitorData *system.MonitorData, err error) {
+	now = this_.getSampleTime(frame, now)
+	cpuStats := ParseProcStat(frame["stat"])
+	memStat, _, err := ParseProcMemInfo(frame["meminfo"], "")
+	if err != nil {
+		return
+	}
+	diskStats, err := ParseProcDiskStats(frame["diskstats"])
+	if err != nil {
+		return
+	}
+	netStats, err := ParseProcNetDev(frame["netdev"])
+	if err != nil {
+		return
+	}
+	diskIOCountersStats := this_.counter.DiskIOCounters(diskStats, now)
+	netIOCountersStats := this_.counter.NetIOCounters(netStats, now)
+
+	lastCpu := this_.lastCpu
+	this_.lastCpu = cpuStats
+	if lastCpu == nil {
+		return
+	}
+	monitorData = &system.MonitorData{
+		VirtualMemoryStat:   system.NewVirtualMemoryStat(memStat),
+		NetIOCountersStats:  netIOCountersStats,
+		DiskIOCountersStats: diskIOCountersStats,
+		StartTime:           now.UnixMilli(),
+		EndTime:             now.UnixMilli(),
+	}
+	// CPU 数量变化（如热插拔）时本次不计算 CPU 使用率
+	monitorData.CpuPercents, _ = calculateAllBusy(lastCpu, cpuStats)
+	return
+}
+
+// MonitorCollector SSH 服务器监控数据采集，连接断开后自动重连
+type MonitorCollector struct {
+	Config   *Config
+	Interval int // 采集间隔（秒），为 0 时使用 DefaultMonitorInterval
+	Store    *system.MonitorStore
+
+	client    *ssh.Client
+	lastError string
+	isStopped bool
+	stopChan  chan struct{}
+	lock      sync.Mutex
+}
+
+func NewMonitorCollector(config *Config, store *system.MonitorStore) *MonitorCollector {
+	return &MonitorCollector{
+		Config:   config,
+		Interval: config.MonitorInterval,
+		Store:    store,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start 开始后台采集
+func (this_ *MonitorCollector) Start() {
+	go this_.run()
+}
+
+// Stop 停止采集并关闭连接
+func (this_ *MonitorCollector) Stop() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.isStopped {
+		return
+	}
+	this_.isStopped = true
+	close(this_.stopChan)
+	if this_.client != nil {
+		_ = this_.client.Close()
+		this_.client = nil
+	}
+}
+
+// GetLastError 最近一次连接或采集的异常，采集正常时为空
+func (this_ *MonitorCollector) GetLastError() string {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	return this_.lastError
+}
+
+func (this_ *MonitorCollector) setLastError(lastError string) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.lastError = lastError
+}
+
+func (this_ *MonitorCollector) run() {
+	for {
+		err := this_.collect()
+		select {
+		case <-this_.stopChan:
+			return
+		default:
+		}
+		if err == nil {
+			err = errors.New("监控会话已结束")
+		}
+		util.Logger.Error("ssh monitor collect error", zap.Any("address", this_.Config.Address), zap.Error(err))
+		this_.setLastError(err.Error())
+
+		select {
+		case <-this_.stopChan:
+			return
+		case <-time.After(MonitorRetryInterval):
+		}
+	}
+}
+
+// collect 在一个会话中持续采集，直到会话结束或停止
+func (this_ *MonitorCollector) collect() (err error) {
+	client, err := NewClient(*this_.Config)
+	if err != nil {
+		return
+	}
+	this_.lock.Lock()
+	if this_.isStopped {
+		this_.lock.Unlock()
+		_ = client.Close()
+		return
+	}
+	this_.client = client
+	this_.lock.Unlock()
+	defer func() { _ = client.Close() }()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return
+	}
+	defer func() { _ = session.Close() }()
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return
+	}
+	interval := this_.Interval
+	if interval <= 0 {
+		interval = DefaultMonitorInterval
+	}
+	err = session.Start("while true; do\n" + monitorFrameScript + "sleep " + strconv.Itoa(interval) + "\ndone")
+	if err != nil {
+		return
+	}
+
+	sampler := newMonitorSampler()
+	err = readMonitorFrames(stdout, func(frame map[string]string) {
+		monitorData, e := sampler.sample(frame, time.Now())
+		if e != nil {
+			this_.setLastError(e.Error())
+			return
+		}
+		if monitorData != nil {
+			this_.setLastError("")
+			this_.Store.Append(monitorData)
+		}
+	})
+	return
+}
@@ -0,0 +1,97 @@
+package ssh
+
+import (
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"teamide/pkg/system"
+	"testing"
+	"time"
+)
+
+func TestParseProcNetDev(t *testing.T) {
+	text := `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:  1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+  eth0: 52000     400    1    2    0     0          0         0    31000     300    3    4    0     0       0          0`
+	list, err := ParseProcNetDev(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[1].Name != "eth0" || list[1].BytesRecv != 52000 || list[1].BytesSent != 31000 ||
+		list[1].Errin != 1 || list[1].Dropout != 4 {
+		t.Fatalf("net dev error: %+v", list)
+	}
+}
+
+func TestMonitorSampler(t *testing.T) {
+	frame := func(uptime string, busy int, recv int) string {
+		return monitorDumpMark + "uptime\n" + uptime + " 100.00\n" +
+			monitorDumpMark + "stat\ncpu  " + strconv.Itoa(busy) + " 0 0 " + strconv.Itoa(busy*2) + " 0 0 0 0 0 0\ncpu0 " + strconv.Itoa(busy) + " 0 0 " + strconv.Itoa(busy) + " 0 0 0 0 0 0\ncpu1 0 0 0 " + strconv.Itoa(busy) + " 0 0 0 0 0 0\nintr 1\n" +
+			monitorDumpMark + "meminfo\nMemTotal: 1000 kB\nMemFree: 250 kB\nMemAvailable: 500 kB\n" +
+			monitorDumpMark + "diskstats\n" +
+			monitorDumpMark + "netdev\n  eth0: " + strconv.Itoa(recv) + " 1 0 0 0 0 0 0 2000 1 0 0 0 0 0 0\n" +
+			monitorDumpMark + "end\n"
+	}
+	text := "noise\n" + frame("100.00", 100, 1000) + frame("102.00", 200, 5000)
+
+	sampler := newMonitorSampler()
+	var list []*system.MonitorData
+	now := time.Now()
+	err := readMonitorFrames(strings.NewReader(text), func(frame map[string]string) {
+		// 读取延迟不影响速度的计算
+		now = now.Add(10 * time.Second)
+		monitorData, e := sampler.sample(frame, now)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if monitorData != nil {
+			list = append(list, monitorData)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatal("first frame should be baseline:", len(list))
+	}
+	monitorData := list[0]
+	if len(monitorData.CpuPercents) != 2 || monitorData.CpuPercents[0] != 50 || monitorData.CpuPercents[1] != 0 {
+		t.Fatal("cpu percents error:", monitorData.CpuPercents)
+	}
+	if monitorData.VirtualMemoryStat.Total != 1000*1024 || monitorData.VirtualMemoryStat.Available != 500*1024 {
+		t.Fatalf("memory error: %+v", monitorData.VirtualMemoryStat)
+	}
+	if len(monitorData.NetIOCountersStats) != 1 || monitorData.NetIOCountersStats[0].SpeedRecv != 2000 {
+		t.Fatalf("net speed error: %+v", monitorData.NetIOCountersStats)
+	}
+}
+
+// TestMonitorFrameScript 在本机执行监控脚本，校验解析结果
+func TestMonitorFrameScript(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("monitor script reads /proc")
+	}
+	bs, err := exec.Command("sh", "-c", monitorFrameScript+"sleep 1\n"+monitorFrameScript).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sampler := newMonitorSampler()
+	var res *system.MonitorData
+	err = readMonitorFrames(strings.NewReader(string(bs)), func(frame map[string]string) {
+		monitorData, e := sampler.sample(frame, time.Now())
+		if e != nil {
+			t.Fatal(e)
+		}
+		if monitorData != nil {
+			res = monitorData
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res == nil || len(res.CpuPercents) == 0 || res.VirtualMemoryStat.Total == 0 || len(res.NetIOCountersStats) == 0 {
+		t.Fatalf("monitor data error: %+v", res)
+	}
+}
@@ -0,0 +1,199 @@
+package ssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"sync"
+	"teamide/pkg/base"
+	"time"
+)
+
+// SSH 连接池
+//
+// 终端、文件管理、数据库等通过 SSH 隧道的连接按连接配置共用一个 SSH 连接，每个使用方在连接上打开各自的会话或通道；
+// 使用方用完后调用 release 减少引用，没有引用超过 PoolIdleTimeout 后关闭连接；
+// 每 PoolCheckInterval 对池中的连接发送 keepalive 请求，没有响应的连接断开并从池中移除，之后获取时重新连接。
+
+var (
+	// PoolIdleTimeout 连接没有引用后保留的时间
+	PoolIdleTimeout = 5 * time.Minute
+	// PoolCheckInterval 检查空闲和健康的间隔，也是 keepalive 请求的超时时间
+	PoolCheckInterval = 30 * time.Second
+)
+
+// HostKeyStoreKey 主机公钥存储实现该接口时，连接池按其返回值区分连接，如不同用户已信任的主机公钥不同，不能共用连接；
+// 未实现时每个主机公钥存储的连接都不共用
+type HostKeyStoreKey interface {
+	GetStoreKey() string
+}
+
+type poolClient struct {
+	key         string
+	client      *ssh.Client
+	err         error
+	ready       chan struct{}
+	refCount    int
+	lastUseTime time.Time
+}
+
+var (
+	poolCache       = make(map[string]*poolClient)
+	poolCacheLock   = &sync.Mutex{}
+	poolCheckerOnce sync.Once
+)
+
+// GetPoolClient 获取连接池中的连接，没有可用的连接时创建；使用方不能关闭返回的连接，用完后调用 release，release 可以多次调用
+func GetPoolClient(config Config) (client *ssh.Client, release func(), err error) {
+	poolCheckerOnce.Do(func() {
+		go startPoolChecker()
+	})
+
+	key := GetPoolKey(config)
+	poolCacheLock.Lock()
+	find := poolCache[key]
+	if find == nil {
+		find = &poolClient{
+			key:   key,
+			ready: make(chan struct{}),
+		}
+		poolCache[key] = find
+		go find.connect(config)
+	}
+	find.refCount++
+	poolCacheLock.Unlock()
+
+	// 同一配置同时获取时只创建一个连接
+	<-find.ready
+	if find.err != nil {
+		err = find.err
+		return
+	}
+	client = find.client
+
+	var once sync.Once
+	release = func() {
+		once.Do(find.release)
+	}
+	return
+}
+
+// GetPoolKey 连接池的 key，由影响连接的配置组成，密码等取 MD5
+func GetPoolKey(config Config) (key string) {
+	bs, _ := json.Marshal(getPoolKeyConfig(config))
+	key = config.Username + "@" + config.Address + "-" + base.GetMd5String(string(bs))
+	return
+}
+
+func getPoolKeyConfig(config Config) (res map[string]interface{}) {
+	res = map[string]interface{}{
+		"type":        config.Type,
+		"address":     config.Address,
+		"username":    config.Username,
+		"password":    config.Password,
+		"publicKey":   config.PublicKey,
+		"certificate": config.Certificate,
+		"agent":       config.Agent,
+		"timeout":     config.Timeout,
+	}
+	if config.HostKeyStore != nil {
+		if storeKey, ok := config.HostKeyStore.(HostKeyStoreKey); ok {
+			res["hostKeyStore"] = storeKey.GetStoreKey()
+		} else {
+			res["hostKeyStore"] = fmt.Sprintf("%p", config.HostKeyStore)
+		}
+	}
+	var jumpHosts []map[string]interface{}
+	for _, jumpHost := range config.JumpHosts {
+		if jumpHost == nil {
+			continue
+		}
+		jumpHosts = append(jumpHosts, getPoolKeyConfig(*jumpHost))
+	}
+	res["jumpHosts"] = jumpHosts
+	return
+}
+
+func (this_ *poolClient) connect(config Config) {
+	client, err := NewClient(config)
+	poolCacheLock.Lock()
+	this_.client, this_.err = client, err
+	if err != nil {
+		this_.remove()
+	}
+	poolCacheLock.Unlock()
+	close(this_.ready)
+	if err != nil {
+		util.Logger.Error("SSH pool client connect error", zap.Any("address", config.Address), zap.Error(err))
+		return
+	}
+	util.Logger.Info("SSH pool client connect success", zap.Any("address", config.Address))
+
+	go func() {
+		_ = this_.client.Wait()
+		util.Logger.Info("SSH pool client end", zap.Any("address", config.Address))
+		poolCacheLock.Lock()
+		this_.remove()
+		poolCacheLock.Unlock()
+	}()
+}
+
+// remove 从池中移除，需要持有 poolCacheLock
+func (this_ *poolClient) remove() {
+	if poolCache[this_.key] == this_ {
+		delete(poolCache, this_.key)
+	}
+}
+
+func (this_ *poolClient) release() {
+	poolCacheLock.Lock()
+	defer poolCacheLock.Unlock()
+
+	this_.refCount--
+	this_.lastUseTime = time.Now()
+}
+
+func startPoolChecker() {
+	for {
+		time.Sleep(PoolCheckInterval)
+		checkPoolClients()
+	}
+}
+
+// checkPoolClients 关闭空闲的连接，其它连接发送 keepalive 请求，没有响应时断开
+func checkPoolClients() {
+	var idleList []*poolClient
+	var checkList []*poolClient
+	poolCacheLock.Lock()
+	for _, one := range poolCache {
+		if one.client == nil {
+			continue
+		}
+		if one.refCount <= 0 && time.Since(one.lastUseTime) >= PoolIdleTimeout {
+			one.remove()
+			idleList = append(idleList, one)
+			continue
+		}
+		checkList = append(checkList, one)
+	}
+	poolCacheLock.Unlock()
+
+	for _, one := range idleList {
+		util.Logger.Info("SSH pool client idle close", zap.Any("key", one.key))
+		_ = one.client.Close()
+	}
+	var wait sync.WaitGroup
+	for _, one := range checkList {
+		wait.Add(1)
+		go func(one *poolClient) {
+			defer wait.Done()
+			if err := keepAlive(one.client, PoolCheckInterval); err != nil {
+				util.Logger.Warn("SSH pool client keepalive error", zap.Any("key", one.key), zap.Error(err))
+				_ = one.client.Close()
+			}
+		}(one)
+	}
+	wait.Wait()
+}
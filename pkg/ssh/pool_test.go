@@ -0,0 +1,104 @@
+package ssh
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPoolClient(t *testing.T) {
+	server := newTestServer(t, "pool")
+	config := server.Config()
+
+	// 同时获取只创建一个连接，多个会话共用
+	var wait sync.WaitGroup
+	var releases = make([]func(), 5)
+	for i := range releases {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			client, release, err := GetPoolClient(*config)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			releases[i] = release
+			if name := testExec(t, client); name != "pool" {
+				t.Error("exec error:", name)
+			}
+		}(i)
+	}
+	wait.Wait()
+	if t.Failed() {
+		return
+	}
+	if server.ConnCount() != 1 {
+		t.Fatal("conn count should be 1:", server.ConnCount())
+	}
+
+	// 密码不同不共用
+	wrong := server.Config()
+	wrong.Password = "wrong"
+	if _, _, err := GetPoolClient(*wrong); err == nil {
+		t.Fatal("wrong password should fail")
+	}
+
+	// 还有引用时不关闭
+	for _, release := range releases[1:] {
+		release()
+		release()
+	}
+	checkPoolClients()
+	if server.ConnCount() != 1 {
+		t.Fatal("conn should not close:", server.ConnCount())
+	}
+
+	// 连接断开后从池中移除，再次获取时重新连接
+	server.CloseConns()
+	waitPool(t, func() bool { return getPoolRefCount(GetPoolKey(*config)) < 0 })
+	releases[0]()
+	client, release, err := GetPoolClient(*config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := testExec(t, client); name != "pool" {
+		t.Fatal("exec error:", name)
+	}
+	release()
+
+	// 没有引用超过空闲时间后关闭
+	checkPoolClients()
+	if server.ConnCount() != 1 {
+		t.Fatal("conn should not close before idle timeout:", server.ConnCount())
+	}
+	idleTimeout := PoolIdleTimeout
+	PoolIdleTimeout = 0
+	defer func() { PoolIdleTimeout = idleTimeout }()
+	checkPoolClients()
+	waitPool(t, func() bool { return server.ConnCount() == 0 })
+	if getPoolRefCount(GetPoolKey(*config)) >= 0 {
+		t.Fatal("idle client should remove from pool")
+	}
+}
+
+// getPoolRefCount 池中连接的引用数量，不存在时返回 -1
+func getPoolRefCount(key string) int {
+	poolCacheLock.Lock()
+	defer poolCacheLock.Unlock()
+
+	find := poolCache[key]
+	if find == nil {
+		return -1
+	}
+	return find.refCount
+}
+
+func waitPool(t *testing.T, ok func() bool) {
+	for i := 0; i < 100; i++ {
+		if ok() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("wait timeout")
+}
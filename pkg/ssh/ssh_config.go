@@ -0,0 +1,394 @@
+package ssh
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenSSH 客户端配置（ssh_config）解析
+//
+// 每个不含通配符的 Host 别名解析为一个主机，按 OpenSSH 的规则从上到下匹配所有 Host 块（包括通配符和否定模式），
+// 每个配置项取第一次出现的值，转发配置累加；Include 的文件按出现的位置展开，Match 块不支持，跳过并记录警告。
+// 文件路径中的 ~ 不展开，相对路径按 ~/.ssh 拼接，由 SSHConfigReader 和使用方按实际的目录读取。
+
+// SSHConfigMaxIncludeDepth Include 最大嵌套层数，与 OpenSSH 一致
+var SSHConfigMaxIncludeDepth = 16
+
+// SSHConfigFile ssh_config 文件，Path 为 ~/.ssh 下的相对路径或绝对路径
+type SSHConfigFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// SSHConfigReader 读取 Include 匹配的文件，pattern 的相对路径已转为 ~/.ssh/ 开头
+type SSHConfigReader func(pattern string) (files []*SSHConfigFile, err error)
+
+// SSHConfigHost Host 别名解析后的配置
+type SSHConfigHost struct {
+	Alias           string           `json:"alias"`
+	HostName        string           `json:"hostName"`
+	User            string           `json:"user,omitempty"`
+	Port            int              `json:"port,omitempty"`
+	IdentityFile    string           `json:"identityFile,omitempty"`    // 第一个私钥文件
+	CertificateFile string           `json:"certificateFile,omitempty"` // 第一个用户证书文件
+	IdentityAgent   string           `json:"identityAgent,omitempty"`   // none、SSH_AUTH_SOCK 或 Agent 地址
+	ProxyJump       []string         `json:"proxyJump,omitempty"`       // 跳板机，[user@]host[:port] 或 Host 别名
+	ConnectTimeout  int              `json:"connectTimeout,omitempty"`
+	Forwards        []*ForwardConfig `json:"forwards,omitempty"`
+	File            string           `json:"file"` // 定义 Host 别名的文件
+}
+
+// GetAddress 连接地址
+func (this_ *SSHConfigHost) GetAddress() string {
+	port := this_.Port
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(this_.HostName, strconv.Itoa(port))
+}
+
+type SSHConfigResult struct {
+	Hosts    []*SSHConfigHost `json:"hosts"`
+	Warnings []string         `json:"warnings,omitempty"`
+}
+
+type sshConfigOption struct {
+	key  string // 小写
+	args []string
+	line string // 文件和行号，用于警告
+}
+
+type sshConfigBlock struct {
+	patterns []string // 为空表示 Match 块，不匹配任何主机
+	file     string
+	options  []*sshConfigOption
+}
+
+type sshConfigParser struct {
+	reader   SSHConfigReader
+	blocks   []*sshConfigBlock
+	warnings []string
+}
+
+// ParseSSHConfig 解析 ssh_config，reader 为空时 Include 记录警告并跳过
+func ParseSSHConfig(file *SSHConfigFile, reader SSHConfigReader) (res *SSHConfigResult, err error) {
+	parser := &sshConfigParser{
+		reader: reader,
+	}
+	current := &sshConfigBlock{patterns: []string{"*"}, file: file.Path}
+	parser.blocks = append(parser.blocks, current)
+	_, err = parser.parse(file, current, 0)
+	if err != nil {
+		return
+	}
+	res = &SSHConfigResult{}
+
+	var aliasCache = make(map[string]bool)
+	for _, block := range parser.blocks {
+		for _, pattern := range block.patterns {
+			if strings.ContainsAny(pattern, "*?!") || aliasCache[pattern] {
+				continue
+			}
+			aliasCache[pattern] = true
+			res.Hosts = append(res.Hosts, parser.resolve(pattern, block.file))
+		}
+	}
+	res.Warnings = parser.warnings
+	return
+}
+
+// parse 解析文件，Host 行开始新的块，返回最后的块；Include 的文件结束后回到原来的块
+func (this_ *sshConfigParser) parse(file *SSHConfigFile, current *sshConfigBlock, depth int) (last *sshConfigBlock, err error) {
+	last = current
+	lines := strings.Split(strings.ReplaceAll(file.Content, "\r\n", "\n"), "\n")
+	for index, line := range lines {
+		key, args, e := splitSSHConfigLine(line)
+		lineInfo := file.Path + ":" + strconv.Itoa(index+1)
+		if e != nil {
+			err = errors.New(lineInfo + " " + e.Error())
+			return
+		}
+		if key == "" {
+			continue
+		}
+		switch key {
+		case "host":
+			last = &sshConfigBlock{patterns: args, file: file.Path}
+			this_.blocks = append(this_.blocks, last)
+		case "match":
+			this_.warnings = append(this_.warnings, lineInfo+" 不支持 Match，已跳过该块")
+			last = &sshConfigBlock{file: file.Path}
+			this_.blocks = append(this_.blocks, last)
+		case "include":
+			if depth >= SSHConfigMaxIncludeDepth {
+				err = errors.New(lineInfo + " Include 嵌套超过" + strconv.Itoa(SSHConfigMaxIncludeDepth) + "层")
+				return
+			}
+			if this_.reader == nil {
+				this_.warnings = append(this_.warnings, lineInfo+" 无法读取 Include 的文件，已跳过")
+				continue
+			}
+			for _, pattern := range args {
+				if !strings.HasPrefix(pattern, "/") && !strings.HasPrefix(pattern, "~") {
+					pattern = "~/.ssh/" + pattern
+				}
+				var files []*SSHConfigFile
+				files, err = this_.reader(pattern)
+				if err != nil {
+					return
+				}
+				if len(files) == 0 {
+					this_.warnings = append(this_.warnings, lineInfo+" Include 的文件["+pattern+"]不存在")
+				}
+				for _, one := range files {
+					var includeLast *sshConfigBlock
+					includeLast, err = this_.parse(one, last, depth+1)
+					if err != nil {
+						return
+					}
+					if includeLast != last {
+						// Include 的文件中有 Host 时，之后的配置属于原来的块
+						last = &sshConfigBlock{patterns: last.patterns, file: last.file}
+						this_.blocks = append(this_.blocks, last)
+					}
+				}
+			}
+		default:
+			last.options = append(last.options, &sshConfigOption{key: key, args: args, line: lineInfo})
+		}
+	}
+	return
+}
+
+// splitSSHConfigLine 拆分配置行，关键字与参数之间可以是空白或 =，参数支持双引号
+func splitSSHConfigLine(line string) (key string, args []string, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		key = strings.ToLower(line)
+		return
+	}
+	key = strings.ToLower(line[:end])
+	rest := strings.TrimLeft(line[end:], " \t")
+	if strings.HasPrefix(rest, "=") {
+		rest = strings.TrimLeft(rest[1:], " \t")
+	}
+	for rest != "" {
+		var arg string
+		if rest[0] == '"' {
+			closeIndex := strings.IndexByte(rest[1:], '"')
+			if closeIndex < 0 {
+				err = errors.New("引号未闭合")
+				return
+			}
+			arg = rest[1 : closeIndex+1]
+			rest = rest[closeIndex+2:]
+		} else {
+			end = strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			arg = rest[:end]
+			rest = rest[end:]
+		}
+		if strings.HasPrefix(arg, "#") {
+			break
+		}
+		args = append(args, arg)
+		rest = strings.TrimLeft(rest, " \t")
+	}
+	return
+}
+
+// matchSSHConfigHost 任意一个模式匹配并且没有否定模式匹配
+func matchSSHConfigHost(patterns []string, host string) (match bool) {
+	for _, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		if negate {
+			pattern = pattern[1:]
+		}
+		expr := "^" + strings.ReplaceAll(strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*"), `\?`, ".") + "$"
+		if !regexp.MustCompile(expr).MatchString(host) {
+			continue
+		}
+		if negate {
+			return false
+		}
+		match = true
+	}
+	return
+}
+
+// resolve 按顺序匹配所有块，每个配置项取第一次出现的值
+func (this_ *sshConfigParser) resolve(alias string, file string) (host *SSHConfigHost) {
+	host = &SSHConfigHost{
+		Alias: alias,
+		File:  file,
+	}
+	var setCache = make(map[string]bool)
+	var options []*sshConfigOption
+	for _, block := range this_.blocks {
+		if !matchSSHConfigHost(block.patterns, alias) {
+			continue
+		}
+		for _, option := range block.options {
+			if len(option.args) == 0 {
+				continue
+			}
+			switch option.key {
+			case "localforward", "remoteforward", "dynamicforward":
+			default:
+				if setCache[option.key] {
+					continue
+				}
+				setCache[option.key] = true
+			}
+			options = append(options, option)
+		}
+	}
+
+	// HostName 和 User 先确定，用于其它配置中的 %h、%r
+	for _, option := range options {
+		switch option.key {
+		case "hostname":
+			host.HostName = strings.ReplaceAll(option.args[0], "%h", alias)
+		case "user":
+			host.User = option.args[0]
+		}
+	}
+	if host.HostName == "" {
+		host.HostName = alias
+	}
+	for _, option := range options {
+		arg := option.args[0]
+		switch option.key {
+		case "port":
+			port, e := strconv.Atoi(arg)
+			if e != nil || port < 1 || port > 65535 {
+				this_.warnings = append(this_.warnings, option.line+" 端口["+arg+"]错误")
+				continue
+			}
+			host.Port = port
+		case "identityfile":
+			host.IdentityFile = host.expandToken(arg)
+		case "certificatefile":
+			host.CertificateFile = host.expandToken(arg)
+		case "identityagent":
+			host.IdentityAgent = host.expandToken(arg)
+		case "connecttimeout":
+			host.ConnectTimeout, _ = strconv.Atoi(arg)
+		case "proxyjump":
+			if strings.ToLower(arg) == "none" {
+				continue
+			}
+			for _, one := range strings.Split(arg, ",") {
+				if one = strings.TrimSpace(one); one != "" {
+					host.ProxyJump = append(host.ProxyJump, strings.TrimPrefix(one, "ssh://"))
+				}
+			}
+		case "proxycommand":
+			if strings.ToLower(arg) != "none" {
+				this_.warnings = append(this_.warnings, option.line+" ["+alias+"]不支持 ProxyCommand，请改为 ProxyJump")
+			}
+		case "localforward", "remoteforward", "dynamicforward":
+			forward, e := parseSSHConfigForward(option.key, option.args)
+			if e != nil {
+				this_.warnings = append(this_.warnings, option.line+" ["+alias+"] "+e.Error())
+				continue
+			}
+			host.Forwards = append(host.Forwards, forward)
+		}
+	}
+	return
+}
+
+// expandToken 替换 %d、%h、%r、%u、%%，~ 保留
+func (this_ *SSHConfigHost) expandToken(value string) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '%' || i == len(value)-1 {
+			builder.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'd':
+			builder.WriteString("~")
+		case 'h':
+			builder.WriteString(this_.HostName)
+		case 'r':
+			builder.WriteString(this_.User)
+		case 'u':
+			builder.WriteString(os.Getenv("USER"))
+		case '%':
+			builder.WriteByte('%')
+		default:
+			builder.WriteByte('%')
+			builder.WriteByte(value[i])
+		}
+	}
+	return builder.String()
+}
+
+// parseSSHConfigForward 解析 [bind_address:]port [host:hostport]，未指定监听地址时监听 127.0.0.1
+func parseSSHConfigForward(key string, args []string) (forward *ForwardConfig, err error) {
+	forward = &ForwardConfig{
+		Open: true,
+	}
+	switch key {
+	case "localforward":
+		forward.Type = ForwardTypeLocal
+	case "remoteforward":
+		forward.Type = ForwardTypeRemote
+	default:
+		forward.Type = ForwardTypeDynamic
+	}
+	if strings.Contains(args[0], "/") {
+		err = errors.New("不支持 Unix Socket 转发")
+		return
+	}
+	forward.BindAddress = args[0]
+	if _, _, e := net.SplitHostPort(forward.BindAddress); e != nil {
+		forward.BindAddress = net.JoinHostPort("127.0.0.1", forward.BindAddress)
+	} else if strings.HasPrefix(forward.BindAddress, "*:") {
+		forward.BindAddress = "0.0.0.0" + forward.BindAddress[1:]
+	}
+	if forward.Type != ForwardTypeDynamic {
+		if len(args) < 2 {
+			err = errors.New(key + " 缺少目标地址")
+			return
+		}
+		forward.TargetAddress = args[1]
+	}
+	err = forward.Check()
+	return
+}
+
+// NewSSHConfigFilesReader 从上传的文件中读取 Include 的文件，文件路径按 ~/.ssh 下的相对路径匹配
+func NewSSHConfigFilesReader(files []*SSHConfigFile) SSHConfigReader {
+	return func(pattern string) (res []*SSHConfigFile, err error) {
+		for _, one := range files {
+			filePath := filepath.ToSlash(one.Path)
+			if !strings.HasPrefix(filePath, "/") && !strings.HasPrefix(filePath, "~") {
+				filePath = "~/.ssh/" + filePath
+			}
+			if match, _ := path.Match(pattern, filePath); match {
+				res = append(res, one)
+			}
+		}
+		sort.Slice(res, func(i, j int) bool {
+			return res[i].Path < res[j].Path
+		})
+		return
+	}
+}
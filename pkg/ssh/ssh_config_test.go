@@ -0,0 +1,156 @@
+package ssh
+
+import (
+	"testing"
+)
+
+func TestParseSSHConfig(t *testing.T) {
+	main := &SSHConfigFile{
+		Path: "config",
+		Content: `# 全局配置
+Include config.d/*
+ConnectTimeout 10
+
+Host bastion
+    HostName 10.0.0.1
+    User jump
+    Port 2222
+
+Host web-1 web-2
+    HostName %h.internal
+    ProxyJump bastion
+    LocalForward 8080 127.0.0.1:80
+    DynamicForward 1080
+
+Host web-*
+    User deploy
+    IdentityFile ~/.ssh/%r_key
+    User ignored
+
+Match host db
+    User ignored
+
+Host "quoted"
+    User=root
+    ProxyCommand ssh -W %h:%p bastion
+
+Host * !bastion
+    IdentityFile ~/.ssh/id_default
+`,
+	}
+	work := &SSHConfigFile{
+		Path: "config.d/work",
+		Content: `Host db
+    HostName 10.0.1.5
+    ProxyJump admin@10.0.0.1:2222,bastion
+    RemoteForward 9000 localhost:3000
+`,
+	}
+	res, err := ParseSSHConfig(main, NewSSHConfigFilesReader([]*SSHConfigFile{main, work}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hosts = make(map[string]*SSHConfigHost)
+	for _, one := range res.Hosts {
+		hosts[one.Alias] = one
+	}
+	if len(res.Hosts) != 5 || res.Hosts[0].Alias != "db" {
+		t.Fatalf("hosts error: %+v", res.Hosts)
+	}
+
+	db := hosts["db"]
+	if db.File != "config.d/work" || db.GetAddress() != "10.0.1.5:22" || db.User != "" || db.ConnectTimeout != 10 {
+		t.Fatalf("db error: %+v", db)
+	}
+	if len(db.ProxyJump) != 2 || db.ProxyJump[0] != "admin@10.0.0.1:2222" || db.ProxyJump[1] != "bastion" {
+		t.Fatal("db proxy jump error:", db.ProxyJump)
+	}
+	if len(db.Forwards) != 1 || db.Forwards[0].Type != ForwardTypeRemote || db.Forwards[0].BindAddress != "127.0.0.1:9000" {
+		t.Fatalf("db forward error: %+v", db.Forwards)
+	}
+
+	bastion := hosts["bastion"]
+	if bastion.GetAddress() != "10.0.0.1:2222" || bastion.User != "jump" || bastion.IdentityFile != "" {
+		t.Fatalf("bastion error: %+v", bastion)
+	}
+
+	web := hosts["web-2"]
+	if web.HostName != "web-2.internal" || web.User != "deploy" || web.IdentityFile != "~/.ssh/deploy_key" {
+		t.Fatalf("web error: %+v", web)
+	}
+	if len(web.Forwards) != 2 || web.Forwards[0].BindAddress != "127.0.0.1:8080" || web.Forwards[0].TargetAddress != "127.0.0.1:80" ||
+		web.Forwards[1].Type != ForwardTypeDynamic || web.Forwards[1].BindAddress != "127.0.0.1:1080" {
+		t.Fatalf("web forward error: %+v %+v", web.Forwards[0], web.Forwards[1])
+	}
+
+	quoted := hosts["quoted"]
+	if quoted.User != "root" || quoted.IdentityFile != "~/.ssh/id_default" {
+		t.Fatalf("quoted error: %+v", quoted)
+	}
+	// Match 和 ProxyCommand 的警告
+	if len(res.Warnings) != 2 {
+		t.Fatal("warnings error:", res.Warnings)
+	}
+}
+
+func TestParseSSHConfigForward(t *testing.T) {
+	for _, one := range []struct {
+		key           string
+		args          []string
+		bindAddress   string
+		targetAddress string
+		isErr         bool
+	}{
+		{"localforward", []string{"8080", "127.0.0.1:80"}, "127.0.0.1:8080", "127.0.0.1:80", false},
+		{"localforward", []string{"localhost:8080", "db:3306"}, "localhost:8080", "db:3306", false},
+		{"localforward", []string{"8080"}, "", "", true},
+		{"localforward", []string{"/tmp/a.sock", "127.0.0.1:80"}, "", "", true},
+		{"remoteforward", []string{"9000", "localhost:3000"}, "127.0.0.1:9000", "localhost:3000", false},
+		{"remoteforward", []string{"0.0.0.0:9000", "localhost:3000"}, "0.0.0.0:9000", "localhost:3000", false},
+		{"dynamicforward", []string{"1080"}, "127.0.0.1:1080", "", false},
+		{"dynamicforward", []string{"[::1]:1080"}, "[::1]:1080", "", false},
+		// 本地监听非回环地址需要服务端配置允许
+		{"dynamicforward", []string{"0.0.0.0:1080"}, "", "", true},
+		{"dynamicforward", []string{"*:1080"}, "", "", true},
+	} {
+		forward, err := parseSSHConfigForward(one.key, one.args)
+		if one.isErr {
+			if err == nil {
+				t.Errorf("%s %v should be error", one.key, one.args)
+			}
+			continue
+		}
+		if err != nil || forward.BindAddress != one.bindAddress || forward.TargetAddress != one.targetAddress || !forward.Open {
+			t.Errorf("%s %v error: %+v %v", one.key, one.args, forward, err)
+		}
+	}
+}
+
+func TestParseSSHConfigInclude(t *testing.T) {
+	main := &SSHConfigFile{
+		Path: "config",
+		Content: `Host outer
+    Include conf.d/*.conf
+    Port 2300
+`,
+	}
+	included := &SSHConfigFile{Path: "conf.d/a.conf", Content: "User included\nHost inner\n  Port 2200\n"}
+	res, err := ParseSSHConfig(main, NewSSHConfigFilesReader([]*SSHConfigFile{main, included}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Hosts) != 2 {
+		t.Fatalf("hosts error: %+v", res.Hosts)
+	}
+	// Include 文件中 Host 之前的配置属于 outer，文件结束后回到 outer
+	outer, inner := res.Hosts[0], res.Hosts[1]
+	if outer.User != "included" || outer.Port != 2300 || inner.Port != 2200 || inner.User != "" {
+		t.Fatalf("include error: %+v %+v", outer, inner)
+	}
+
+	// 嵌套 Include 自身时报错
+	loop := &SSHConfigFile{Path: "config", Content: "Include config\n"}
+	if _, err = ParseSSHConfig(loop, NewSSHConfigFilesReader([]*SSHConfigFile{loop})); err == nil {
+		t.Fatal("include loop should error")
+	}
+}
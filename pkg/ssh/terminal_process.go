@@ -0,0 +1,197 @@
+package ssh
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"teamide/pkg/system"
+)
+
+// processDumpMark 进程信息脚本输出的分段标记
+const processDumpMark = "#teamide:"
+
+// processDumpScript 一次会话中读取进程信息，两次读取 stat 间隔 1 秒用于计算 CPU 使用率
+var processDumpScript = `M='` + processDumpMark + `'
+echo "${M}clk"; getconf CLK_TCK 2>/dev/null
+echo "${M}btime"; grep '^btime' /proc/stat
+echo "${M}meminfo"; grep '^MemTotal' /proc/meminfo
+echo "${M}stat1"; cat /proc/uptime; cat /proc/[0-9]*/stat 2>/dev/null
+sleep 1
+echo "${M}stat2"; cat /proc/uptime; cat /proc/[0-9]*/stat 2>/dev/null
+echo "${M}passwd"; cat /etc/passwd 2>/dev/null
+for f in tcp tcp6 udp udp6; do echo "${M}net $f"; cat /proc/net/$f 2>/dev/null; done
+for d in /proc/[0-9]*; do
+  echo "${M}pid ${d#/proc/}"
+  grep -E '^(Uid|VmRSS):' $d/status 2>/dev/null
+  echo "cmdline: $(tr '\0' ' ' 2>/dev/null < $d/cmdline)"
+  echo "sockets: $(ls -l $d/fd 2>/dev/null | sed -n 's/.*socket:\[\([0-9]*\)\].*/\1/p' | tr '\n' ' ')"
+done
+exit 0
+`
+
+func (this_ *terminalService) SystemProcessList(request *system.ProcessQueryRequest) (res *system.ProcessQueryResponse, err error) {
+	text, err := this_.runCmd(processDumpScript)
+	if err != nil {
+		return
+	}
+	res = system.FilterProcessList(parseProcessDump(text), request)
+	return
+}
+
+func (this_ *terminalService) SystemProcessSignal(request *system.ProcessSignalRequest) (err error) {
+	if request == nil || request.Pid <= 0 {
+		err = errors.New("进程ID错误")
+		return
+	}
+	signal, err := system.FormatProcessSignal(request.Signal)
+	if err != nil {
+		return
+	}
+	if this_.sshClient == nil {
+		err = errors.New("ssh client is null")
+		return
+	}
+	s, err := this_.sshClient.NewSession()
+	if err != nil {
+		return
+	}
+	defer func() { _ = s.Close() }()
+	bs, err := s.CombinedOutput("kill -s " + signal + " " + strconv.Itoa(int(request.Pid)))
+	if err != nil {
+		if msg := strings.TrimSpace(string(bs)); msg != "" {
+			err = errors.New(msg)
+		}
+		return
+	}
+	return
+}
+
+// parseProcessDump 解析 processDumpScript 的输出
+func parseProcessDump(text string) (list []*system.ProcessStat) {
+	var clkTck float64 = 100
+	var bootTime, memTotal uint64
+	var uptime1, uptime2 float64
+	stat1 := make(map[int32]*ProcPidStat)
+	var stat2 []*ProcPidStat
+	var passwd strings.Builder
+	netTexts := make(map[string]*strings.Builder)
+	pidTexts := make(map[int32]*strings.Builder)
+
+	var section, sectionArg string
+	var sectionLine int
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, processDumpMark) {
+			section, sectionArg, _ = strings.Cut(strings.TrimPrefix(line, processDumpMark), " ")
+			sectionLine = 0
+			switch section {
+			case "net":
+				netTexts[sectionArg] = &strings.Builder{}
+			case "pid":
+				if pid, e := strconv.ParseInt(sectionArg, 10, 32); e == nil {
+					pidTexts[int32(pid)] = &strings.Builder{}
+				}
+			}
+			continue
+		}
+		sectionLine++
+		fields := strings.Fields(line)
+		switch section {
+		case "clk":
+			if v, e := strconv.ParseFloat(strings.TrimSpace(line), 64); e == nil && v > 0 {
+				clkTck = v
+			}
+		case "btime":
+			if len(fields) == 2 {
+				bootTime, _ = strconv.ParseUint(fields[1], 10, 64)
+			}
+		case "meminfo":
+			if len(fields) >= 2 {
+				memTotal, _ = strconv.ParseUint(fields[1], 10, 64)
+				memTotal *= 1024
+			}
+		case "stat1", "stat2":
+			if sectionLine == 1 {
+				var uptime float64
+				if len(fields) > 0 {
+					uptime, _ = strconv.ParseFloat(fields[0], 64)
+				}
+				if section == "stat1" {
+					uptime1 = uptime
+				} else {
+					uptime2 = uptime
+				}
+				continue
+			}
+			stat, e := ParseProcPidStat(line)
+			if e != nil {
+				continue
+			}
+			if section == "stat1" {
+				stat1[stat.Pid] = stat
+			} else {
+				stat2 = append(stat2, stat)
+			}
+		case "passwd":
+			passwd.WriteString(line + "\n")
+		case "net":
+			if builder := netTexts[sectionArg]; builder != nil {
+				builder.WriteString(line + "\n")
+			}
+		case "pid":
+			if pid, e := strconv.ParseInt(sectionArg, 10, 32); e == nil && pidTexts[int32(pid)] != nil {
+				pidTexts[int32(pid)].WriteString(line + "\n")
+			}
+		}
+	}
+
+	userCache := ParseEtcPasswd(passwd.String())
+	listenCache := make(map[string]string)
+	for name, builder := range netTexts {
+		protocol := strings.TrimSuffix(name, "6")
+		socketList, _ := ParseProcNetSockets(builder.String(), protocol)
+		for _, socket := range socketList {
+			if socket.Listen && socket.Inode != "0" {
+				listenCache[socket.Inode] = socket.Address()
+			}
+		}
+	}
+
+	useTime := uptime2 - uptime1
+	for _, stat := range stat2 {
+		one := &system.ProcessStat{
+			Pid:        stat.Pid,
+			Ppid:       stat.Ppid,
+			Name:       stat.Name,
+			Status:     ConvertProcState(stat.State),
+			CreateTime: int64((float64(bootTime) + float64(stat.StartTime)/clkTck) * 1000),
+		}
+		if last := stat1[stat.Pid]; last != nil && useTime > 0 {
+			ticks := float64(stat.Utime+stat.Stime) - float64(last.Utime+last.Stime)
+			one.CpuPercent = ticks / clkTck / useTime * 100
+		}
+		if builder := pidTexts[stat.Pid]; builder != nil {
+			var uid string
+			for _, line := range strings.Split(builder.String(), "\n") {
+				if strings.HasPrefix(line, "cmdline:") {
+					one.Cmdline = strings.TrimSpace(strings.TrimPrefix(line, "cmdline:"))
+				} else if strings.HasPrefix(line, "sockets:") {
+					for _, inode := range strings.Fields(strings.TrimPrefix(line, "sockets:")) {
+						if address, find := listenCache[inode]; find {
+							one.PortList = append(one.PortList, address)
+						}
+					}
+				}
+			}
+			uid, one.Rss = ParseProcPidStatus(builder.String())
+			one.User = userCache[uid]
+			if one.User == "" {
+				one.User = uid
+			}
+		}
+		if memTotal > 0 {
+			one.MemoryPercent = float64(one.Rss) / float64(memTotal) * 100
+		}
+		list = append(list, one)
+	}
+	return
+}
@@ -30,20 +30,21 @@ func NewTerminalService(config *Config, lastUser string, lastDir string) (res *t
 }
 
 type terminalService struct {
-	config       *Config
-	sshClient    *ssh.Client
-	sshSession   *ssh.Session
-	stdout       io.Reader
-	stdin        io.Writer
-	onClose      func()
-	readeLock    sync.Mutex
-	readeErrLock sync.Mutex
-	writeLock    sync.Mutex
-	isStopped    bool
-	lastActive   time.Time
-	lastUser     string
-	lastDir      string
-	sftpClient   *sftp.Client
+	config        *Config
+	sshClient     *ssh.Client
+	releaseClient func()
+	sshSession    *ssh.Session
+	stdout        io.Reader
+	stdin         io.Writer
+	onClose       func()
+	readeLock     sync.Mutex
+	readeErrLock  sync.Mutex
+	writeLock     sync.Mutex
+	isStopped     bool
+	lastActive    time.Time
+	lastUser      string
+	lastDir       string
+	sftpClient    *sftp.Client
 }
 
 func (this_ *terminalService) IsWindows() (isWindows bool, err error) {
@@ -59,8 +60,8 @@ func (this_ *terminalService) Stop() {
 	if this_.sshSession != nil {
 		_ = this_.sshSession.Close()
 	}
-	if this_.sshClient != nil {
-		_ = this_.sshClient.Close()
+	if this_.releaseClient != nil {
+		this_.releaseClient()
 	}
 	if this_.stdout != nil {
 		if readerCloser, ok := this_.stdout.(io.ReadCloser); ok {
@@ -175,17 +176,13 @@ func (this_ *terminalService) fileExist(filepath string) (exist bool) {
 }
 func (this_ *terminalService) Start(size *terminal.Size) (err error) {
 
-	this_.sshClient, err = NewClient(*this_.config)
+	// 连接池中的连接与文件管理等共用，会话结束或连接断开时读取结束，由使用方停止
+	this_.sshClient, this_.releaseClient, err = GetPoolClient(*this_.config)
 	if err != nil {
-		util.Logger.Error("SSH NewClient error", zap.Error(err))
+		util.Logger.Error("SSH GetPoolClient error", zap.Error(err))
 		return
 	}
-	util.Logger.Info("SSH NewClient success", zap.Any("address", this_.config.Address))
-	go func() {
-		err = this_.sshClient.Wait()
-		this_.Stop()
-		util.Logger.Info("SSH Client end", zap.Any("address", this_.config.Address))
-	}()
+	util.Logger.Info("SSH GetPoolClient success", zap.Any("address", this_.config.Address))
 
 	this_.sshSession, err = this_.sshClient.NewSession()
 	if err != nil {
@@ -296,8 +293,28 @@ func (this_ *terminalService) SystemInfo() (res *system.Info, err error) {
 	return this_.Info()
 }
 
+// SystemMonitorData 同一会话中间隔 1 秒读取两次，计算当前的监控数据
 func (this_ *terminalService) SystemMonitorData() (res *system.MonitorData, err error) {
-	return system.GetCacheOrNew()
+	text, err := this_.runCmd(monitorFrameScript + "sleep 1\n" + monitorFrameScript)
+	if err != nil {
+		return
+	}
+	sampler := newMonitorSampler()
+	var sampleErr error
+	err = readMonitorFrames(strings.NewReader(text), func(frame map[string]string) {
+		monitorData, e := sampler.sample(frame, time.Now())
+		if e != nil {
+			sampleErr = e
+			return
+		}
+		if monitorData != nil {
+			res = monitorData
+		}
+	})
+	if err == nil {
+		err = sampleErr
+	}
+	return
 }
 
 func (this_ *terminalService) Info() (res *system.Info, err error) {
@@ -0,0 +1,271 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// testServer 测试用 SSH 服务，支持密码认证、exec（输出服务名称）、direct-tcpip 和 tcpip-forward 转发
+type testServer struct {
+	Name     string
+	Address  string
+	Username string
+	Password string
+	HostKey  ssh.PublicKey
+
+	listener net.Listener
+	config   *ssh.ServerConfig
+	lock     sync.Mutex
+	conns    []*ssh.ServerConn
+}
+
+func newTestServer(t *testing.T, name string) *testServer {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &testServer{
+		Name:     name,
+		Username: "test",
+		Password: "test-" + name,
+		HostKey:  signer.PublicKey(),
+	}
+	server.config = &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == server.Username && string(password) == server.Password {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	server.config.AddHostKey(signer)
+	server.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Address = server.listener.Addr().String()
+	t.Cleanup(server.Close)
+	go server.serve()
+	return server
+}
+
+// Config 连接配置
+func (this_ *testServer) Config() *Config {
+	return &Config{
+		Type:     "tcp",
+		Address:  this_.Address,
+		Username: this_.Username,
+		Password: this_.Password,
+	}
+}
+
+// SetConfig 修改服务配置，如认证方式，之后的连接生效
+func (this_ *testServer) SetConfig(set func(config *ssh.ServerConfig)) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	set(this_.config)
+}
+
+// ConnCount 当前的连接数量
+func (this_ *testServer) ConnCount() int {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	return len(this_.conns)
+}
+
+func (this_ *testServer) Close() {
+	_ = this_.listener.Close()
+	this_.CloseConns()
+}
+
+// CloseConns 断开当前所有的连接，服务继续接受新的连接
+func (this_ *testServer) CloseConns() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	for _, conn := range this_.conns {
+		_ = conn.Close()
+	}
+}
+
+func (this_ *testServer) serve() {
+	for {
+		netConn, err := this_.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			this_.lock.Lock()
+			config := *this_.config
+			this_.lock.Unlock()
+			conn, channels, requests, err := ssh.NewServerConn(netConn, &config)
+			if err != nil {
+				_ = netConn.Close()
+				return
+			}
+			this_.lock.Lock()
+			this_.conns = append(this_.conns, conn)
+			this_.lock.Unlock()
+			var forwardListeners []net.Listener
+			var forwardLock sync.Mutex
+			go func() {
+				for request := range requests {
+					if request.Type != "tcpip-forward" {
+						if request.WantReply {
+							_ = request.Reply(false, nil)
+						}
+						continue
+					}
+					listener, e := this_.listenForward(conn, request.Payload)
+					if e != nil {
+						_ = request.Reply(false, nil)
+						continue
+					}
+					forwardLock.Lock()
+					forwardListeners = append(forwardListeners, listener)
+					forwardLock.Unlock()
+					port := listener.Addr().(*net.TCPAddr).Port
+					_ = request.Reply(true, ssh.Marshal(struct{ Port uint32 }{uint32(port)}))
+				}
+			}()
+			for newChannel := range channels {
+				go this_.handleChannel(newChannel)
+			}
+			_ = conn.Wait()
+			forwardLock.Lock()
+			for _, listener := range forwardListeners {
+				_ = listener.Close()
+			}
+			forwardLock.Unlock()
+			this_.lock.Lock()
+			for i, one := range this_.conns {
+				if one == conn {
+					this_.conns = append(this_.conns[:i], this_.conns[i+1:]...)
+					break
+				}
+			}
+			this_.lock.Unlock()
+		}()
+	}
+}
+
+func (this_ *testServer) handleChannel(newChannel ssh.NewChannel) {
+	switch newChannel.ChannelType() {
+	case "session":
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = channel.Close() }()
+		for request := range requests {
+			if request.Type != "exec" {
+				_ = request.Reply(false, nil)
+				continue
+			}
+			_ = request.Reply(true, nil)
+			_, _ = channel.Write([]byte(this_.Name))
+			_, _ = channel.SendRequest("exit-status", false, make([]byte, 4))
+			return
+		}
+	case "direct-tcpip":
+		var payload struct {
+			Host       string
+			Port       uint32
+			OriginAddr string
+			OriginPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			return
+		}
+		target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+		if err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			return
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			_ = target.Close()
+			return
+		}
+		go ssh.DiscardRequests(requests)
+		go func() {
+			_, _ = io.Copy(channel, target)
+			_ = channel.CloseWrite()
+		}()
+		_, _ = io.Copy(target, channel)
+		_ = target.Close()
+		_ = channel.Close()
+	default:
+		_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+	}
+}
+
+// listenForward 处理 tcpip-forward 请求，监听后每个连接通过 forwarded-tcpip 通道发给客户端
+func (this_ *testServer) listenForward(conn *ssh.ServerConn, payload []byte) (listener net.Listener, err error) {
+	var request struct {
+		BindAddr string
+		BindPort uint32
+	}
+	if err = ssh.Unmarshal(payload, &request); err != nil {
+		return
+	}
+	listener, err = net.Listen("tcp", net.JoinHostPort(request.BindAddr, strconv.Itoa(int(request.BindPort))))
+	if err != nil {
+		return
+	}
+	go func() {
+		for {
+			netConn, e := listener.Accept()
+			if e != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = netConn.Close() }()
+				origin := netConn.RemoteAddr().(*net.TCPAddr)
+				channel, requests, e := conn.OpenChannel("forwarded-tcpip", ssh.Marshal(struct {
+					Addr       string
+					Port       uint32
+					OriginAddr string
+					OriginPort uint32
+				}{request.BindAddr, uint32(listener.Addr().(*net.TCPAddr).Port), origin.IP.String(), uint32(origin.Port)}))
+				if e != nil {
+					return
+				}
+				go ssh.DiscardRequests(requests)
+				go func() {
+					_, _ = io.Copy(channel, netConn)
+					_ = channel.CloseWrite()
+				}()
+				_, _ = io.Copy(netConn, channel)
+				_ = channel.Close()
+			}()
+		}
+	}()
+	return
+}
+
+// testExec 执行命令返回输出，测试服务返回服务名称
+func testExec(t *testing.T, client *ssh.Client) string {
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = session.Close() }()
+	bs, err := session.Output("name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bs)
+}
@@ -0,0 +1,149 @@
+package system
+
+import (
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
+	"time"
+)
+
+// MonitorCounter 记录上一次采集的磁盘、网卡计数，用于计算速度和增量
+type MonitorCounter struct {
+	lastDiskTime  time.Time
+	lastDiskCache map[string]disk.IOCountersStat
+	lastNetTime   time.Time
+	lastNetCache  map[string]net.IOCountersStat
+}
+
+// getUseSecond 距上次采集的秒数，没有上次采集时为 0
+func getUseSecond(lastTime time.Time, now time.Time) float64 {
+	if lastTime.IsZero() || !now.After(lastTime) {
+		return 0
+	}
+	return now.Sub(lastTime).Seconds()
+}
+
+func getSpeed(increase uint64, useSecond float64) uint64 {
+	return uint64(float64(increase) / useSecond)
+}
+
+// DiskIOCounters 转换磁盘计数，now 为采集时间
+func (this_ *MonitorCounter) DiskIOCounters(diskStat map[string]disk.IOCountersStat, now time.Time) (res []*DiskIOCountersStat) {
+	useSecond := getUseSecond(this_.lastDiskTime, now)
+	for _, one := range diskStat {
+		nInfo := &DiskIOCountersStat{
+			Name:             one.Name,
+			ReadCount:        one.ReadCount,
+			MergedReadCount:  one.MergedReadCount,
+			WriteCount:       one.WriteCount,
+			MergedWriteCount: one.MergedWriteCount,
+			ReadBytes:        one.ReadBytes,
+			WriteBytes:       one.WriteBytes,
+			ReadTime:         one.ReadTime,
+			WriteTime:        one.WriteTime,
+			IopsInProgress:   one.IopsInProgress,
+			IoTime:           one.IoTime,
+			WeightedIO:       one.WeightedIO,
+			SerialNumber:     one.SerialNumber,
+			Label:            one.Label,
+		}
+		find := this_.lastDiskCache[nInfo.Name]
+		if useSecond > 0 && find.ReadBytes > 0 && nInfo.ReadBytes > find.ReadBytes {
+			nInfo.ReadBytesSpeed = getSpeed(nInfo.ReadBytes-find.ReadBytes, useSecond)
+		}
+		if useSecond > 0 && find.WriteBytes > 0 && nInfo.WriteBytes > find.WriteBytes {
+			nInfo.WriteBytesSpeed = getSpeed(nInfo.WriteBytes-find.WriteBytes, useSecond)
+		}
+		if find.ReadCount > 0 && nInfo.ReadCount > find.ReadCount {
+			nInfo.ReadCountIncrease = nInfo.ReadCount - find.ReadCount
+		}
+		if find.WriteCount > 0 && nInfo.WriteCount > find.WriteCount {
+			nInfo.WriteCountIncrease = nInfo.WriteCount - find.WriteCount
+		}
+		res = append(res, nInfo)
+	}
+	this_.lastDiskCache = make(map[string]disk.IOCountersStat)
+	for _, one := range diskStat {
+		this_.lastDiskCache[one.Name] = one
+	}
+	this_.lastDiskTime = now
+	return
+}
+
+// NetIOCounters 转换网卡计数，now 为采集时间
+func (this_ *MonitorCounter) NetIOCounters(netStat []net.IOCountersStat, now time.Time) (res []*NetIOCountersStat) {
+	useSecond := getUseSecond(this_.lastNetTime, now)
+	for _, one := range netStat {
+		nInfo := &NetIOCountersStat{
+			Name:        one.Name,
+			BytesSent:   one.BytesSent,
+			BytesRecv:   one.BytesRecv,
+			PacketsSent: one.PacketsSent,
+			PacketsRecv: one.PacketsRecv,
+			Errin:       one.Errin,
+			Errout:      one.Errout,
+			Dropin:      one.Dropin,
+			Dropout:     one.Dropout,
+			Fifoin:      one.Fifoin,
+			Fifoout:     one.Fifoout,
+		}
+		find := this_.lastNetCache[one.Name]
+		if useSecond > 0 && find.BytesSent > 0 && nInfo.BytesSent > find.BytesSent {
+			nInfo.SpeedSent = getSpeed(nInfo.BytesSent-find.BytesSent, useSecond)
+		}
+		if useSecond > 0 && find.BytesRecv > 0 && nInfo.BytesRecv > find.BytesRecv {
+			nInfo.SpeedRecv = getSpeed(nInfo.BytesRecv-find.BytesRecv, useSecond)
+		}
+		res = append(res, nInfo)
+	}
+	this_.lastNetCache = make(map[string]net.IOCountersStat)
+	for _, one := range netStat {
+		this_.lastNetCache[one.Name] = one
+	}
+	this_.lastNetTime = now
+	return
+}
+
+// NewVirtualMemoryStat 转换内存信息
+func NewVirtualMemoryStat(virtualMemoryStat *mem.VirtualMemoryStat) *VirtualMemoryStat {
+	return &VirtualMemoryStat{
+		Total:          virtualMemoryStat.Total,
+		Available:      virtualMemoryStat.Available,
+		Used:           virtualMemoryStat.Used,
+		UsedPercent:    virtualMemoryStat.UsedPercent,
+		Free:           virtualMemoryStat.Free,
+		Active:         virtualMemoryStat.Active,
+		Inactive:       virtualMemoryStat.Inactive,
+		Wired:          virtualMemoryStat.Wired,
+		Laundry:        virtualMemoryStat.Laundry,
+		Buffers:        virtualMemoryStat.Buffers,
+		Cached:         virtualMemoryStat.Cached,
+		WriteBack:      virtualMemoryStat.WriteBack,
+		Dirty:          virtualMemoryStat.Dirty,
+		WriteBackTmp:   virtualMemoryStat.WriteBackTmp,
+		Shared:         virtualMemoryStat.Shared,
+		Slab:           virtualMemoryStat.Slab,
+		Sreclaimable:   virtualMemoryStat.Sreclaimable,
+		Sunreclaim:     virtualMemoryStat.Sunreclaim,
+		PageTables:     virtualMemoryStat.PageTables,
+		SwapCached:     virtualMemoryStat.SwapCached,
+		CommitLimit:    virtualMemoryStat.CommitLimit,
+		CommittedAS:    virtualMemoryStat.CommittedAS,
+		HighTotal:      virtualMemoryStat.HighTotal,
+		HighFree:       virtualMemoryStat.HighFree,
+		LowTotal:       virtualMemoryStat.LowTotal,
+		LowFree:        virtualMemoryStat.LowFree,
+		SwapTotal:      virtualMemoryStat.SwapTotal,
+		SwapFree:       virtualMemoryStat.SwapFree,
+		Mapped:         virtualMemoryStat.Mapped,
+		VmallocTotal:   virtualMemoryStat.VmallocTotal,
+		VmallocUsed:    virtualMemoryStat.VmallocUsed,
+		VmallocChunk:   virtualMemoryStat.VmallocChunk,
+		HugePagesTotal: virtualMemoryStat.HugePagesTotal,
+		HugePagesFree:  virtualMemoryStat.HugePagesFree,
+		HugePagesRsvd:  virtualMemoryStat.HugePagesRsvd,
+		HugePagesSurp:  virtualMemoryStat.HugePagesSurp,
+		HugePageSize:   virtualMemoryStat.HugePageSize,
+		AnonHugePages:  virtualMemoryStat.AnonHugePages,
+	}
+}
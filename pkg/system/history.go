@@ -0,0 +1,245 @@
+package system
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 监控数据持久化
+//
+// 开启后每次采集的数据按天追加写入目录下的 monitor-20060102.log 文件，每行一条 JSON，
+// 超过保留天数的文件在写入时删除。按时间范围查询时从文件读取，重启后历史数据仍可查询。
+
+// monitorHistory 监控数据持久化目录
+type monitorHistory struct {
+	dir      string
+	saveDays int
+	lastDay  string
+	lock     sync.Mutex
+}
+
+const (
+	historyFilePrefix = "monitor-"
+	historyFileSuffix = ".log"
+	historyDayLayout  = "20060102"
+)
+
+// SetHistory 设置本机监控数据持久化目录和保留天数，目录为空时只保存在内存中，保留天数小于等于 0 时永久保留
+func SetHistory(dir string, saveDays int) (err error) {
+	return defaultStore.SetHistory(dir, saveDays)
+}
+
+// IsHistoryEnabled 本机监控数据是否开启了持久化
+func IsHistoryEnabled() bool {
+	return defaultStore.IsHistoryEnabled()
+}
+
+func appendHistory(monitorData *MonitorData) {
+	defaultStore.history.append(monitorData)
+}
+
+func cleanHistory() {
+	defaultStore.history.clean()
+}
+
+func (this_ *monitorHistory) set(dir string, saveDays int) (err error) {
+	if dir != "" {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return
+		}
+	}
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.dir = dir
+	this_.saveDays = saveDays
+	this_.lastDay = ""
+	return
+}
+
+func (this_ *monitorHistory) isEnabled() bool {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	return this_.dir != ""
+}
+
+func getHistoryFileName(day string) string {
+	return historyFilePrefix + day + historyFileSuffix
+}
+
+func getHistoryFileDay(name string) (day string, ok bool) {
+	if !strings.HasPrefix(name, historyFilePrefix) || !strings.HasSuffix(name, historyFileSuffix) {
+		return
+	}
+	day = strings.TrimSuffix(strings.TrimPrefix(name, historyFilePrefix), historyFileSuffix)
+	if _, err := time.ParseInLocation(historyDayLayout, day, time.Local); err != nil {
+		return
+	}
+	ok = true
+	return
+}
+
+// getHistoryDayList 目录下已有数据的日期，按时间排序
+func getHistoryDayList(dir string) (dayList []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if day, ok := getHistoryFileDay(entry.Name()); ok {
+			dayList = append(dayList, day)
+		}
+	}
+	sort.Strings(dayList)
+	return
+}
+
+func (this_ *monitorHistory) append(monitorData *MonitorData) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.dir == "" {
+		return
+	}
+	day := time.UnixMilli(monitorData.StartTime).Format(historyDayLayout)
+	if day != this_.lastDay {
+		this_.lastDay = day
+		this_.cleanExpired(day)
+	}
+
+	bs, err := json.Marshal(monitorData)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(this_.dir, getHistoryFileName(day)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		util.Logger.Error("system monitor history open error", zap.Error(err))
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err = f.Write(append(bs, '\n')); err != nil {
+		util.Logger.Error("system monitor history write error", zap.Error(err))
+	}
+}
+
+// cleanExpired 删除超过保留天数的文件，需要持有锁
+func (this_ *monitorHistory) cleanExpired(today string) {
+	if this_.saveDays <= 0 {
+		return
+	}
+	todayTime, err := time.ParseInLocation(historyDayLayout, today, time.Local)
+	if err != nil {
+		return
+	}
+	minDay := todayTime.AddDate(0, 0, -this_.saveDays+1).Format(historyDayLayout)
+	dayList, _ := getHistoryDayList(this_.dir)
+	for _, day := range dayList {
+		if day >= minDay {
+			break
+		}
+		_ = os.Remove(filepath.Join(this_.dir, getHistoryFileName(day)))
+	}
+}
+
+func (this_ *monitorHistory) clean() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.dir == "" {
+		return
+	}
+	dayList, _ := getHistoryDayList(this_.dir)
+	for _, day := range dayList {
+		_ = os.Remove(filepath.Join(this_.dir, getHistoryFileName(day)))
+	}
+}
+
+// query 从文件中查询时间范围内的数据
+func (this_ *monitorHistory) query(startTime int64, endTime int64, size int, response *QueryResponse) (err error) {
+	this_.lock.Lock()
+	dir := this_.dir
+	this_.lock.Unlock()
+
+	dayList, err := getHistoryDayList(dir)
+	if err != nil {
+		return
+	}
+	var startDay, endDay string
+	if startTime > 0 {
+		startDay = time.UnixMilli(startTime).Format(historyDayLayout)
+	}
+	if endTime > 0 {
+		endDay = time.UnixMilli(endTime).Format(historyDayLayout)
+	}
+	for _, day := range dayList {
+		if response.Size >= size {
+			return
+		}
+		if (startDay != "" && day < startDay) || (endDay != "" && day > endDay) {
+			continue
+		}
+		err = readHistoryFile(filepath.Join(dir, getHistoryFileName(day)), func(monitorData *MonitorData) bool {
+			if !inTimeRange(monitorData, startTime, endTime) {
+				return true
+			}
+			response.MonitorDataList = append(response.MonitorDataList, monitorData)
+			response.LastTimestamp = monitorData.StartTime
+			response.Size++
+			return response.Size < size
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func readHistoryFile(path string, on func(monitorData *MonitorData) bool) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		monitorData := &MonitorData{}
+		// 进程退出时可能写入不完整的行，跳过
+		if json.Unmarshal(scanner.Bytes(), monitorData) != nil {
+			continue
+		}
+		if !on(monitorData) {
+			return
+		}
+	}
+	err = scanner.Err()
+	return
+}
+
+// inTimeRange 开始时间之后（不含）、结束时间之前（含），为 0 时不限制
+func inTimeRange(monitorData *MonitorData, startTime int64, endTime int64) bool {
+	if startTime > 0 && monitorData.StartTime <= startTime {
+		return false
+	}
+	if endTime > 0 && monitorData.StartTime > endTime {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	dir := t.TempDir()
+	if err := SetHistory(dir, 2); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = SetHistory("", 0) }()
+
+	today := time.Now().Truncate(time.Hour)
+	var timeList []int64
+	for _, day := range []int{-3, -1, 0} {
+		for i := 0; i < 3; i++ {
+			timeList = append(timeList, today.AddDate(0, 0, day).Add(time.Duration(i)*time.Minute).UnixMilli())
+		}
+	}
+	for _, one := range timeList {
+		appendHistory(&MonitorData{StartTime: one, EndTime: one, CpuPercents: []float64{1}})
+	}
+
+	// 保留 2 天，3 天前的文件已删除
+	if _, err := os.Stat(filepath.Join(dir, getHistoryFileName(today.AddDate(0, 0, -3).Format(historyDayLayout)))); !os.IsNotExist(err) {
+		t.Fatal("expired history file should be removed:", err)
+	}
+
+	response := QueryMonitorData(&QueryRequest{Timestamp: timeList[3], EndTimestamp: timeList[7], Size: 100})
+	if response.Size != 4 || response.MonitorDataList[0].StartTime != timeList[4] || response.LastTimestamp != timeList[7] {
+		t.Fatal("query range error:", response.Size, response.LastTimestamp)
+	}
+
+	// 按 LastTimestamp 分页
+	response = QueryMonitorData(&QueryRequest{EndTimestamp: timeList[8], Size: 2})
+	if response.Size != 2 || response.LastTimestamp != timeList[4] {
+		t.Fatal("query page error:", response.Size, response.LastTimestamp)
+	}
+	response = QueryMonitorData(&QueryRequest{Timestamp: response.LastTimestamp, EndTimestamp: timeList[8], Size: 100})
+	if response.Size != 4 || response.LastTimestamp != timeList[8] {
+		t.Fatal("query next page error:", response.Size, response.LastTimestamp)
+	}
+
+	// 不完整的行跳过
+	f, err := os.OpenFile(filepath.Join(dir, getHistoryFileName(today.Format(historyDayLayout))), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"startTime":`)
+	_ = f.Close()
+	response = QueryMonitorData(&QueryRequest{Timestamp: timeList[5], EndTimestamp: time.Now().Add(time.Hour).UnixMilli()})
+	if response.Size != 3 {
+		t.Fatal("query with broken line error:", response.Size)
+	}
+
+	cleanHistory()
+	if dayList, _ := getHistoryDayList(dir); len(dayList) != 0 {
+		t.Fatal("history should be cleaned:", dayList)
+	}
+}
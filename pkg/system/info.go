@@ -14,11 +14,9 @@ import (
 )
 
 var (
-	monitorDataList     []*MonitorData
-	CollectMaxSize      = 3600
-	collectLock         = &sync.Mutex{}
-	monitorDataListLock = &sync.Mutex{}
-	monitorDataTask     *task.CronTask
+	CollectMaxSize  = 3600
+	collectLock     = &sync.Mutex{}
+	monitorDataTask *task.CronTask
 )
 
 //func StopCollectMonitorData() {
@@ -45,13 +43,7 @@ func StartCollectMonitorData() {
 					return
 				}
 
-				monitorDataListLock.Lock()
-				defer monitorDataListLock.Unlock()
-
-				if len(monitorDataList) >= CollectMaxSize {
-					monitorDataList = monitorDataList[len(monitorDataList)-CollectMaxSize+1:]
-				}
-				monitorDataList = append(monitorDataList, monitorData)
+				defaultStore.Append(monitorData)
 			},
 		},
 	}
@@ -63,41 +55,13 @@ const (
 	monitorDataTaskKey = "system-monitor-data-task-key"
 )
 
+// QueryMonitorData 查询 Timestamp 之后的数据，设置 EndTimestamp 且开启持久化时从文件查询
 func QueryMonitorData(request *QueryRequest) (response *QueryResponse) {
-	response = &QueryResponse{}
-	var startTimestamp int64
-	var size int
-	if request != nil {
-		startTimestamp = request.Timestamp
-		size = request.Size
-	}
-	if size <= 0 {
-		size = 100
-	}
-	//util.Logger.Info("QueryMonitorData start", zap.Any("request", request))
-	var list = monitorDataList
-	var appendSize = 0
-	for _, one := range list {
-		if appendSize >= size {
-			break
-		}
-		if one.StartTime <= startTimestamp {
-			continue
-		}
-		response.MonitorDataList = append(response.MonitorDataList, one)
-		response.LastTimestamp = one.StartTime
-		appendSize++
-	}
-
-	//util.Logger.Info("QueryMonitorData end", zap.Any("response", response))
-	return
+	return defaultStore.Query(request)
 }
 
 func CleanMonitorData() {
-	monitorDataListLock.Lock()
-	defer monitorDataListLock.Unlock()
-
-	monitorDataList = []*MonitorData{}
+	defaultStore.Clean()
 }
 
 func GetInfo() (info *Info) {
@@ -147,46 +111,7 @@ func GetInfo() (info *Info) {
 
 	virtualMemoryStat, _ := mem.VirtualMemory()
 	if virtualMemoryStat != nil {
-		info.Memory = &VirtualMemoryStat{
-			Total:          virtualMemoryStat.Total,
-			Available:      virtualMemoryStat.Available,
-			Used:           virtualMemoryStat.Used,
-			UsedPercent:    virtualMemoryStat.UsedPercent,
-			Free:           virtualMemoryStat.Free,
-			Active:         virtualMemoryStat.Active,
-			Inactive:       virtualMemoryStat.Inactive,
-			Wired:          virtualMemoryStat.Wired,
-			Laundry:        virtualMemoryStat.Laundry,
-			Buffers:        virtualMemoryStat.Buffers,
-			Cached:         virtualMemoryStat.Cached,
-			WriteBack:      virtualMemoryStat.WriteBack,
-			Dirty:          virtualMemoryStat.Dirty,
-			WriteBackTmp:   virtualMemoryStat.WriteBackTmp,
-			Shared:         virtualMemoryStat.Shared,
-			Slab:           virtualMemoryStat.Slab,
-			Sreclaimable:   virtualMemoryStat.Sreclaimable,
-			Sunreclaim:     virtualMemoryStat.Sunreclaim,
-			PageTables:     virtualMemoryStat.PageTables,
-			SwapCached:     virtualMemoryStat.SwapCached,
-			CommitLimit:    virtualMemoryStat.CommitLimit,
-			CommittedAS:    virtualMemoryStat.CommittedAS,
-			HighTotal:      virtualMemoryStat.HighTotal,
-			HighFree:       virtualMemoryStat.HighFree,
-			LowTotal:       virtualMemoryStat.LowTotal,
-			LowFree:        virtualMemoryStat.LowFree,
-			SwapTotal:      virtualMemoryStat.SwapTotal,
-			SwapFree:       virtualMemoryStat.SwapFree,
-			Mapped:         virtualMemoryStat.Mapped,
-			VmallocTotal:   virtualMemoryStat.VmallocTotal,
-			VmallocUsed:    virtualMemoryStat.VmallocUsed,
-			VmallocChunk:   virtualMemoryStat.VmallocChunk,
-			HugePagesTotal: virtualMemoryStat.HugePagesTotal,
-			HugePagesFree:  virtualMemoryStat.HugePagesFree,
-			HugePagesRsvd:  virtualMemoryStat.HugePagesRsvd,
-			HugePagesSurp:  virtualMemoryStat.HugePagesSurp,
-			HugePageSize:   virtualMemoryStat.HugePageSize,
-			AnonHugePages:  virtualMemoryStat.AnonHugePages,
-		}
+		info.Memory = NewVirtualMemoryStat(virtualMemoryStat)
 	}
 
 	ps, _ := disk.Partitions(true)
@@ -215,14 +140,9 @@ func GetInfo() (info *Info) {
 }
 
 func GetCacheOrNew() (monitorData *MonitorData, err error) {
-	monitorDataListLock.Lock()
-	size := len(monitorDataList)
-	if size > 0 {
-		monitorData = monitorDataList[size-1]
-		monitorDataListLock.Unlock()
+	if monitorData = defaultStore.GetLast(); monitorData != nil {
 		return
 	}
-	monitorDataListLock.Unlock()
 	return GetMonitorData()
 }
 
@@ -243,130 +163,15 @@ func GetMonitorData() (monitorData *MonitorData, err error) {
 	if err != nil {
 		return
 	}
-	monitorData.VirtualMemoryStat = &VirtualMemoryStat{
-		Total:          virtualMemoryStat.Total,
-		Available:      virtualMemoryStat.Available,
-		Used:           virtualMemoryStat.Used,
-		UsedPercent:    virtualMemoryStat.UsedPercent,
-		Free:           virtualMemoryStat.Free,
-		Active:         virtualMemoryStat.Active,
-		Inactive:       virtualMemoryStat.Inactive,
-		Wired:          virtualMemoryStat.Wired,
-		Laundry:        virtualMemoryStat.Laundry,
-		Buffers:        virtualMemoryStat.Buffers,
-		Cached:         virtualMemoryStat.Cached,
-		WriteBack:      virtualMemoryStat.WriteBack,
-		Dirty:          virtualMemoryStat.Dirty,
-		WriteBackTmp:   virtualMemoryStat.WriteBackTmp,
-		Shared:         virtualMemoryStat.Shared,
-		Slab:           virtualMemoryStat.Slab,
-		Sreclaimable:   virtualMemoryStat.Sreclaimable,
-		Sunreclaim:     virtualMemoryStat.Sunreclaim,
-		PageTables:     virtualMemoryStat.PageTables,
-		SwapCached:     virtualMemoryStat.SwapCached,
-		CommitLimit:    virtualMemoryStat.CommitLimit,
-		CommittedAS:    virtualMemoryStat.CommittedAS,
-		HighTotal:      virtualMemoryStat.HighTotal,
-		HighFree:       virtualMemoryStat.HighFree,
-		LowTotal:       virtualMemoryStat.LowTotal,
-		LowFree:        virtualMemoryStat.LowFree,
-		SwapTotal:      virtualMemoryStat.SwapTotal,
-		SwapFree:       virtualMemoryStat.SwapFree,
-		Mapped:         virtualMemoryStat.Mapped,
-		VmallocTotal:   virtualMemoryStat.VmallocTotal,
-		VmallocUsed:    virtualMemoryStat.VmallocUsed,
-		VmallocChunk:   virtualMemoryStat.VmallocChunk,
-		HugePagesTotal: virtualMemoryStat.HugePagesTotal,
-		HugePagesFree:  virtualMemoryStat.HugePagesFree,
-		HugePagesRsvd:  virtualMemoryStat.HugePagesRsvd,
-		HugePagesSurp:  virtualMemoryStat.HugePagesSurp,
-		HugePageSize:   virtualMemoryStat.HugePageSize,
-		AnonHugePages:  virtualMemoryStat.AnonHugePages,
-	}
+	monitorData.VirtualMemoryStat = NewVirtualMemoryStat(virtualMemoryStat)
 
 	diskStat, _ := disk.IOCounters("/")
-	diskStatCache := lastDiskIOCountersStatCache
-	newSce := uint64(time.Now().Unix())
-	if diskStat != nil {
-		for _, one := range diskStat {
-			nInfo := &DiskIOCountersStat{
-				Name:             one.Name,
-				ReadCount:        one.ReadCount,
-				MergedReadCount:  one.MergedReadCount,
-				WriteCount:       one.WriteCount,
-				MergedWriteCount: one.MergedWriteCount,
-				ReadBytes:        one.ReadBytes,
-				WriteBytes:       one.WriteBytes,
-				ReadTime:         one.ReadTime,
-				WriteTime:        one.WriteTime,
-				IopsInProgress:   one.IopsInProgress,
-				IoTime:           one.IoTime,
-				WeightedIO:       one.WeightedIO,
-				SerialNumber:     one.SerialNumber,
-				Label:            one.Label,
-			}
-			find := diskStatCache[nInfo.Name]
-			if find.ReadBytes > 0 && nInfo.ReadBytes > find.ReadBytes {
-				nInfo.ReadBytesSpeed = (nInfo.ReadBytes - find.ReadBytes) / (newSce - lastDiskIOCounters)
-			}
-			if find.WriteBytes > 0 && nInfo.WriteBytes > find.WriteBytes {
-				nInfo.WriteBytesSpeed = (nInfo.WriteBytes - find.WriteBytes) / (newSce - lastDiskIOCounters)
-			}
-			if find.ReadCount > 0 && nInfo.ReadCount > find.ReadCount {
-				nInfo.ReadCountIncrease = nInfo.ReadCount - find.ReadCount
-			}
-			if find.WriteCount > 0 && nInfo.WriteCount > find.WriteCount {
-				nInfo.WriteCountIncrease = nInfo.WriteCount - find.WriteCount
-			}
-			monitorData.DiskIOCountersStats = append(monitorData.DiskIOCountersStats, nInfo)
-		}
-	}
-	diskStatCache = make(map[string]disk.IOCountersStat)
-	if diskStat != nil {
-		for _, one := range diskStat {
-			diskStatCache[one.Name] = one
-		}
-	}
-	lastDiskIOCounters = newSce
-	lastDiskIOCountersStatCache = diskStatCache
+	monitorData.DiskIOCountersStats = localCounter.DiskIOCounters(diskStat, time.Now())
 
-	newSce = uint64(time.Now().Unix())
 	netIOCountersStats, _ := net.IOCounters(true)
-	netStatCache := lastNetIOCountersStatCache
-	for _, one := range netIOCountersStats {
-		nInfo := &NetIOCountersStat{
-			Name:        one.Name,
-			BytesSent:   one.BytesSent,
-			BytesRecv:   one.BytesRecv,
-			PacketsSent: one.PacketsSent,
-			PacketsRecv: one.PacketsRecv,
-			Errin:       one.Errin,
-			Errout:      one.Errout,
-			Dropin:      one.Dropin,
-			Dropout:     one.Dropout,
-			Fifoin:      one.Fifoin,
-			Fifoout:     one.Fifoout,
-		}
-		find := netStatCache[one.Name]
-		if find.BytesSent > 0 && nInfo.BytesSent > find.BytesSent {
-			nInfo.SpeedSent = (nInfo.BytesSent - find.BytesSent) / (newSce - lastNetIOCounters)
-		}
-		if find.BytesRecv > 0 && nInfo.BytesRecv > find.BytesRecv {
-			nInfo.SpeedRecv = (nInfo.BytesRecv - find.BytesRecv) / (newSce - lastNetIOCounters)
-		}
-		monitorData.NetIOCountersStats = append(monitorData.NetIOCountersStats, nInfo)
-	}
-	netStatCache = make(map[string]net.IOCountersStat)
-	for _, one := range netIOCountersStats {
-		netStatCache[one.Name] = one
-	}
-	lastNetIOCounters = newSce
-	lastNetIOCountersStatCache = netStatCache
+	monitorData.NetIOCountersStats = localCounter.NetIOCounters(netIOCountersStats, time.Now())
 	return
 }
 
-var lastDiskIOCounters uint64
-var lastDiskIOCountersStatCache = make(map[string]disk.IOCountersStat)
-
-var lastNetIOCounters uint64
-var lastNetIOCountersStatCache = make(map[string]net.IOCountersStat)
+// localCounter 本机磁盘、网卡计数
+var localCounter = &MonitorCounter{}
@@ -0,0 +1,194 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ProcessCpuSampleTime 计算进程 CPU 使用率的采样间隔
+	ProcessCpuSampleTime = 500 * time.Millisecond
+
+	// ProcessSignalList 支持的信号
+	ProcessSignalList = []string{"TERM", "KILL", "INT", "HUP", "QUIT", "STOP", "CONT", "USR1", "USR2"}
+
+	ProcessSortByCpu    = "cpu"
+	ProcessSortByMemory = "memory"
+	ProcessSortByPid    = "pid"
+)
+
+// FormatProcessSignal 校验信号名称，支持 SIGTERM、term 等写法，为空时为 TERM
+func FormatProcessSignal(signal string) (res string, err error) {
+	res = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(signal)), "SIG")
+	if res == "" {
+		res = "TERM"
+	}
+	for _, one := range ProcessSignalList {
+		if one == res {
+			return
+		}
+	}
+	err = errors.New("不支持的信号[" + signal + "]")
+	return
+}
+
+// CheckProcessSignalRequest 校验信号请求，不允许向当前进程和进程组发送信号
+func CheckProcessSignalRequest(request *ProcessSignalRequest) (err error) {
+	if request == nil || request.Pid <= 0 {
+		err = errors.New("进程ID错误")
+		return
+	}
+	if int(request.Pid) == os.Getpid() {
+		err = errors.New("不能向当前服务进程发送信号")
+		return
+	}
+	request.Signal, err = FormatProcessSignal(request.Signal)
+	return
+}
+
+// FilterProcessList 按关键字过滤、排序并截取
+func FilterProcessList(list []*ProcessStat, request *ProcessQueryRequest) (response *ProcessQueryResponse) {
+	response = &ProcessQueryResponse{}
+	var keyword, sortBy string
+	var size int
+	if request != nil {
+		keyword = strings.ToLower(request.Keyword)
+		sortBy = request.SortBy
+		size = request.Size
+	}
+	if size <= 0 {
+		size = 100
+	}
+	for _, one := range list {
+		if keyword != "" && !strings.Contains(strings.ToLower(one.Name), keyword) &&
+			!strings.Contains(strings.ToLower(one.Cmdline), keyword) && strconv.Itoa(int(one.Pid)) != keyword {
+			continue
+		}
+		response.ProcessList = append(response.ProcessList, one)
+	}
+	response.Total = len(response.ProcessList)
+	sort.SliceStable(response.ProcessList, func(i, j int) bool {
+		a, b := response.ProcessList[i], response.ProcessList[j]
+		switch sortBy {
+		case ProcessSortByMemory:
+			return a.Rss > b.Rss
+		case ProcessSortByPid:
+			return a.Pid < b.Pid
+		}
+		return a.CpuPercent > b.CpuPercent
+	})
+	if len(response.ProcessList) > size {
+		response.ProcessList = response.ProcessList[:size]
+	}
+	return
+}
+
+// getListenPortCache 进程监听的端口，tcp 为 LISTEN 状态，udp 为未连接的端口
+func getListenPortCache() (cache map[int32][]string) {
+	cache = make(map[int32][]string)
+	connList, err := net.Connections("inet")
+	if err != nil {
+		return
+	}
+	for _, conn := range connList {
+		if conn.Pid == 0 || conn.Laddr.Port == 0 {
+			continue
+		}
+		var protocol string
+		switch conn.Type {
+		case 1: // SOCK_STREAM
+			if conn.Status != "LISTEN" {
+				continue
+			}
+			protocol = "tcp"
+		case 2: // SOCK_DGRAM
+			if conn.Raddr.Port != 0 {
+				continue
+			}
+			protocol = "udp"
+		default:
+			continue
+		}
+		cache[conn.Pid] = append(cache[conn.Pid], fmt.Sprintf("%s:%s:%d", protocol, conn.Laddr.IP, conn.Laddr.Port))
+	}
+	return
+}
+
+// QueryProcess 本机进程列表，CPU 使用率为 ProcessCpuSampleTime 内的使用率
+func QueryProcess(request *ProcessQueryRequest) (response *ProcessQueryResponse, err error) {
+	processList, err := process.Processes()
+	if err != nil {
+		return
+	}
+	var totalMemory uint64
+	if memory, _ := mem.VirtualMemory(); memory != nil {
+		totalMemory = memory.Total
+	}
+
+	startTimes := make(map[int32]float64)
+	for _, p := range processList {
+		if times, e := p.Times(); e == nil {
+			startTimes[p.Pid] = times.User + times.System
+		}
+	}
+	startTime := time.Now()
+	time.Sleep(ProcessCpuSampleTime)
+	useTime := time.Since(startTime).Seconds()
+
+	portCache := getListenPortCache()
+	var list []*ProcessStat
+	for _, p := range processList {
+		// 进程可能已退出
+		name, e := p.Name()
+		if e != nil {
+			continue
+		}
+		one := &ProcessStat{
+			Pid:      p.Pid,
+			Name:     name,
+			PortList: portCache[p.Pid],
+		}
+		one.Ppid, _ = p.Ppid()
+		one.User, _ = p.Username()
+		one.Cmdline, _ = p.Cmdline()
+		if status, _ := p.Status(); len(status) > 0 {
+			one.Status = status[0]
+		}
+		one.CreateTime, _ = p.CreateTime()
+		if memory, _ := p.MemoryInfo(); memory != nil {
+			one.Rss = memory.RSS
+			if totalMemory > 0 {
+				one.MemoryPercent = float64(memory.RSS) / float64(totalMemory) * 100
+			}
+		}
+		if startCpu, ok := startTimes[p.Pid]; ok {
+			if times, e := p.Times(); e == nil && useTime > 0 {
+				one.CpuPercent = (times.User + times.System - startCpu) / useTime * 100
+			}
+		}
+		list = append(list, one)
+	}
+	response = FilterProcessList(list, request)
+	return
+}
+
+// SignalProcess 向本机进程发送信号
+func SignalProcess(request *ProcessSignalRequest) (err error) {
+	if err = CheckProcessSignalRequest(request); err != nil {
+		return
+	}
+	p, err := process.NewProcess(request.Pid)
+	if err != nil {
+		return
+	}
+	err = sendProcessSignal(p, request.Signal)
+	return
+}
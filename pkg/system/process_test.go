@@ -0,0 +1,81 @@
+package system
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestFilterProcessList(t *testing.T) {
+	list := []*ProcessStat{
+		{Pid: 1, Name: "init", CpuPercent: 0.1, Rss: 300},
+		{Pid: 20, Name: "nginx", Cmdline: "nginx: master process", CpuPercent: 5, Rss: 100},
+		{Pid: 30, Name: "java", Cmdline: "java -jar app.jar", CpuPercent: 2, Rss: 900},
+	}
+	response := FilterProcessList(list, nil)
+	if response.Total != 3 || response.ProcessList[0].Pid != 20 {
+		t.Fatal("default sort by cpu error")
+	}
+	response = FilterProcessList(list, &ProcessQueryRequest{SortBy: ProcessSortByMemory, Size: 2})
+	if response.Total != 3 || len(response.ProcessList) != 2 || response.ProcessList[0].Pid != 30 {
+		t.Fatal("sort by memory error")
+	}
+	response = FilterProcessList(list, &ProcessQueryRequest{Keyword: "APP.JAR"})
+	if response.Total != 1 || response.ProcessList[0].Pid != 30 {
+		t.Fatal("keyword error")
+	}
+	response = FilterProcessList(list, &ProcessQueryRequest{Keyword: "1"})
+	if response.Total != 1 || response.ProcessList[0].Pid != 1 {
+		t.Fatal("pid keyword error")
+	}
+}
+
+func TestProcessSignalCheck(t *testing.T) {
+	for signal, want := range map[string]string{"": "TERM", "sigkill": "KILL", "HUP": "HUP"} {
+		request := &ProcessSignalRequest{Pid: 1, Signal: signal}
+		if err := CheckProcessSignalRequest(request); err != nil || request.Signal != want {
+			t.Fatal("signal format error:", signal, request.Signal, err)
+		}
+	}
+	if err := CheckProcessSignalRequest(&ProcessSignalRequest{Pid: 1, Signal: "SEGV"}); err == nil {
+		t.Fatal("unsupported signal should be error")
+	}
+	if err := CheckProcessSignalRequest(&ProcessSignalRequest{Pid: int32(os.Getpid())}); err == nil {
+		t.Fatal("signal self should be error")
+	}
+	if err := CheckProcessSignalRequest(&ProcessSignalRequest{Pid: 0}); err == nil {
+		t.Fatal("pid 0 should be error")
+	}
+}
+
+func TestQueryAndSignalProcess(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skip("sleep not available:", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	response, err := QueryProcess(&ProcessQueryRequest{Keyword: "sleep 30", Size: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, one := range response.ProcessList {
+		if one.Pid == int32(cmd.Process.Pid) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("process not found")
+	}
+	if err = SignalProcess(&ProcessSignalRequest{Pid: int32(cmd.Process.Pid), Signal: "KILL"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("process not killed")
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package system
+
+import (
+	"github.com/shirou/gopsutil/v3/process"
+	"syscall"
+)
+
+var processSignalCache = map[string]syscall.Signal{
+	"TERM": syscall.SIGTERM,
+	"KILL": syscall.SIGKILL,
+	"INT":  syscall.SIGINT,
+	"HUP":  syscall.SIGHUP,
+	"QUIT": syscall.SIGQUIT,
+	"STOP": syscall.SIGSTOP,
+	"CONT": syscall.SIGCONT,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+func sendProcessSignal(p *process.Process, signal string) error {
+	return p.SendSignal(processSignalCache[signal])
+}
@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package system
+
+import (
+	"errors"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// sendProcessSignal Windows 只支持结束进程
+func sendProcessSignal(p *process.Process, signal string) error {
+	switch signal {
+	case "TERM", "KILL":
+		return p.Kill()
+	}
+	return errors.New("Windows 不支持信号[" + signal + "]")
+}
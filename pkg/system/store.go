@@ -0,0 +1,110 @@
+package system
+
+import (
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"sync"
+)
+
+// defaultStore 本机采集的监控数据
+var defaultStore = NewMonitorStore()
+
+// MonitorStore 监控数据存储，内存中保留最近的数据，设置持久化目录后同时按天写入文件
+type MonitorStore struct {
+	MaxSize  int // 内存中保留的数量，为 0 时使用 CollectMaxSize
+	list     []*MonitorData
+	listLock sync.Mutex
+	history  *monitorHistory
+}
+
+func NewMonitorStore() *MonitorStore {
+	return &MonitorStore{
+		history: &monitorHistory{},
+	}
+}
+
+// SetHistory 设置持久化目录和保留天数，目录为空时只保存在内存中，保留天数小于等于 0 时永久保留
+func (this_ *MonitorStore) SetHistory(dir string, saveDays int) (err error) {
+	return this_.history.set(dir, saveDays)
+}
+
+// IsHistoryEnabled 是否开启了持久化
+func (this_ *MonitorStore) IsHistoryEnabled() bool {
+	return this_.history.isEnabled()
+}
+
+// Append 追加一条数据，超过保留数量时删除最早的数据
+func (this_ *MonitorStore) Append(monitorData *MonitorData) {
+	this_.history.append(monitorData)
+
+	maxSize := this_.MaxSize
+	if maxSize <= 0 {
+		maxSize = CollectMaxSize
+	}
+
+	this_.listLock.Lock()
+	defer this_.listLock.Unlock()
+
+	if len(this_.list) >= maxSize {
+		this_.list = this_.list[len(this_.list)-maxSize+1:]
+	}
+	this_.list = append(this_.list, monitorData)
+}
+
+// Query 查询 Timestamp 之后的数据，设置 EndTimestamp 且开启持久化时从文件查询
+func (this_ *MonitorStore) Query(request *QueryRequest) (response *QueryResponse) {
+	response = &QueryResponse{}
+	var startTimestamp int64
+	var endTimestamp int64
+	var size int
+	if request != nil {
+		startTimestamp = request.Timestamp
+		endTimestamp = request.EndTimestamp
+		size = request.Size
+	}
+	if size <= 0 {
+		size = 100
+	}
+	if endTimestamp > 0 && this_.IsHistoryEnabled() {
+		err := this_.history.query(startTimestamp, endTimestamp, size, response)
+		if err != nil {
+			util.Logger.Error("system monitor history query error", zap.Error(err))
+		}
+		return
+	}
+	this_.listLock.Lock()
+	var list = this_.list
+	this_.listLock.Unlock()
+	for _, one := range list {
+		if response.Size >= size {
+			break
+		}
+		if !inTimeRange(one, startTimestamp, endTimestamp) {
+			continue
+		}
+		response.MonitorDataList = append(response.MonitorDataList, one)
+		response.LastTimestamp = one.StartTime
+		response.Size++
+	}
+	return
+}
+
+// GetLast 最近一条数据
+func (this_ *MonitorStore) GetLast() (monitorData *MonitorData) {
+	this_.listLock.Lock()
+	defer this_.listLock.Unlock()
+
+	if size := len(this_.list); size > 0 {
+		monitorData = this_.list[size-1]
+	}
+	return
+}
+
+// Clean 清理内存和文件中的数据
+func (this_ *MonitorStore) Clean() {
+	this_.listLock.Lock()
+	this_.list = []*MonitorData{}
+	this_.listLock.Unlock()
+
+	this_.history.clean()
+}
@@ -0,0 +1,36 @@
+package system
+
+import "testing"
+
+func TestMonitorStore(t *testing.T) {
+	store := NewMonitorStore()
+	store.MaxSize = 3
+	if store.GetLast() != nil || store.IsHistoryEnabled() {
+		t.Fatal("new store should be empty")
+	}
+	for i := 1; i <= 5; i++ {
+		store.Append(&MonitorData{StartTime: int64(i)})
+	}
+	response := store.Query(nil)
+	if response.Size != 3 || response.MonitorDataList[0].StartTime != 3 || store.GetLast().StartTime != 5 {
+		t.Fatal("store max size error:", response.Size)
+	}
+	response = store.Query(&QueryRequest{Timestamp: 3, Size: 1})
+	if response.Size != 1 || response.LastTimestamp != 4 {
+		t.Fatal("store query error:", response.Size, response.LastTimestamp)
+	}
+
+	dir := t.TempDir()
+	if err := store.SetHistory(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	store.Append(&MonitorData{StartTime: 6})
+	response = store.Query(&QueryRequest{EndTimestamp: 10})
+	if response.Size != 1 || response.LastTimestamp != 6 {
+		t.Fatal("store history query error:", response.Size)
+	}
+	store.Clean()
+	if store.GetLast() != nil || store.Query(&QueryRequest{EndTimestamp: 10}).Size != 0 {
+		t.Fatal("store should be cleaned")
+	}
+}
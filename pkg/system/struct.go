@@ -140,8 +140,9 @@ type Info struct {
 }
 
 type QueryRequest struct {
-	Timestamp int64 `json:"timestamp,omitempty"`
-	Size      int   `json:"size,omitempty"`
+	Timestamp    int64 `json:"timestamp,omitempty"`    // 查询该时间（毫秒）之后的数据
+	EndTimestamp int64 `json:"endTimestamp,omitempty"` // 查询截止时间（毫秒），开启持久化时从文件查询
+	Size         int   `json:"size,omitempty"`
 }
 
 type QueryResponse struct {
@@ -149,3 +150,33 @@ type QueryResponse struct {
 	MonitorDataList []*MonitorData `json:"monitorDataList,omitempty"`
 	Size            int            `json:"size,omitempty"`
 }
+
+type ProcessStat struct {
+	Pid           int32    `json:"pid"`
+	Ppid          int32    `json:"ppid,omitempty"`
+	Name          string   `json:"name,omitempty"`
+	User          string   `json:"user,omitempty"`
+	Status        string   `json:"status,omitempty"`
+	CpuPercent    float64  `json:"cpuPercent"`
+	Rss           uint64   `json:"rss"`
+	MemoryPercent float64  `json:"memoryPercent"`
+	CreateTime    int64    `json:"createTime,omitempty"` // 启动时间（毫秒）
+	Cmdline       string   `json:"cmdline,omitempty"`
+	PortList      []string `json:"portList,omitempty"` // 监听的端口，如 tcp:0.0.0.0:22
+}
+
+type ProcessQueryRequest struct {
+	Keyword string `json:"keyword,omitempty"` // 匹配进程名称、命令行或进程ID
+	SortBy  string `json:"sortBy,omitempty"`  // cpu、memory、pid，默认 cpu
+	Size    int    `json:"size,omitempty"`    // 默认 100
+}
+
+type ProcessQueryResponse struct {
+	ProcessList []*ProcessStat `json:"processList,omitempty"`
+	Total       int            `json:"total,omitempty"`
+}
+
+type ProcessSignalRequest struct {
+	Pid    int32  `json:"pid,omitempty"`
+	Signal string `json:"signal,omitempty"` // TERM、KILL、INT、HUP 等，默认 TERM
+}
@@ -119,3 +119,9 @@ func (this_ *localService) SystemInfo() (res *system.Info, err error) {
 func (this_ *localService) SystemMonitorData() (res *system.MonitorData, err error) {
 	return system.GetCacheOrNew()
 }
+func (this_ *localService) SystemProcessList(request *system.ProcessQueryRequest) (res *system.ProcessQueryResponse, err error) {
+	return system.QueryProcess(request)
+}
+func (this_ *localService) SystemProcessSignal(request *system.ProcessSignalRequest) (err error) {
+	return system.SignalProcess(request)
+}
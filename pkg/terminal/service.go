@@ -18,4 +18,6 @@ type Service interface {
 
 	SystemInfo() (res *system.Info, err error)
 	SystemMonitorData() (res *system.MonitorData, err error)
+	SystemProcessList(request *system.ProcessQueryRequest) (res *system.ProcessQueryResponse, err error)
+	SystemProcessSignal(request *system.ProcessSignalRequest) (err error)
 }